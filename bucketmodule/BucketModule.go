@@ -71,12 +71,12 @@ func (c *giveCommand) Process(args []string, msg *discordgo.Message, indices []i
 	if len(info.Config.Bucket.Items) >= info.Config.Bucket.MaxItems {
 		dropped := BucketDropRandom(info)
 		info.Config.Bucket.Items[arg] = true
-		info.SaveConfig()
+		info.SaveConfigBy(bot.DiscordUser(msg.Author.ID))
 		return "```\nI dropped " + dropped + " and picked up " + arg + ".```", false, nil
 	}
 
 	info.Config.Bucket.Items[arg] = true
-	info.SaveConfig()
+	info.SaveConfigBy(bot.DiscordUser(msg.Author.ID))
 	return "```\nI picked up " + arg + ".```", false, nil
 }
 func (c *giveCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
@@ -125,7 +125,7 @@ func (c *dropCommand) Process(args []string, msg *discordgo.Message, indices []i
 		return "```\nI don't have " + arg + "!```", false, nil
 	}
 	delete(info.Config.Bucket.Items, arg)
-	info.SaveConfig()
+	info.SaveConfigBy(bot.DiscordUser(msg.Author.ID))
 	return "```\nDropped " + arg + ".```", false, nil
 }
 func (c *dropCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
@@ -75,7 +75,7 @@ func (c *addCounterCommand) Process(args []string, msg *discordgo.Message, indic
 
 	info.Config.Counters.Map[name] = init
 	info.Config.Counters.Descriptions[name] = desc
-	info.SaveConfig()
+	info.SaveConfigBy(bot.DiscordUser(msg.Author.ID))
 	return fmt.Sprintf("```\nAdded the %v counter, starting at %v```", name, init), false, nil
 }
 func (c *addCounterCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
@@ -107,7 +107,7 @@ func (c *removeCounterCommand) Process(args []string, msg *discordgo.Message, in
 	if _, ok := info.Config.Counters.Map[arg]; ok {
 		delete(info.Config.Counters.Map, arg)
 		delete(info.Config.Counters.Descriptions, arg)
-		info.SaveConfig()
+		info.SaveConfigBy(bot.DiscordUser(msg.Author.ID))
 		return "```\nDeleted " + arg + ".```", false, nil
 	}
 	return "```\nThat counter doesn't exist!```", false, nil
@@ -183,7 +183,7 @@ func (c *incrementCommand) Process(args []string, msg *discordgo.Message, indice
 		counter++
 		info.Config.Counters.Map[arg] = counter
 		desc, _ := info.Config.Counters.Descriptions[arg]
-		info.SaveConfig()
+		info.SaveConfigBy(bot.DiscordUser(msg.Author.ID))
 		return resolveDesc(counter, desc), false, nil
 	}
 	return arg + " is not a counter!", false, nil
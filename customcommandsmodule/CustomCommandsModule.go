@@ -0,0 +1,338 @@
+package customcommandsmodule
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	bot "../sweetiebot"
+	"github.com/blackhole12/discordgo"
+)
+
+// maxResponseLength is the longest response a custom command can produce, which is the longest message discord allows
+const maxResponseLength = 2000
+
+var commandNameRegex = regexp.MustCompile("^[a-z0-9_-]{1,32}$")
+var errResponseTooLong = errors.New("the response is longer than 2000 characters")
+
+// CustomCommandsConfig holds the options in the customcommands category of the config
+type CustomCommandsConfig struct {
+	Commands map[string]string `json:"commands"`
+}
+
+var customCommandsSection = &bot.ConfigSection{
+	Name:    "CustomCommands",
+	Version: 2,
+	Default: func() interface{} {
+		return &CustomCommandsConfig{
+			Commands: make(map[string]string),
+		}
+	},
+	Help: map[string]string{
+		"commands": "A map of custom command names to the template they respond with. Use `!addcommand` and `!removecommand` instead of editing this directly, because commands added here only show up after the bot restarts. See `!help addcommand` for how templates work.",
+	},
+	Migrate: func(info *bot.GuildInfo, section interface{}, from int) {
+		if from < 2 { // Version 1 didn't restrict these on servers that were already set up
+			info.RestrictCommand("addcommand")
+			info.RestrictCommand("removecommand")
+		}
+	},
+}
+
+// customConfig returns the customcommands section of the config, or an empty one if the section hasn't been loaded yet
+func customConfig(info *bot.GuildInfo) *CustomCommandsConfig {
+	if c, ok := info.Config.Section("customcommands").(*CustomCommandsConfig); ok {
+		return c
+	}
+	return customCommandsSection.Default().(*CustomCommandsConfig)
+}
+
+// CustomCommandsModule lets moderators create their own commands, which respond with a template
+type CustomCommandsModule struct {
+	guild *bot.GuildInfo
+}
+
+// New CustomCommandsModule
+func New(guild *bot.GuildInfo) *CustomCommandsModule {
+	return &CustomCommandsModule{guild}
+}
+
+// Name of the module
+func (w *CustomCommandsModule) Name() string {
+	return "CustomCommands"
+}
+
+// Commands in the module, including every custom command on the server
+func (w *CustomCommandsModule) Commands() []bot.Command {
+	commands := []bot.Command{
+		&addCommandCommand{w},
+		&removeCommandCommand{},
+	}
+	c := customConfig(w.guild)
+	names := make([]string, 0, len(c.Commands))
+	for name := range c.Commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		commands = append(commands, &customCommand{name})
+	}
+	return commands
+}
+
+// Config section of the module
+func (w *CustomCommandsModule) Config() *bot.ConfigSection {
+	return customCommandsSection
+}
+
+// Description of the module
+func (w *CustomCommandsModule) Description() string {
+	return "Lets moderators add their own commands with `!addcommand`. Custom commands respond with a template that can use the arguments they were given, who ran them, random choices, counters and tags. They can be restricted with `modules.commandroles`, `modules.commandchannels` and `modules.commandlimits` just like any other command."
+}
+
+// commandData is what a template can refer to with a dot, like {{.Author}}
+type commandData struct {
+	Args     []string // Every argument after the command name
+	Text     string   // Everything after the command name, exactly as it was typed
+	Author   string   // Name of whoever ran the command
+	Mention  string   // Ping of whoever ran the command
+	Mentions []string // Names of everyone pinged in the message
+	Channel  string   // The channel the command was run in
+	Server   string   // Name of the server
+}
+
+// limitWriter stops a template once it has written more than a message can hold, so a template can't use up all our
+// memory by nesting itself
+type limitWriter struct {
+	bytes.Buffer
+}
+
+func (w *limitWriter) Write(p []byte) (int, error) {
+	if w.Len()+len(p) > maxResponseLength {
+		return 0, errResponseTooLong
+	}
+	return w.Buffer.Write(p)
+}
+
+// templateFuncs returns the functions a template can call. Errors returned by a function stop the template and are
+// shown to the user.
+func templateFuncs(info *bot.GuildInfo, args []string) template.FuncMap {
+	return template.FuncMap{
+		"arg": func(i int) string {
+			if i < 0 || i >= len(args) {
+				return ""
+			}
+			return args[i]
+		},
+		"choose": func(choices ...string) string {
+			if len(choices) == 0 {
+				return ""
+			}
+			return choices[rand.Intn(len(choices))]
+		},
+		"random": func(min int, max int) int {
+			if max < min {
+				min, max = max, min
+			}
+			return min + rand.Intn(max-min+1)
+		},
+		"counter": func(name string) (int64, error) {
+			info.ConfigLock.RLock()
+			v, ok := info.Config.Counters.Map[name]
+			info.ConfigLock.RUnlock()
+			if !ok {
+				return 0, fmt.Errorf("there is no counter called %s", name)
+			}
+			return v, nil
+		},
+		"increment": func(name string) (int64, error) {
+			info.ConfigLock.Lock()
+			v, ok := info.Config.Counters.Map[name]
+			if ok {
+				v++
+				info.Config.Counters.Map[name] = v
+			}
+			info.ConfigLock.Unlock()
+			if !ok {
+				return 0, fmt.Errorf("there is no counter called %s", name)
+			}
+			info.SaveConfigWithoutHistory() // Counters change too often to fill the config history with them
+			return v, nil
+		},
+		"pick": func(tag string) (string, error) {
+			if !info.Bot.DB.CheckStatus() {
+				return "", errors.New("a temporary database outage is preventing tags from being used")
+			}
+			item, err := info.Bot.DB.PickTag(tag, bot.SBatoi(info.ID))
+			if err == sql.ErrNoRows {
+				return "", fmt.Errorf("the %s tag doesn't have any items", tag)
+			}
+			return item, err
+		},
+		"now": func() time.Time {
+			return time.Now().UTC()
+		},
+		"date": func(layout string, t time.Time) string {
+			return t.Format(layout)
+		},
+	}
+}
+
+func parseTemplate(info *bot.GuildInfo, name string, text string, args []string) (*template.Template, error) {
+	return template.New(name).Funcs(templateFuncs(info, args)).Parse(text)
+}
+
+type customCommand struct {
+	name string
+}
+
+func (c *customCommand) Info() *bot.CommandInfo {
+	return &bot.CommandInfo{
+		Name:  c.name,
+		Usage: "Custom command.",
+	}
+}
+func (c *customCommand) Process(args []string, msg *discordgo.Message, indices []int, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+	config := customConfig(info)
+	text, ok := config.Commands[c.name]
+	if !ok {
+		return "```\nThis custom command has been removed.```", false, nil
+	}
+
+	for i := range args {
+		args[i] = info.Sanitize(args[i], bot.CleanMentions|bot.CleanPings)
+	}
+	data := commandData{
+		Args:    args,
+		Author:  info.GetUserName(bot.DiscordUser(msg.Author.ID)),
+		Mention: bot.DiscordUser(msg.Author.ID).Display(),
+		Channel: bot.DiscordChannel(msg.ChannelID).Display(),
+		Server:  info.Name,
+	}
+	if len(indices) > 0 {
+		data.Text = info.Sanitize(msg.Content[indices[0]:], bot.CleanMentions|bot.CleanPings)
+	}
+	for _, u := range msg.Mentions {
+		data.Mentions = append(data.Mentions, info.GetUserName(bot.DiscordUser(u.ID)))
+	}
+
+	t, err := parseTemplate(info, c.name, text, args)
+	if err != nil {
+		return "```\nThis custom command is broken: " + err.Error() + "```", false, nil
+	}
+	w := &limitWriter{}
+	if err = t.Execute(w, data); err != nil {
+		return "```\nError running custom command: " + err.Error() + "```", false, nil
+	}
+	// Templates can pull in text from tags and arguments, so the only ping that gets through is whoever ran the command
+	mention := info.Sanitize(data.Mention, bot.CleanPings)
+	return strings.Replace(info.Sanitize(w.String(), bot.CleanPings), mention, data.Mention, -1), false, nil
+}
+func (c *customCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
+	return &bot.CommandUsage{
+		Desc: "A custom command added with `" + info.Prefix(bot.ChannelEmpty) + "addcommand`. It responds with:\n```\n" + customConfig(info).Commands[c.name] + "```",
+		Params: []bot.CommandUsageParam{
+			{Name: "arguments", Desc: "Anything the command's template uses.", Optional: true, Variadic: true},
+		},
+	}
+}
+
+type addCommandCommand struct {
+	w *CustomCommandsModule
+}
+
+func (c *addCommandCommand) Info() *bot.CommandInfo {
+	return &bot.CommandInfo{
+		Name:      "AddCommand",
+		Usage:     "Adds a custom command.",
+		Sensitive: true,
+	}
+}
+func (c *addCommandCommand) Process(args []string, msg *discordgo.Message, indices []int, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+	if len(args) < 2 {
+		return "```\nYou have to give both a name for the command and the template it responds with.```", false, nil
+	}
+	name := strings.ToLower(args[0])
+	if !commandNameRegex.MatchString(name) {
+		return "```\nCommand names can only contain letters, numbers, - and _, and can't be longer than 32 characters.```", false, nil
+	}
+	config := customConfig(info)
+	if _, custom := config.Commands[name]; !custom && info.HasCommand(bot.CommandID(name)) {
+		return "```\nThere is already a command called " + name + "!```", false, nil
+	}
+	text := msg.Content[indices[1]:]
+	if _, err := parseTemplate(info, name, text, nil); err != nil {
+		return "```\nThat template has an error: " + err.Error() + "```", false, nil
+	}
+
+	_, replaced := config.Commands[name]
+	if config.Commands == nil {
+		config.Commands = make(map[string]string)
+	}
+	config.Commands[name] = text
+	info.AddCommand(&customCommand{name}, c.w)
+	info.SaveConfigBy(bot.DiscordUser(msg.Author.ID))
+	info.UpdateSlashCommands()
+	if replaced {
+		return "```\nChanged the response of " + info.Prefix(bot.DiscordChannel(msg.ChannelID)) + name + ".```", false, nil
+	}
+	return "```\nAdded " + info.Prefix(bot.DiscordChannel(msg.ChannelID)) + name + ". You can restrict who can use it or where with modules.commandroles and modules.commandchannels, like any other command.```", false, nil
+}
+func (c *addCommandCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
+	p := info.Prefix(bot.ChannelEmpty)
+	return &bot.CommandUsage{
+		Desc: "Adds a command that responds with a template, or changes the response of a custom command that already exists. Templates use Go's template syntax, where anything inside `{{ }}` is replaced when the command runs:\n" +
+			"`{{.Author}}` and `{{.Mention}}` are the name and ping of whoever ran the command, `{{.Text}}` is everything they typed after the command name, `{{arg 0}}` is the first argument, `{{.Mentions}}` lists everyone they pinged, and `{{.Channel}}` and `{{.Server}}` are where they ran it.\n" +
+			"`{{choose \"a\" \"b\"}}` picks one of the choices at random, and `{{random 1 6}}` picks a number between 1 and 6.\n" +
+			"`{{counter \"name\"}}` is the value of a counter, and `{{increment \"name\"}}` adds one to it first.\n" +
+			"`{{pick \"tag\"}}` is a random item from a tag.\n" +
+			"`{{now | date \"Jan 2 15:04\"}}` is the current time in UTC, using Go's time layout.\n" +
+			"Custom commands can't ping anyone except whoever ran them.\n" +
+			"Example: `" + p + "addcommand hug {{.Author}} hugs {{.Text}}!`",
+		Params: []bot.CommandUsageParam{
+			{Name: "name", Desc: "The name of the command. Can only contain letters, numbers, - and _.", Optional: false},
+			{Name: "template", Desc: "What the command responds with.", Optional: false, Variadic: true},
+		},
+	}
+}
+
+type removeCommandCommand struct {
+}
+
+func (c *removeCommandCommand) Info() *bot.CommandInfo {
+	return &bot.CommandInfo{
+		Name:      "RemoveCommand",
+		Usage:     "Removes a custom command.",
+		Sensitive: true,
+	}
+}
+func (c *removeCommandCommand) Process(args []string, msg *discordgo.Message, indices []int, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+	if len(args) < 1 {
+		return "```\nYou have to give the name of the custom command to remove.```", false, nil
+	}
+	name := strings.ToLower(args[0])
+	config := customConfig(info)
+	if _, ok := config.Commands[name]; !ok {
+		return "```\nThere is no custom command called " + name + ".```", false, nil
+	}
+	delete(config.Commands, name)
+	info.RemoveCommand(bot.CommandID(name))
+	info.SaveConfigBy(bot.DiscordUser(msg.Author.ID))
+	info.UpdateSlashCommands()
+	return "```\nRemoved " + info.Prefix(bot.DiscordChannel(msg.ChannelID)) + name + ".```", false, nil
+}
+func (c *removeCommandCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
+	return &bot.CommandUsage{
+		Desc: "Removes a custom command that was added with `" + info.Prefix(bot.ChannelEmpty) + "addcommand`. Built-in commands can't be removed, but they can be disabled with `" + info.Prefix(bot.ChannelEmpty) + "disable`.",
+		Params: []bot.CommandUsageParam{
+			{Name: "name", Desc: "The name of the custom command.", Optional: false},
+		},
+	}
+}
@@ -0,0 +1,132 @@
+package discordtest
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	opDispatch        = 0
+	opHeartbeat       = 1
+	opIdentify        = 2
+	opResume          = 6
+	opHello           = 10
+	opHeartbeatAck    = 11
+	heartbeatInterval = 45000
+)
+
+var upgrader = websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return true }}
+
+type gatewayPayload struct {
+	Op       int             `json:"op"`
+	Sequence int64           `json:"s,omitempty"`
+	Type     string          `json:"t,omitempty"`
+	Data     json.RawMessage `json:"d"`
+}
+
+// gatewayConn is a single websocket connection from the bot. Only one goroutine can write to a websocket at a time,
+// so every write goes through send.
+type gatewayConn struct {
+	ws       *websocket.Conn
+	lock     sync.Mutex
+	sequence int64
+}
+
+func (c *gatewayConn) send(op int, t string, data interface{}) error {
+	d, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	p := gatewayPayload{Op: op, Type: t, Data: d}
+	if op == opDispatch {
+		c.sequence++
+		p.Sequence = c.sequence
+	}
+	return c.ws.WriteJSON(p)
+}
+
+func (c *gatewayConn) Close() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	return c.ws.Close()
+}
+
+// dispatch sends an event to the bot if it's connected. Events that happen before the bot connects are simply
+// reflected in the guilds it receives once it identifies itself.
+func (s *Server) dispatch(t string, data interface{}) {
+	s.lock.Lock()
+	conn := s.gateway
+	s.lock.Unlock()
+	if conn != nil {
+		conn.send(opDispatch, t, data)
+	}
+}
+
+func (s *Server) serveGateway(w http.ResponseWriter, r *http.Request) {
+	ws, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	conn := &gatewayConn{ws: ws}
+	defer func() {
+		s.lock.Lock()
+		if s.gateway == conn {
+			s.gateway = nil
+		}
+		s.lock.Unlock()
+		ws.Close()
+	}()
+
+	if conn.send(opHello, "", map[string]interface{}{"heartbeat_interval": heartbeatInterval}) != nil {
+		return
+	}
+
+	for {
+		var p gatewayPayload
+		if err := ws.ReadJSON(&p); err != nil {
+			return
+		}
+		switch p.Op {
+		case opHeartbeat:
+			conn.send(opHeartbeatAck, "", nil)
+		case opIdentify:
+			s.identify(conn)
+		case opResume:
+			s.lock.Lock()
+			s.gateway = conn
+			s.lock.Unlock()
+			conn.send(opDispatch, "RESUMED", map[string]interface{}{})
+		}
+	}
+}
+
+// identify sends the READY event, followed by a GUILD_CREATE for every guild, just like discord does for bot accounts
+func (s *Server) identify(conn *gatewayConn) {
+	s.lock.Lock()
+	unavailable := make([]map[string]interface{}, 0, len(s.order))
+	for _, id := range s.order {
+		unavailable = append(unavailable, map[string]interface{}{"id": id, "unavailable": true})
+	}
+	ready := map[string]interface{}{
+		"v":                6,
+		"session_id":       s.nextID(),
+		"user":             copyUser(s.Self),
+		"guilds":           unavailable,
+		"private_channels": []interface{}{},
+	}
+	s.gateway = conn
+	// Hold the lock until every guild has been sent, so no other event can sneak in before its GUILD_CREATE
+	defer s.lock.Unlock()
+
+	conn.send(opDispatch, "READY", ready)
+	for _, id := range s.order {
+		conn.send(opDispatch, "GUILD_CREATE", copyGuild(s.guilds[id]))
+	}
+	s.readyOnce.Do(func() { close(s.ready) })
+}
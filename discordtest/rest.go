@@ -0,0 +1,764 @@
+package discordtest
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/blackhole12/discordgo"
+	"github.com/gorilla/websocket"
+)
+
+var apiPrefix = regexp.MustCompile("^/api(/v[0-9]+)?")
+
+type restError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+var errNotFound = restError{10000, "404: Not Found"}
+
+// messageSend mirrors the JSON body discordgo sends when creating or editing a message
+type messageSend struct {
+	Content *string                 `json:"content"`
+	Embed   *discordgo.MessageEmbed `json:"embed"`
+}
+
+// webhookSend mirrors the JSON body used to respond to an interaction
+type webhookSend struct {
+	Content *string                   `json:"content"`
+	Embeds  []*discordgo.MessageEmbed `json:"embeds"`
+}
+
+// upload is a file attached to a multipart request
+type upload struct {
+	name string
+	data []byte
+}
+
+// request holds everything a REST handler needs to know about an incoming request
+type request struct {
+	method string
+	parts  []string
+	query  url.Values
+	body   []byte
+	files  []upload
+	reason string
+	auth   string
+}
+
+func (r *request) match(method string, pattern ...string) bool {
+	if r.method != method || len(r.parts) != len(pattern) {
+		return false
+	}
+	for i, p := range pattern {
+		if p != "*" && p != r.parts[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if websocket.IsWebSocketUpgrade(r) {
+		s.serveGateway(w, r)
+		return
+	}
+
+	req := &request{
+		method: r.Method,
+		parts:  strings.Split(strings.Trim(apiPrefix.ReplaceAllString(r.URL.Path, ""), "/"), "/"),
+		query:  r.URL.Query(),
+		reason: r.Header.Get("X-Audit-Log-Reason"),
+		auth:   r.Header.Get("Authorization"),
+	}
+	if r.Method == "GET" && strings.HasPrefix(r.URL.Path, "/attachments/") {
+		s.serveAttachment(w, r.URL.Path)
+		return
+	}
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+		req.body = []byte(r.FormValue("payload_json"))
+		if r.MultipartForm != nil {
+			for _, headers := range r.MultipartForm.File {
+				for _, h := range headers {
+					if f, err := h.Open(); err == nil {
+						data, _ := ioutil.ReadAll(f)
+						f.Close()
+						req.files = append(req.files, upload{h.Filename, data})
+					}
+				}
+			}
+		}
+	} else {
+		req.body, _ = ioutil.ReadAll(r.Body)
+	}
+	if len(req.reason) == 0 {
+		req.reason = req.query.Get("reason")
+	}
+
+	status, response := s.route(req, r)
+	if response == nil {
+		w.WriteHeader(status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
+
+// route finds the handler for a request and returns the HTTP status and the object to send back as JSON
+func (s *Server) route(req *request, r *http.Request) (int, interface{}) {
+	switch {
+	case req.match("GET", "gateway"), req.match("GET", "gateway", "bot"):
+		return http.StatusOK, map[string]interface{}{"url": "ws://" + r.Host + "/gateway", "shards": 1}
+	case req.match("GET", "oauth2", "applications", "@me"):
+		return http.StatusOK, map[string]interface{}{"id": s.Self.ID, "name": s.Self.Username, "owner": s.Owner}
+	case req.match("POST", "oauth2", "token"):
+		return s.exchangeCode(req)
+	case req.match("GET", "users", "@me") && strings.HasPrefix(req.auth, "Bearer "):
+		return s.getOAuthUser(strings.TrimPrefix(req.auth, "Bearer "))
+	case req.match("GET", "users", "*"):
+		return s.getUser(req.parts[1])
+	case req.match("PATCH", "users", "@me"):
+		return s.editSelf(req)
+	case req.match("POST", "users", "@me", "channels"):
+		return s.createDM(req)
+	case req.match("GET", "channels", "*"):
+		return s.getChannel(req.parts[1])
+	case req.match("DELETE", "channels", "*"):
+		return s.deleteChannel(req.parts[1])
+	case req.match("GET", "channels", "*", "messages"):
+		return s.getMessages(req.parts[1], req.query)
+	case req.match("POST", "channels", "*", "messages"):
+		return s.sendMessage(req.parts[1], req)
+	case req.match("POST", "channels", "*", "messages", "bulk-delete"), req.match("POST", "channels", "*", "messages", "bulk_delete"):
+		var body struct {
+			Messages []string `json:"messages"`
+		}
+		json.Unmarshal(req.body, &body)
+		return s.deleteMessages(req.parts[1], body.Messages)
+	case req.match("GET", "channels", "*", "messages", "*"):
+		return s.getMessage(req.parts[1], req.parts[3])
+	case req.match("PATCH", "channels", "*", "messages", "*"):
+		return s.editMessage(req.parts[1], req.parts[3], req)
+	case req.match("DELETE", "channels", "*", "messages", "*"):
+		return s.deleteMessages(req.parts[1], []string{req.parts[3]})
+	case req.match("PUT", "channels", "*", "messages", "*", "reactions", "*", "@me"):
+		s.lock.Lock()
+		s.record(Action{Type: ActionReaction, Channel: req.parts[1], Messages: []string{req.parts[3]}, Reason: req.parts[5]})
+		s.lock.Unlock()
+		return http.StatusNoContent, nil
+	case req.match("DELETE", "channels", "*", "messages", "*", "reactions"):
+		s.lock.Lock()
+		s.record(Action{Type: ActionReactionRemove, Channel: req.parts[1], Messages: []string{req.parts[3]}})
+		s.lock.Unlock()
+		return http.StatusNoContent, nil
+	case req.match("DELETE", "channels", "*", "messages", "*", "reactions", "*", "*"):
+		s.lock.Lock()
+		s.record(Action{Type: ActionReactionRemove, Channel: req.parts[1], User: req.parts[6], Messages: []string{req.parts[3]}, Reason: req.parts[5]})
+		s.lock.Unlock()
+		return http.StatusNoContent, nil
+	case req.match("POST", "channels", "*", "typing"):
+		return http.StatusNoContent, nil
+	case req.match("PUT", "channels", "*", "permissions", "*"):
+		return s.setPermission(req.parts[1], req.parts[3], req)
+	case req.match("GET", "guilds", "*"):
+		return s.getGuild(req.parts[1])
+	case req.match("PATCH", "guilds", "*"):
+		return s.editGuild(req.parts[1], req)
+	case req.match("GET", "guilds", "*", "channels"):
+		if g := s.Guild(req.parts[1]); g != nil {
+			return http.StatusOK, g.Channels
+		}
+	case req.match("POST", "guilds", "*", "channels"):
+		return s.createChannel(req.parts[1], req)
+	case req.match("GET", "guilds", "*", "roles"):
+		if g := s.Guild(req.parts[1]); g != nil {
+			return http.StatusOK, g.Roles
+		}
+	case req.match("POST", "guilds", "*", "roles"):
+		if s.Guild(req.parts[1]) != nil {
+			return http.StatusOK, s.AddRole(req.parts[1], "new role", 0)
+		}
+	case req.match("PATCH", "guilds", "*", "roles", "*"):
+		return s.editRole(req.parts[1], req.parts[3], req)
+	case req.match("GET", "guilds", "*", "members"):
+		return s.getMembers(req.parts[1], req.query)
+	case req.match("GET", "guilds", "*", "members", "*"):
+		if m := s.Member(req.parts[1], req.parts[3]); m != nil {
+			return http.StatusOK, m
+		}
+	case req.match("PATCH", "guilds", "*", "members", "*"):
+		return s.editMember(req.parts[1], req.parts[3], req)
+	case req.match("DELETE", "guilds", "*", "members", "*"):
+		return s.kick(req.parts[1], req.parts[3], req.reason)
+	case req.match("PUT", "guilds", "*", "members", "*", "roles", "*"):
+		return s.changeRole(ActionRoleAdd, req.parts[1], req.parts[3], req.parts[5])
+	case req.match("DELETE", "guilds", "*", "members", "*", "roles", "*"):
+		return s.changeRole(ActionRoleRemove, req.parts[1], req.parts[3], req.parts[5])
+	case req.match("GET", "guilds", "*", "bans"):
+		return s.getBans(req.parts[1])
+	case req.match("PUT", "guilds", "*", "bans", "*"):
+		return s.ban(req.parts[1], req.parts[3], req)
+	case req.match("DELETE", "guilds", "*", "bans", "*"):
+		return s.unban(req.parts[1], req.parts[3])
+	case req.match("PUT", "applications", "*", "guilds", "*", "commands"):
+		return s.setCommands(req.parts[3], req)
+	case req.match("POST", "interactions", "*", "*", "callback"):
+		return s.acknowledge(req.parts[2])
+	case req.match("PATCH", "webhooks", "*", "*", "messages", "@original"):
+		return s.respond(req.parts[2], true, req)
+	case req.match("POST", "webhooks", "*", "*"):
+		return s.respond(req.parts[2], false, req)
+	case req.match("DELETE", "webhooks", "*", "*", "messages", "@original"):
+		return s.deleteOriginal(req.parts[2])
+	default:
+		s.lock.Lock()
+		s.record(Action{Type: ActionUnhandled, Request: req.method + " /" + strings.Join(req.parts, "/")})
+		s.lock.Unlock()
+	}
+	return http.StatusNotFound, errNotFound
+}
+
+// less compares two snowflakes
+func less(a string, b string) bool {
+	x, _ := strconv.ParseUint(a, 10, 64)
+	y, _ := strconv.ParseUint(b, 10, 64)
+	return x < y
+}
+
+func (s *Server) getUser(userID string) (int, interface{}) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if userID == "@me" {
+		userID = s.Self.ID
+	}
+	if u, ok := s.users[userID]; ok {
+		return http.StatusOK, copyUser(u)
+	}
+	return http.StatusNotFound, errNotFound
+}
+
+// exchangeCode trades an OAuth2 code from OAuthCode for an access token, like discord does once a user authorizes an
+// application
+func (s *Server) exchangeCode(req *request) (int, interface{}) {
+	form, _ := url.ParseQuery(string(req.body))
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	user, ok := s.oauth[form.Get("code")]
+	if !ok || form.Get("grant_type") != "authorization_code" || form.Get("client_id") != s.Self.ID || len(form.Get("client_secret")) == 0 {
+		return http.StatusBadRequest, map[string]string{"error": "invalid_grant"}
+	}
+	delete(s.oauth, form.Get("code"))
+	token := "token" + s.nextID()
+	s.oauth[token] = user
+	return http.StatusOK, map[string]interface{}{"access_token": token, "token_type": "Bearer", "expires_in": 604800, "scope": "identify"}
+}
+
+func (s *Server) getOAuthUser(token string) (int, interface{}) {
+	s.lock.Lock()
+	user, ok := s.oauth[token]
+	s.lock.Unlock()
+	if !ok || strings.HasPrefix(token, "code") {
+		return http.StatusUnauthorized, restError{0, "401: Unauthorized"}
+	}
+	return s.getUser(user)
+}
+
+func (s *Server) editSelf(req *request) (int, interface{}) {
+	s.lock.Lock()
+	u := s.users[s.Self.ID]
+	json.Unmarshal(req.body, u)
+	r := copyUser(u)
+	s.lock.Unlock()
+
+	s.dispatch("USER_UPDATE", r)
+	return http.StatusOK, r
+}
+
+func (s *Server) createDM(req *request) (int, interface{}) {
+	var body struct {
+		Recipient string `json:"recipient_id"`
+	}
+	json.Unmarshal(req.body, &body)
+	s.lock.Lock()
+	if _, ok := s.users[body.Recipient]; !ok {
+		s.lock.Unlock()
+		return http.StatusNotFound, errNotFound
+	}
+	// Discord always returns the same DM channel for a given user, and so do we, by using their ID as the channel ID
+	ch, ok := s.channels[body.Recipient]
+	if !ok {
+		ch = &discordgo.Channel{ID: body.Recipient, Type: discordgo.ChannelTypeDM, PermissionOverwrites: []*discordgo.PermissionOverwrite{}}
+		s.channels[ch.ID] = ch
+	}
+	r := copyChannel(ch)
+	s.lock.Unlock()
+
+	if !ok {
+		s.dispatch("CHANNEL_CREATE", r)
+	}
+	return http.StatusOK, r
+}
+
+func (s *Server) getChannel(channelID string) (int, interface{}) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if ch, ok := s.channels[channelID]; ok {
+		return http.StatusOK, copyChannel(ch)
+	}
+	return http.StatusNotFound, errNotFound
+}
+
+func (s *Server) createChannel(guildID string, req *request) (int, interface{}) {
+	var body struct {
+		Name     string                `json:"name"`
+		Type     discordgo.ChannelType `json:"type"`
+		ParentID string                `json:"parent_id"`
+	}
+	json.Unmarshal(req.body, &body)
+	s.lock.Lock()
+	if _, ok := s.guilds[guildID]; !ok {
+		s.lock.Unlock()
+		return http.StatusNotFound, errNotFound
+	}
+	r := s.addChannel(guildID, body.Name, body.Type, body.ParentID)
+	s.record(Action{Type: ActionChannelCreate, Guild: guildID, Channel: r.ID, Reason: req.reason})
+	s.lock.Unlock()
+
+	s.dispatch("CHANNEL_CREATE", r)
+	return http.StatusOK, r
+}
+
+func (s *Server) deleteChannel(channelID string) (int, interface{}) {
+	s.lock.Lock()
+	ch, ok := s.channels[channelID]
+	if !ok {
+		s.lock.Unlock()
+		return http.StatusNotFound, errNotFound
+	}
+	delete(s.channels, channelID)
+	if g, ok := s.guilds[ch.GuildID]; ok {
+		for i, v := range g.Channels {
+			if v.ID == channelID {
+				g.Channels = append(g.Channels[:i], g.Channels[i+1:]...)
+				break
+			}
+		}
+	}
+	r := copyChannel(ch)
+	s.record(Action{Type: ActionChannelDelete, Guild: ch.GuildID, Channel: channelID})
+	s.lock.Unlock()
+
+	s.dispatch("CHANNEL_DELETE", r)
+	return http.StatusOK, r
+}
+
+func (s *Server) getMessages(channelID string, query url.Values) (int, interface{}) {
+	limit, err := strconv.Atoi(query.Get("limit"))
+	if err != nil || limit <= 0 || limit > 100 {
+		limit = 50
+	}
+	before := query.Get("before")
+	after := query.Get("after")
+
+	messages := s.Messages(channelID)
+	r := make([]*discordgo.Message, 0, limit)
+	for i := len(messages) - 1; i >= 0 && len(r) < limit; i-- { // discord returns the newest messages first
+		m := messages[i]
+		if (len(before) > 0 && !less(m.ID, before)) || (len(after) > 0 && !less(after, m.ID)) {
+			continue
+		}
+		r = append(r, m)
+	}
+	return http.StatusOK, r
+}
+
+func (s *Server) getMessage(channelID string, messageID string) (int, interface{}) {
+	for _, m := range s.Messages(channelID) {
+		if m.ID == messageID {
+			return http.StatusOK, m
+		}
+	}
+	return http.StatusNotFound, errNotFound
+}
+
+func (s *Server) sendMessage(channelID string, req *request) (int, interface{}) {
+	var body messageSend
+	json.Unmarshal(req.body, &body)
+
+	s.lock.Lock()
+	ch, ok := s.channels[channelID]
+	if !ok {
+		s.lock.Unlock()
+		return http.StatusNotFound, errNotFound
+	}
+	content := ""
+	if body.Content != nil {
+		content = *body.Content
+	}
+	m := s.newMessage(channelID, s.Self, content)
+	if body.Embed != nil {
+		m.Embeds = append(m.Embeds, body.Embed)
+	}
+	for _, f := range req.files {
+		s.attach(m, f.name, f.data)
+	}
+	r := copyMessage(m)
+	s.record(Action{Type: ActionSend, Guild: ch.GuildID, Channel: channelID, Message: copyMessage(m)})
+	s.lock.Unlock()
+
+	s.dispatch("MESSAGE_CREATE", r)
+	return http.StatusOK, r
+}
+
+func (s *Server) editMessage(channelID string, messageID string, req *request) (int, interface{}) {
+	var body messageSend
+	json.Unmarshal(req.body, &body)
+
+	s.lock.Lock()
+	var m *discordgo.Message
+	for _, v := range s.history[channelID] {
+		if v.ID == messageID {
+			m = v
+		}
+	}
+	if m == nil {
+		s.lock.Unlock()
+		return http.StatusNotFound, errNotFound
+	}
+	if body.Content != nil {
+		m.Content = *body.Content
+	}
+	if body.Embed != nil {
+		m.Embeds = []*discordgo.MessageEmbed{body.Embed}
+	}
+	m.EditedTimestamp = timestamp()
+	r := copyMessage(m)
+	s.record(Action{Type: ActionEdit, Guild: s.channels[channelID].GuildID, Channel: channelID, Message: copyMessage(m)})
+	s.lock.Unlock()
+
+	s.dispatch("MESSAGE_UPDATE", r)
+	return http.StatusOK, r
+}
+
+func (s *Server) deleteMessages(channelID string, messages []string) (int, interface{}) {
+	s.lock.Lock()
+	ch, ok := s.channels[channelID]
+	if !ok {
+		s.lock.Unlock()
+		return http.StatusNotFound, errNotFound
+	}
+	deleted := make(map[string]bool)
+	for _, id := range messages {
+		deleted[id] = true
+	}
+	history := s.history[channelID][:0]
+	for _, m := range s.history[channelID] {
+		if !deleted[m.ID] {
+			history = append(history, m)
+		}
+	}
+	s.history[channelID] = history
+	s.record(Action{Type: ActionDelete, Guild: ch.GuildID, Channel: channelID, Messages: append([]string{}, messages...)})
+	s.lock.Unlock()
+
+	if len(messages) == 1 {
+		s.dispatch("MESSAGE_DELETE", map[string]interface{}{"id": messages[0], "channel_id": channelID})
+	} else {
+		s.dispatch("MESSAGE_DELETE_BULK", map[string]interface{}{"ids": messages, "channel_id": channelID})
+	}
+	return http.StatusNoContent, nil
+}
+
+func (s *Server) setPermission(channelID string, targetID string, req *request) (int, interface{}) {
+	s.lock.Lock()
+	ch, ok := s.channels[channelID]
+	if !ok {
+		s.lock.Unlock()
+		return http.StatusNotFound, errNotFound
+	}
+	overwrite := &discordgo.PermissionOverwrite{}
+	json.Unmarshal(req.body, overwrite)
+	overwrite.ID = targetID
+	replaced := false
+	for i, v := range ch.PermissionOverwrites {
+		if v.ID == targetID {
+			ch.PermissionOverwrites[i] = overwrite
+			replaced = true
+		}
+	}
+	if !replaced {
+		ch.PermissionOverwrites = append(ch.PermissionOverwrites, overwrite)
+	}
+	r := copyChannel(ch)
+	s.lock.Unlock()
+
+	s.dispatch("CHANNEL_UPDATE", r)
+	return http.StatusNoContent, nil
+}
+
+func (s *Server) getGuild(guildID string) (int, interface{}) {
+	if g := s.Guild(guildID); g != nil {
+		return http.StatusOK, g
+	}
+	return http.StatusNotFound, errNotFound
+}
+
+func (s *Server) editGuild(guildID string, req *request) (int, interface{}) {
+	s.lock.Lock()
+	g, ok := s.guilds[guildID]
+	if !ok {
+		s.lock.Unlock()
+		return http.StatusNotFound, errNotFound
+	}
+	json.Unmarshal(req.body, g)
+	g.ID = guildID
+	r := copyGuild(g)
+	s.record(Action{Type: ActionGuildEdit, Guild: guildID, Reason: req.reason})
+	s.lock.Unlock()
+
+	s.dispatch("GUILD_UPDATE", r)
+	return http.StatusOK, r
+}
+
+func (s *Server) editRole(guildID string, roleID string, req *request) (int, interface{}) {
+	s.lock.Lock()
+	var role *discordgo.Role
+	if g, ok := s.guilds[guildID]; ok {
+		for _, v := range g.Roles {
+			if v.ID == roleID {
+				role = v
+			}
+		}
+	}
+	if role == nil {
+		s.lock.Unlock()
+		return http.StatusNotFound, errNotFound
+	}
+	json.Unmarshal(req.body, role)
+	role.ID = roleID
+	r := *role
+	s.lock.Unlock()
+
+	s.dispatch("GUILD_ROLE_UPDATE", map[string]interface{}{"guild_id": guildID, "role": r})
+	return http.StatusOK, &r
+}
+
+func (s *Server) getMembers(guildID string, query url.Values) (int, interface{}) {
+	g := s.Guild(guildID)
+	if g == nil {
+		return http.StatusNotFound, errNotFound
+	}
+	limit, err := strconv.Atoi(query.Get("limit"))
+	if err != nil || limit <= 0 || limit > 1000 {
+		limit = 1
+	}
+	after := query.Get("after")
+	sort.Slice(g.Members, func(i, j int) bool { return less(g.Members[i].User.ID, g.Members[j].User.ID) })
+	r := make([]*discordgo.Member, 0, limit)
+	for _, m := range g.Members {
+		if len(r) < limit && (len(after) == 0 || less(after, m.User.ID)) {
+			r = append(r, m)
+		}
+	}
+	return http.StatusOK, r
+}
+
+func (s *Server) editMember(guildID string, userID string, req *request) (int, interface{}) {
+	s.lock.Lock()
+	m := s.getMember(guildID, userID)
+	if m == nil {
+		s.lock.Unlock()
+		return http.StatusNotFound, errNotFound
+	}
+	json.Unmarshal(req.body, m)
+	r := copyMember(m)
+	var move struct {
+		ChannelID *string `json:"channel_id"`
+	}
+	json.Unmarshal(req.body, &move)
+	var v *discordgo.VoiceState
+	if move.ChannelID != nil {
+		v = s.setVoice(guildID, userID, *move.ChannelID)
+		s.record(Action{Type: ActionMove, Guild: guildID, Channel: *move.ChannelID, User: userID})
+	}
+	s.lock.Unlock()
+
+	s.dispatch("GUILD_MEMBER_UPDATE", r)
+	if v != nil {
+		s.dispatch("VOICE_STATE_UPDATE", v)
+	}
+	return http.StatusNoContent, nil
+}
+
+func (s *Server) kick(guildID string, userID string, reason string) (int, interface{}) {
+	s.lock.Lock()
+	m := s.removeMember(guildID, userID)
+	if m != nil {
+		s.record(Action{Type: ActionKick, Guild: guildID, User: userID, Reason: reason})
+	}
+	s.lock.Unlock()
+
+	if m == nil {
+		return http.StatusNotFound, errNotFound
+	}
+	s.dispatch("GUILD_MEMBER_REMOVE", m)
+	return http.StatusNoContent, nil
+}
+
+func (s *Server) changeRole(t ActionType, guildID string, userID string, roleID string) (int, interface{}) {
+	s.lock.Lock()
+	m := s.getMember(guildID, userID)
+	if m == nil {
+		s.lock.Unlock()
+		return http.StatusNotFound, errNotFound
+	}
+	roles := []string{}
+	for _, v := range m.Roles {
+		if v != roleID {
+			roles = append(roles, v)
+		}
+	}
+	if t == ActionRoleAdd {
+		roles = append(roles, roleID)
+	}
+	m.Roles = roles
+	r := copyMember(m)
+	s.record(Action{Type: t, Guild: guildID, User: userID, Role: roleID})
+	s.lock.Unlock()
+
+	s.dispatch("GUILD_MEMBER_UPDATE", r)
+	return http.StatusNoContent, nil
+}
+
+func (s *Server) getBans(guildID string) (int, interface{}) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	bans := []map[string]interface{}{}
+	for id, reason := range s.bans[guildID] {
+		bans = append(bans, map[string]interface{}{"reason": reason, "user": copyUser(s.users[id])})
+	}
+	return http.StatusOK, bans
+}
+
+func (s *Server) ban(guildID string, userID string, req *request) (int, interface{}) {
+	s.lock.Lock()
+	u, ok := s.users[userID]
+	if !ok || s.guilds[guildID] == nil {
+		s.lock.Unlock()
+		return http.StatusNotFound, errNotFound
+	}
+	s.bans[guildID][userID] = req.reason
+	m := s.removeMember(guildID, userID)
+	s.record(Action{Type: ActionBan, Guild: guildID, User: userID, Reason: req.reason})
+	user := copyUser(u)
+	s.lock.Unlock()
+
+	s.dispatch("GUILD_BAN_ADD", map[string]interface{}{"guild_id": guildID, "user": user})
+	if m != nil {
+		s.dispatch("GUILD_MEMBER_REMOVE", m)
+	}
+	return http.StatusNoContent, nil
+}
+
+func (s *Server) unban(guildID string, userID string) (int, interface{}) {
+	s.lock.Lock()
+	if _, ok := s.bans[guildID][userID]; !ok {
+		s.lock.Unlock()
+		return http.StatusNotFound, errNotFound
+	}
+	delete(s.bans[guildID], userID)
+	s.record(Action{Type: ActionUnban, Guild: guildID, User: userID})
+	user := copyUser(s.users[userID])
+	s.lock.Unlock()
+
+	s.dispatch("GUILD_BAN_REMOVE", map[string]interface{}{"guild_id": guildID, "user": user})
+	return http.StatusNoContent, nil
+}
+
+func (s *Server) setCommands(guildID string, req *request) (int, interface{}) {
+	var commands []Command
+	if err := json.Unmarshal(req.body, &commands); err != nil {
+		return http.StatusBadRequest, restError{50035, "Invalid Form Body"}
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, ok := s.guilds[guildID]; !ok {
+		return http.StatusNotFound, errNotFound
+	}
+	s.commands[guildID] = commands
+	s.record(Action{Type: ActionCommands, Guild: guildID})
+	return http.StatusOK, commands
+}
+
+func (s *Server) acknowledge(token string) (int, interface{}) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	i, ok := s.tokens[token]
+	if !ok {
+		return http.StatusNotFound, errNotFound
+	}
+	s.record(Action{Type: ActionDefer, Guild: i.guild, Channel: i.channel})
+	return http.StatusNoContent, nil
+}
+
+// respond either replaces the deferred response to an interaction or sends a followup message. Both show up in the
+// channel the command was used in as a normal message from the bot.
+func (s *Server) respond(token string, original bool, req *request) (int, interface{}) {
+	var body webhookSend
+	json.Unmarshal(req.body, &body)
+
+	s.lock.Lock()
+	i, ok := s.tokens[token]
+	if !ok {
+		s.lock.Unlock()
+		return http.StatusNotFound, errNotFound
+	}
+	content := ""
+	if body.Content != nil {
+		content = *body.Content
+	}
+	var m *discordgo.Message
+	if original && i.original != nil {
+		m = i.original
+		m.Content = content
+		m.Embeds = append([]*discordgo.MessageEmbed{}, body.Embeds...)
+		m.EditedTimestamp = timestamp()
+	} else {
+		m = s.newMessage(i.channel, s.Self, content)
+		m.Embeds = append(m.Embeds, body.Embeds...)
+		if original {
+			i.original = m
+		}
+	}
+	r := copyMessage(m)
+	s.record(Action{Type: ActionSend, Guild: i.guild, Channel: i.channel, Message: copyMessage(m)})
+	s.lock.Unlock()
+
+	s.dispatch("MESSAGE_CREATE", r)
+	return http.StatusOK, r
+}
+
+func (s *Server) deleteOriginal(token string) (int, interface{}) {
+	s.lock.Lock()
+	i, ok := s.tokens[token]
+	if !ok {
+		s.lock.Unlock()
+		return http.StatusNotFound, errNotFound
+	}
+	if i.original == nil { // The bot gave up on a deferred response without ever sending anything
+		s.record(Action{Type: ActionDelete, Guild: i.guild, Channel: i.channel})
+		s.lock.Unlock()
+		return http.StatusNoContent, nil
+	}
+	channelID, messageID := i.channel, i.original.ID
+	i.original = nil
+	s.lock.Unlock()
+	return s.deleteMessages(channelID, []string{messageID})
+}
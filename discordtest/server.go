@@ -0,0 +1,752 @@
+// Package discordtest provides an in-process stand-in for the discord gateway and REST API. A bot session that is
+// attached to a Server connects to it exactly like it would connect to discord, which lets tests script entire
+// conversations against a running bot and then check what the bot did in response.
+package discordtest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/blackhole12/discordgo"
+)
+
+const discordEpoch = 1420070400000
+
+var mentionRegex = regexp.MustCompile("<@!?([0-9]+)>")
+
+// ActionType identifies what kind of request the bot made
+type ActionType string
+
+// Every REST request the bot makes that changes something is recorded as one of these actions
+const (
+	ActionSend           = ActionType("send")
+	ActionEdit           = ActionType("edit")
+	ActionDelete         = ActionType("delete")
+	ActionRoleAdd        = ActionType("roleadd")
+	ActionRoleRemove     = ActionType("roleremove")
+	ActionBan            = ActionType("ban")
+	ActionUnban          = ActionType("unban")
+	ActionKick           = ActionType("kick")
+	ActionGuildEdit      = ActionType("guildedit")
+	ActionReaction       = ActionType("reaction")
+	ActionReactionRemove = ActionType("reactionremove") // The bot removed a single reaction, or all of them if User and Reason are empty
+	ActionChannelCreate  = ActionType("channelcreate")
+	ActionChannelDelete  = ActionType("channeldelete")
+	ActionMove           = ActionType("move")     // The bot moved a member to another voice channel
+	ActionCommands       = ActionType("commands") // The bot registered a new set of slash commands for a guild
+	ActionDefer          = ActionType("defer")    // The bot acknowledged an interaction and promised to respond later
+	ActionUnhandled      = ActionType("unhandled")
+)
+
+// Action records a single change the bot made through the REST API
+type Action struct {
+	Type     ActionType
+	Guild    string
+	Channel  string
+	User     string
+	Role     string
+	Message  *discordgo.Message // The message that was sent or edited
+	Messages []string           // IDs of all the deleted messages
+	Reason   string             // Audit log reason, if one was given
+	Request  string             // Method and path of the request, only set on ActionUnhandled
+}
+
+// Command is a slash command the bot registered
+type Command struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Options     []CommandOption `json:"options"`
+}
+
+// CommandOption is a single parameter of a slash command
+type CommandOption struct {
+	Type        int    `json:"type"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Required    bool   `json:"required"`
+}
+
+// interaction tracks a slash command that was used, so responses sent through its webhook end up in the right channel
+type interaction struct {
+	id       string
+	guild    string
+	channel  string
+	original *discordgo.Message
+}
+
+// Server is a fake discord server. Guilds, channels and members can be added both before and after the bot connects.
+// All methods are safe to call from multiple goroutines, and everything they return is a copy of the server state.
+type Server struct {
+	URL       string
+	Self      *discordgo.User // The bot's own user account
+	Owner     *discordgo.User // The owner of the bot's application
+	http      *httptest.Server
+	lock      sync.Mutex
+	lastid    uint64
+	users     map[string]*discordgo.User
+	guilds    map[string]*discordgo.Guild
+	order     []string // guild IDs in the order they were created, so GUILD_CREATE events are deterministic
+	channels  map[string]*discordgo.Channel
+	history   map[string][]*discordgo.Message // Messages in each channel, oldest first
+	bans      map[string]map[string]string
+	commands  map[string][]Command    // Slash commands registered on each guild
+	tokens    map[string]*interaction // Interactions by their token
+	files     map[string][]byte       // Contents of every attachment, by the path of its URL
+	oauth     map[string]string       // OAuth2 codes and access tokens, mapped to the user they log in as
+	actions   []Action
+	notify    chan struct{} // closed and replaced every time an action is recorded
+	gateway   *gatewayConn
+	ready     chan struct{}
+	readyOnce sync.Once
+}
+
+// NewServer starts a new fake discord server with a bot user and an application owner
+func NewServer() *Server {
+	s := &Server{
+		users:    make(map[string]*discordgo.User),
+		guilds:   make(map[string]*discordgo.Guild),
+		channels: make(map[string]*discordgo.Channel),
+		history:  make(map[string][]*discordgo.Message),
+		bans:     make(map[string]map[string]string),
+		commands: make(map[string][]Command),
+		tokens:   make(map[string]*interaction),
+		files:    make(map[string][]byte),
+		oauth:    make(map[string]string),
+		notify:   make(chan struct{}),
+		ready:    make(chan struct{}),
+	}
+	s.http = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	s.URL = s.http.URL
+	s.Self = s.AddUser("Sweetie Bot", true)
+	s.Owner = s.AddUser("Bot Owner", false)
+	return s
+}
+
+// Close disconnects the bot and shuts down the server
+func (s *Server) Close() {
+	s.lock.Lock()
+	conn := s.gateway
+	s.gateway = nil
+	s.lock.Unlock()
+	if conn != nil {
+		conn.Close()
+	}
+	s.http.Close()
+}
+
+// Client returns an http.Client that sends every request to this server, no matter what host it was meant for
+func (s *Server) Client() *http.Client {
+	target, _ := url.Parse(s.URL)
+	return &http.Client{Timeout: 20 * time.Second, Transport: &redirectTransport{target}}
+}
+
+// Attach points a discordgo session at this server. Because discordgo asks the REST API where the gateway is, this
+// also makes the session open its websocket connection to the server.
+func (s *Server) Attach(dg *discordgo.Session) {
+	dg.Client = s.Client()
+}
+
+// WaitReady blocks until the bot has identified itself on the gateway and been sent all of the guilds
+func (s *Server) WaitReady(timeout time.Duration) bool {
+	select {
+	case <-s.ready:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+type redirectTransport struct {
+	target *url.URL
+}
+
+func (t *redirectTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	req := new(http.Request)
+	*req = *r
+	u := *r.URL
+	u.Scheme = t.target.Scheme
+	u.Host = t.target.Host
+	req.URL = &u
+	req.Host = t.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// nextID generates a unique snowflake, which means the bot can get a timestamp out of any ID the server creates. The
+// caller must hold the lock.
+func (s *Server) nextID() string {
+	id := uint64(time.Now().UTC().UnixNano()/int64(time.Millisecond)-discordEpoch) << 22
+	if id <= s.lastid {
+		id = s.lastid + 1
+	}
+	s.lastid = id
+	return strconv.FormatUint(id, 10)
+}
+
+func timestamp() discordgo.Timestamp {
+	return discordgo.Timestamp(time.Now().UTC().Format(time.RFC3339Nano))
+}
+
+// record appends an action to the log and wakes up anyone waiting for one. The caller must hold the lock.
+func (s *Server) record(a Action) {
+	s.actions = append(s.actions, a)
+	close(s.notify)
+	s.notify = make(chan struct{})
+}
+
+// AddUser creates a new user account that isn't a member of any guild yet
+func (s *Server) AddUser(name string, isbot bool) *discordgo.User {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	u := &discordgo.User{
+		ID:            s.nextID(),
+		Username:      name,
+		Discriminator: strconv.Itoa(1000 + len(s.users)),
+		Bot:           isbot,
+	}
+	s.users[u.ID] = u
+	return copyUser(u)
+}
+
+// AddGuild creates a new guild owned by the given user. Every guild starts out with an @everyone role, a role for the
+// bot that has all the permissions it needs, and both the bot and the owner as members.
+func (s *Server) AddGuild(name string, owner *discordgo.User) *discordgo.Guild {
+	s.lock.Lock()
+	id := s.nextID()
+	g := &discordgo.Guild{
+		ID:                id,
+		Name:              name,
+		OwnerID:           owner.ID,
+		VerificationLevel: discordgo.VerificationLevelNone,
+		Roles: []*discordgo.Role{
+			{ID: id, Name: "@everyone", Permissions: discordgo.PermissionAllText},
+		},
+		Channels: []*discordgo.Channel{},
+		Members:  []*discordgo.Member{},
+	}
+	botrole := &discordgo.Role{
+		ID:          s.nextID(),
+		Name:        s.Self.Username,
+		Position:    1,
+		Permissions: discordgo.PermissionAllText | discordgo.PermissionManageRoles | discordgo.PermissionManageMessages | discordgo.PermissionManageChannels | discordgo.PermissionManageServer | discordgo.PermissionBanMembers,
+	}
+	g.Roles = append(g.Roles, botrole)
+	g.Members = append(g.Members, s.newMember(id, s.Self.ID, botrole.ID), s.newMember(id, owner.ID))
+	s.guilds[id] = g
+	s.bans[id] = make(map[string]string)
+	s.order = append(s.order, id)
+	r := copyGuild(g)
+	s.lock.Unlock()
+
+	s.dispatch("GUILD_CREATE", r)
+	return r
+}
+
+// AddRole creates a new role in a guild with the given permissions
+func (s *Server) AddRole(guildID string, name string, perms int) *discordgo.Role {
+	s.lock.Lock()
+	g := s.guilds[guildID]
+	role := &discordgo.Role{ID: s.nextID(), Name: name, Permissions: perms, Mentionable: true}
+	g.Roles = append(g.Roles, role)
+	r := *role
+	s.lock.Unlock()
+
+	s.dispatch("GUILD_ROLE_CREATE", map[string]interface{}{"guild_id": guildID, "role": r})
+	return &r
+}
+
+// AddChannel creates a new text channel in a guild
+func (s *Server) AddChannel(guildID string, name string) *discordgo.Channel {
+	s.lock.Lock()
+	r := s.addChannel(guildID, name, discordgo.ChannelTypeGuildText, "")
+	s.lock.Unlock()
+
+	s.dispatch("CHANNEL_CREATE", r)
+	return r
+}
+
+// AddVoiceChannel creates a new voice channel in a guild
+func (s *Server) AddVoiceChannel(guildID string, name string) *discordgo.Channel {
+	s.lock.Lock()
+	r := s.addChannel(guildID, name, discordgo.ChannelTypeGuildVoice, "")
+	s.lock.Unlock()
+
+	s.dispatch("CHANNEL_CREATE", r)
+	return r
+}
+
+// addChannel creates a channel and returns a copy of it. The caller must hold the lock.
+func (s *Server) addChannel(guildID string, name string, ty discordgo.ChannelType, parentID string) *discordgo.Channel {
+	ch := &discordgo.Channel{
+		ID:                   s.nextID(),
+		GuildID:              guildID,
+		Name:                 name,
+		Type:                 ty,
+		ParentID:             parentID,
+		PermissionOverwrites: []*discordgo.PermissionOverwrite{},
+	}
+	g := s.guilds[guildID]
+	g.Channels = append(g.Channels, ch)
+	s.channels[ch.ID] = ch
+	return copyChannel(ch)
+}
+
+// AddMember adds a user to a guild with the given roles
+func (s *Server) AddMember(guildID string, user *discordgo.User, roles ...string) *discordgo.Member {
+	s.lock.Lock()
+	g := s.guilds[guildID]
+	m := s.newMember(guildID, user.ID, roles...)
+	g.Members = append(g.Members, m)
+	r := copyMember(m)
+	s.lock.Unlock()
+
+	s.dispatch("GUILD_MEMBER_ADD", r)
+	return r
+}
+
+// RemoveMember makes a user leave a guild
+func (s *Server) RemoveMember(guildID string, userID string) {
+	s.lock.Lock()
+	m := s.removeMember(guildID, userID)
+	s.lock.Unlock()
+	if m != nil {
+		s.dispatch("GUILD_MEMBER_REMOVE", m)
+	}
+}
+
+func (s *Server) newMember(guildID string, userID string, roles ...string) *discordgo.Member {
+	return &discordgo.Member{
+		GuildID:  guildID,
+		JoinedAt: time.Now().UTC().Format(time.RFC3339),
+		User:     copyUser(s.users[userID]),
+		Roles:    append([]string{}, roles...),
+	}
+}
+
+// getMember returns the server's copy of a member, or nil. The caller must hold the lock.
+func (s *Server) getMember(guildID string, userID string) *discordgo.Member {
+	if g, ok := s.guilds[guildID]; ok {
+		for _, m := range g.Members {
+			if m.User.ID == userID {
+				return m
+			}
+		}
+	}
+	return nil
+}
+
+// removeMember removes a member from a guild and returns a copy of them, or nil. The caller must hold the lock.
+func (s *Server) removeMember(guildID string, userID string) *discordgo.Member {
+	if g, ok := s.guilds[guildID]; ok {
+		for i, m := range g.Members {
+			if m.User.ID == userID {
+				g.Members = append(g.Members[:i], g.Members[i+1:]...)
+				return copyMember(m)
+			}
+		}
+	}
+	return nil
+}
+
+// Send posts a message from the given user on a channel, as if they had typed it into discord
+func (s *Server) Send(channelID string, userID string, content string) *discordgo.Message {
+	s.lock.Lock()
+	m := s.newMessage(channelID, s.users[userID], content)
+	r := copyMessage(m)
+	s.lock.Unlock()
+
+	s.dispatch("MESSAGE_CREATE", r)
+	return r
+}
+
+// React adds a reaction from the given user to a message, as if they had clicked on it in discord
+func (s *Server) React(channelID string, messageID string, userID string, emoji string) {
+	s.lock.Lock()
+	guildID := ""
+	if ch, ok := s.channels[channelID]; ok {
+		guildID = ch.GuildID
+	}
+	s.lock.Unlock()
+
+	s.dispatch("MESSAGE_REACTION_ADD", map[string]interface{}{
+		"user_id":    userID,
+		"message_id": messageID,
+		"channel_id": channelID,
+		"guild_id":   guildID,
+		"emoji":      map[string]interface{}{"name": emoji},
+	})
+}
+
+// SendFile posts a message with a file attached from the given user, as if they had uploaded it into discord
+func (s *Server) SendFile(channelID string, userID string, content string, name string, data []byte) *discordgo.Message {
+	s.lock.Lock()
+	m := s.newMessage(channelID, s.users[userID], content)
+	s.attach(m, name, data)
+	r := copyMessage(m)
+	s.lock.Unlock()
+
+	s.dispatch("MESSAGE_CREATE", r)
+	return r
+}
+
+// File returns the contents of an attachment, given its URL, or nil if there is no such attachment
+func (s *Server) File(fileURL string) []byte {
+	u, err := url.Parse(fileURL)
+	if err != nil {
+		return nil
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.files[u.Path]
+}
+
+// OAuthCode returns a code that logs in as the given user, as if they had authorized the bot's application on discord's
+// OAuth2 page and been sent back to the bot with it
+func (s *Server) OAuthCode(userID string) string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	code := "code" + s.nextID()
+	s.oauth[code] = userID
+	return code
+}
+
+// attach adds a file to a message and makes it available for download. The caller must hold the lock.
+func (s *Server) attach(m *discordgo.Message, name string, data []byte) {
+	id := s.nextID()
+	path := "/attachments/" + m.ChannelID + "/" + id + "/" + name
+	s.files[path] = data
+	m.Attachments = append(m.Attachments, &discordgo.MessageAttachment{ID: id, URL: s.URL + path, ProxyURL: s.URL + path, Filename: name, Size: len(data)})
+}
+
+func (s *Server) serveAttachment(w http.ResponseWriter, path string) {
+	s.lock.Lock()
+	data, ok := s.files[path]
+	s.lock.Unlock()
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(data)
+}
+
+// Edit changes the content of a message, as if its author had edited it in discord
+func (s *Server) Edit(channelID string, messageID string, content string) *discordgo.Message {
+	s.lock.Lock()
+	var m *discordgo.Message
+	for _, v := range s.history[channelID] {
+		if v.ID == messageID {
+			m = v
+		}
+	}
+	if m == nil {
+		s.lock.Unlock()
+		return nil
+	}
+	m.Content = content
+	m.EditedTimestamp = timestamp()
+	r := copyMessage(m)
+	s.lock.Unlock()
+
+	s.dispatch("MESSAGE_UPDATE", r)
+	return r
+}
+
+// Delete removes a message, as if its author had deleted it in discord
+func (s *Server) Delete(channelID string, messageID string) {
+	s.lock.Lock()
+	history := s.history[channelID][:0]
+	for _, m := range s.history[channelID] {
+		if m.ID != messageID {
+			history = append(history, m)
+		}
+	}
+	s.history[channelID] = history
+	s.lock.Unlock()
+
+	s.dispatch("MESSAGE_DELETE", map[string]interface{}{"id": messageID, "channel_id": channelID})
+}
+
+// SetNick changes a member's nickname, as if they had changed it themselves
+func (s *Server) SetNick(guildID string, userID string, nick string) {
+	s.lock.Lock()
+	m := s.getMember(guildID, userID)
+	if m == nil {
+		s.lock.Unlock()
+		return
+	}
+	m.Nick = nick
+	r := copyMember(m)
+	s.lock.Unlock()
+
+	s.dispatch("GUILD_MEMBER_UPDATE", r)
+}
+
+// SetVoice moves a member into a voice channel, as if they had joined it themselves. An empty channel ID disconnects
+// them from voice.
+func (s *Server) SetVoice(guildID string, userID string, channelID string) {
+	s.lock.Lock()
+	v := s.setVoice(guildID, userID, channelID)
+	s.lock.Unlock()
+
+	s.dispatch("VOICE_STATE_UPDATE", v)
+}
+
+// setVoice replaces a member's voice state and returns a copy of the new one. The caller must hold the lock.
+func (s *Server) setVoice(guildID string, userID string, channelID string) *discordgo.VoiceState {
+	g := s.guilds[guildID]
+	states := g.VoiceStates[:0]
+	for _, v := range g.VoiceStates {
+		if v.UserID != userID {
+			states = append(states, v)
+		}
+	}
+	v := &discordgo.VoiceState{GuildID: guildID, ChannelID: channelID, UserID: userID, SessionID: "session" + userID}
+	if len(channelID) > 0 {
+		states = append(states, v)
+	}
+	g.VoiceStates = states
+	r := *v
+	return &r
+}
+
+// Voice returns the ID of the voice channel a member is in, or an empty string if they aren't in one
+func (s *Server) Voice(guildID string, userID string) string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if g, ok := s.guilds[guildID]; ok {
+		for _, v := range g.VoiceStates {
+			if v.UserID == userID {
+				return v.ChannelID
+			}
+		}
+	}
+	return ""
+}
+
+// Channel returns a channel, or nil if it doesn't exist
+func (s *Server) Channel(channelID string) *discordgo.Channel {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if ch, ok := s.channels[channelID]; ok {
+		return copyChannel(ch)
+	}
+	return nil
+}
+
+// Interact uses a slash command as the given user, as if they had picked it from discord's command list, and returns
+// the ID of the interaction. Options are sent in the order the bot registered them, regardless of map order.
+func (s *Server) Interact(channelID string, userID string, name string, options map[string]string) string {
+	s.lock.Lock()
+	ch := s.channels[channelID]
+	i := &interaction{id: s.nextID(), guild: ch.GuildID, channel: channelID}
+	token := "token" + i.id
+	s.tokens[token] = i
+	values := []map[string]interface{}{}
+	for _, c := range s.commands[ch.GuildID] {
+		if c.Name == name {
+			for _, o := range c.Options {
+				if v, ok := options[o.Name]; ok {
+					values = append(values, map[string]interface{}{"name": o.Name, "type": o.Type, "value": v})
+				}
+			}
+		}
+	}
+	event := map[string]interface{}{
+		"id":             i.id,
+		"application_id": s.Self.ID,
+		"type":           2,
+		"data":           map[string]interface{}{"id": s.nextID(), "name": name, "options": values},
+		"guild_id":       ch.GuildID,
+		"channel_id":     channelID,
+		"member":         copyMember(s.getMember(ch.GuildID, userID)),
+		"token":          token,
+	}
+	s.lock.Unlock()
+
+	s.dispatch("INTERACTION_CREATE", event)
+	return i.id
+}
+
+// Commands returns the slash commands the bot has registered on a guild
+func (s *Server) Commands(guildID string) []Command {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]Command{}, s.commands[guildID]...)
+}
+
+// newMessage creates a message and appends it to the channel history. The caller must hold the lock.
+func (s *Server) newMessage(channelID string, author *discordgo.User, content string) *discordgo.Message {
+	m := &discordgo.Message{
+		ID:        s.nextID(),
+		ChannelID: channelID,
+		Content:   content,
+		Timestamp: timestamp(),
+		Author:    copyUser(author),
+		Mentions:  []*discordgo.User{},
+		Embeds:    []*discordgo.MessageEmbed{},
+		Type:      discordgo.MessageTypeDefault,
+	}
+	for _, match := range mentionRegex.FindAllStringSubmatch(content, -1) {
+		if u, ok := s.users[match[1]]; ok {
+			m.Mentions = append(m.Mentions, copyUser(u))
+		}
+	}
+	s.history[channelID] = append(s.history[channelID], m)
+	return m
+}
+
+// Member returns the current state of a guild member, or nil if they aren't in the guild
+func (s *Server) Member(guildID string, userID string) *discordgo.Member {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if m := s.getMember(guildID, userID); m != nil {
+		return copyMember(m)
+	}
+	return nil
+}
+
+// HasRole returns true if the guild member currently has the given role
+func (s *Server) HasRole(guildID string, userID string, roleID string) bool {
+	if m := s.Member(guildID, userID); m != nil {
+		for _, r := range m.Roles {
+			if r == roleID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Banned returns true if the user is banned from the guild
+func (s *Server) Banned(guildID string, userID string) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	_, ok := s.bans[guildID][userID]
+	return ok
+}
+
+// Guild returns the current state of a guild, or nil if it doesn't exist
+func (s *Server) Guild(guildID string) *discordgo.Guild {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if g, ok := s.guilds[guildID]; ok {
+		return copyGuild(g)
+	}
+	return nil
+}
+
+// Messages returns every message in a channel that hasn't been deleted, oldest first
+func (s *Server) Messages(channelID string) []*discordgo.Message {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	r := make([]*discordgo.Message, 0, len(s.history[channelID]))
+	for _, m := range s.history[channelID] {
+		r = append(r, copyMessage(m))
+	}
+	return r
+}
+
+// Actions returns every action the bot has taken so far, in order
+func (s *Server) Actions() []Action {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]Action{}, s.actions...)
+}
+
+// WaitFor waits until an action that satisfies match is recorded, skipping the first n actions. Pass len(Actions())
+// before sending a message to only look at how the bot responded to that message.
+func (s *Server) WaitFor(n int, timeout time.Duration, match func(Action) bool) (Action, bool) {
+	deadline := time.After(timeout)
+	for {
+		s.lock.Lock()
+		for ; n < len(s.actions); n++ {
+			if match(s.actions[n]) {
+				a := s.actions[n]
+				s.lock.Unlock()
+				return a, true
+			}
+		}
+		notify := s.notify
+		s.lock.Unlock()
+
+		select {
+		case <-notify:
+		case <-deadline:
+			return Action{}, false
+		}
+	}
+}
+
+// Say sends a message from the given user and waits for the bot to send a message in the same channel, returning
+// the first one it sends, or nil if it never responds.
+func (s *Server) Say(channelID string, userID string, content string, timeout time.Duration) *discordgo.Message {
+	n := len(s.Actions())
+	s.Send(channelID, userID, content)
+	a, ok := s.WaitFor(n, timeout, func(a Action) bool { return a.Type == ActionSend && a.Channel == channelID })
+	if !ok {
+		return nil
+	}
+	return a.Message
+}
+
+func copyUser(u *discordgo.User) *discordgo.User {
+	if u == nil {
+		return nil
+	}
+	r := *u
+	return &r
+}
+
+func copyMember(m *discordgo.Member) *discordgo.Member {
+	r := *m
+	r.User = copyUser(m.User)
+	r.Roles = append([]string{}, m.Roles...)
+	return &r
+}
+
+func copyChannel(ch *discordgo.Channel) *discordgo.Channel {
+	r := *ch
+	r.PermissionOverwrites = make([]*discordgo.PermissionOverwrite, len(ch.PermissionOverwrites))
+	for i, v := range ch.PermissionOverwrites {
+		o := *v
+		r.PermissionOverwrites[i] = &o
+	}
+	return &r
+}
+
+func copyMessage(m *discordgo.Message) *discordgo.Message {
+	r := *m
+	r.Author = copyUser(m.Author)
+	r.Mentions = append([]*discordgo.User{}, m.Mentions...)
+	r.Embeds = append([]*discordgo.MessageEmbed{}, m.Embeds...)
+	r.Attachments = append([]*discordgo.MessageAttachment{}, m.Attachments...)
+	return &r
+}
+
+func copyGuild(g *discordgo.Guild) *discordgo.Guild {
+	r := *g
+	r.Roles = make([]*discordgo.Role, len(g.Roles))
+	for i, v := range g.Roles {
+		role := *v
+		r.Roles[i] = &role
+	}
+	r.Channels = make([]*discordgo.Channel, len(g.Channels))
+	for i, v := range g.Channels {
+		r.Channels[i] = copyChannel(v)
+	}
+	r.Members = make([]*discordgo.Member, len(g.Members))
+	for i, v := range g.Members {
+		r.Members[i] = copyMember(v)
+	}
+	r.VoiceStates = make([]*discordgo.VoiceState, len(g.VoiceStates))
+	for i, v := range g.VoiceStates {
+		state := *v
+		r.VoiceStates[i] = &state
+	}
+	return &r
+}
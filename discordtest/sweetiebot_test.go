@@ -0,0 +1,939 @@
+package discordtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"../boredmodule"
+	"../bucketmodule"
+	"../countersmodule"
+	"../customcommandsmodule"
+	"../filtermodule"
+	"../loggingmodule"
+	"../markovmodule"
+	"../miscmodule"
+	"../quotemodule"
+	"../rolesmodule"
+	"../schedulermodule"
+	"../spammodule"
+	"../statusmodule"
+	bot "../sweetiebot"
+	"../tagmodule"
+	"../usersmodule"
+	"../voicemodule"
+	"../wittymodule"
+	"github.com/blackhole12/discordgo"
+)
+
+const timeout = 5 * time.Second
+
+func Check(result interface{}, expected interface{}, t *testing.T) bool {
+	if result != expected {
+		_, fn, line, _ := runtime.Caller(1)
+		fmt.Printf("[%s:%v] Expected %v but got %v\n", filepath.Base(fn), line, expected, result)
+		t.Fail()
+		return false
+	}
+	return true
+}
+func CheckNot(result interface{}, expected interface{}, t *testing.T) bool {
+	if result == expected {
+		_, fn, line, _ := runtime.Caller(1)
+		fmt.Printf("[%s:%v] Unexpected result: %v\n", filepath.Base(fn), line, result)
+		t.Fail()
+		return false
+	}
+	return true
+}
+
+func loader(guild *bot.GuildInfo) []bot.Module {
+	modules := make([]bot.Module, 0, 20)
+	modules = append(modules, &bot.InfoModule{})
+	modules = append(modules, &bot.ConfigModule{})
+	modules = append(modules, &bot.DebugModule{})
+	modules = append(modules, statusmodule.New())
+	modules = append(modules, usersmodule.New())
+	modules = append(modules, tagmodule.New())
+	modules = append(modules, schedulermodule.New())
+	modules = append(modules, rolesmodule.New())
+	modules = append(modules, markovmodule.New())
+	modules = append(modules, quotemodule.New())
+	modules = append(modules, bucketmodule.New())
+	modules = append(modules, boredmodule.New())
+	modules = append(modules, miscmodule.New())
+	modules = append(modules, countersmodule.New())
+	modules = append(modules, wittymodule.New(guild))
+	modules = append(modules, spammodule.New())
+	modules = append(modules, filtermodule.New(guild))
+	modules = append(modules, loggingmodule.New(guild))
+	modules = append(modules, voicemodule.New(guild))
+	modules = append(modules, customcommandsmodule.New(guild))
+	return modules
+}
+
+// harness runs a bot backed by an SQLite database against a fake server with one guild that has already been set up.
+type harness struct {
+	t        *testing.T
+	server   *Server
+	bot      *bot.SweetieBot
+	hostfile []byte
+	guild    *discordgo.Guild
+	general  *discordgo.Channel
+	modch    *discordgo.Channel
+	logch    *discordgo.Channel
+	modrole  *discordgo.Role
+	silence  *discordgo.Role
+	mod      *discordgo.User
+	user     *discordgo.User
+}
+
+func newHarness(t *testing.T) *harness {
+	s := NewServer()
+	h := &harness{t: t, server: s}
+	h.guild = s.AddGuild("Test Server", s.Owner)
+	h.modrole = s.AddRole(h.guild.ID, "Mods", discordgo.PermissionAllText|discordgo.PermissionManageMessages)
+	h.silence = s.AddRole(h.guild.ID, "Silence", 0)
+	h.general = s.AddChannel(h.guild.ID, "general")
+	h.modch = s.AddChannel(h.guild.ID, "staff")
+	h.logch = s.AddChannel(h.guild.ID, "bot-log")
+	h.mod = s.AddUser("Cheerilee", false)
+	s.AddMember(h.guild.ID, h.mod, h.modrole.ID)
+	h.user = s.AddUser("Scootaloo", false)
+	s.AddMember(h.guild.ID, h.user)
+
+	// The database is a file instead of :memory: so it's still there if the test restarts the bot
+	h.hostfile, _ = json.Marshal(map[string]interface{}{
+		"token":          "fake",
+		"dbdriver":       bot.DriverSQLite,
+		"dbauth":         h.guild.ID + ".db",
+		"mainguildid":    h.guild.ID,
+		"maxconfigsize":  1000000,
+		"maxuniqueitems": 25000,
+		"clientsecret":   "secret",
+	})
+	h.bot = h.create()
+
+	// Modules register their config sections when the bot is created, so the config has to be written afterwards
+	config := bot.DefaultConfig()
+	config.Version = bot.ConfigVersion
+	config.SetupDone = true
+	config.Basic.ModRole = bot.DiscordRole(h.modrole.ID)
+	config.Basic.ModChannel = bot.DiscordChannel(h.modch.ID)
+	config.Basic.SilenceRole = bot.DiscordRole(h.silence.ID)
+	config.Log.Channel = bot.DiscordChannel(h.logch.ID)
+	config.Spam.RaidSize = 0              // Everyone on the test server joined moments ago, which would otherwise look like a raid
+	config.Modules.CommandPerDuration = 0 // Tests run commands much faster than any real server would
+	config.FillConfig()                   // The bot never saves empty maps as null, so neither should we
+	data, _ := json.Marshal(config)
+	if err := ioutil.WriteFile(h.guild.ID+".json", data, 0664); err != nil {
+		h.Close()
+		t.Fatal(err)
+	}
+
+	h.start()
+	return h
+}
+
+// create makes a new bot from the harness's selfhost file
+func (h *harness) create() *bot.SweetieBot {
+	sb := bot.NewFromSelfhost("", h.hostfile, loader)
+	if sb == nil {
+		h.Close()
+		h.t.Fatal("Failed to create bot")
+	}
+	sb.TickInterval = 100 * time.Millisecond
+	return sb
+}
+
+// start connects the bot to the server and waits for it to load the guild
+func (h *harness) start() {
+	n := len(h.server.Actions())
+	h.server.Attach(&h.bot.DG.Session)
+	if err := h.bot.Start(); err != nil {
+		h.Close()
+		h.t.Fatal(err)
+	}
+
+	// The bot always announces itself on the log channel once it has finished loading a guild
+	if _, ok := h.server.WaitFor(n, timeout, func(a Action) bool {
+		return a.Type == ActionSend && a.Channel == h.logch.ID && strings.Contains(a.Message.Content, "successfully loaded")
+	}); !ok {
+		h.Close()
+		h.t.Fatal("Bot never finished loading the guild")
+	}
+}
+
+// restart stops the bot and starts a new one with the same database and config, just like restarting a selfhost. It
+// returns the number of actions the server had recorded when the old bot stopped.
+func (h *harness) restart() int {
+	h.bot.Stop()
+	h.bot = nil
+	n := len(h.server.Actions())
+	h.bot = h.create()
+	h.start()
+	return n
+}
+
+func (h *harness) Close() {
+	if h.bot != nil {
+		h.bot.Stop()
+	}
+	h.server.Close()
+	os.Remove(h.guild.ID + ".json")
+	os.Remove(h.guild.ID + ".state.json")
+	os.Remove(h.guild.ID + ".db")
+}
+
+func TestConnect(t *testing.T) {
+	h := newHarness(t)
+	defer h.Close()
+
+	Check(h.bot.SelfID.String(), h.server.Self.ID, t)
+	Check(h.bot.Owner.String(), h.server.Owner.ID, t)
+	m := h.server.Say(h.general.ID, h.user.ID, "!about", timeout)
+	if m == nil {
+		t.Fatal("Bot never responded to !about")
+	}
+	Check(len(m.Embeds), 1, t)
+}
+
+func TestTagConversation(t *testing.T) {
+	h := newHarness(t)
+	defer h.Close()
+
+	m := h.server.Say(h.general.ID, h.mod.ID, "!new cute", timeout)
+	CheckNot(m, (*discordgo.Message)(nil), t)
+	m = h.server.Say(h.general.ID, h.mod.ID, "!add cute Sweetie Belle", timeout)
+	CheckNot(m, (*discordgo.Message)(nil), t)
+	m = h.server.Say(h.general.ID, h.user.ID, "!pick cute", timeout)
+	if m == nil {
+		t.Fatal("Bot never responded to !pick")
+	}
+	Check(m.Content, "Sweetie Belle", t)
+}
+
+func TestTemporaryBan(t *testing.T) {
+	h := newHarness(t)
+	defer h.Close()
+
+	n := len(h.server.Actions())
+	h.server.Send(h.general.ID, h.mod.ID, "!ban <@"+h.user.ID+"> for: 1 second being rude")
+	ban, ok := h.server.WaitFor(n, timeout, func(a Action) bool { return a.Type == ActionBan })
+	if !ok {
+		t.Fatal("User was never banned")
+	}
+	Check(ban.User, h.user.ID, t)
+	Check(h.server.Banned(h.guild.ID, h.user.ID), true, t)
+	Check(h.server.Member(h.guild.ID, h.user.ID), (*discordgo.Member)(nil), t)
+
+	// The scheduler module should lift the ban on its own once the duration has passed
+	if _, ok = h.server.WaitFor(n, timeout, func(a Action) bool { return a.Type == ActionUnban && a.User == h.user.ID }); !ok {
+		t.Fatal("User was never unbanned")
+	}
+	Check(h.server.Banned(h.guild.ID, h.user.ID), false, t)
+}
+
+func TestModerationCases(t *testing.T) {
+	h := newHarness(t)
+	defer h.Close()
+
+	m := h.server.Say(h.general.ID, h.mod.ID, "!note <@"+h.user.ID+"> keeps posting memes", timeout)
+	if m == nil {
+		t.Fatal("Bot never responded to !note")
+	}
+	Check(strings.Contains(m.Content, "case #1"), true, t)
+	m = h.server.Say(h.general.ID, h.mod.ID, "!silence <@"+h.user.ID+">", timeout)
+	if m == nil {
+		t.Fatal("Bot never responded to !silence")
+	}
+	Check(strings.Contains(m.Content, "case #2"), true, t)
+	h.server.Say(h.general.ID, h.mod.ID, "!reason 2 memes in #general", timeout)
+
+	m = h.server.Say(h.general.ID, h.mod.ID, "!case 2", timeout)
+	if m == nil {
+		t.Fatal("Bot never responded to !case")
+	}
+	Check(strings.Contains(m.Content, "Silence"), true, t)
+	Check(strings.Contains(m.Content, "memes in #general"), true, t)
+	Check(strings.Contains(m.Content, "/channels/"+h.guild.ID+"/"+h.general.ID+"/"), true, t)
+
+	m = h.server.Say(h.general.ID, h.mod.ID, "!cases <@"+h.user.ID+">", timeout)
+	if m == nil {
+		t.Fatal("Bot never responded to !cases")
+	}
+	Check(strings.Contains(m.Content, "#1"), true, t)
+	Check(strings.Contains(m.Content, "#2"), true, t)
+}
+
+func TestWarnings(t *testing.T) {
+	h := newHarness(t)
+	defer h.Close()
+	gID := bot.SBatoi(h.guild.ID)
+
+	m := h.server.Say(h.general.ID, h.mod.ID, "!warn <@"+h.user.ID+"> 2 posting memes", timeout)
+	if m == nil {
+		t.Fatal("Bot never responded to !warn")
+	}
+	Check(strings.Contains(m.Content, "2 warning points"), true, t)
+	Check(h.server.HasRole(h.guild.ID, h.user.ID, h.silence.ID), false, t)
+
+	m = h.server.Say(h.general.ID, h.mod.ID, "!warn <@"+h.user.ID+"> still posting memes", timeout)
+	if m == nil {
+		t.Fatal("Bot never responded to the second !warn")
+	}
+	Check(strings.Contains(m.Content, "3 warning points"), true, t)
+	Check(strings.Contains(m.Content, "silenced"), true, t)
+	Check(h.server.HasRole(h.guild.ID, h.user.ID, h.silence.ID), true, t)
+	CheckNot(h.bot.DB.GetScheduleDate(gID, 8, h.user.ID), (*time.Time)(nil), t)
+
+	m = h.server.Say(h.general.ID, h.mod.ID, "!warn <@"+h.user.ID+"> 2 evading the silence", timeout)
+	if m == nil {
+		t.Fatal("Bot never responded to the third !warn")
+	}
+	Check(strings.Contains(m.Content, "banned"), true, t)
+	Check(h.server.Banned(h.guild.ID, h.user.ID), true, t)
+	CheckNot(h.bot.DB.GetScheduleDate(gID, 0, h.user.ID), (*time.Time)(nil), t)
+
+	m = h.server.Say(h.general.ID, h.mod.ID, "!cases <@"+h.user.ID+">", timeout)
+	if m == nil {
+		t.Fatal("Bot never responded to !cases")
+	}
+	Check(strings.Contains(m.Content, "Warn"), true, t)
+	Check(strings.Contains(m.Content, "Ban"), true, t)
+}
+
+func TestSpamSilence(t *testing.T) {
+	h := newHarness(t)
+	defer h.Close()
+
+	n := len(h.server.Actions())
+	for i := 0; i < 8; i++ {
+		h.server.Send(h.general.ID, h.user.ID, "buy cheap bits")
+	}
+	if _, ok := h.server.WaitFor(n, timeout, func(a Action) bool {
+		return a.Type == ActionRoleAdd && a.User == h.user.ID && a.Role == h.silence.ID
+	}); !ok {
+		t.Fatal("Spammer was never silenced")
+	}
+	Check(h.server.HasRole(h.guild.ID, h.user.ID, h.silence.ID), true, t)
+	if _, ok := h.server.WaitFor(n, timeout, func(a Action) bool { return a.Type == ActionDelete && a.Channel == h.general.ID }); !ok {
+		t.Fatal("Spam was never deleted")
+	}
+	if len(h.server.Messages(h.general.ID)) >= 8 {
+		t.Error("Spam messages were not removed from the channel")
+	}
+}
+
+func TestShutdownState(t *testing.T) {
+	h := newHarness(t)
+	defer h.Close()
+
+	h.server.Send(h.general.ID, h.user.ID, "hello")
+	h.server.Send(h.general.ID, h.user.ID, "is anyone here")
+	h.server.Send(h.general.ID, h.user.ID, "guess not")
+	if m := h.server.Say(h.general.ID, h.user.ID, "!about", timeout); m == nil {
+		t.Fatal("Bot never responded to !about")
+	}
+	h.bot.Stop()
+	h.bot = nil
+
+	data, err := ioutil.ReadFile(h.guild.ID + ".state.json")
+	if err != nil {
+		t.Fatal("Spam pressure was never saved: ", err)
+	}
+	state := struct {
+		Modules struct {
+			Spam struct {
+				Lockdown int
+				Pressure map[string]json.RawMessage
+			}
+		}
+	}{}
+	Check(json.Unmarshal(data, &state), nil, t)
+	Check(state.Modules.Spam.Lockdown, -1, t)
+	_, ok := state.Modules.Spam.Pressure[h.user.ID]
+	Check(ok, true, t)
+}
+
+func TestSilenceTimeout(t *testing.T) {
+	h := newHarness(t)
+	defer h.Close()
+
+	h.server.Say(h.general.ID, h.mod.ID, "!setconfig spam.silencetimeout 1", timeout)
+	n := len(h.server.Actions())
+	for i := 0; i < 8; i++ {
+		h.server.Send(h.general.ID, h.user.ID, "buy cheap bits")
+	}
+	if _, ok := h.server.WaitFor(n, timeout, func(a Action) bool {
+		return a.Type == ActionRoleAdd && a.User == h.user.ID && a.Role == h.silence.ID
+	}); !ok {
+		t.Fatal("Spammer was never silenced")
+	}
+	// The timeout is handed to the scheduler, which unsilences them once it expires
+	if _, ok := h.server.WaitFor(n, timeout, func(a Action) bool {
+		return a.Type == ActionRoleRemove && a.User == h.user.ID && a.Role == h.silence.ID
+	}); !ok {
+		t.Fatal("Spammer was never unsilenced")
+	}
+}
+
+func TestSilenceTimeoutRestart(t *testing.T) {
+	h := newHarness(t)
+	defer h.Close()
+
+	h.server.Say(h.general.ID, h.mod.ID, "!setconfig spam.silencetimeout 3", timeout)
+	n := len(h.server.Actions())
+	for i := 0; i < 8; i++ {
+		h.server.Send(h.general.ID, h.user.ID, "buy cheap bits")
+	}
+	if _, ok := h.server.WaitFor(n, timeout, func(a Action) bool {
+		return a.Type == ActionRoleAdd && a.User == h.user.ID && a.Role == h.silence.ID
+	}); !ok {
+		t.Fatal("Spammer was never silenced")
+	}
+
+	// The timeout is kept in the database, so the new bot still lifts it
+	n = h.restart()
+	if _, ok := h.server.WaitFor(n, timeout, func(a Action) bool {
+		return a.Type == ActionRoleRemove && a.User == h.user.ID && a.Role == h.silence.ID
+	}); !ok {
+		t.Fatal("Spammer was never unsilenced after the bot restarted")
+	}
+}
+
+func TestSilenceTimeoutSchedulerDisabled(t *testing.T) {
+	h := newHarness(t)
+	defer h.Close()
+
+	h.server.Say(h.general.ID, h.mod.ID, "!setconfig spam.silencetimeout 60", timeout)
+	h.server.Say(h.general.ID, h.mod.ID, "!disable scheduler", timeout)
+	n := len(h.server.Actions())
+	for i := 0; i < 8; i++ {
+		h.server.Send(h.general.ID, h.user.ID, "buy cheap bits")
+	}
+	alert, ok := h.server.WaitFor(n, timeout, func(a Action) bool {
+		return a.Type == ActionSend && a.Channel == h.modch.ID && strings.Contains(a.Message.Content, "was silenced")
+	})
+	if !ok {
+		t.Fatal("Moderators were never alerted")
+	}
+	Check(strings.Contains(alert.Message.Content, "Scheduler module is disabled"), true, t)
+}
+
+func TestTemporarySilence(t *testing.T) {
+	h := newHarness(t)
+	defer h.Close()
+
+	m := h.server.Say(h.general.ID, h.mod.ID, "!silence <@"+h.user.ID+"> for: 2 hours posting memes", timeout)
+	if m == nil {
+		t.Fatal("Bot never responded to !silence")
+	}
+	Check(strings.Contains(m.Content, "because posting memes"), true, t)
+	Check(h.server.HasRole(h.guild.ID, h.user.ID, h.silence.ID), true, t)
+	date := h.bot.DB.GetScheduleDate(bot.SBatoi(h.guild.ID), 8, h.user.ID)
+	if date == nil {
+		t.Fatal("No unsilence event was scheduled")
+	}
+	if d := date.Sub(time.Now().UTC()); d < 119*time.Minute || d > 121*time.Minute {
+		t.Errorf("Unsilence was scheduled %v from now instead of 2 hours", d)
+	}
+
+	m = h.server.Say(h.general.ID, h.mod.ID, "!schedule silences", timeout)
+	if m == nil {
+		t.Fatal("Bot never responded to !schedule")
+	}
+	Check(len(m.Embeds), 1, t)
+	Check(strings.Contains(m.Embeds[0].Description, "UNSILENCE"), true, t)
+}
+
+func TestLogging(t *testing.T) {
+	h := newHarness(t)
+	defer h.Close()
+
+	logged := func(n int, what string, match func(e *discordgo.MessageEmbed) bool) *discordgo.MessageEmbed {
+		a, ok := h.server.WaitFor(n, timeout, func(a Action) bool {
+			return a.Type == ActionSend && a.Channel == h.logch.ID && len(a.Message.Embeds) > 0 && match(a.Message.Embeds[0])
+		})
+		if !ok {
+			t.Fatal("Never logged " + what)
+		}
+		return a.Message.Embeds[0]
+	}
+	field := func(e *discordgo.MessageEmbed, name string) string {
+		for _, f := range e.Fields {
+			if f.Name == name {
+				return f.Value
+			}
+		}
+		return ""
+	}
+
+	m := h.server.Send(h.general.ID, h.user.ID, "ponies are great")
+	h.server.Say(h.general.ID, h.user.ID, "!about", timeout) // Make sure the bot has seen the message before editing it
+	n := len(h.server.Actions())
+	h.server.Edit(h.general.ID, m.ID, "ponies are the best")
+	e := logged(n, "the edit", func(e *discordgo.MessageEmbed) bool { return strings.Contains(e.Description, "edited") })
+	Check(field(e, "Before"), "ponies are great", t)
+	Check(field(e, "After"), "ponies are the best", t)
+
+	n = len(h.server.Actions())
+	h.server.Delete(h.general.ID, m.ID)
+	e = logged(n, "the delete", func(e *discordgo.MessageEmbed) bool { return strings.Contains(e.Description, "deleted") })
+	Check(field(e, "Content"), "ponies are the best", t)
+
+	n = len(h.server.Actions())
+	h.server.SetNick(h.guild.ID, h.user.ID, "Scoots")
+	e = logged(n, "the nickname change", func(e *discordgo.MessageEmbed) bool { return strings.Contains(e.Description, "nickname") })
+	Check(field(e, "After"), "Scoots", t)
+
+	// Excluded channels aren't logged at all
+	h.server.Say(h.general.ID, h.mod.ID, "!setconfig modules.channels logging ! #general", timeout)
+	m = h.server.Send(h.general.ID, h.user.ID, "secret")
+	h.server.Say(h.modch.ID, h.mod.ID, "!about", timeout)
+	n = len(h.server.Actions())
+	h.server.Delete(h.general.ID, m.ID)
+	h.server.Say(h.modch.ID, h.mod.ID, "!about", timeout)
+	for _, a := range h.server.Actions()[n:] {
+		if a.Type == ActionSend && a.Channel == h.logch.ID && len(a.Message.Embeds) > 0 {
+			t.Error("Logged a message deleted from an excluded channel")
+		}
+	}
+}
+
+func TestVoice(t *testing.T) {
+	h := newHarness(t)
+	defer h.Close()
+
+	logged := func(n int, what string) {
+		if _, ok := h.server.WaitFor(n, timeout, func(a Action) bool {
+			return a.Type == ActionSend && a.Channel == h.logch.ID && len(a.Message.Embeds) > 0 && strings.Contains(a.Message.Embeds[0].Description, what)
+		}); !ok {
+			t.Fatal("Never logged " + what)
+		}
+	}
+	lobby := h.server.AddVoiceChannel(h.guild.ID, "Lobby")
+	hangout := h.server.AddVoiceChannel(h.guild.ID, "Hangout")
+
+	n := len(h.server.Actions())
+	h.server.SetVoice(h.guild.ID, h.user.ID, hangout.ID)
+	logged(n, "joined voice channel **Hangout**")
+	n = len(h.server.Actions())
+	h.server.SetVoice(h.guild.ID, h.user.ID, lobby.ID)
+	logged(n, "moved from voice channel **Hangout** to **Lobby**")
+	n = len(h.server.Actions())
+	h.server.SetVoice(h.guild.ID, h.user.ID, "")
+	logged(n, "left voice channel **Lobby**")
+
+	m := h.server.Say(h.general.ID, h.mod.ID, "!voicetime <@"+h.user.ID+">", timeout)
+	if m == nil {
+		t.Fatal("Bot never responded to !voicetime")
+	}
+	Check(strings.Contains(m.Content, "over 1 session,"), true, t) // Moving between channels doesn't start a new session
+	m = h.server.Say(h.general.ID, h.mod.ID, "!voicetime", timeout)
+	Check(strings.Contains(m.Content, "1. Scootaloo"), true, t)
+
+	// Joining a lobby creates a temporary channel, which is deleted as soon as everyone leaves it
+	h.server.Say(h.general.ID, h.mod.ID, "!setconfig voice.tempchannels <#"+lobby.ID+">", timeout)
+	n = len(h.server.Actions())
+	h.server.SetVoice(h.guild.ID, h.user.ID, lobby.ID)
+	a, ok := h.server.WaitFor(n, timeout, func(a Action) bool { return a.Type == ActionMove && a.User == h.user.ID })
+	if !ok {
+		t.Fatal("Member was never moved into a temporary channel")
+	}
+	temp := h.server.Channel(a.Channel)
+	if temp == nil {
+		t.Fatal("Temporary channel doesn't exist")
+	}
+	Check(temp.Name, "Scootaloo's channel", t)
+	Check(temp.Type, discordgo.ChannelTypeGuildVoice, t)
+	Check(h.server.Voice(h.guild.ID, h.user.ID), temp.ID, t)
+	Check(len(h.bot.DB.GetTempChannels(bot.SBatoi(h.guild.ID))), 1, t)
+
+	n = len(h.server.Actions())
+	h.server.SetVoice(h.guild.ID, h.user.ID, "")
+	if _, ok := h.server.WaitFor(n, timeout, func(a Action) bool { return a.Type == ActionChannelDelete && a.Channel == temp.ID }); !ok {
+		t.Fatal("Empty temporary channel was never deleted")
+	}
+	Check(h.server.Channel(temp.ID) == nil, true, t)
+}
+
+func TestConfigHistory(t *testing.T) {
+	h := newHarness(t)
+	defer h.Close()
+	gID := bot.SBatoi(h.guild.ID)
+
+	h.server.Say(h.general.ID, h.mod.ID, "!setconfig spam.silencetimeout 30", timeout)
+	history := h.bot.DB.GetConfigHistory(gID, 1, 0)
+	if len(history) == 0 {
+		t.Fatal("Config change was not recorded")
+	}
+	version := history[0].Version
+	Check(history[0].Author, bot.SBatoi(h.mod.ID), t)
+	Check(strings.Contains(history[0].Changes, "spam.silencetimeout"), true, t)
+	h.server.Say(h.general.ID, h.mod.ID, "!setconfig spam.silencetimeout 45", timeout)
+
+	m := h.server.Say(h.general.ID, h.mod.ID, "!confighistory", timeout)
+	if m == nil {
+		t.Fatal("Bot never responded to !confighistory")
+	}
+	Check(strings.Contains(m.Content, "spam.silencetimeout: 30 -> 45"), true, t)
+	Check(strings.Contains(m.Content, "Cheerilee"), true, t)
+
+	m = h.server.Say(h.general.ID, h.mod.ID, fmt.Sprintf("!configrollback %v", version), timeout)
+	if m == nil {
+		t.Fatal("Bot never responded to !configrollback")
+	}
+	Check(strings.Contains(m.Content, "Restored"), true, t)
+	m = h.server.Say(h.general.ID, h.mod.ID, "!getconfig spam.silencetimeout", timeout)
+	Check(m.Content, "```\nspam.silencetimeout: 30```", t)
+}
+
+func TestConfigImportExport(t *testing.T) {
+	h := newHarness(t)
+	defer h.Close()
+
+	h.server.Say(h.general.ID, h.mod.ID, "!setconfig spam.silencetimeout 30", timeout)
+	n := len(h.server.Actions())
+	h.server.Send(h.general.ID, h.mod.ID, "!exportconfig")
+	a, ok := h.server.WaitFor(n, timeout, func(a Action) bool {
+		return a.Type == ActionSend && a.Channel == h.general.ID && len(a.Message.Attachments) > 0
+	})
+	if !ok {
+		t.Fatal("Bot never sent the config")
+	}
+	data := string(h.server.File(a.Message.Attachments[0].URL))
+	Check(strings.Contains(data, `"silencetimeout": 30`), true, t)
+
+	imported := func(name string, data string) string {
+		n := len(h.server.Actions())
+		h.server.SendFile(h.general.ID, h.mod.ID, "!importconfig", name, []byte(data))
+		a, ok := h.server.WaitFor(n, timeout, func(a Action) bool { return a.Type == ActionSend && a.Channel == h.general.ID })
+		if !ok {
+			t.Fatal("Bot never responded to !importconfig")
+		}
+		return a.Message.Content
+	}
+	Check(strings.Contains(imported("broken.json", "{not json"), "Failed to import"), true, t)
+	Check(strings.Contains(imported("empty.json", "{}"), "Failed to import"), true, t)
+	m := h.server.Say(h.general.ID, h.mod.ID, "!getconfig spam.silencetimeout", timeout)
+	Check(m.Content, "```\nspam.silencetimeout: 30```", t)
+
+	Check(strings.Contains(imported("config.json", strings.Replace(data, `"silencetimeout": 30`, `"silencetimeout": 60`, 1)), "Imported"), true, t)
+	m = h.server.Say(h.general.ID, h.mod.ID, "!getconfig spam.silencetimeout", timeout)
+	Check(m.Content, "```\nspam.silencetimeout: 60```", t)
+}
+
+var csrfRegex = regexp.MustCompile(`name="csrf" value="([0-9a-f]+)"`)
+
+func TestDashboard(t *testing.T) {
+	h := newHarness(t)
+	defer h.Close()
+	web := httptest.NewServer(h.bot.WebHandler())
+	defer web.Close()
+	h.bot.WebDomain = strings.TrimPrefix(web.URL, "http://")
+
+	// Logging in sends the browser to discord, which sends it back with a code that the server hands out
+	login := func(userID string) *http.Client {
+		jar, _ := cookiejar.New(nil)
+		client := &http.Client{Jar: jar, CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+		resp, err := client.Get(web.URL + "/dashboard/login")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		authorize, err := url.Parse(resp.Header.Get("Location"))
+		if err != nil || !strings.HasSuffix(authorize.Path, "oauth2/authorize") {
+			t.Fatal("Login didn't redirect to discord: ", resp.Header.Get("Location"))
+		}
+		Check(authorize.Query().Get("redirect_uri"), web.URL+"/dashboard/callback", t)
+		resp, err = client.Get(web.URL + "/dashboard/callback?code=" + h.server.OAuthCode(userID) + "&state=" + authorize.Query().Get("state"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		Check(resp.StatusCode, http.StatusSeeOther, t)
+		return client
+	}
+	page := func(client *http.Client, path string, form url.Values) (int, string) {
+		var resp *http.Response
+		var err error
+		if form == nil {
+			resp, err = client.Get(web.URL + path)
+		} else {
+			resp, err = client.PostForm(web.URL+path, form)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+	guild := "/dashboard/" + h.guild.ID
+
+	status, body := page(login(h.user.ID), "/dashboard", nil)
+	Check(status, http.StatusOK, t)
+	Check(strings.Contains(body, "Test Server"), false, t)
+	status, _ = page(login(h.user.ID), guild, nil)
+	Check(status, http.StatusForbidden, t)
+
+	owner := login(h.server.Owner.ID)
+	_, body = page(owner, "/dashboard", nil)
+	Check(strings.Contains(body, guild), true, t)
+	status, body = page(owner, guild, nil)
+	Check(status, http.StatusOK, t)
+	Check(strings.Contains(body, `name="spam.silencetimeout"`), true, t)
+	csrf := csrfRegex.FindStringSubmatch(body)
+	if len(csrf) < 2 {
+		t.Fatal("Dashboard form has no CSRF token")
+	}
+
+	status, _ = page(owner, guild, url.Values{"csrf": {"wrong"}, "group": {"Spam"}, "spam.silencetimeout": {"45"}})
+	Check(status, http.StatusForbidden, t)
+	status, body = page(owner, guild, url.Values{"csrf": {csrf[1]}, "group": {"Spam"}, "spam.silencetimeout": {"45"}, "spam.maxpressure": {"lots"}})
+	Check(status, http.StatusBadRequest, t)
+	Check(strings.Contains(body, `value="lots"`), true, t)
+	m := h.server.Say(h.general.ID, h.mod.ID, "!getconfig spam.silencetimeout", timeout)
+	Check(m.Content, "```\nspam.silencetimeout: 0```", t) // Nothing is saved if any option is invalid
+
+	status, _ = page(owner, guild, url.Values{"csrf": {csrf[1]}, "group": {"Spam"}, "spam.silencetimeout": {"45"}})
+	Check(status, http.StatusOK, t)
+	m = h.server.Say(h.general.ID, h.mod.ID, "!getconfig spam.silencetimeout", timeout)
+	Check(m.Content, "```\nspam.silencetimeout: 45```", t)
+
+	status, _ = page(owner, guild, url.Values{
+		"csrf":               {csrf[1]},
+		"group":              {"Basic"},
+		"basic.freechannels": {"#general\r\n"},
+		"basic.aliases":      {"Kawaii = pick cute"},
+		"basic.listentobots": {"false", "true"},
+	})
+	Check(status, http.StatusOK, t)
+	h.bot.GuildsLock.RLock()
+	info := h.bot.Guilds[bot.DiscordGuild(h.guild.ID)]
+	h.bot.GuildsLock.RUnlock()
+	info.ConfigLock.RLock()
+	Check(info.Config.Basic.FreeChannels[bot.DiscordChannel(h.general.ID)], true, t)
+	Check(info.Config.Basic.Aliases["kawaii"], "pick cute", t)
+	Check(info.Config.Basic.ListenToBots, true, t)
+	Check(info.Config.Basic.ModRole, bot.DiscordRole(h.modrole.ID), t) // Options that weren't changed are left alone
+	info.ConfigLock.RUnlock()
+}
+
+func TestHealth(t *testing.T) {
+	h := newHarness(t)
+	defer h.Close()
+	web := httptest.NewServer(h.bot.WebHandler())
+	defer web.Close()
+	h.bot.WebDomain = strings.TrimPrefix(web.URL, "http://")
+
+	for _, path := range []string{"/healthz", "/readyz"} {
+		resp, err := http.Get(web.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		health := bot.Health{}
+		Check(json.NewDecoder(resp.Body).Decode(&health), nil, t)
+		resp.Body.Close()
+		Check(resp.StatusCode, http.StatusOK, t)
+		Check(health.Database, true, t)
+		if Check(len(health.Shards), 1, t) {
+			Check(health.Shards[0].Connected, true, t)
+		}
+	}
+}
+
+func TestSlashCommands(t *testing.T) {
+	h := newHarness(t)
+	defer h.Close()
+
+	n := len(h.server.Actions())
+	h.server.Say(h.general.ID, h.mod.ID, "!setconfig basic.slashcommands true", timeout)
+	if _, ok := h.server.WaitFor(n, timeout, func(a Action) bool { return a.Type == ActionCommands && a.Guild == h.guild.ID }); !ok {
+		t.Fatal("Slash commands were never registered")
+	}
+	var pick *Command
+	commands := h.server.Commands(h.guild.ID)
+	for i := range commands {
+		if commands[i].Name == "pick" {
+			pick = &commands[i]
+		}
+	}
+	if pick == nil {
+		t.Fatal("!pick was not registered as a slash command")
+	}
+	if Check(len(pick.Options), 1, t) {
+		Check(pick.Options[0].Name, "tags", t)
+		Check(pick.Options[0].Required, false, t)
+	}
+
+	h.server.Say(h.general.ID, h.mod.ID, "!new cute", timeout)
+	h.server.Say(h.general.ID, h.mod.ID, "!add cute Sweetie Belle", timeout)
+	n = len(h.server.Actions())
+	h.server.Interact(h.general.ID, h.user.ID, "pick", map[string]string{"tags": "cute"})
+	if _, ok := h.server.WaitFor(n, timeout, func(a Action) bool { return a.Type == ActionDefer }); !ok {
+		t.Fatal("Interaction was never acknowledged")
+	}
+	m, ok := h.server.WaitFor(n, timeout, func(a Action) bool { return a.Type == ActionSend && a.Channel == h.general.ID })
+	if !ok {
+		t.Fatal("Bot never responded to /pick")
+	}
+	Check(m.Message.Content, "Sweetie Belle", t)
+
+	// Disabled commands are removed from the list, and if someone uses one before discord catches up, the pending
+	// response is removed instead of being left behind.
+	n = len(h.server.Actions())
+	h.server.Send(h.general.ID, h.mod.ID, "!disable pick")
+	if _, ok := h.server.WaitFor(n, timeout, func(a Action) bool { return a.Type == ActionCommands }); !ok {
+		t.Fatal("Slash commands were not updated after disabling !pick")
+	}
+	for _, c := range h.server.Commands(h.guild.ID) {
+		CheckNot(c.Name, "pick", t)
+	}
+	n = len(h.server.Actions())
+	h.server.Interact(h.general.ID, h.user.ID, "pick", map[string]string{"tags": "cute"})
+	if _, ok := h.server.WaitFor(n, timeout, func(a Action) bool { return a.Type == ActionDelete && a.Channel == h.general.ID }); !ok {
+		t.Fatal("Deferred response to a disabled command was never removed")
+	}
+}
+
+func TestCustomCommands(t *testing.T) {
+	h := newHarness(t)
+	defer h.Close()
+
+	m := h.server.Say(h.general.ID, h.mod.ID, "!addcommand hug {{.Author}} hugs {{.Text}}!", timeout)
+	if m == nil {
+		t.Fatal("Bot never responded to !addcommand")
+	}
+	Check(strings.Contains(m.Content, "Added !hug"), true, t)
+	m = h.server.Say(h.general.ID, h.user.ID, "!hug Sweetie Belle", timeout)
+	if m == nil {
+		t.Fatal("Bot never responded to !hug")
+	}
+	Check(m.Content, "Scootaloo hugs Sweetie Belle!", t)
+
+	m = h.server.Say(h.general.ID, h.mod.ID, "!addcommand pick {{.Text}}", timeout)
+	if m == nil {
+		t.Fatal("Bot never responded to !addcommand")
+	}
+	Check(strings.Contains(m.Content, "already a command"), true, t)
+	m = h.server.Say(h.general.ID, h.mod.ID, "!addcommand broken {{.Author", timeout)
+	if m == nil {
+		t.Fatal("Bot never responded to !addcommand")
+	}
+	Check(strings.Contains(m.Content, "template has an error"), true, t)
+
+	m = h.server.Say(h.general.ID, h.mod.ID, "!addcommand boop {{.Mention}} boops <@"+h.user.ID+">", timeout)
+	if m == nil {
+		t.Fatal("Bot never responded to !addcommand")
+	}
+	m = h.server.Say(h.general.ID, h.mod.ID, "!boop", timeout)
+	if m == nil {
+		t.Fatal("Bot never responded to !boop")
+	}
+	Check(m.Content, "<@"+h.mod.ID+"> boops <\\@"+h.user.ID+">", t)
+
+	h.server.Say(h.general.ID, h.mod.ID, "!addcounter hugs", timeout)
+	h.server.Say(h.general.ID, h.mod.ID, "!addcommand hugcount {{increment \"hugs\"}} hugs so far", timeout)
+	history := len(h.bot.DB.GetConfigHistory(bot.SBatoi(h.guild.ID), bot.MaxConfigHistory, 0))
+	for i := 1; i <= 2; i++ {
+		m = h.server.Say(h.general.ID, h.mod.ID, "!hugcount", timeout)
+		if m == nil {
+			t.Fatal("Bot never responded to !hugcount")
+		}
+		Check(m.Content, fmt.Sprintf("%v hugs so far", i), t)
+	}
+	Check(len(h.bot.DB.GetConfigHistory(bot.SBatoi(h.guild.ID), bot.MaxConfigHistory, 0)), history, t) // Counters don't fill up the config history
+
+	m = h.server.Say(h.general.ID, h.mod.ID, "!removecommand hug", timeout)
+	if m == nil {
+		t.Fatal("Bot never responded to !removecommand")
+	}
+	Check(strings.Contains(m.Content, "Removed !hug"), true, t)
+	m = h.server.Say(h.general.ID, h.user.ID, "!hug Sweetie Belle", timeout)
+	if m == nil {
+		t.Fatal("Bot never responded to !hug after it was removed")
+	}
+	Check(strings.Contains(m.Content, "not a valid command"), true, t)
+}
+
+func TestCustomCommandsOldConfig(t *testing.T) {
+	h := newHarness(t)
+	defer h.Close()
+
+	// Rewrite the config the way it looked before the CustomCommands module existed
+	h.bot.Stop()
+	h.bot = nil
+	data, _ := ioutil.ReadFile(h.guild.ID + ".json")
+	config := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &config); err != nil {
+		t.Fatal(err)
+	}
+	versions := map[string]int{}
+	json.Unmarshal(config["sectionversions"], &versions)
+	delete(versions, "customcommands")
+	delete(config, "customcommands")
+	config["sectionversions"], _ = json.Marshal(versions)
+	data, _ = json.Marshal(config)
+	ioutil.WriteFile(h.guild.ID+".json", data, 0664)
+	h.bot = h.create()
+	h.start()
+
+	m := h.server.Say(h.general.ID, h.user.ID, "!addcommand hug {{.Author}} hugs {{.Text}}!", timeout)
+	if m == nil {
+		t.Fatal("Bot never responded to !addcommand")
+	}
+	Check(strings.Contains(m.Content, "Added"), false, t)
+	m = h.server.Say(h.general.ID, h.mod.ID, "!addcommand hug {{.Author}} hugs {{.Text}}!", timeout)
+	if m == nil {
+		t.Fatal("Bot never responded to !addcommand")
+	}
+	Check(strings.Contains(m.Content, "Added !hug"), true, t)
+	m = h.server.Say(h.general.ID, h.user.ID, "!removecommand hug", timeout)
+	Check(m == nil || !strings.Contains(m.Content, "Removed"), true, t) // The error might not show up so soon after the last one
+	m = h.server.Say(h.general.ID, h.user.ID, "!hug Sweetie Belle", timeout)
+	if m == nil {
+		t.Fatal("Bot never responded to !hug")
+	}
+	Check(m.Content, "Scootaloo hugs Sweetie Belle!", t)
+}
+
+func TestPaginator(t *testing.T) {
+	h := newHarness(t)
+	defer h.Close()
+
+	n := len(h.server.Actions())
+	m := h.server.Say(h.general.ID, h.user.ID, "!help", timeout)
+	if m == nil {
+		t.Fatal("Bot never responded to !help")
+	}
+	Check(len(m.Embeds), 1, t)
+	Check(strings.HasSuffix(m.Embeds[0].Footer.Text, "Page 1 of 2"), true, t)
+	for _, emoji := range []string{"\u25c0", "\u25b6"} {
+		if _, ok := h.server.WaitFor(n, timeout, func(a Action) bool {
+			return a.Type == ActionReaction && a.Messages[0] == m.ID && a.Reason == emoji
+		}); !ok {
+			t.Fatalf("Bot never reacted with %s", emoji)
+		}
+	}
+
+	n = len(h.server.Actions())
+	h.server.React(h.general.ID, m.ID, h.user.ID, "\u25b6")
+	a, ok := h.server.WaitFor(n, timeout, func(a Action) bool { return a.Type == ActionEdit && a.Message.ID == m.ID })
+	if !ok {
+		t.Fatal("Bot never changed the page")
+	}
+	Check(strings.HasSuffix(a.Message.Embeds[0].Footer.Text, "Page 2 of 2"), true, t)
+}
@@ -7,24 +7,21 @@ import (
 	"strings"
 	"time"
 
-	"../spammodule"
 	bot "../sweetiebot"
 	"github.com/blackhole12/discordgo"
 )
 
 // FilterModule implements word filters that allow you to look for spoilers or profanity uses regex matching.
 type FilterModule struct {
-	spam    *spammodule.SpamModule
 	filters map[string]*regexp.Regexp
 	lastmsg int64 // Universal saturation limit on all filter responses
 }
 
 // New instance of FilterModule
-func New(info *bot.GuildInfo, s *spammodule.SpamModule) *FilterModule {
+func New(info *bot.GuildInfo) *FilterModule {
 	w := &FilterModule{
 		filters: make(map[string]*regexp.Regexp),
 		lastmsg: 0,
-		spam:    s,
 	}
 	for k := range info.Config.Filter.Filters {
 		w.UpdateRegex(k, info)
@@ -72,11 +69,8 @@ func (w *FilterModule) matchFilter(info *bot.GuildInfo, m *discordgo.Message) bo
 		if v.MatchString(m.Content) {
 			ch, _ := info.Bot.DG.State.Channel(m.ChannelID)
 
-			if len(info.Config.Filter.Pressure) > 0 && w.spam != nil {
-				if p, ok := info.Config.Filter.Pressure[k]; ok && p > 0.0 {
-					w.spam.AddPressure(info, m, w.spam.TrackUser(author, bot.GetTimestamp(m)), p, "triggering the "+k+" filter")
-				}
-			}
+			p, _ := info.Config.Filter.Pressure[k]
+			info.Publish(&bot.FilterTriggered{Filter: k, Message: m, Pressure: p})
 
 			if s, _ := info.Config.Filter.Responses[k]; len(s) != 1 || s[0] != '!' {
 				time.Sleep(bot.DelayTime)
@@ -176,7 +170,7 @@ func (c *setFilterCommand) Process(args []string, msg *discordgo.Message, indice
 	}
 	info.ConfigLock.Unlock()
 
-	info.SaveConfig()
+	info.SaveConfigBy(bot.DiscordUser(msg.Author.ID))
 	return fmt.Sprintf("```\n"+add+"```", info.Sanitize(filter, bot.CleanCodeBlock)), false, nil
 }
 func (c *setFilterCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
@@ -231,7 +225,7 @@ func (c *addFilterCommand) Process(args []string, msg *discordgo.Message, indice
 	}
 	info.ConfigLock.Unlock()
 
-	info.SaveConfig()
+	info.SaveConfigBy(bot.DiscordUser(msg.Author.ID))
 	filter = info.Sanitize(filter, bot.CleanCodeBlock)
 	return fmt.Sprintf("```\n"+add+". Length of %s: %v```", info.Sanitize(arg, bot.CleanCodeBlock), filter, filter, strconv.Itoa(len(info.Config.Filter.Filters[filter]))), false, nil
 }
@@ -280,7 +274,7 @@ func (c *removeFilterCommand) Process(args []string, msg *discordgo.Message, ind
 
 	filter = info.Sanitize(filter, bot.CleanCodeBlock)
 	retval := fmt.Sprintf("```\nRemoved %s from %s. Length of %s: %v```", info.Sanitize(arg, bot.CleanCodeBlock), filter, filter, len(info.Config.Filter.Filters[filter]))
-	info.SaveConfig()
+	info.SaveConfigBy(bot.DiscordUser(msg.Author.ID))
 	return retval, false, nil
 }
 func (c *removeFilterCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
@@ -309,7 +303,7 @@ func (c *deleteFilterCommand) Process(args []string, msg *discordgo.Message, ind
 		return "```\nNo filter given. All filters: " + strings.Join(getAllFilters(info), ", ") + "```", false, nil
 	}
 	if len(args) > 1 {
-		return "```\nYou specified more than one argument. This command completely removes an entire filter, use " + info.Config.Basic.CommandPrefix + "removefilter to remove a single item.```", false, nil
+		return "```\nYou specified more than one argument. This command completely removes an entire filter, use " + info.Prefix(bot.DiscordChannel(msg.ChannelID)) + "removefilter to remove a single item.```", false, nil
 	}
 
 	filter := args[0]
@@ -326,7 +320,7 @@ func (c *deleteFilterCommand) Process(args []string, msg *discordgo.Message, ind
 	c.m.UpdateRegex(filter, info)
 
 	filter = info.Sanitize(filter, bot.CleanCodeBlock)
-	info.SaveConfig()
+	info.SaveConfigBy(bot.DiscordUser(msg.Author.ID))
 	return fmt.Sprintf("```\nDeleted the %s filter```", filter), false, nil
 }
 func (c *deleteFilterCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
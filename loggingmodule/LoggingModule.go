@@ -0,0 +1,295 @@
+package loggingmodule
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	bot "../sweetiebot"
+	"github.com/blackhole12/discordgo"
+)
+
+// maxCachedMessages is how many recent messages are remembered, so we can show what they said after they get edited or
+// deleted. Anything older has to be looked up in the chatlog, which only exists on silver servers.
+const maxCachedMessages = 2000
+
+// Colors used for each kind of log entry
+const (
+	colorEdit   = 0x3e92e5
+	colorDelete = 0xd54141
+	colorJoin   = 0x56d34f
+	colorLeave  = 0xe5a03e
+	colorMember = 0x9b59b6
+)
+
+type cachedMessage struct {
+	author      *discordgo.User
+	channel     string
+	content     string
+	attachments []string
+}
+
+type memberState struct {
+	nick  string
+	roles []string
+}
+
+// LoggingModule tells moderators about edits, deletes, joins, leaves, nickname and role changes. Discord doesn't say
+// what a message or member looked like before it changed, so the module keeps its own copy of recent messages and of
+// every member's nickname and roles.
+type LoggingModule struct {
+	lock     sync.Mutex
+	messages map[string]*cachedMessage
+	order    []string // Ring buffer of cached message IDs, so the oldest one can be forgotten
+	next     int
+	members  map[string]memberState
+}
+
+// New LoggingModule
+func New(guild *bot.GuildInfo) *LoggingModule {
+	w := &LoggingModule{
+		messages: make(map[string]*cachedMessage),
+		order:    make([]string, maxCachedMessages),
+		members:  make(map[string]memberState),
+	}
+	if g, err := guild.GetGuild(); err == nil {
+		guild.Bot.DG.State.RLock()
+		for _, m := range g.Members {
+			w.members[m.User.ID] = memberState{m.Nick, append([]string{}, m.Roles...)}
+		}
+		guild.Bot.DG.State.RUnlock()
+	}
+	return w
+}
+
+// Name of the module
+func (w *LoggingModule) Name() string {
+	return "Logging"
+}
+
+// Commands in the module
+func (w *LoggingModule) Commands() []bot.Command {
+	return []bot.Command{}
+}
+
+// Description of the module
+func (w *LoggingModule) Description() string {
+	return "Posts message edits and deletions, members joining and leaving, and nickname and role changes to `log.eventchannel`, or `log.channel` if that isn't set. To stop logging messages from certain channels, exclude them with `modules.channels`, like `!setconfig modules.channels logging ! #secret-channel`."
+}
+
+func (w *LoggingModule) send(info *bot.GuildInfo, embed *discordgo.MessageEmbed) {
+	ch := info.EventChannel()
+	if ch == bot.ChannelEmpty {
+		return
+	}
+	embed.Type = "rich"
+	embed.Timestamp = time.Now().UTC().Format(time.RFC3339)
+	if err := info.SendEmbed(ch, embed); err != nil {
+		info.LogError("Failed to send log entry: ", err)
+	}
+}
+
+func embedAuthor(u *discordgo.User) *discordgo.MessageEmbedAuthor {
+	a := &discordgo.MessageEmbedAuthor{Name: u.Username + "#" + u.Discriminator}
+	if len(u.Avatar) > 0 {
+		a.IconURL = fmt.Sprintf("https://cdn.discordapp.com/avatars/%s/%s.jpg", u.ID, u.Avatar)
+	}
+	return a
+}
+
+// fieldText fits text into an embed field, which can't be empty or longer than 1024 characters
+func fieldText(s string) string {
+	if len(strings.TrimSpace(s)) == 0 {
+		return "*(no text)*"
+	}
+	if r := []rune(s); len(r) > 1024 {
+		return string(r[:1021]) + "..."
+	}
+	return s
+}
+
+// cache remembers a message, forgetting the oldest one if the cache is full. The caller must hold the lock.
+func (w *LoggingModule) cache(m *discordgo.Message) {
+	c := &cachedMessage{author: m.Author, channel: m.ChannelID, content: m.Content}
+	for _, a := range m.Attachments {
+		c.attachments = append(c.attachments, a.URL)
+	}
+	if _, ok := w.messages[m.ID]; !ok {
+		if old := w.order[w.next]; len(old) > 0 {
+			delete(w.messages, old)
+		}
+		w.order[w.next] = m.ID
+		w.next = (w.next + 1) % len(w.order)
+	}
+	w.messages[m.ID] = c
+}
+
+func (w *LoggingModule) ignored(info *bot.GuildInfo, channel string) bool {
+	return info.EventChannel() == bot.ChannelEmpty || info.EventChannel().Equals(channel)
+}
+
+// OnMessageCreate discord hook
+func (w *LoggingModule) OnMessageCreate(info *bot.GuildInfo, m *discordgo.Message) {
+	if m.Author == nil || w.ignored(info, m.ChannelID) {
+		return
+	}
+	w.lock.Lock()
+	w.cache(m)
+	w.lock.Unlock()
+}
+
+// OnMessageUpdate discord hook
+func (w *LoggingModule) OnMessageUpdate(info *bot.GuildInfo, m *discordgo.Message) {
+	if w.ignored(info, m.ChannelID) {
+		return
+	}
+	w.lock.Lock()
+	before, ok := "", false
+	if c, cached := w.messages[m.ID]; cached {
+		before, ok = c.content, true
+	}
+	w.cache(m)
+	w.lock.Unlock()
+
+	if !ok && info.Bot.DB.CheckStatus() {
+		before, ok = info.Bot.DB.GetLastEdit(bot.SBatoi(m.ID))
+	}
+	if ok && before == m.Content { // Discord also sends updates when it adds link previews to a message
+		return
+	}
+	if !ok {
+		before = "*(this message was sent before " + info.GetBotName() + " started watching)*"
+	}
+	w.send(info, &discordgo.MessageEmbed{
+		Author:      embedAuthor(m.Author),
+		Color:       colorEdit,
+		Description: fmt.Sprintf("Message edited in <#%s> [(jump)](https://discordapp.com/channels/%s/%s/%s)", m.ChannelID, info.ID, m.ChannelID, m.ID),
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Before", Value: fieldText(before)},
+			{Name: "After", Value: fieldText(m.Content)},
+		},
+		Footer: &discordgo.MessageEmbedFooter{Text: "User ID: " + m.Author.ID + " | Message ID: " + m.ID},
+	})
+}
+
+// OnMessageDelete discord hook
+func (w *LoggingModule) OnMessageDelete(info *bot.GuildInfo, m *discordgo.Message) {
+	if w.ignored(info, m.ChannelID) {
+		return
+	}
+	w.lock.Lock()
+	c, ok := w.messages[m.ID]
+	delete(w.messages, m.ID)
+	w.lock.Unlock()
+
+	if !ok && info.Bot.DB.CheckStatus() {
+		if l := info.Bot.DB.GetMessage(bot.SBatoi(m.ID)); l != nil {
+			author := &discordgo.User{ID: bot.SBitoa(l.Author), Username: info.GetUserName(bot.DiscordUser(bot.SBitoa(l.Author)))}
+			c, ok = &cachedMessage{author: author, channel: m.ChannelID, content: l.Message}, true
+		}
+	}
+	if !ok || info.Bot.SelfID.Equals(c.author.ID) { // Our own messages never reach the cache, so this is usually one of them
+		return
+	}
+	embed := &discordgo.MessageEmbed{
+		Author:      embedAuthor(c.author),
+		Color:       colorDelete,
+		Description: fmt.Sprintf("Message deleted in <#%s>", m.ChannelID),
+		Fields:      []*discordgo.MessageEmbedField{{Name: "Content", Value: fieldText(c.content)}},
+		Footer:      &discordgo.MessageEmbedFooter{Text: "User ID: " + c.author.ID + " | Message ID: " + m.ID},
+	}
+	if len(c.attachments) > 0 {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{Name: "Attachments", Value: fieldText(strings.Join(c.attachments, "\n"))})
+	}
+	w.send(info, embed)
+}
+
+// OnGuildMemberAdd discord hook
+func (w *LoggingModule) OnGuildMemberAdd(info *bot.GuildInfo, m *discordgo.Member, t time.Time) {
+	w.lock.Lock()
+	w.members[m.User.ID] = memberState{m.Nick, append([]string{}, m.Roles...)}
+	w.lock.Unlock()
+	w.send(info, &discordgo.MessageEmbed{
+		Author:      embedAuthor(m.User),
+		Color:       colorJoin,
+		Description: fmt.Sprintf("<@%s> joined the server. Their account was created %s ago.", m.User.ID, bot.TimeDiff(t.Sub(bot.SnowflakeTime(bot.SBatoi(m.User.ID))))),
+		Footer:      &discordgo.MessageEmbedFooter{Text: "User ID: " + m.User.ID},
+	})
+}
+
+// OnGuildMemberRemove discord hook
+func (w *LoggingModule) OnGuildMemberRemove(info *bot.GuildInfo, m *discordgo.Member, t time.Time) {
+	w.lock.Lock()
+	delete(w.members, m.User.ID)
+	w.lock.Unlock()
+	w.send(info, &discordgo.MessageEmbed{
+		Author:      embedAuthor(m.User),
+		Color:       colorLeave,
+		Description: fmt.Sprintf("<@%s> left the server.", m.User.ID),
+		Footer:      &discordgo.MessageEmbedFooter{Text: "User ID: " + m.User.ID},
+	})
+}
+
+// OnGuildMemberUpdate discord hook
+func (w *LoggingModule) OnGuildMemberUpdate(info *bot.GuildInfo, m *discordgo.Member, t time.Time) {
+	w.lock.Lock()
+	old, ok := w.members[m.User.ID]
+	w.members[m.User.ID] = memberState{m.Nick, append([]string{}, m.Roles...)}
+	w.lock.Unlock()
+	if !ok {
+		return // We don't know what changed
+	}
+
+	footer := &discordgo.MessageEmbedFooter{Text: "User ID: " + m.User.ID}
+	if old.nick != m.Nick {
+		w.send(info, &discordgo.MessageEmbed{
+			Author:      embedAuthor(m.User),
+			Color:       colorMember,
+			Description: fmt.Sprintf("<@%s> changed their nickname", m.User.ID),
+			Fields: []*discordgo.MessageEmbedField{
+				{Name: "Before", Value: fieldText(old.nick), Inline: true},
+				{Name: "After", Value: fieldText(m.Nick), Inline: true},
+			},
+			Footer: footer,
+		})
+	}
+
+	added, removed := diffRoles(old.roles, m.Roles)
+	if len(added) > 0 || len(removed) > 0 {
+		fields := []*discordgo.MessageEmbedField{}
+		if len(added) > 0 {
+			fields = append(fields, &discordgo.MessageEmbedField{Name: "Added", Value: fieldText(strings.Join(added, " ")), Inline: true})
+		}
+		if len(removed) > 0 {
+			fields = append(fields, &discordgo.MessageEmbedField{Name: "Removed", Value: fieldText(strings.Join(removed, " ")), Inline: true})
+		}
+		w.send(info, &discordgo.MessageEmbed{
+			Author:      embedAuthor(m.User),
+			Color:       colorMember,
+			Description: fmt.Sprintf("<@%s>'s roles changed", m.User.ID),
+			Fields:      fields,
+			Footer:      footer,
+		})
+	}
+}
+
+// diffRoles returns role pings for every role that was added or removed
+func diffRoles(before []string, after []string) (added []string, removed []string) {
+	had := make(map[string]bool, len(before))
+	for _, r := range before {
+		had[r] = true
+	}
+	for _, r := range after {
+		if !had[r] {
+			added = append(added, "<@&"+r+">")
+		}
+		delete(had, r)
+	}
+	for _, r := range before {
+		if had[r] {
+			removed = append(removed, "<@&"+r+">")
+		}
+	}
+	return
+}
@@ -51,6 +51,9 @@ func (c *episodeGenCommand) Name() string {
 	return "episodegen"
 }
 func (c *episodeGenCommand) Process(args []string, msg *discordgo.Message, indices []int, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+	return info.ProcessTyped(c, args, msg, indices)
+}
+func (c *episodeGenCommand) ProcessArgs(args bot.CommandArgs, msg *discordgo.Message, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
 	if !info.Bot.DB.CheckStatus() {
 		return "```\nA temporary database outage is preventing this command from being executed.```", false, nil
 	}
@@ -58,14 +61,8 @@ func (c *episodeGenCommand) Process(args []string, msg *discordgo.Message, indic
 		return "```\nSorry, I'm busy processing another request right now. Please try again later!```", false, nil
 	}
 	defer c.lock.Clear()
-	maxlines := info.Config.Markov.DefaultLines
-	double := true
-	if len(args) > 0 {
-		maxlines, _ = strconv.Atoi(args[0])
-	}
-	if len(args) > 1 {
-		double = (strings.ToLower(args[1]) != "single")
-	}
+	maxlines := int(args.Int("lines", int64(info.Config.Markov.DefaultLines)))
+	double := args.String("single") != "single"
 	if maxlines > 50 {
 		maxlines = 50
 	}
@@ -95,8 +92,8 @@ func (c *episodeGenCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
 	return &bot.CommandUsage{
 		Desc: "Randomly generates a my little pony episode using a markov chain, up to a maximum line count of `lines`. Will be sent via PM if the line count exceeds 5.",
 		Params: []bot.CommandUsageParam{
-			{Name: "lines", Desc: "Number of dialogue lines to generate", Optional: true},
-			{Name: "single", Desc: "The markov chain uses double-lookback by default, if this is specified, will revert to single-lookback, which produces much more chaotic results.", Optional: true},
+			{Name: "lines", Desc: "Number of dialogue lines to generate", Optional: true, Type: bot.ParamInt},
+			{Name: "single", Desc: "The markov chain uses double-lookback by default, if this is specified, will revert to single-lookback, which produces much more chaotic results.", Optional: true, Type: bot.ParamEnum, Values: []string{"single", "double"}},
 		},
 	}
 }
@@ -177,7 +174,7 @@ func (c *episodeQuoteCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
 	return &bot.CommandUsage{
 		Desc: "If the S0E00:000-000 format is used, returns all the lines from the given season and episode, between the starting and ending line numbers (inclusive). Returns a maximum of " + strconv.Itoa(info.Config.Markov.MaxLines) + " lines, but a line count above 5 will be sent in a private message. \n\nIf \"action\" is specified, returns a random action quote from the show.\n\nIf \"speech\" is specified, returns a random quote from one of the characters in the show.\n\nIf a \"Character Name\" is specified, it attempts to quote a random line from the show spoken by that character. If the character can't be found, returns an error. The character name doesn't have to be in quotes unless it has spaces in it, but you must specify the entire name.\n\nIf no arguments are specified, quotes a completely random line from the show.",
 		Params: []bot.CommandUsageParam{
-			{Name: "S0E00:000-000|action|speech|\"Character Name\"", Desc: "Example: `" + info.Config.Basic.CommandPrefix + "quote S4E22:7-14`", Optional: true},
+			{Name: "S0E00:000-000|action|speech|\"Character Name\"", Desc: "Example: `" + info.Prefix(bot.ChannelEmpty) + "quote S4E22:7-14`", Optional: true},
 		},
 	}
 }
@@ -84,7 +84,7 @@ func (c *pollCommand) Process(args []string, msg *discordgo.Message, indices []i
 					votes[v.ID] = n + 1
 				}
 			} else {
-				fmt.Println(err.Error())
+				info.LogError("Failed to get poll reactions: ", err)
 			}
 		}
 
@@ -278,7 +278,7 @@ func (c *rollCommand) Process(args []string, msg *discordgo.Message, indices []i
 }
 func (c *rollCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
 	return &bot.CommandUsage{
-		Desc: "Evaluates an arbitrary mathematical expression, replacing all **N**d**X** values with the sum of `n` random numbers from 1 to **X**, inclusive. For example, `" + info.Config.Basic.CommandPrefix + "roll d10` will return 1-10, whereas `" + info.Config.Basic.CommandPrefix + "roll 2d10 + 2` will return a number between 4 and 22.",
+		Desc: "Evaluates an arbitrary mathematical expression, replacing all **N**d**X** values with the sum of `n` random numbers from 1 to **X**, inclusive. For example, `" + info.Prefix(bot.ChannelEmpty) + "roll d10` will return 1-10, whereas `" + info.Prefix(bot.ChannelEmpty) + "roll 2d10 + 2` will return a number between 4 and 22.",
 		Params: []bot.CommandUsageParam{
 			{Name: "expression", Desc: "The mathematical expression to parse.", Optional: false},
 		},
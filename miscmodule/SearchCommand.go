@@ -155,10 +155,11 @@ func (c *searchCommand) Process(args []string, msg *discordgo.Message, indices [
 		params = append(params, t)
 	}
 
-	query += "C.ID != ? AND C.Author != ? AND C.Channel != ? AND C.Message NOT LIKE '" + info.Config.Basic.CommandPrefix + "search %' ORDER BY C.Timestamp DESC" // Always exclude the message corresponding to the command and all sweetie bot messages (which also prevents trailing ANDs)
+	query += "C.ID != ? AND C.Author != ? AND C.Channel != ? AND C.Message NOT LIKE ? ORDER BY C.Timestamp DESC" // Always exclude the message corresponding to the command and all sweetie bot messages (which also prevents trailing ANDs)
 	params = append(params, bot.SBatoi(msg.ID))
 	params = append(params, info.Bot.SelfID.Convert())
 	params = append(params, info.Config.Basic.ModChannel.Convert())
+	params = append(params, info.Prefix(bot.DiscordChannel(msg.ChannelID))+"search %")
 
 	querylimit := query
 	if rangeend >= 0 {
@@ -253,15 +254,18 @@ func (c *searchCommand) Process(args []string, msg *discordgo.Message, indices [
 		return "```\nNo results in range.```", false, nil
 	}
 
+	lines := make([]string, 0, len(r))
 	for _, v := range r {
-		ret += "[" + info.ApplyTimezone(v.Timestamp, bot.DiscordUser(msg.Author.ID)).Format("1/2 3:04:05PM") + "] " + v.Author + ": " + msgHighlightMatch(v.Message, message) + "\n"
+		line := "[" + info.ApplyTimezone(v.Timestamp, bot.DiscordUser(msg.Author.ID)).Format("1/2 3:04:05PM") + "] " + v.Author + ": " + msgHighlightMatch(v.Message, message)
+		lines = append(lines, info.Sanitize(line, bot.CleanPings|bot.CleanEmotes|bot.CleanMentions|bot.CleanURL))
 	}
 
-	return info.Sanitize(ret, bot.CleanPings|bot.CleanEmotes|bot.CleanMentions|bot.CleanURL), len(r) > 5, nil
+	embed := &discordgo.MessageEmbed{Title: "Search results: " + strconv.Itoa(count) + strmatch + ".", Color: 0x3e92e5}
+	return "", false, info.Paginate(msg, bot.PageLines(embed, lines, 5))
 }
 func (c *searchCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
 	return &bot.CommandUsage{
-		Desc: "This is an arbitrary search command run on the 7 day chat log. All parameters are optional and can be input in any order, and will all be combined into a single search as appropriate, but if no searchable parameters are given, the operation will fail.  Remember that if a username has spaces in it, you have to put the entire username parameter in quotes, not just the username itself! \n\n Example: `" + info.Config.Basic.CommandPrefix + "search #manechat @cloud|@Potluck *4 \"~Sep 8 12:00pm\"`\n This will return the most recent 4 messages said by any user with \"cloud\" in the name, or the user Potluck, in the #manechat channel, before Sept 8 12:00pm.",
+		Desc: "This is an arbitrary search command run on the 7 day chat log. All parameters are optional and can be input in any order, and will all be combined into a single search as appropriate, but if no searchable parameters are given, the operation will fail.  Remember that if a username has spaces in it, you have to put the entire username parameter in quotes, not just the username itself! \n\n Example: `" + info.Prefix(bot.ChannelEmpty) + "search #manechat @cloud|@Potluck *4 \"~Sep 8 12:00pm\"`\n This will return the most recent 4 messages said by any user with \"cloud\" in the name, or the user Potluck, in the #manechat channel, before Sept 8 12:00pm.",
 		Params: []bot.CommandUsageParam{
 			{Name: "*[result-range]", Desc: "Specifies what results should be returned. Specifying '*10' will return the first 10 results, while '*5-10' will return the 5th to the 10th result (inclusive). If you ONLY specify a single * character, it will only return a count of the total number of results.", Optional: true},
 			{Name: "@user[|@user2|...]", Desc: "Specifies a target user name to search for. An actual ping will be more effective, as it can directly use the user ID, but a raw username will be searched for in the alias table. Multiple users can be searched for by separating them with `|`, but each user must still be prefixed with `@` even if it's not a ping", Optional: true},
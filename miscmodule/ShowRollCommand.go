@@ -109,7 +109,7 @@ func (c *showrollCommand) Process(args []string, msg *discordgo.Message, indices
 	index := 0
 	var s string
 	for index < len(args) {
-		s += c.value(args, &index, info.Config.Basic.CommandPrefix) + "\n"
+		s += c.value(args, &index, info.Prefix(bot.DiscordChannel(msg.ChannelID))) + "\n"
 	}
 	return "```\n" + s + "```", false, nil
 }
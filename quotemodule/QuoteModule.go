@@ -92,7 +92,7 @@ func (c *quoteCommand) Process(args []string, msg *discordgo.Message, indices []
 		i = rand.Intn(l)
 	}
 	if i >= l || i < 0 {
-		return "```\nInvalid quote index. Use " + info.Config.Basic.CommandPrefix + "searchquote [user] to list a user's quotes and their indexes.```", false, nil
+		return "```\nInvalid quote index. Use " + info.Prefix(bot.DiscordChannel(msg.ChannelID)) + "searchquote [user] to list a user's quotes and their indexes.```", false, nil
 	}
 	return "**" + info.GetUserName(user) + "**: " + q[i], false, nil
 }
@@ -101,7 +101,7 @@ func (c *quoteCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
 		Desc: "If no arguments are specified, returns a random quote. If a user is specified, returns a random quote from that user. If a quote index is specified, returns that specific quote.",
 		Params: []bot.CommandUsageParam{
 			{Name: "user", Desc: "A @user ping or simply the name of the user to quote.", Optional: true},
-			{Name: "quote", Desc: "A specific quote index. Use `" + info.Config.Basic.CommandPrefix + "searchquote` to find a quote index.", Optional: true},
+			{Name: "quote", Desc: "A specific quote index. Use `" + info.Prefix(bot.ChannelEmpty) + "searchquote` to find a quote index.", Optional: true},
 		},
 	}
 }
@@ -134,7 +134,7 @@ func (c *addquoteCommand) Process(args []string, msg *discordgo.Message, indices
 		info.Config.Quote.Quotes = make(map[bot.DiscordUser][]string)
 	}
 	info.Config.Quote.Quotes[user] = append(info.Config.Quote.Quotes[user], msg.Content[indices[1]:])
-	info.SaveConfig()
+	info.SaveConfigBy(bot.DiscordUser(msg.Author.ID))
 	return "```\nQuote added to " + info.GetUserName(user) + ".```", false, nil
 }
 func (c *addquoteCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
@@ -142,7 +142,7 @@ func (c *addquoteCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
 		Desc: "Adds a quote to the quote database for the given user. If the user is ambiguous, returns all possible matches.",
 		Params: []bot.CommandUsageParam{
 			{Name: "user", Desc: "A @user ping or simply the name of the user to quote. If the username has spaces, it must be in quotes.", Optional: false},
-			{Name: "quote", Desc: "A specific quote index. Use `" + info.Config.Basic.CommandPrefix + "searchquote` to find a quote index.", Optional: false},
+			{Name: "quote", Desc: "A specific quote index. Use `" + info.Prefix(bot.ChannelEmpty) + "searchquote` to find a quote index.", Optional: false},
 		},
 	}
 }
@@ -162,7 +162,7 @@ func (c *removequoteCommand) Process(args []string, msg *discordgo.Message, indi
 		return "```\nMust specify username.```", false, nil
 	}
 	if len(args) < 2 {
-		return "```\nMust specify quote index. Use " + info.Config.Basic.CommandPrefix + "searchquote to list them.```", false, nil
+		return "```\nMust specify quote index. Use " + info.Prefix(bot.DiscordChannel(msg.ChannelID)) + "searchquote to list them.```", false, nil
 	}
 
 	last := len(args) - 1
@@ -172,15 +172,15 @@ func (c *removequoteCommand) Process(args []string, msg *discordgo.Message, indi
 	}
 	index, err := strconv.Atoi(args[last])
 	if err != nil {
-		return "```\nError: could not parse quote index. Did you surround your username with quotes? Use " + info.Config.Basic.CommandPrefix + "searchquote to find a quote index.```", false, nil
+		return "```\nError: could not parse quote index. Did you surround your username with quotes? Use " + info.Prefix(bot.DiscordChannel(msg.ChannelID)) + "searchquote to find a quote index.```", false, nil
 	}
 
 	index--
 	if index >= len(info.Config.Quote.Quotes[user]) || index < 0 {
-		return "```\nInvalid quote index. Use " + info.Config.Basic.CommandPrefix + "searchquote [user] to list a user's quotes and their indexes.```", false, nil
+		return "```\nInvalid quote index. Use " + info.Prefix(bot.DiscordChannel(msg.ChannelID)) + "searchquote [user] to list a user's quotes and their indexes.```", false, nil
 	}
 	info.Config.Quote.Quotes[user] = append(info.Config.Quote.Quotes[user][:index], info.Config.Quote.Quotes[user][index+1:]...)
-	info.SaveConfig()
+	info.SaveConfigBy(bot.DiscordUser(msg.Author.ID))
 	return "```\nDeleted quote #" + strconv.Itoa(index+1) + " from " + info.GetUserName(user) + ".```", false, nil
 }
 func (c *removequoteCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
@@ -188,7 +188,7 @@ func (c *removequoteCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
 		Desc: "Removes the quote with the given quote index from the user's set of quotes. If the user is ambiguous, returns all possible matches.",
 		Params: []bot.CommandUsageParam{
 			{Name: "user", Desc: "A @user ping or simply the name of the user to quote. If the username has spaces, it must be in quotes.", Optional: false},
-			{Name: "quote", Desc: "A specific quote index. Use `" + info.Config.Basic.CommandPrefix + "searchquote` to find a quote index.", Optional: false},
+			{Name: "quote", Desc: "A specific quote index. Use `" + info.Prefix(bot.ChannelEmpty) + "searchquote` to find a quote index.", Optional: false},
 		},
 	}
 }
@@ -115,7 +115,7 @@ func (c *createRoleCommand) Process(args []string, msg *discordgo.Message, indic
 	}
 	roles := bot.FindRole(role, g)
 	if len(roles) > 0 {
-		return "```\nThat role already exists! Use " + info.Config.Basic.CommandPrefix + "addrole to make it user-assignable if it isn't already.```", false, nil
+		return "```\nThat role already exists! Use " + info.Prefix(bot.DiscordChannel(msg.ChannelID)) + "addrole to make it user-assignable if it isn't already.```", false, nil
 	}
 
 	r, err := info.Bot.DG.GuildRoleCreate(info.ID)
@@ -129,8 +129,8 @@ func (c *createRoleCommand) Process(args []string, msg *discordgo.Message, indic
 		info.Config.Users.Roles = make(map[bot.DiscordRole]bool)
 	}
 	info.Config.Users.Roles[bot.DiscordRole(r.ID)] = true
-	info.SaveConfig()
-	return fmt.Sprintf("```Created the %s role. By default, it has no permissions and can be pinged by users, but you can change these settings if you like. Use "+info.Config.Basic.CommandPrefix+"deleterole to delete it.```", r.Name), false, nil
+	info.SaveConfigBy(bot.DiscordUser(msg.Author.ID))
+	return fmt.Sprintf("```Created the %s role. By default, it has no permissions and can be pinged by users, but you can change these settings if you like. Use "+info.Prefix(bot.DiscordChannel(msg.ChannelID))+"deleterole to delete it.```", r.Name), false, nil
 }
 func (c *createRoleCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
 	return &bot.CommandUsage{
@@ -153,16 +153,12 @@ func (c *addRoleCommand) Info() *bot.CommandInfo {
 }
 
 func (c *addRoleCommand) Process(args []string, msg *discordgo.Message, indices []int, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
-	if len(args) < 1 {
-		return "```\nYou must either ping or provide the name of an existing role!```", false, nil
-	}
-	g, _ := info.GetGuild()
-	role, err := bot.ParseRole(msg.Content[indices[0]:], g)
-	if err != nil {
-		return bot.ReturnError(err)
-	}
-	if role == bot.RoleEmpty || role == bot.RoleExclusion {
-		return "```\nThat's not a role! Use " + info.Config.Basic.CommandPrefix + "createroll to create a new role.```", false, nil
+	return info.ProcessTyped(c, args, msg, indices)
+}
+func (c *addRoleCommand) ProcessArgs(args bot.CommandArgs, msg *discordgo.Message, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+	role := args.Role("name/id")
+	if role == bot.RoleExclusion {
+		return "```\nThat's not a role! Use " + info.Prefix(bot.DiscordChannel(msg.ChannelID)) + "createroll to create a new role.```", false, nil
 	}
 	if info.Config.Basic.ModRole == role {
 		return "```\nYou can't make the moderator role user-assignable you maniac!```", false, nil
@@ -181,7 +177,7 @@ func (c *addRoleCommand) Process(args []string, msg *discordgo.Message, indices
 				info.Config.Users.Roles = make(map[bot.DiscordRole]bool)
 			}
 			info.Config.Users.Roles[role] = true
-			info.SaveConfig()
+			info.SaveConfigBy(bot.DiscordUser(msg.Author.ID))
 			return "```\n" + v.Name + " is now a user-assignable role. You can change the name or permissions of the role without worrying about messing something up.```", false, nil
 		}
 	}
@@ -191,7 +187,7 @@ func (c *addRoleCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
 	return &bot.CommandUsage{
 		Desc: "Adds an existing role to the list of user-assignable roles.",
 		Params: []bot.CommandUsageParam{
-			{Name: "name/id", Desc: "Name or ping of an existing role.", Optional: false},
+			{Name: "name/id", Desc: "Name or ping of an existing role.", Optional: false, Variadic: true, Type: bot.ParamRole},
 		},
 	}
 }
@@ -226,7 +222,7 @@ func (c *joinRoleCommand) Process(args []string, msg *discordgo.Message, indices
 	if r.Mentionable {
 		pingable = " You may ping everyone in the role via @" + r.Name + ", but do so sparingly."
 	}
-	return fmt.Sprintf("```You now have the %s role. You can remove yourself from the role via "+info.Config.Basic.CommandPrefix+"leaverole %s, or list everyone in it via "+info.Config.Basic.CommandPrefix+"listrole %s.%s```", r.Name, r.Name, r.Name, pingable), false, nil
+	return fmt.Sprintf("```You now have the %s role. You can remove yourself from the role via "+info.Prefix(bot.DiscordChannel(msg.ChannelID))+"leaverole %s, or list everyone in it via "+info.Prefix(bot.DiscordChannel(msg.ChannelID))+"listrole %s.%s```", r.Name, r.Name, r.Name, pingable), false, nil
 }
 func (c *joinRoleCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
 	return &bot.CommandUsage{
@@ -356,12 +352,12 @@ func (c *removeRoleCommand) Process(args []string, msg *discordgo.Message, indic
 		return bot.ReturnError(info.ResolveRoleAddError(err))
 	}
 	delete(info.Config.Users.Roles, bot.DiscordRole(r.ID))
-	info.SaveConfig()
-	return fmt.Sprintf("```The %s role is no longer user-assignable, but it has NOT been deleted! Use "+info.Config.Basic.CommandPrefix+"deleterole to delete a user-assignable role.```", r.Name), false, nil
+	info.SaveConfigBy(bot.DiscordUser(msg.Author.ID))
+	return fmt.Sprintf("```The %s role is no longer user-assignable, but it has NOT been deleted! Use "+info.Prefix(bot.DiscordChannel(msg.ChannelID))+"deleterole to delete a user-assignable role.```", r.Name), false, nil
 }
 func (c *removeRoleCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
 	return &bot.CommandUsage{
-		Desc: "Removes a role from the list of user-assignable roles, but DOES NOT DELETE IT. If you want to also delete the role, use " + info.Config.Basic.CommandPrefix + "deleterole.",
+		Desc: "Removes a role from the list of user-assignable roles, but DOES NOT DELETE IT. If you want to also delete the role, use " + info.Prefix(bot.ChannelEmpty) + "deleterole.",
 		Params: []bot.CommandUsageParam{
 			{Name: "name", Desc: "Name or ping of the role you no longer want user-assignable.", Optional: false},
 		},
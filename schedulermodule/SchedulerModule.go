@@ -28,8 +28,11 @@ const ( // We don't use iota here because this must match the database values ex
 	typeEventRole       = 7
 	typeEventSilence    = 8
 	typeEventRemoveRole = 9
+	typeEventUnsilence  = bot.ScheduleUnsilence // Silence timeouts set by the spam filter, as opposed to temporary silences given by moderators
 )
 
+const maxScheduleResults = 100 // The most events !schedule will list. Anything past the first page is paginated.
+
 // New SchedulerModule
 func New() *SchedulerModule {
 	return &SchedulerModule{}
@@ -105,11 +108,12 @@ func (w *SchedulerModule) OnTick(info *bot.GuildInfo, t time.Time) {
 			if err != nil {
 				info.SendMessage(info.Config.Basic.ModChannel, "Error unbanning <@"+v.Data+">: "+err.Error())
 			} else {
-				info.SendMessage(info.Config.Basic.ModChannel, "Unbanned <@"+v.Data+">")
+				id := info.AddCase(bot.CaseUnban, v.Data, info.Bot.SelfID, "Temporary ban expired", "")
+				info.SendMessage(info.Config.Basic.ModChannel, "Unbanned <@"+v.Data+">"+bot.CaseSuffix(id))
 			}
 		case typeEventBirthday:
 			if info.Config.Scheduler.BirthdayRole == bot.RoleEmpty {
-				info.Log("No birthday role set!")
+				info.Logger().Warning("No birthday role set!")
 			} else {
 				err := info.ResolveRoleAddError(info.Bot.DG.GuildMemberRoleAdd(info.ID, v.Data, info.Config.Scheduler.BirthdayRole.String()))
 				info.LogError("Failed to set birthday role: ", err)
@@ -121,7 +125,7 @@ func (w *SchedulerModule) OnTick(info *bot.GuildInfo, t time.Time) {
 			info.SendMessage(channel, v.Data+" is starting now!")
 		case typeEventUnbirthday:
 			if info.Config.Scheduler.BirthdayRole == bot.RoleEmpty {
-				info.Log("No birthday role set!")
+				info.Logger().Warning("No birthday role set!")
 			} else {
 				err := info.ResolveRoleAddError(info.Bot.DG.GuildMemberRoleRemove(info.ID, v.Data, info.Config.Scheduler.BirthdayRole.String()))
 				info.LogError("Failed to remove birthday role: ", err)
@@ -141,7 +145,16 @@ func (w *SchedulerModule) OnTick(info *bot.GuildInfo, t time.Time) {
 			if err != nil {
 				info.SendMessage(info.Config.Basic.ModChannel, "Error unsilencing <@"+v.Data+">: "+err.Error())
 			} else {
-				info.SendMessage(info.Config.Basic.ModChannel, "Unsilenced <@"+v.Data+">")
+				id := info.AddCase(bot.CaseUnsilence, v.Data, info.Bot.SelfID, "Temporary silence expired", "")
+				info.SendMessage(info.Config.Basic.ModChannel, "Unsilenced <@"+v.Data+">"+bot.CaseSuffix(id))
+			}
+		case typeEventUnsilence:
+			err := info.ResolveRoleAddError(info.Bot.DG.RemoveRole(info.ID, bot.DiscordUser(v.Data), info.Config.Basic.SilenceRole))
+			if err != nil {
+				info.SendMessage(info.Config.Basic.ModChannel, "```\nError unsilencing member: "+err.Error()+"```")
+			} else {
+				id := info.AddCase(bot.CaseUnsilence, v.Data, info.Bot.SelfID, "Silence timeout expired", "")
+				info.SendMessage(info.Config.Basic.ModChannel, "```\nUnsilenced "+info.GetUserName(bot.DiscordUser(v.Data))+bot.CaseSuffix(id)+".```")
 			}
 		case typeEventRemoveRole:
 			dat := strings.SplitN(v.Data, "|", 2)
@@ -196,13 +209,13 @@ func (c *scheduleCommand) Process(args []string, msg *discordgo.Message, indices
 			}
 		}
 	}
-	if maxresults > 20 {
-		maxresults = 20
+	if maxresults > maxScheduleResults {
+		maxresults = maxScheduleResults
 	}
 	if maxresults < 1 {
 		maxresults = 1
 	}
-	if !info.UserIsMod(bot.DiscordUser(msg.Author.ID)) && !info.UserIsAdmin(bot.DiscordUser(msg.Author.ID)) && (ty == 0 || ty == 4 || ty == 8) {
+	if !info.UserIsMod(bot.DiscordUser(msg.Author.ID)) && !info.UserIsAdmin(bot.DiscordUser(msg.Author.ID)) && (ty == typeEventBan || ty == typeEventUnbirthday || ty == typeEventSilence || ty == typeEventUnsilence) {
 		return "```\nYou aren't allowed to view those events.```", false, nil
 	}
 	var events []bot.ScheduleEvent
@@ -216,8 +229,7 @@ func (c *scheduleCommand) Process(args []string, msg *discordgo.Message, indices
 	if len(events) == 0 {
 		return "There are no upcoming events.", false, nil
 	}
-	lines := make([]string, len(events)+1, len(events)+1)
-	lines[0] = "Upcoming Events:"
+	lines := make([]string, len(events), len(events))
 	for k, v := range events {
 		t := ""
 		if v.Date.Year() == timestamp.Year() {
@@ -247,21 +259,27 @@ func (c *scheduleCommand) Process(args []string, msg *discordgo.Message, indices
 			datas := strings.SplitN(data, "|", 2)
 			mt = "ROLE:" + bot.ReplaceAllRolePings(datas[0], info)
 			data = datas[1]
+		case typeEventSilence:
+			mt = "UNSILENCE"
+			data = "<@" + data + ">"
+		case typeEventUnsilence:
+			mt = "TIMEOUT"
+			data = "<@" + data + ">"
 		case typeEventRemoveRole:
 			datas := strings.SplitN(data, "|", 2)
 			mt = "REMOVAL:" + bot.DiscordRole(datas[1]).Show(info)
 			data = "<@" + datas[0] + ">"
 		}
-		lines[k+1] = fmt.Sprintf("#%v **%s** [%s] %s", bot.SBitoa(v.ID), t, mt, info.Sanitize(data, bot.CleanMentions|bot.CleanPings|bot.CleanEmotes))
+		lines[k] = fmt.Sprintf("#%v **%s** [%s] %s", bot.SBitoa(v.ID), t, mt, info.Sanitize(data, bot.CleanMentions|bot.CleanPings|bot.CleanEmotes))
 	}
 
-	return strings.Join(lines, "\n"), len(lines) > 6, nil
+	return "", false, info.Paginate(msg, bot.PageLines(&discordgo.MessageEmbed{Title: "Upcoming Events", Color: 0x3e92e5}, lines, 10))
 }
 func (c *scheduleCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
 	return &bot.CommandUsage{
-		Desc: "Lists up to `maxresults` upcoming events from the schedule. If the first argument is specified, lists only events of that type. Some event types can only be viewed by moderators. Max results: 20",
+		Desc: "Lists up to `maxresults` upcoming events from the schedule. If the first argument is specified, lists only events of that type. Some event types can only be viewed by moderators. Max results: " + strconv.Itoa(maxScheduleResults),
 		Params: []bot.CommandUsageParam{
-			{Name: "type", Desc: "Can be one of: bans, birthdays, messages, episodes, events, roles, reminders.", Optional: true},
+			{Name: "type", Desc: "Can be one of: bans, birthdays, messages, episodes, events, roles, reminders, silences, timeouts. Bans, silences and timeouts can only be viewed by moderators.", Optional: true},
 			{Name: "maxresults", Desc: "Defaults to 5.", Optional: true},
 		},
 	}
@@ -285,6 +303,8 @@ func getScheduleType(s string) uint8 {
 		return typeEventRole
 	case "silences", "silence":
 		return typeEventSilence
+	case "timeouts", "timeout":
+		return typeEventUnsilence
 	case "removals", "removal":
 		return typeEventRemoveRole
 	}
@@ -364,7 +384,7 @@ func (c *addEventCommand) Process(args []string, msg *discordgo.Message, indices
 		return "```\nError: Invalid type specified.```", false, nil
 	}
 	if ty == typeEventReminder {
-		return "```\nError: You cannot add a reminder event this way. Use " + info.Config.Basic.CommandPrefix + "remindme instead.```", false, nil
+		return "```\nError: You cannot add a reminder event this way. Use " + info.Prefix(bot.DiscordChannel(msg.ChannelID)) + "remindme instead.```", false, nil
 	}
 	data := ""
 	if ty == typeEventRole {
@@ -415,7 +435,7 @@ func (c *addEventCommand) Process(args []string, msg *discordgo.Message, indices
 }
 func (c *addEventCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
 	return &bot.CommandUsage{
-		Desc: "Adds an arbitrary event to the schedule table. For example: `" + info.Config.Basic.CommandPrefix + "addevent message \"12 Jun 16\" \"REPEAT 1 YEAR\" happy birthday!`, or `" + info.Config.Basic.CommandPrefix + "addevent episode \"9 Dec 15\" Slice of Life`. ",
+		Desc: "Adds an arbitrary event to the schedule table. For example: `" + info.Prefix(bot.ChannelEmpty) + "addevent message \"12 Jun 16\" \"REPEAT 1 YEAR\" happy birthday!`, or `" + info.Prefix(bot.ChannelEmpty) + "addevent episode \"9 Dec 15\" Slice of Life`. ",
 		Params: []bot.CommandUsageParam{
 			{Name: "type", Desc: "Can be one of: ban, message, episode, event, role.", Optional: false},
 			{Name: "role", Desc: "A ping of the role that should be notified. Only include this when using the role event type.", Optional: true},
@@ -472,7 +492,7 @@ func (c *removeEventCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
 	return &bot.CommandUsage{
 		Desc: "Removes an event with the given ID from the schedule. ",
 		Params: []bot.CommandUsageParam{
-			{Name: "ID", Desc: "The event ID as gotten from a `" + info.Config.Basic.CommandPrefix + "schedule` command.", Optional: false},
+			{Name: "ID", Desc: "The event ID as gotten from a `" + info.Prefix(bot.ChannelEmpty) + "schedule` command.", Optional: false},
 		},
 	}
 }
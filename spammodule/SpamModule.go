@@ -1,9 +1,9 @@
 package spammodule
 
 import (
-	"container/heap"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -18,47 +18,19 @@ type userPressure struct {
 	lastcache   string
 }
 
-type userTimeout struct {
-	user bot.DiscordUser
-	time time.Time
-}
-
-type userTimeoutHeap []userTimeout
-
-func (h userTimeoutHeap) Len() int           { return len(h) }
-func (h userTimeoutHeap) Less(i, j int) bool { return h[i].time.Before(h[j].time) }
-func (h userTimeoutHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
-func (h userTimeoutHeap) Peek() *userTimeout { return &h[0] }
-
-func (h *userTimeoutHeap) Push(x interface{}) {
-	*h = append(*h, x.(userTimeout))
-}
-
-func (h *userTimeoutHeap) Pop() interface{} {
-	old := *h
-	n := len(old)
-	item := old[n-1]
-	*h = old[0 : n-1]
-	return item
-}
-
 // SpamModule detects banned emotes and deletes them
 type SpamModule struct {
 	tracker      sync.Map                    //map[bot.DiscordUser]*userPressure
 	lockdown     discordgo.VerificationLevel // if -1 no lockdown was initiated, otherwise remembers the previous lockdown setting
 	lastlockdown time.Time
-	timeouts     *userTimeoutHeap
-	timeoutLock  sync.Mutex
+	lastraid     int64 // Unix time of the last raid this module detected
 }
 
 // New spam module
 func New() *SpamModule {
-	w := &SpamModule{
+	return &SpamModule{
 		lockdown: -1,
-		timeouts: &userTimeoutHeap{},
 	}
-	heap.Init(w.timeouts)
-	return w
 }
 
 // Name of the module
@@ -82,21 +54,80 @@ func (w *SpamModule) Description() string {
 	return "Tracks all channels it is active on for spammers. Each message someone sends generates \"pressure\", which decays rapidly. Long messages, messages with links, or messages with pings will generate more pressure. If a user generates too much pressure, they will be silenced and the moderators notified. Also detects groups of people joining at the same time and alerts the moderators of a potential raid."
 }
 
+// Subscribe to events from other modules
+func (w *SpamModule) Subscribe(info *bot.GuildInfo) {
+	info.SubscribeFilterTriggered(w.onFilterTriggered)
+}
+
+// Filters can add pressure to whoever triggered them, on top of the pressure from the message itself
+func (w *SpamModule) onFilterTriggered(info *bot.GuildInfo, e *bot.FilterTriggered) {
+	if e.Pressure > 0.0 {
+		w.AddPressure(info, e.Message, w.TrackUser(bot.DiscordUser(e.Message.Author.ID), bot.GetTimestamp(e.Message)), e.Pressure, "triggering the "+e.Filter+" filter")
+	}
+}
+
 // OnTick discord hook
 func (w *SpamModule) OnTick(info *bot.GuildInfo, t time.Time) {
 	if w.lockdown != -1 && t.Sub(w.lastlockdown) > (time.Duration(info.Config.Spam.LockdownDuration)*time.Second) {
 		w.DisableLockdown(info)
 	}
-	w.timeoutLock.Lock()
-	for w.timeouts.Len() > 0 && w.timeouts.Peek().time.Before(t) {
-		u := heap.Pop(w.timeouts).(userTimeout).user
-		err := info.Bot.DG.RemoveRole(info.ID, u, info.Config.Basic.SilenceRole)
-		if err != nil {
-			info.SendMessage(info.Config.Basic.ModChannel, "```\nError unsilencing member: "+err.Error()+"```")
+}
+
+// spamState is what the spam module remembers across a restart
+type spamState struct {
+	Lockdown     discordgo.VerificationLevel       `json:"lockdown"`
+	LastLockdown time.Time                         `json:"lastlockdown"`
+	LastRaid     int64                             `json:"lastraid"`
+	Pressure     map[bot.DiscordUser]savedPressure `json:"pressure"`
+}
+
+type savedPressure struct {
+	Pressure    float32 `json:"pressure"`
+	LastMessage int64   `json:"lastmessage"`
+	LastCache   string  `json:"lastcache"`
+}
+
+// SaveState remembers any lockdown that still has to be lifted, when the last raid happened, and everyone whose
+// pressure hasn't decayed yet, so restarting in the middle of a raid doesn't let it carry on
+func (w *SpamModule) SaveState(info *bot.GuildInfo) ([]byte, error) {
+	state := spamState{Lockdown: w.lockdown, LastLockdown: w.lastlockdown, LastRaid: w.lastraid, Pressure: make(map[bot.DiscordUser]savedPressure)}
+	now := time.Now().UTC()
+	millis := now.Unix()*1000 + int64(now.Nanosecond()/1000000)
+	w.tracker.Range(func(k, v interface{}) bool {
+		track := v.(*userPressure)
+		decayed := info.Config.Spam.BasePressure * (float32(millis-track.lastmessage) / (info.Config.Spam.PressureDecay * 1000.0))
+		if track.pressure > decayed {
+			state.Pressure[k.(bot.DiscordUser)] = savedPressure{track.pressure, track.lastmessage, track.lastcache}
 		}
-		info.SendMessage(info.Config.Basic.ModChannel, "```\nUnsilenced "+info.GetUserName(u)+".```")
+		return true
+	})
+	if state.Lockdown == -1 && state.LastRaid == 0 && len(state.Pressure) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(&state)
+}
+
+// LoadState restores what SaveState saved. A lockdown that was still engaged is lifted by OnTick as usual.
+func (w *SpamModule) LoadState(info *bot.GuildInfo, data []byte) error {
+	state := spamState{}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	w.lockdown = state.Lockdown
+	w.lastlockdown = state.LastLockdown
+	w.lastraid = state.LastRaid
+	for user, p := range state.Pressure {
+		w.tracker.Store(user, &userPressure{p.Pressure, p.LastMessage, p.LastCache})
+	}
+	return nil
+}
+
+// scheduleUnsilence adds a silence timeout to the schedule, so it still happens if the bot restarts in the meantime
+func scheduleUnsilence(info *bot.GuildInfo, user string, t time.Time) error {
+	if !info.Bot.DB.Status.Get() {
+		return errors.New("the database is unavailable")
 	}
-	w.timeoutLock.Unlock()
+	return info.Bot.DB.AddSchedule(bot.SBatoi(info.ID), t, bot.ScheduleUnsilence, user)
 }
 
 func silenceMember(user *discordgo.User, info *bot.GuildInfo) int8 {
@@ -147,7 +178,8 @@ func (w *SpamModule) killSpammer(u *discordgo.User, info *bot.GuildInfo, msg *di
 	logmsg := fmt.Sprintf("Killing spammer %s (pressure: %v -> %v). Last message sent on #%s in %s: \n%s%s", u.Username, oldpressure, newpressure, chname, info.Name, lastmsg, msgembeds)
 	if info.Config.Users.WelcomeChannel.Equals(msg.ChannelID) {
 		info.Bot.DG.GuildBanCreateWithReason(info.ID, u.ID, "Autobanned for "+reason+" in the welcome channel.", 1)
-		info.SendMessage(info.Config.Basic.ModChannel, "Alert: <@"+u.ID+"> was banned for "+reason+" in the welcome channel.")
+		id := info.AddCase(bot.CaseBan, u.ID, info.Bot.SelfID, "Autobanned for "+reason+" in the welcome channel. Last message: "+lastmsg, "")
+		info.SendMessage(info.Config.Basic.ModChannel, "Alert: <@"+u.ID+"> was banned for "+reason+" in the welcome channel"+bot.CaseSuffix(id)+".")
 		info.Log(logmsg)
 		return
 	}
@@ -182,14 +214,23 @@ func (w *SpamModule) killSpammer(u *discordgo.User, info *bot.GuildInfo, msg *di
 
 	if !silenced { // Only send the alert if they weren't silenced already
 		addmsg := "."
+		duration := ""
 		if info.Config.Spam.SilenceTimeout > 0 {
 			timeout := time.Duration(info.Config.Spam.SilenceTimeout) * time.Second
-			addmsg = ", or they will be unsilenced automatically in " + bot.TimeDiff(timeout)
-			w.timeoutLock.Lock()
-			heap.Push(w.timeouts, userTimeout{bot.DiscordUser(u.ID), timestamp.Add(timeout)})
-			w.timeoutLock.Unlock()
+			if err := scheduleUnsilence(info, u.ID, timestamp.Add(timeout)); err != nil {
+				info.LogError("Failed to schedule silence timeout: ", err)
+				addmsg = ". They could not be scheduled to be unsilenced automatically, so they will stay silenced until a moderator unsilences them."
+			} else if _, disabled := info.Config.Modules.Disabled["scheduler"]; disabled {
+				addmsg = ". They were scheduled to be unsilenced in " + bot.TimeDiff(timeout) + ", but the Scheduler module is disabled, so this won't happen until it's enabled again."
+				duration = bot.TimeDiff(timeout)
+			} else {
+				addmsg = ", or they will be unsilenced automatically in " + bot.TimeDiff(timeout)
+				duration = bot.TimeDiff(timeout)
+			}
 		}
-		info.SendMessage(info.Config.Basic.ModChannel, "Alert: <@"+u.ID+"> was silenced for "+reason+". Please investigate"+addmsg) // Alert admins
+		id := info.AddCase(bot.CaseSilence, u.ID, info.Bot.SelfID, "Silenced for "+reason+". Last message: "+lastmsg, duration)
+		info.Publish(&bot.MemberSilenced{User: bot.DiscordUser(u.ID), Moderator: info.Bot.SelfID, Reason: reason, Case: id, Spam: true})
+		info.SendMessage(info.Config.Basic.ModChannel, "Alert: <@"+u.ID+"> was silenced for "+reason+bot.CaseSuffix(id)+". Please investigate"+addmsg) // Alert admins
 		info.Log(logmsg)
 	} else {
 		info.Log("Killing spammer " + u.Username)
@@ -202,7 +243,7 @@ func (w *SpamModule) TrackUser(author bot.DiscordUser, timestamp time.Time) *use
 	return v.(*userPressure)
 }
 
-// AddPressure to a user and checks to see if it goes over the limit. Used to supplement spam module via filter module
+// AddPressure to a user and checks to see if it goes over the limit
 func (w *SpamModule) AddPressure(info *bot.GuildInfo, m *discordgo.Message, track *userPressure, p float32, reason string) bool {
 	old := track.pressure
 
@@ -212,6 +253,9 @@ func (w *SpamModule) AddPressure(info *bot.GuildInfo, m *discordgo.Message, trac
 	}
 
 	track.pressure += p
+	if p != 0.0 {
+		info.Publish(&bot.PressureAdded{User: bot.DiscordUser(m.Author.ID), Message: m, Pressure: p, Total: track.pressure, Reason: reason})
+	}
 	if track.pressure > info.Config.Spam.MaxPressure {
 		w.killSpammer(m.Author, info, m, reason, old, track.pressure)
 		return true
@@ -326,21 +370,24 @@ func (w *SpamModule) checkRaid(info *bot.GuildInfo, m *discordgo.Member, t time.
 		return
 	}
 	raidsize := info.Bot.DB.CountNewUsers(info.Config.Spam.RaidTime, bot.SBatoi(info.ID))
-	if info.Config.Spam.RaidSize > 0 && raidsize >= info.Config.Spam.RaidSize && bot.RateLimit(&info.LastRaid, info.Config.Spam.RaidTime*2, t.Unix()) {
+	if info.Config.Spam.RaidSize > 0 && raidsize >= info.Config.Spam.RaidSize && bot.RateLimit(&w.lastraid, info.Config.Spam.RaidTime*2, t.Unix()) {
 		r := info.Bot.DB.GetNewestUsers(raidsize, bot.SBatoi(info.ID))
 		s := make([]string, 0, len(r))
+		users := make([]*discordgo.User, 0, len(r))
 
 		for _, v := range r {
+			users = append(users, v.User)
 			s = append(s, v.User.Username+"  (joined: "+info.ApplyTimezone(v.FirstSeen, bot.UserEmpty).Format(time.ANSIC)+")")
 			if info.Config.Spam.RaidSilence >= 1 {
 				silenceMember(v.User, info)
 			}
 		}
+		info.Publish(&bot.RaidDetected{Users: users, Time: t})
 		ch := info.Config.Basic.ModChannel
 		if info.Bot.Debug {
 			ch, _ = info.Bot.DebugChannels[bot.DiscordGuild(info.ID)]
 		}
-		message := "Use `" + info.Config.Basic.CommandPrefix + "raidsilence all` to silence them!"
+		message := "Use `" + info.Prefix(ch) + "raidsilence all` to silence them!"
 		if info.Config.Spam.RaidSilence > 0 {
 			message = "RaidSilence has been engaged and the following users silenced:"
 		}
@@ -357,9 +404,9 @@ func (w *SpamModule) checkRaid(info *bot.GuildInfo, m *discordgo.Member, t time.
 				g := discordgo.GuildParams{"", "", &level, 0, "", 0, "", "", ""}
 				_, err = info.Bot.DG.GuildEdit(info.ID, g)
 				if err != nil {
-					info.SendMessage(ch, "Could not engage lockdown! Make sure you've given "+info.GetBotName()+" the Manage Server permission, or disable the lockdown entirely via `"+info.Config.Basic.CommandPrefix+"setconfig spam.lockdownduration 0`.")
+					info.SendMessage(ch, "Could not engage lockdown! Make sure you've given "+info.GetBotName()+" the Manage Server permission, or disable the lockdown entirely via `"+info.Prefix(ch)+"setconfig spam.lockdownduration 0`.")
 				} else {
-					info.SendMessage(ch, fmt.Sprintf("Lockdown engaged! Server verification level will be reset in %v seconds. This lockdown can be manually ended via `"+info.Config.Basic.CommandPrefix+"raidsilence off/alert/log`.", info.Config.Spam.LockdownDuration))
+					info.SendMessage(ch, fmt.Sprintf("Lockdown engaged! Server verification level will be reset in %v seconds. This lockdown can be manually ended via `"+info.Prefix(ch)+"raidsilence off/alert/log`.", info.Config.Spam.LockdownDuration))
 				}
 			}
 			// Otherwise just reset the timer
@@ -370,7 +417,7 @@ func (w *SpamModule) checkRaid(info *bot.GuildInfo, m *discordgo.Member, t time.
 
 // OnGuildMemberAdd discord hook
 func (w *SpamModule) OnGuildMemberAdd(info *bot.GuildInfo, m *discordgo.Member, t time.Time) {
-	if info.Config.Spam.RaidSilence >= 2 || (info.Config.Spam.RaidSilence >= 1 && ((info.LastRaid + info.Config.Spam.RaidTime*2) > t.Unix())) {
+	if info.Config.Spam.RaidSilence >= 2 || (info.Config.Spam.RaidSilence >= 1 && ((w.lastraid + info.Config.Spam.RaidTime*2) > t.Unix())) {
 		silenceMember(m.User, info)
 		if len(info.Config.Users.WelcomeMessage) > 0 {
 			info.SendMessage(info.Config.Users.WelcomeChannel, "<@"+m.User.ID+"> "+info.Config.Users.WelcomeMessage)
@@ -385,10 +432,10 @@ func (w *SpamModule) OnGuildMemberUpdate(info *bot.GuildInfo, m *discordgo.Membe
 }
 
 func (w *SpamModule) getRaidUsers(info *bot.GuildInfo) []*discordgo.User {
-	return info.Bot.DB.GetRecentUsers(time.Unix(info.LastRaid-info.Config.Spam.RaidTime, 0).UTC(), bot.SBatoi(info.ID))
+	return info.Bot.DB.GetRecentUsers(time.Unix(w.lastraid-info.Config.Spam.RaidTime, 0).UTC(), bot.SBatoi(info.ID))
 }
 func (w *SpamModule) isRecentRaid(info *bot.GuildInfo, t time.Time) bool {
-	return info.LastRaid+info.Config.Spam.RaidTime*2 > t.Unix()
+	return w.lastraid+info.Config.Spam.RaidTime*2 > t.Unix()
 }
 
 type raidSilenceCommand struct {
@@ -420,13 +467,13 @@ func (c *raidSilenceCommand) Process(args []string, msg *discordgo.Message, indi
 	if len(args) > 1 {
 		subtract, _ = strconv.ParseInt(args[1], 10, 64)
 	}
-	info.LastRaid = timestamp.Unix() - subtract
-	fmt.Println(time.Unix(info.LastRaid, 0))*/
+	c.s.lastraid = timestamp.Unix() - subtract
+	fmt.Println(time.Unix(c.s.lastraid, 0))*/
 	default:
 		return "```\nOnly all, raid, and off are valid raid silence levels.```", false, nil
 	}
 
-	info.SaveConfig()
+	info.SaveConfigBy(bot.DiscordUser(msg.Author.ID))
 
 	if info.Config.Spam.RaidSilence <= 0 {
 		c.s.DisableLockdown(info)
@@ -504,15 +551,10 @@ func (c *wipeCommand) WipeMessages(ch *discordgo.Channel, num int, seconds int,
 	return ret, nil
 }
 func (c *wipeCommand) Process(args []string, msg *discordgo.Message, indices []int, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
-	if len(args) < 2 {
-		return "```\nYou must specify the channel and the duration.```", false, nil
-	}
-
-	g, _ := info.GetGuild()
-	ch, err := bot.ParseChannel(args[0], g)
-	if err != nil {
-		return bot.ReturnError(err)
-	}
+	return info.ProcessTyped(c, args, msg, indices)
+}
+func (c *wipeCommand) ProcessArgs(args bot.CommandArgs, msg *discordgo.Message, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+	ch := args.Channel("channel")
 	channel, private := info.Bot.ChannelIsPrivate(ch)
 	if private {
 		return "```\nCan't delete messages in a PM!```", false, nil
@@ -520,12 +562,10 @@ func (c *wipeCommand) Process(args []string, msg *discordgo.Message, indices []i
 	if channel == nil || channel.GuildID != info.ID {
 		return "```\nThat channel isn't on this server!```", false, nil
 	}
-	num, err := strconv.Atoi(args[1])
+	num := int(args.Int("seconds", 0))
 	timestamp := bot.GetTimestamp(msg)
-	if err != nil || num <= 0 {
-		return "```\nThere's no point deleting 0 messages!.```", false, nil
-	}
-	if len(args) > 2 && strings.ToLower(args[2]) == "messages" {
+	var err error
+	if args.Has("MESSAGES") {
 		num, err = c.WipeMessages(channel, num, 0, timestamp, info)
 	} else {
 		num, err = c.WipeMessages(channel, 9999, num, timestamp, info)
@@ -533,15 +573,16 @@ func (c *wipeCommand) Process(args []string, msg *discordgo.Message, indices []i
 	if err != nil {
 		return "```\nError retrieving messages. Are you sure you gave " + info.GetBotName() + " a channel that exists? This won't work in PMs! " + err.Error() + "```", false, nil
 	}
-	return fmt.Sprintf("Deleted %v messages in <#%s>.", num, ch), false, nil
+	id := info.AddCase(bot.CaseWipe, ch.String(), bot.DiscordUser(msg.Author.ID), fmt.Sprintf("Deleted %v messages", num), "", msg)
+	return fmt.Sprintf("Deleted %v messages in <#%s>%s.", num, ch, bot.CaseSuffix(id)), false, nil
 }
 func (c *wipeCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
 	return &bot.CommandUsage{
 		Desc: "Removes all messages in a channel sent within the last N seconds, or simply removes the last N messages if \"messages\" is appended.",
 		Params: []bot.CommandUsageParam{
-			{Name: "channel", Desc: "The channel to delete from. You must use the #channel format so discord actually highlights the channel, otherwise it won't work.", Optional: false},
-			{Name: "seconds", Desc: "Specifies the number of seconds to look back. The command deletes all messages sent up to this many seconds ago.", Optional: false},
-			{Name: "MESSAGES", Desc: "If you append \"MESSAGES\" to the end of the command, it will remove that many messages, instead of looking back that many seconds.", Optional: true},
+			{Name: "channel", Desc: "The channel to delete from. You must use the #channel format so discord actually highlights the channel, otherwise it won't work.", Optional: false, Type: bot.ParamChannel},
+			{Name: "seconds", Desc: "Specifies the number of seconds to look back. The command deletes all messages sent up to this many seconds ago.", Optional: false, Type: bot.ParamInt, Min: 1, Max: 1000000},
+			{Name: "MESSAGES", Desc: "If you append \"MESSAGES\" to the end of the command, it will remove that many messages, instead of looking back that many seconds.", Optional: true, Type: bot.ParamEnum, Values: []string{"messages"}},
 		},
 	}
 }
@@ -559,16 +600,10 @@ func (c *getPressureCommand) Info() *bot.CommandInfo {
 }
 
 func (c *getPressureCommand) Process(args []string, msg *discordgo.Message, indices []int, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
-	if len(args) < 1 {
-		return "```\nYou must provide a user to search for.```", false, nil
-	}
-
-	user, err := bot.ParseUser(msg.Content[indices[0]:], info)
-	if err != nil {
-		return bot.ReturnError(err)
-	}
-
-	u, ok := c.s.tracker.Load(user)
+	return info.ProcessTyped(c, args, msg, indices)
+}
+func (c *getPressureCommand) ProcessArgs(args bot.CommandArgs, msg *discordgo.Message, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+	u, ok := c.s.tracker.Load(args.User("user"))
 	if !ok {
 		return "0", false, nil
 	}
@@ -578,7 +613,7 @@ func (c *getPressureCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
 	return &bot.CommandUsage{
 		Desc: "Gets the current spam pressure of a user.",
 		Params: []bot.CommandUsageParam{
-			{Name: "user", Desc: "User to retrieve pressure from.", Optional: false},
+			{Name: "user", Desc: "User to retrieve pressure from.", Optional: false, Variadic: true, Type: bot.ParamUser},
 		},
 	}
 }
@@ -627,10 +662,12 @@ func (c *banRaidCommand) Process(args []string, msg *discordgo.Message, indices
 	reason := fmt.Sprintf("Banned by %s#%s via the !banraid command.", msg.Author.Username, msg.Author.Discriminator)
 	users := c.s.getRaidUsers(info)
 	for _, v := range users {
-		info.Bot.DG.GuildBanCreateWithReason(info.ID, v.ID, reason, 1)
+		if info.Bot.DG.GuildBanCreateWithReason(info.ID, v.ID, reason, 1) == nil {
+			info.AddCase(bot.CaseBan, v.ID, bot.DiscordUser(msg.Author.ID), "Part of a raid", "", msg)
+		}
 	}
 	return fmt.Sprintf("```\nBanned %v users. The ban log will reflect who ran this command.```", len(users)), false, nil
 }
 func (c *banRaidCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
-	return &bot.CommandUsage{Desc: "Bans all users that are considered part of the most recent raid, if there was one. Use " + info.Config.Basic.CommandPrefix + "getraid to check who will be banned before using this command."}
+	return &bot.CommandUsage{Desc: "Bans all users that are considered part of the most recent raid, if there was one. Use " + info.Prefix(bot.ChannelEmpty) + "getraid to check who will be banned before using this command."}
 }
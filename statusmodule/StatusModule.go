@@ -102,7 +102,7 @@ func (c *addStatusCommand) Process(args []string, msg *discordgo.Message, indice
 		return "```\n" + arg + " is already in the status rotation!```", false, nil
 	}
 	info.Config.Status.Lines[arg] = true
-	info.SaveConfig()
+	info.SaveConfigBy(bot.DiscordUser(msg.Author.ID))
 	return "```\nAdded " + arg + " to the status rotation.```", false, nil
 }
 func (c *addStatusCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
@@ -138,12 +138,12 @@ func (c *removeStatusCommand) Process(args []string, msg *discordgo.Message, ind
 		return "```\n" + arg + " is not in the status rotation!```", false, nil
 	}
 	delete(info.Config.Status.Lines, arg)
-	info.SaveConfig()
+	info.SaveConfigBy(bot.DiscordUser(msg.Author.ID))
 	return "```\nRemoved " + arg + " from the status rotation.```", false, nil
 }
 func (c *removeStatusCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
 	return &bot.CommandUsage{
-		Desc: "Removes a string to the discord status rotation. Use " + info.Config.Basic.CommandPrefix + "getconfig status.lines to get a list of all strings currently in rotation.",
+		Desc: "Removes a string to the discord status rotation. Use " + info.Prefix(bot.ChannelEmpty) + "getconfig status.lines to get a list of all strings currently in rotation.",
 		Params: []bot.CommandUsageParam{
 			{Name: "arbitrary string", Desc: "Status string that must exactly match the one you want to remove.", Optional: false},
 		},
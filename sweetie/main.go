@@ -6,7 +6,9 @@ import (
 	"../boredmodule"
 	"../bucketmodule"
 	"../countersmodule"
+	"../customcommandsmodule"
 	"../filtermodule"
+	"../loggingmodule"
 	"../markovmodule"
 	"../miscmodule"
 	"../quotemodule"
@@ -17,11 +19,12 @@ import (
 	"../sweetiebot"
 	"../tagmodule"
 	"../usersmodule"
+	"../voicemodule"
 	"../wittymodule"
 )
 
 func loader(guild *sweetiebot.GuildInfo) []sweetiebot.Module {
-	modules := make([]sweetiebot.Module, 0, 18)
+	modules := make([]sweetiebot.Module, 0, 20)
 	modules = append(modules, &sweetiebot.InfoModule{})
 	modules = append(modules, &sweetiebot.ConfigModule{})
 	modules = append(modules, &sweetiebot.DebugModule{})
@@ -37,9 +40,11 @@ func loader(guild *sweetiebot.GuildInfo) []sweetiebot.Module {
 	modules = append(modules, miscmodule.New())
 	modules = append(modules, countersmodule.New())
 	modules = append(modules, wittymodule.New(guild))
-	spam := spammodule.New()
-	modules = append(modules, spam)
-	modules = append(modules, filtermodule.New(guild, spam))
+	modules = append(modules, spammodule.New())
+	modules = append(modules, filtermodule.New(guild))
+	modules = append(modules, loggingmodule.New(guild))
+	modules = append(modules, voicemodule.New(guild))
+	modules = append(modules, customcommandsmodule.New(guild))
 
 	return modules
 }
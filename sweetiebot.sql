@@ -157,6 +157,66 @@ CREATE TABLE IF NOT EXISTS `aliases` (
   CONSTRAINT `ALIASES_USERS` FOREIGN KEY (`User`) REFERENCES `users` (`ID`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4//
 
+-- Dumping structure for table sweetiebot.cases
+CREATE TABLE IF NOT EXISTS `cases` (
+  `Guild` bigint(20) unsigned NOT NULL,
+  `ID` bigint(20) unsigned NOT NULL,
+  `Action` tinyint(3) unsigned NOT NULL,
+  `Target` bigint(20) unsigned NOT NULL,
+  `Moderator` bigint(20) unsigned NOT NULL,
+  `Reason` varchar(1000) NOT NULL DEFAULT '',
+  `Duration` varchar(64) NOT NULL DEFAULT '',
+  `Points` int(10) unsigned NOT NULL DEFAULT 0,
+  `Messages` text NOT NULL,
+  `Timestamp` datetime NOT NULL,
+  PRIMARY KEY (`Guild`,`ID`),
+  KEY `INDEX_GUILD_TARGET` (`Guild`,`Target`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4//
+
+-- Data exporting was unselected.
+-- Dumping structure for table sweetiebot.confighistory
+CREATE TABLE IF NOT EXISTS `confighistory` (
+  `Guild` bigint(20) unsigned NOT NULL,
+  `Version` bigint(20) unsigned NOT NULL,
+  `Author` bigint(20) unsigned NOT NULL,
+  `Changes` text NOT NULL,
+  `Config` mediumtext NOT NULL,
+  `Timestamp` datetime NOT NULL,
+  PRIMARY KEY (`Guild`,`Version`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4//
+
+-- Data exporting was unselected.
+-- Dumping structure for table sweetiebot.tempchannels
+CREATE TABLE IF NOT EXISTS `tempchannels` (
+  `Channel` bigint(20) unsigned NOT NULL,
+  `Guild` bigint(20) unsigned NOT NULL,
+  `Owner` bigint(20) unsigned NOT NULL,
+  `Created` datetime NOT NULL,
+  PRIMARY KEY (`Channel`),
+  KEY `INDEX_GUILD` (`Guild`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4//
+
+-- Data exporting was unselected.
+-- Dumping structure for table sweetiebot.cooldowns
+CREATE TABLE IF NOT EXISTS `cooldowns` (
+  `Guild` bigint(20) unsigned NOT NULL,
+  `Scope` varchar(64) NOT NULL,
+  `Command` varchar(64) NOT NULL,
+  `Expires` datetime NOT NULL,
+  PRIMARY KEY (`Guild`,`Scope`,`Command`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4//
+
+-- Data exporting was unselected.
+-- Dumping structure for table sweetiebot.voicetime
+CREATE TABLE IF NOT EXISTS `voicetime` (
+  `Guild` bigint(20) unsigned NOT NULL,
+  `ID` bigint(20) unsigned NOT NULL,
+  `Seconds` bigint(20) unsigned NOT NULL DEFAULT 0,
+  `Sessions` int(10) unsigned NOT NULL DEFAULT 0,
+  `LastSeen` datetime NOT NULL,
+  PRIMARY KEY (`Guild`,`ID`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4//
+
 -- Data exporting was unselected.
 -- Dumping structure for table sweetiebot.chatlog
 CREATE TABLE IF NOT EXISTS `chatlog` (
@@ -534,6 +594,11 @@ DELETE FROM `chatlog` WHERE Guild = _guild;
 DELETE FROM `debuglog` WHERE Guild = _guild;
 DELETE FROM `editlog` WHERE Guild = _guild;
 DELETE FROM `tags` WHERE Guild = _guild;
+DELETE FROM `cases` WHERE Guild = _guild;
+DELETE FROM `confighistory` WHERE Guild = _guild;
+DELETE FROM `voicetime` WHERE Guild = _guild;
+DELETE FROM `tempchannels` WHERE Guild = _guild;
+DELETE FROM `cooldowns` WHERE Guild = _guild;
 
 END//
 
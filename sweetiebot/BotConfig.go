@@ -15,23 +15,29 @@ import (
 type ModuleID string
 type CommandID string
 
-// BotConfig lists all bot configuration options, grouped into structs
+// BotConfig lists all bot configuration options, grouped into structs. Modules that implement ModuleConfig keep their
+// options in their own section instead, which is stored alongside these.
 type BotConfig struct {
-	Version     int   `json:"version"`
-	LastVersion int   `json:"lastversion"`
-	SetupDone   bool  `json:"setupdone"`
-	Expires     int64 `json:"expires"`
-	Basic       struct {
-		IgnoreInvalidCommands bool                    `json:"ignoreinvalidcommands"`
-		Importable            bool                    `json:"importable"`
-		ModRole               DiscordRole             `json:"modrole"`
-		ModChannel            DiscordChannel          `json:"modchannel"`
-		FreeChannels          map[DiscordChannel]bool `json:"freechannels"`
-		BotChannel            DiscordChannel          `json:"botchannel"`
-		Aliases               map[string]string       `json:"aliases"`
-		ListenToBots          bool                    `json:"listentobots"`
-		CommandPrefix         string                  `json:"commandprefix"`
-		SilenceRole           DiscordRole             `json:"silencerole"`
+	Version         int            `json:"version"`
+	LastVersion     int            `json:"lastversion"`
+	SetupDone       bool           `json:"setupdone"`
+	Expires         int64          `json:"expires"`
+	SectionVersions map[string]int `json:"sectionversions"` // Version of each module's config section
+	sections        map[string]interface{}
+	Basic           struct {
+		IgnoreInvalidCommands bool                      `json:"ignoreinvalidcommands"`
+		Importable            bool                      `json:"importable"`
+		ModRole               DiscordRole               `json:"modrole"`
+		ModChannel            DiscordChannel            `json:"modchannel"`
+		FreeChannels          map[DiscordChannel]bool   `json:"freechannels"`
+		BotChannel            DiscordChannel            `json:"botchannel"`
+		Aliases               map[string]string         `json:"aliases"`
+		ListenToBots          bool                      `json:"listentobots"`
+		CommandPrefix         string                    `json:"commandprefix"`
+		ExtraPrefixes         map[string]bool           `json:"extraprefixes"`
+		ChannelPrefixes       map[DiscordChannel]string `json:"channelprefixes"`
+		SilenceRole           DiscordRole               `json:"silencerole"`
+		SlashCommands         bool                      `json:"slashcommands"`
 	} `json:"basic"`
 	Modules struct {
 		Channels           map[ModuleID]map[DiscordChannel]bool  `json:"modulechannels"`
@@ -39,6 +45,8 @@ type BotConfig struct {
 		CommandRoles       map[CommandID]map[DiscordRole]bool    `json:"commandroles"`
 		CommandChannels    map[CommandID]map[DiscordChannel]bool `json:"commandchannels"`
 		CommandLimits      map[CommandID]int64                   `json:"Commandlimits"`
+		UserCooldowns      map[CommandID]int64                   `json:"usercooldowns"`
+		RoleCooldowns      map[CommandID]int64                   `json:"rolecooldowns"`
 		CommandDisabled    map[CommandID]bool                    `json:"commanddisabled"`
 		CommandPerDuration int                                   `json:"commandperduration"`
 		CommandMaxDuration int64                                 `json:"commandmaxduration"`
@@ -70,6 +78,14 @@ type BotConfig struct {
 		NotifyChannel    DiscordChannel       `json:"joinchannel"`
 		TrackUserLeft    bool                 `json:"trackuserleft"`
 	} `json:"users"`
+	Warnings struct {
+		Points           int   `json:"points"`
+		DecayTime        int64 `json:"decaytime"`
+		SilenceThreshold int   `json:"silencethreshold"`
+		SilenceDuration  int64 `json:"silenceduration"`
+		BanThreshold     int   `json:"banthreshold"`
+		BanDuration      int64 `json:"banduration"`
+	} `json:"warnings"`
 	Bucket struct {
 		MaxItems       int             `json:"maxbucket"`
 		MaxItemLength  int             `json:"maxbucketlength"`
@@ -99,8 +115,11 @@ type BotConfig struct {
 		HideNegativeRules bool           `json:"hidenegativerules"`
 	} `json:"help"`
 	Log struct {
-		Cooldown int64          `json:"maxerror"`
-		Channel  DiscordChannel `json:"logchannel"`
+		Cooldown     int64          `json:"maxerror"`
+		Channel      DiscordChannel `json:"logchannel"`
+		EventChannel DiscordChannel `json:"eventchannel"`
+		Level        LogLevel       `json:"level"`
+		ChannelLevel LogLevel       `json:"channellevel"`
 	} `json:"log"`
 	Witty struct {
 		Responses map[string]string `json:"witty"`
@@ -136,13 +155,18 @@ var ConfigHelp = map[string]map[string]string{
 		"botchannel":            "This allows you to designate a particular channel to point users if they are trying to run too many commands at once. Usually this channel will also be included in `basic.freechannels`",
 		"aliases":               "Can be used to redirect commands, such as making `!listgroup` call the `!listgroups` command. Useful for making shortcuts.\n\nExample: `!setconfig basic.aliases kawaii \"pick cute\"` sets an alias mapping `!kawaii arg1...` to `!pick cute arg1...`, preserving all arguments that are passed to the alias.",
 		"listentobots":          "If true, processes messages from other bots and allows them to run commands. Bots can never trigger anti-spam. Defaults to false.",
-		"commandprefix":         "Determines the SINGLE ASCII CHARACTER prefix used to denote bot commands. You can't set it to an emoji or any weird foreign character. The default is `!`. If this is set to an invalid value, it defaults to `!`.",
+		"commandprefix":         "Determines the prefix used to denote bot commands, which is also the prefix shown in help text. It can be more than one character long, and can include emoji. The default is `!`. If this is empty, it defaults to `!`. Mentioning the bot right before a command, as in `@Sweetie Bot help`, always works as a prefix.",
+		"extraprefixes":         "A list of additional prefixes that also denote bot commands, for servers where other bots share the same prefix. Example: `!setconfig basic.extraprefixes sb! ?`",
+		"channelprefixes":       "A map of channels to the only prefix that works in them, replacing `commandprefix` and `extraprefixes` in those channels. Help text and aliases use the prefix of the channel they're in.\n\nExample: `!setconfig basic.channelprefixes #bots ?`",
 		"silencerole":           "This should be a role with no permissions, so the bot can quarantine potential spammers without banning them.",
+		"slashcommands":         "If true, every enabled command is also registered as a slash command on this server, with one option per command parameter. Slash commands obey the same role, channel and rate limit restrictions as normal commands. Changes to enabled commands are pushed to discord automatically.",
 	},
 	"modules": {
 		"commandroles":       "A map of which roles are allowed to run which command. If no mapping exists, everyone can run the command.",
 		"commandchannels":    "A map of which channels commands are allowed to run on. No entry means a command can be run anywhere. If `!` is included as a channel, it switches from a whitelist to a blacklist, enabling you to exclude certain channels instead of allow certain channels.",
-		"commandlimits":      "A map of timeouts for commands. A value of 30 means the command can't be used more than once every 30 seconds.",
+		"commandlimits":      "A map of timeouts for commands in each channel. A value of 30 means the command can't be used more than once every 30 seconds in the same channel.",
+		"usercooldowns":      "A map of timeouts for commands for each user. A value of 30 means a user can't use the command more than once every 30 seconds, but other users can still use it.",
+		"rolecooldowns":      "A map of timeouts for commands that are shared by everyone with the same highest role. A value of 30 means the command can only be used once every 30 seconds by members of each role. Users without any roles share the cooldown of @everyone.",
 		"commanddisabled":    "A list of disabled commands.",
 		"commandperduration": "Maximum number of commands that can be run within `commandmaxduration` seconds. Default: 3",
 		"commandmaxduration": "Default: 20. This means that by default, at most 3 commands can be run every 20 seconds.",
@@ -165,7 +189,7 @@ var ConfigHelp = map[string]map[string]string{
 		"raidsize":           "Specifies how many people must have joined the server within the `spam.raidtime` period to qualify as a raid.",
 		"raidsilence":        "Gets the current raidsilence state. Use the `!RaidSilence` command to set this.",
 		"lockdownduration":   "Determines how long the server's verification mode will temporarily be increased to tableflip levels after a raid is detected. If set to 0, disables lockdown entirely.",
-		"silencetimeout":     "If greater than 0, any members silenced by sweetie (not by the `!silence` command) will be automatically unsilenced after this many seconds. This includes anyone silenced during a raid.",
+		"silencetimeout":     "If greater than 0, any members silenced by sweetie (not by the `!silence` command) will be automatically unsilenced after this many seconds. This includes anyone silenced during a raid. Pending unsilences are kept in the schedule, so they survive restarts, but the scheduler module must be enabled for them to happen.",
 	},
 	"bucket": {
 		"maxitems":       "Determines the maximum number of items that can be carried in the bucket. If set to 0, the bucket is disabled.",
@@ -189,6 +213,14 @@ var ConfigHelp = map[string]map[string]string{
 		"notifychannel":    "If set to a channel ID other than zero, sends a message to that channel whenever a new user joins the server.",
 		"trackuserleft":    "If true, tracks users that leave the server if notifychannel is set.",
 	},
+	"warnings": {
+		"points":           "The number of points a `!warn` gives someone if the moderator doesn't say otherwise. Defaults to 1.",
+		"decaytime":        "The number of seconds it takes for a member to lose a single warning point. Defaults to 604800, so one point is forgotten each week. If set to 0, warning points never go away.",
+		"silencethreshold": "When a warning brings someone's points up to at least this many, they are automatically silenced. If set to 0, warnings never silence anyone. Defaults to 3.",
+		"silenceduration":  "The number of seconds someone is silenced for after reaching `warnings.silencethreshold`. If set to 0, they stay silenced until a moderator unsilences them. Defaults to 3600.",
+		"banthreshold":     "When a warning brings someone's points up to at least this many, they are automatically banned. If set to 0, warnings never ban anyone. Defaults to 5.",
+		"banduration":      "The number of seconds someone is banned for after reaching `warnings.banthreshold`. If set to 0, the ban is permanent. Defaults to 86400.",
+	},
 	"filter": {
 		"filters":   "A collection of word lists for each filter. These are combined into a single regex of the form `(word1|word2|etc...)`, depending on the filter template.",
 		"channels":  "A collection of channel exclusions for each filter.",
@@ -205,8 +237,11 @@ var ConfigHelp = map[string]map[string]string{
 		"hidenegativerules": "If true, `!rules -1` will display a rule at index -1, but `!rules` will not. This is useful for joke rules or additional rules that newcomers don't need to know about.",
 	},
 	"log": {
-		"channel":  "This is the channel where log output is sent.",
-		"cooldown": "The cooldown time to display an error message, in seconds, intended to prevent the bot from spamming itself. Default: 4",
+		"channel":      "This is the channel where log output is sent.",
+		"cooldown":     "The cooldown time to display an error message, in seconds, intended to prevent the bot from spamming itself. Default: 4",
+		"eventchannel": "If set, the Logging module posts edits, deletes, joins, leaves, nickname and role changes, and the Voice module posts voice activity, to this channel instead of `log.channel`.",
+		"level":        "The least important log entries that are saved to the debug log, which can be `debug`, `info`, `warning` or `error`. Default: info",
+		"channellevel": "The least important log entries that are posted in `log.channel`, which can be `debug`, `info`, `warning` or `error`. Default: info",
 	},
 	"witty": {
 		"responses": "Stores the replies used by the Witty module and must be configured using `!addwit` or `!removewit`",
@@ -234,17 +269,24 @@ var ConfigHelp = map[string]map[string]string{
 	},
 }
 
-func getConfigHelp(module string, option string) (string, bool) {
-	x, ok := ConfigHelp[strings.ToLower(module)]
-	if !ok {
-		return "", false
+// configHelp returns the help strings of a config category, whether it's built in or belongs to a module
+func configHelp(module string) map[string]string {
+	if x, ok := ConfigHelp[strings.ToLower(module)]; ok {
+		return x
+	}
+	if section := findConfigSection(module); section != nil {
+		return section.Help
 	}
-	s, b := x[strings.ToLower(option)]
+	return nil
+}
+
+func getConfigHelp(module string, option string) (string, bool) {
+	s, b := configHelp(module)[strings.ToLower(option)]
 	return s, b
 }
 
 // ConfigVersion is the latest version of the config file
-var ConfigVersion = 27
+var ConfigVersion = 32
 
 // DefaultConfig returns a default BotConfig struct. We can't define this as a variable because you can't initialize nested structs in a sane way in Go
 func DefaultConfig() *BotConfig {
@@ -271,6 +313,12 @@ func DefaultConfig() *BotConfig {
 	config.Spam.RaidSize = 4
 	config.Spam.RaidSilence = 1 // Default to raid mode
 	config.Spam.LockdownDuration = 120
+	config.Warnings.Points = 1
+	config.Warnings.DecayTime = 604800
+	config.Warnings.SilenceThreshold = 3
+	config.Warnings.SilenceDuration = 3600
+	config.Warnings.BanThreshold = 5
+	config.Warnings.BanDuration = 86400
 	config.Bucket.MaxItems = 10
 	config.Bucket.MaxItemLength = 100
 	config.Bucket.MaxFightHP = 300
@@ -281,33 +329,42 @@ func DefaultConfig() *BotConfig {
 	config.Markov.UseMemberNames = true
 	config.Bored.Cooldown = 500
 	config.Log.Cooldown = 4
+	config.Log.Level = LogInfo
+	config.Log.ChannelLevel = LogInfo
 	config.Witty.Cooldown = 180
 	config.Miscellaneous.MaxSearchResults = 10
 	config.Status.Cooldown = 3600
 
+	config.fillSections()
+	config.SectionVersions = make(map[string]int)
+	for _, section := range ConfigSections() {
+		config.SectionVersions[strings.ToLower(section.Name)] = section.Version
+	}
 	return config
 }
 
 // FixRequest takes a request that is not fully qualified and attempts to find a fully qualified version
-func FixRequest(arg string, t reflect.Value) (string, error) {
+func FixRequest(arg string, config *BotConfig) (string, error) {
 	args := strings.SplitN(strings.ToLower(arg), ".", 3)
 	list := []string{}
-	n := t.NumField()
+	t := reflect.ValueOf(config).Elem()
 
-	for i := 0; i < n; i++ {
+	for i := 0; i < t.NumField(); i++ {
 		if strings.ToLower(t.Type().Field(i).Name) == args[0] {
 			return arg, nil
 		}
 	}
+	groups := config.groups()
+	for _, g := range groups {
+		if strings.ToLower(g.Name) == args[0] {
+			return arg, nil
+		}
+	}
 
-	for i := 0; i < n; i++ {
-		switch t.Field(i).Kind() {
-		case reflect.Struct:
-			f := t.Field(i)
-			for j := 0; j < f.NumField(); j++ {
-				if strings.ToLower(f.Type().Field(j).Name) == args[0] {
-					list = append(list, t.Type().Field(i).Name)
-				}
+	for _, g := range groups {
+		for j := 0; j < g.Value.NumField(); j++ {
+			if strings.ToLower(g.Value.Type().Field(j).Name) == args[0] {
+				list = append(list, g.Name)
 			}
 		}
 	}
@@ -366,6 +423,12 @@ func setConfigValue(f reflect.Value, value string, info *GuildInfo) error {
 			return fmt.Errorf("%s is not a command name!", value)
 		}
 		f.SetString(value)
+	case LogLevel:
+		level, err := ParseLogLevel(value)
+		if err != nil {
+			return err
+		}
+		f.SetInt(int64(level))
 	case int, int8, int16, int32, int64:
 		k, err := strconv.ParseInt(value, 10, 64)
 		if err != nil {
@@ -481,60 +544,63 @@ func (config *BotConfig) SetConfig(info *GuildInfo, args []string, indices []int
 	names := strings.SplitN(strings.ToLower(name), ".", 3)
 	t := reflect.ValueOf(config).Elem()
 	for i := 0; i < t.NumField(); i++ {
-		if strings.ToLower(t.Type().Field(i).Name) == names[0] {
+		if strings.ToLower(t.Type().Field(i).Name) == names[0] && t.Field(i).Kind() != reflect.Struct {
 			if len(names) < 2 {
 				return "Can't set a configuration category! Use \"Category.Option\" to set a specific option.", false
 			}
-			switch t.Field(i).Kind() {
-			case reflect.Struct:
-				for j := 0; j < t.Field(i).NumField(); j++ {
-					if strings.ToLower(t.Field(i).Type().Field(j).Name) == names[1] {
-						f := t.Field(i).Field(j)
-						switch f.Interface().(type) {
-						case string, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, float32, float64, uint64, DiscordChannel, DiscordRole, DiscordUser:
-							value := ""
-							if len(indices) > 1 {
-								value = message[indices[1]:]
-							}
-							if err := setConfigValue(f, value, info); err != nil {
-								return "Error: " + err.Error(), false
-							}
-						case map[DiscordChannel]bool, map[string]bool, map[DiscordRole]bool, map[CommandID]bool, map[ModuleID]bool:
-							return setConfigList(f, args[1:], info)
-						case bool:
-							if len(indices) < 2 {
-								return "No value parameter given", false
-							}
-							switch strings.ToLower(message[indices[1]:]) {
-							case "true":
-								f.SetBool(true)
-							case "false":
-								f.SetBool(false)
-							default:
-								return name + " must be set to either 'true' or 'false'", false
-							}
-						case map[string]string, map[CommandID]int64, map[DiscordChannel]float32, map[int]string, map[string]float32, map[string]int64:
-							if len(indices) < 2 {
-								return "No key parameter given", false
-							}
-							value := ""
-							if len(indices) > 2 {
-								value = message[indices[2]:]
-							}
-							return setConfigKeyValue(f, strings.ToLower(args[1]), value, info)
-						case map[string]map[DiscordChannel]bool, map[CommandID]map[DiscordRole]bool, map[string]map[string]bool, map[DiscordUser][]string, map[CommandID]map[DiscordChannel]bool, map[ModuleID]map[DiscordChannel]bool:
-							if len(indices) < 2 {
-								return "No key parameter given", false
-							}
-							return setConfigMapList(f, strings.ToLower(args[1]), args[2:], info)
+			return "Not a configuration category!", false
+		}
+	}
+	for _, g := range config.groups() {
+		if strings.ToLower(g.Name) == names[0] {
+			if len(names) < 2 {
+				return "Can't set a configuration category! Use \"Category.Option\" to set a specific option.", false
+			}
+			for j := 0; j < g.Value.NumField(); j++ {
+				if strings.ToLower(g.Value.Type().Field(j).Name) == names[1] {
+					f := g.Value.Field(j)
+					switch f.Interface().(type) {
+					case string, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, float32, float64, uint64, DiscordChannel, DiscordRole, DiscordUser, LogLevel:
+						value := ""
+						if len(indices) > 1 {
+							value = message[indices[1]:]
+						}
+						if err := setConfigValue(f, value, info); err != nil {
+							return "Error: " + err.Error(), false
+						}
+					case map[DiscordChannel]bool, map[string]bool, map[DiscordRole]bool, map[CommandID]bool, map[ModuleID]bool:
+						return setConfigList(f, args[1:], info)
+					case bool:
+						if len(indices) < 2 {
+							return "No value parameter given", false
+						}
+						switch strings.ToLower(message[indices[1]:]) {
+						case "true":
+							f.SetBool(true)
+						case "false":
+							f.SetBool(false)
 						default:
-							return "That config option has an unknown type!", false
+							return name + " must be set to either 'true' or 'false'", false
+						}
+					case map[string]string, map[CommandID]int64, map[DiscordChannel]float32, map[DiscordChannel]string, map[int]string, map[string]float32, map[string]int64:
+						if len(indices) < 2 {
+							return "No key parameter given", false
+						}
+						value := ""
+						if len(indices) > 2 {
+							value = message[indices[2]:]
+						}
+						return setConfigKeyValue(f, strings.ToLower(args[1]), value, info)
+					case map[string]map[DiscordChannel]bool, map[CommandID]map[DiscordRole]bool, map[string]map[string]bool, map[DiscordUser][]string, map[CommandID]map[DiscordChannel]bool, map[ModuleID]map[DiscordChannel]bool:
+						if len(indices) < 2 {
+							return "No key parameter given", false
 						}
-						return fmt.Sprint(f.Interface()), true
+						return setConfigMapList(f, strings.ToLower(args[1]), args[2:], info)
+					default:
+						return "That config option has an unknown type!", false
 					}
+					return fmt.Sprint(f.Interface()), true
 				}
-			default:
-				return "Not a configuration category!", false
 			}
 		}
 	}
@@ -619,9 +685,9 @@ func getConfigMapList(f reflect.Value, state *discordgo.State, guild string) (s
 
 func (config *BotConfig) GetConfig(f reflect.Value, state *discordgo.State, guild string) (s []string) {
 	switch f.Interface().(type) {
-	case string, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, float32, float64, uint64, DiscordChannel, DiscordRole, DiscordUser, ModuleID, CommandID, bool:
+	case string, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, float32, float64, uint64, DiscordChannel, DiscordRole, DiscordUser, ModuleID, CommandID, bool, LogLevel:
 		s = append(s, getConfigValue(f, state, guild))
-	case map[DiscordChannel]bool, map[string]bool, map[DiscordRole]bool, map[string]string, map[CommandID]int64, map[DiscordChannel]float32, map[int]string, map[CommandID]bool, map[ModuleID]bool, map[string]float32, map[string]int64:
+	case map[DiscordChannel]bool, map[string]bool, map[DiscordRole]bool, map[string]string, map[CommandID]int64, map[DiscordChannel]float32, map[DiscordChannel]string, map[int]string, map[CommandID]bool, map[ModuleID]bool, map[string]float32, map[string]int64:
 		s = getConfigList(f, state, guild)
 	case map[string]map[DiscordChannel]bool, map[CommandID]map[DiscordRole]bool, map[string]map[string]bool, map[DiscordUser][]string, map[CommandID]map[DiscordChannel]bool, map[ModuleID]map[DiscordChannel]bool:
 		s = getConfigMapList(f, state, guild)
@@ -654,22 +720,17 @@ func (config *BotConfig) IsCommandDisabled(command Command) (str string) {
 	return
 }
 
-// FillConfig ensures root maps are not nil
+// FillConfig ensures root maps are not nil and every module's config section exists
 func (config *BotConfig) FillConfig() {
-
-	t := reflect.ValueOf(config).Elem()
-	n := t.NumField()
-
-	for i := 0; i < n; i++ {
-		switch t.Field(i).Kind() {
-		case reflect.Struct:
-			f := t.Field(i)
-			for j := 0; j < f.NumField(); j++ {
-				switch f.Field(j).Kind() {
-				case reflect.Map:
-					if f.Field(j).Len() == 0 {
-						f.Field(j).Set(reflect.MakeMap(f.Field(j).Type()))
-					}
+	config.fillSections()
+
+	for _, g := range config.groups() {
+		f := g.Value
+		for j := 0; j < f.NumField(); j++ {
+			switch f.Field(j).Kind() {
+			case reflect.Map:
+				if f.Field(j).Len() == 0 {
+					f.Field(j).Set(reflect.MakeMap(f.Field(j).Type()))
 				}
 			}
 		}
@@ -794,6 +855,16 @@ func restrictCommand(v string, roles map[CommandID]map[DiscordRole]bool, modrole
 	}
 }
 
+// RestrictCommand limits a command to the moderator role, unless the server has already chosen who can use it. Modules
+// call this when migrating their config section, so servers that were set up before the module existed don't end up
+// with sensitive commands anyone can run.
+func (guild *GuildInfo) RestrictCommand(command CommandID) {
+	if guild.Config.Modules.CommandRoles == nil {
+		guild.Config.Modules.CommandRoles = make(map[CommandID]map[DiscordRole]bool)
+	}
+	restrictCommand(string(command), guild.Config.Modules.CommandRoles, guild.Config.Basic.ModRole)
+}
+
 func (guild *GuildInfo) renameCommand(old CommandID, new CommandID) {
 	if val, ok := guild.Config.Modules.CommandRoles[old]; ok {
 		guild.Config.Modules.CommandRoles[new] = val
@@ -810,6 +881,16 @@ func (guild *GuildInfo) renameCommand(old CommandID, new CommandID) {
 		delete(guild.Config.Modules.CommandLimits, old)
 	}
 
+	if val, ok := guild.Config.Modules.UserCooldowns[old]; ok {
+		guild.Config.Modules.UserCooldowns[new] = val
+		delete(guild.Config.Modules.UserCooldowns, old)
+	}
+
+	if val, ok := guild.Config.Modules.RoleCooldowns[old]; ok {
+		guild.Config.Modules.RoleCooldowns[new] = val
+		delete(guild.Config.Modules.RoleCooldowns, old)
+	}
+
 	if val, ok := guild.Config.Modules.CommandDisabled[old]; ok {
 		guild.Config.Modules.CommandDisabled[new] = val
 		delete(guild.Config.Modules.CommandDisabled, old)
@@ -826,16 +907,26 @@ func (guild *GuildInfo) renameCommand(old CommandID, new CommandID) {
 
 // MigrateSettings from earlier config version
 func (guild *GuildInfo) MigrateSettings(config []byte) error {
+	migrated, err := guild.migrateConfig(config)
+	if err == nil && migrated {
+		guild.SaveConfig()
+	}
+	return err
+}
+
+// migrateConfig reads a config into the guild and brings it up to the current version, returning true if it had to
+// change anything. It doesn't save the result.
+func (guild *GuildInfo) migrateConfig(config []byte) (bool, error) {
 	err := json.Unmarshal(config, &guild.Config)
 	if err != nil {
-		return err
+		return false, err
 	}
 
 	if guild.Config.Version < 10 {
 		legacy := legacyBotConfig{}
 		err := json.Unmarshal(config, &legacy)
 		if err != nil {
-			return err
+			return false, err
 		}
 
 		if legacy.Version == 0 {
@@ -979,7 +1070,7 @@ func (guild *GuildInfo) MigrateSettings(config []byte) error {
 			guild.Config.Modules.CommandMaxDuration = legacy.Basic.Commandmaxduration
 			guild.Config.Modules.CommandPerDuration = legacy.Basic.Commandperduration
 		} else {
-			fmt.Println(err.Error())
+			guild.LogError("Failed to migrate config: ", err)
 		}
 	}
 
@@ -1010,7 +1101,7 @@ func (guild *GuildInfo) MigrateSettings(config []byte) error {
 				guild.Config.Spam.PingPressure = 0
 			}
 		} else {
-			fmt.Println(err.Error())
+			guild.LogError("Failed to migrate config: ", err)
 		}
 	}
 
@@ -1040,22 +1131,22 @@ func (guild *GuildInfo) MigrateSettings(config []byte) error {
 					for u := range v {
 						err = guild.Bot.DG.GuildMemberRoleAdd(guild.ID, u, r.ID)
 						if err != nil {
-							fmt.Println(err)
+							guild.LogError("Failed to migrate config: ", err)
 						}
 					}
 				} else {
-					fmt.Println(err)
+					guild.LogError("Failed to migrate config: ", err)
 				}
 			}
 
 			stmt, err := guild.Bot.DB.Prepare("SELECT ID, Data FROM schedule WHERE Guild = ? AND Type = 7")
 			stmt2, err := guild.Bot.DB.Prepare("UPDATE schedule SET Data = ? WHERE ID = ?")
 			if err != nil {
-				fmt.Println(err)
+				guild.LogError("Failed to migrate config: ", err)
 			} else {
 				q, err := stmt.Query(SBatoi(guild.ID))
 				if err != nil {
-					fmt.Println(err)
+					guild.LogError("Failed to migrate config: ", err)
 				} else {
 					defer q.Close()
 					for q.Next() {
@@ -1072,14 +1163,14 @@ func (guild *GuildInfo) MigrateSettings(config []byte) error {
 							}
 							_, err = stmt2.Exec(strings.Join(groups, " ")+"|"+datas[1], id)
 							if err != nil {
-								fmt.Println(err)
+								guild.LogError("Failed to migrate config: ", err)
 							}
 						}
 					}
 				}
 			}
 		} else {
-			fmt.Println(err.Error())
+			guild.LogError("Failed to migrate config: ", err)
 		}
 	}
 
@@ -1131,7 +1222,7 @@ func (guild *GuildInfo) MigrateSettings(config []byte) error {
 			gID := SBatoi(guild.ID)
 			for k, v := range legacy.Basic.Collections {
 				if len(v) > 0 {
-					fmt.Println("Importing:", k)
+					guild.Logger().Debug("Importing: ", k)
 					guild.Bot.DB.CreateTag(k, gID)
 					tag, err := guild.Bot.DB.GetTag(k, gID)
 					if err == nil {
@@ -1143,11 +1234,11 @@ func (guild *GuildInfo) MigrateSettings(config []byte) error {
 						}
 					}
 				} else {
-					fmt.Println("Skipping empty collection:", k)
+					guild.Logger().Debug("Skipping empty collection: ", k)
 				}
 			}
 		} else {
-			fmt.Println(err.Error())
+			guild.LogError("Failed to migrate config: ", err)
 		}
 		guild.Bot.GuildsLock.Unlock()
 		restrictCommand("addset", guild.Config.Modules.CommandRoles, guild.Config.Basic.ModRole)
@@ -1310,11 +1401,37 @@ func (guild *GuildInfo) MigrateSettings(config []byte) error {
 		restrictCommand("removecounter", guild.Config.Modules.CommandRoles, guild.Config.Basic.ModRole)
 	}
 
-	if guild.Config.Version != ConfigVersion {
-		guild.Config.Version = ConfigVersion // set version to most recent config version
-		guild.SaveConfig()
+	if guild.Config.Version <= 27 {
+		restrictCommand("case", guild.Config.Modules.CommandRoles, guild.Config.Basic.ModRole)
+		restrictCommand("cases", guild.Config.Modules.CommandRoles, guild.Config.Basic.ModRole)
+		restrictCommand("note", guild.Config.Modules.CommandRoles, guild.Config.Basic.ModRole)
+		restrictCommand("reason", guild.Config.Modules.CommandRoles, guild.Config.Basic.ModRole)
 	}
-	return nil
+
+	if guild.Config.Version <= 28 {
+		restrictCommand("warn", guild.Config.Modules.CommandRoles, guild.Config.Basic.ModRole)
+	}
+
+	if guild.Config.Version <= 29 {
+		if guild.Config.Modules.Disabled == nil {
+			guild.Config.Modules.Disabled = make(map[ModuleID]bool)
+		}
+		guild.Config.Modules.Disabled["logging"] = true // Don't suddenly flood existing log channels with message edits
+	}
+
+	if guild.Config.Version <= 30 {
+		restrictCommand("confighistory", guild.Config.Modules.CommandRoles, guild.Config.Basic.ModRole)
+		restrictCommand("configrollback", guild.Config.Modules.CommandRoles, guild.Config.Basic.ModRole)
+		restrictCommand("exportconfig", guild.Config.Modules.CommandRoles, guild.Config.Basic.ModRole)
+		restrictCommand("importconfig", guild.Config.Modules.CommandRoles, guild.Config.Basic.ModRole)
+	}
+
+	migrated := guild.Config.Version != ConfigVersion
+	guild.Config.Version = ConfigVersion // set version to most recent config version
+	if guild.migrateSections() {
+		migrated = true
+	}
+	return migrated, nil
 }
 
 func getSubStruct(arg []string, f reflect.Value, j int, info *GuildInfo) []string {
@@ -1323,7 +1440,7 @@ func getSubStruct(arg []string, f reflect.Value, j int, info *GuildInfo) []strin
 		switch f.Field(j).Interface().(type) {
 		case map[string]bool, map[string]string, map[string]int64, map[string]map[DiscordChannel]bool, map[string]map[string]bool, map[string]float32:
 			val = f.Field(j).MapIndex(reflect.ValueOf(arg[2]))
-		case map[DiscordChannel]bool, map[DiscordChannel]float32:
+		case map[DiscordChannel]bool, map[DiscordChannel]float32, map[DiscordChannel]string:
 			val = f.Field(j).MapIndex(reflect.ValueOf(DiscordChannel(arg[2])))
 		case map[DiscordRole]bool:
 			val = f.Field(j).MapIndex(reflect.ValueOf(DiscordRole(arg[2])))
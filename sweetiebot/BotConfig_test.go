@@ -1,6 +1,7 @@
 package sweetiebot
 
 import (
+	"encoding/json"
 	"fmt"
 	"reflect"
 	"strings"
@@ -66,7 +67,7 @@ func TestSetConfig(t *testing.T) {
 	config := &BotConfig{}
 	fnImportable := func(name string) {
 		config.Basic.Importable = false
-		name, _ = FixRequest(name, reflect.ValueOf(config).Elem())
+		name, _ = FixRequest(name, config)
 		if s, ok := config.internalSetConfig(nil, name, "true"); !ok {
 			t.Errorf("SetConfig(%s) returned %v", name, s)
 		}
@@ -94,7 +95,7 @@ func TestSetConfig(t *testing.T) {
 	dbmock.ExpectQuery("SELECT DISTINCT M.ID FROM members.*").WithArgs(sqlmock.AnyArg(), "1", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnRows(sqlmock.NewRows([]string{"ID"}).AddRow(1))
 
 	fnSetInterface := func(name string, value interface{}) {
-		name, _ = FixRequest(name, reflect.ValueOf(config).Elem())
+		name, _ = FixRequest(name, config)
 		if s, ok := config.internalSetConfig(info, name, fmt.Sprintf("%v", value)); !ok {
 			t.Errorf("SetConfig(%s) returned %v", name, s)
 		}
@@ -109,7 +110,7 @@ func TestSetConfig(t *testing.T) {
 	Check(config.Modules.CommandMaxDuration, int64(123456), t)
 
 	fnFreeChannels := func(value DiscordChannel, extra ...string) {
-		name, _ := FixRequest("FreeChannels", reflect.ValueOf(config).Elem())
+		name, _ := FixRequest("FreeChannels", config)
 		if s, ok := config.internalSetConfig(info, append([]string{name, value.String()}, extra...)...); !ok {
 			t.Errorf("SetConfig(FreeChannels) returned %v", s)
 		}
@@ -133,7 +134,7 @@ func TestSetConfig(t *testing.T) {
 	fnFreeChannels(DiscordChannel("2345"))
 
 	fnCommandLimits := func(key string, value int64) {
-		name, _ := FixRequest("CommandLimits", reflect.ValueOf(config).Elem())
+		name, _ := FixRequest("CommandLimits", config)
 		var s string
 		var ok bool
 		if value != 0 {
@@ -180,6 +181,10 @@ func TestSetConfig(t *testing.T) {
 					config.internalSetConfig(info, path, "true")
 					Check(p.Field(i).Field(j).Interface().(bool), true, t)
 					continue
+				case LogLevel:
+					config.internalSetConfig(info, path, "warning")
+					Check(p.Field(i).Field(j).Interface().(LogLevel), LogWarning, t)
+					continue
 				}
 
 				config.internalSetConfig(info, path, "1", "1")
@@ -199,6 +204,9 @@ func TestSetConfig(t *testing.T) {
 				case map[DiscordChannel]float32:
 					v, _ := m["1"]
 					Check(v, float32(1.0), t)
+				case map[DiscordChannel]string:
+					v, _ := m["1"]
+					Check(v, "1", t)
 				case map[int]string:
 					v, _ := m[1]
 					Check(v, "1", t)
@@ -265,3 +273,131 @@ func TestSetConfig(t *testing.T) {
 		}
 	}
 }
+
+func TestDiffConfig(t *testing.T) {
+	t.Parallel()
+
+	old := []byte(`{"version":29,"basic":{"commandprefix":"!","freechannels":{}},"spam":{"silencetimeout":0}}`)
+	config := []byte(`{"version":30,"basic":{"commandprefix":"?","freechannels":{}},"spam":{},"log":{"channel":"5"}}`)
+	changes := diffConfig(old, config)
+	if !Check(len(changes), 4, t) {
+		t.Fatal(changes)
+	}
+	Check(changes[0], `basic.commandprefix: "!" -> "?"`, t)
+	Check(changes[1], `log.channel: (none) -> "5"`, t)
+	Check(changes[2], `spam.silencetimeout: 0 -> (none)`, t)
+	Check(changes[3], `version: 29 -> 30`, t)
+	Check(len(diffConfig(config, config)), 0, t)
+}
+
+func TestMigrateConfig(t *testing.T) {
+	t.Parallel()
+
+	// Existing restrictions are left alone, and everything else is limited to the mod role
+	info := &GuildInfo{}
+	migrated, err := info.migrateConfig([]byte(`{"version":27,"basic":{"modrole":"5"},"modules":{"commandroles":{"note":{"6":true}}}}`))
+	Check(err, nil, t)
+	Check(migrated, true, t)
+	Check(info.Config.Version, ConfigVersion, t)
+	Check(info.Config.Modules.CommandRoles["case"]["5"], true, t)
+	Check(info.Config.Modules.CommandRoles["reason"]["5"], true, t)
+	Check(info.Config.Modules.CommandRoles["note"]["5"], false, t)
+	Check(info.Config.Modules.CommandRoles["warn"]["5"], true, t)
+
+	// A config saved after moderation cases but before warnings only needs !warn restricted
+	info = &GuildInfo{}
+	_, err = info.migrateConfig([]byte(`{"version":28,"basic":{"modrole":"5"},"modules":{"commandroles":{}}}`))
+	Check(err, nil, t)
+	Check(len(info.Config.Modules.CommandRoles["cases"]), 0, t)
+	Check(info.Config.Modules.CommandRoles["warn"]["5"], true, t)
+}
+
+func TestConfigText(t *testing.T) {
+	t.Parallel()
+
+	config := DefaultConfig()
+	info := &GuildInfo{Config: *config}
+	filters := reflect.ValueOf(&config.Filter.Filters).Elem()
+	Check(setConfigText(filters, "Spoilers = twilight\r\nspoilers = luna\nempty =\n\n", info), nil, t)
+	Check(len(config.Filter.Filters["spoilers"]), 2, t)
+	Check(len(config.Filter.Filters["empty"]), 0, t)
+	Check(configText(filters), "empty =\nspoilers = luna\nspoilers = twilight", t)
+
+	aliases := reflect.ValueOf(&config.Basic.Aliases).Elem()
+	Check(setConfigText(aliases, "a = b = c\nd = e", info), nil, t)
+	Check(config.Basic.Aliases["a"], "b = c", t)
+	Check(configText(aliases), "a = b = c\nd = e", t)
+	CheckNot(setConfigText(aliases, "a", info), nil, t)
+
+	items := reflect.ValueOf(&config.Bucket.Items).Elem()
+	Check(setConfigText(items, "", info), nil, t)
+	Check(len(config.Bucket.Items), 0, t)
+	CheckNot(setConfigText(reflect.ValueOf(&config.Spam.MaxPressure).Elem(), "lots", info), nil, t)
+	Check(setConfigText(reflect.ValueOf(&config.Basic.ListenToBots).Elem(), "true", info), nil, t)
+	Check(config.Basic.ListenToBots, true, t)
+}
+
+type testSection struct {
+	Greeting string                  `json:"greeting"`
+	Places   map[DiscordChannel]bool `json:"places"`
+}
+
+func TestConfigSection(t *testing.T) {
+	defer func() { configSections.list = configSections.list[:len(configSections.list)-1] }()
+	migrated := []int{}
+	RegisterConfigSection(&ConfigSection{
+		Name:    "TestSection",
+		Version: 2,
+		Default: func() interface{} { return &testSection{Greeting: "hi", Places: make(map[DiscordChannel]bool)} },
+		Help:    map[string]string{"greeting": "What to say.", "places": "Where to say it."},
+		Migrate: func(info *GuildInfo, section interface{}, from int) {
+			migrated = append(migrated, from)
+			if from < 2 {
+				section.(*testSection).Greeting = "hello"
+			}
+		},
+	})
+
+	config := DefaultConfig()
+	Check(config.Section("testsection").(*testSection).Greeting, "hi", t)
+	Check(config.SectionVersions["testsection"], 2, t)
+	help, ok := getConfigHelp("TestSection", "Greeting")
+	Check(help, "What to say.", t)
+	Check(ok, true, t)
+	name, _ := FixRequest("greeting", config)
+	Check(name, "testsection.greeting", t)
+
+	info := &GuildInfo{Config: *config}
+	info.Config.internalSetConfig(info, "testsection.greeting", "howdy")
+	info.Config.internalSetConfig(info, "testsection.places", "1", "2")
+	Check(info.Config.Section("TestSection").(*testSection).Greeting, "howdy", t)
+	Check(len(info.Config.Section("TestSection").(*testSection).Places), 2, t)
+	str, _ := info.Config.internalSetConfig(info, "testsection")
+	Check(strings.HasPrefix(str, "Can't set a configuration category!"), true, t)
+	g, ok := info.Config.group("testsection")
+	Check(ok, true, t)
+	Check(g.Name, "TestSection", t)
+
+	data, err := json.Marshal(info.Config)
+	Check(err, nil, t)
+	loaded := BotConfig{}
+	Check(json.Unmarshal(data, &loaded), nil, t)
+	Check(loaded.Section("testsection").(*testSection).Greeting, "howdy", t)
+	Check(loaded.Version, config.Version, t)
+	Check(loaded.SectionVersions["testsection"], 2, t)
+
+	// A config saved before the section existed gets the defaults and every migration
+	info = &GuildInfo{}
+	Check(json.Unmarshal([]byte(`{"version":31,"basic":{"commandprefix":"?"}}`), &info.Config), nil, t)
+	Check(info.Config.Section("testsection").(*testSection).Greeting, "hi", t)
+	Check(info.migrateSections(), true, t)
+	Check(info.Config.Section("testsection").(*testSection).Greeting, "hello", t)
+	Check(info.migrateSections(), false, t)
+
+	// A section saved without a version counts as version 1
+	info = &GuildInfo{}
+	Check(json.Unmarshal([]byte(`{"version":31,"testsection":{"greeting":"hey"}}`), &info.Config), nil, t)
+	Check(info.migrateSections(), true, t)
+	Check(info.Config.Section("testsection").(*testSection).Greeting, "hello", t)
+	Check(fmt.Sprint(migrated), "[0 1]", t)
+}
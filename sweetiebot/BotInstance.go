@@ -1,14 +1,252 @@
 package sweetiebot
 
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/blackhole12/discordgo"
+)
+
 // BotInstance represents an instance of the bot using a given token that still runs off the same database as all the other instances
 type BotInstance struct {
-	DG          *DiscordGoSession
-	SelfID      string
-	SelfAvatar  string
-	SelfName    string
-	AppID       uint64
-	AppName     string
-	Token       string `json:"token"`
-	MainGuildID uint64 `json:"mainguildid"`
-	IsUserMode  bool   `json:"runasuser"` // True if running as a user for some godawful reason
+	DG            *DiscordGoSession
+	SelfID        DiscordUser
+	SelfAvatar    string
+	SelfName      string
+	AppID         uint64
+	AppName       string
+	Owner         DiscordUser
+	Token         string                          `json:"token"`
+	MainGuildID   DiscordGuild                    `json:"mainguildid"`
+	DebugChannels map[DiscordGuild]DiscordChannel `json:"debugchannels"`
+	IsUserMode    bool                            `json:"runasuser"`  // True if running as a user for some godawful reason
+	ShardCount    int                             `json:"shardcount"` // Total number of shards across every process running this account. 0 or 1 disables sharding.
+	ShardIDs      []int                           `json:"shards"`     // Shards this process runs. If empty, it runs all of them.
+	ShardID       int                             // Shard this session connects as
+}
+
+// Discord only lets a bot identify once every 5 seconds, so shards have to connect one at a time
+const shardIdentifyDelay = 5 * time.Second
+
+// newInstance creates a bot for one of the extra instances listed in selfhost.json. It shares our database, module
+// loader and limits, but logs in with its own token and keeps track of its own guilds.
+func (sb *SweetieBot) newInstance(config BotInstance) *SweetieBot {
+	instance := sb.clone(config)
+	instance.parent = sb
+	if len(instance.SelfName) == 0 {
+		instance.SelfName = "Sweetie Bot"
+	}
+	if len(instance.AppName) == 0 {
+		instance.AppName = instance.SelfName
+	}
+	if instance.DebugChannels == nil {
+		instance.DebugChannels = make(map[DiscordGuild]DiscordChannel)
+	}
+	return instance
+}
+
+// clone creates a bot with the given account that shares everything except its guilds and discord session with us
+func (sb *SweetieBot) clone(config BotInstance) *SweetieBot {
+	return &SweetieBot{
+		BotInstance:    config,
+		DB:             sb.DB,
+		Debug:          sb.Debug,
+		changelog:      sb.changelog,
+		DBAuth:         sb.DBAuth,
+		DBDriver:       sb.DBDriver,
+		parent:         sb.parent,
+		quit:           sb.quit,
+		Guilds:         make(map[DiscordGuild]*GuildInfo),
+		LastMessages:   make(map[DiscordChannel]int64),
+		MaxConfigSize:  sb.MaxConfigSize,
+		MaxUniqueItems: sb.MaxUniqueItems,
+		StartTime:      sb.StartTime,
+		heartbeat:      4294967290,
+		loader:         sb.loader,
+		commandStages:  sb.commandStages,
+		Selfhoster:     sb.Selfhoster,
+		WebSecure:      sb.WebSecure,
+		WebDomain:      sb.WebDomain,
+		WebPort:        sb.WebPort,
+		LogLevel:       sb.LogLevel,
+		LogJSON:        sb.LogJSON,
+		Log:            sb.Log,
+		TickInterval:   sb.TickInterval,
+	}
+}
+
+// initShards creates a session for every shard of this account that this process runs. The bot it's called on
+// becomes the first shard.
+func (sb *SweetieBot) initShards() error {
+	ids := sb.ShardIDs
+	if sb.ShardCount <= 1 {
+		sb.ShardCount = 1
+		ids = []int{0}
+	} else if len(ids) == 0 {
+		for i := 0; i < sb.ShardCount; i++ {
+			ids = append(ids, i)
+		}
+	}
+	for _, id := range ids {
+		if id < 0 || id >= sb.ShardCount {
+			return fmt.Errorf("shard %v does not exist, because there are only %v shards", id, sb.ShardCount)
+		}
+	}
+
+	sb.ShardID = ids[0]
+	sb.Shards = []*SweetieBot{sb}
+	for _, id := range ids[1:] {
+		shard := sb.clone(sb.BotInstance)
+		shard.ShardID = id
+		sb.Shards = append(sb.Shards, shard)
+	}
+	for _, shard := range sb.Shards {
+		shard.Shards = sb.Shards
+		if err := shard.initInstance(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// initInstance loads the server independent commands and creates the discord session for this instance
+func (sb *SweetieBot) initInstance() error {
+	sb.Token = strings.TrimSpace(sb.Token)
+	sb.EmptyGuild = NewGuildInfo(sb, &discordgo.Guild{})
+
+	sb.EmptyGuild.Config.SetupDone = true
+	sb.EmptyGuild.Modules = sb.loader(sb.EmptyGuild)
+	registerModuleConfigs(sb.EmptyGuild.Modules)
+	sb.EmptyGuild.Config.FillConfig()
+	sort.Sort(moduleArray(sb.EmptyGuild.Modules))
+
+	for _, v := range sb.EmptyGuild.Modules {
+		sb.EmptyGuild.RegisterModule(v)
+		for _, command := range v.Commands() {
+			if command.Info().ServerIndependent {
+				sb.EmptyGuild.AddCommand(command, v)
+			}
+		}
+	}
+
+	var dg *discordgo.Session
+	var err error
+	if sb.IsUserMode {
+		dg, err = discordgo.New(sb.Token)
+		sb.Log.Info("Started SweetieBot on a user account.")
+	} else {
+		dg, err = discordgo.New("Bot " + sb.Token)
+	}
+	if err != nil {
+		return err
+	}
+	sb.DG = &DiscordGoSession{*dg}
+	sb.DG.LogLevel = discordgo.LogWarning
+	sb.DG.ShardID = sb.ShardID
+	sb.DG.ShardCount = sb.ShardCount
+
+	sb.DG.AddHandler(sb.OnReady)
+	sb.DG.AddHandler(sb.OnResumed)
+	sb.DG.AddHandler(sb.OnDisconnect)
+	sb.DG.AddHandler(sb.MessageCreate)
+	sb.DG.AddHandler(sb.MessageUpdate)
+	sb.DG.AddHandler(sb.MessageDelete)
+	sb.DG.AddHandler(sb.UserUpdate)
+	sb.DG.AddHandler(sb.GuildUpdate)
+	sb.DG.AddHandler(sb.GuildMemberAdd)
+	sb.DG.AddHandler(sb.GuildMemberRemove)
+	sb.DG.AddHandler(sb.GuildMemberUpdate)
+	sb.DG.AddHandler(sb.GuildBanAdd)
+	sb.DG.AddHandler(sb.GuildBanRemove)
+	sb.DG.AddHandler(sb.GuildRoleDelete)
+	sb.DG.AddHandler(sb.VoiceStateUpdate)
+	sb.DG.AddHandler(sb.GuildCreate)
+	sb.DG.AddHandler(sb.ChannelCreate)
+	sb.DG.AddHandler(sb.InteractionCreate)
+	sb.DG.AddHandler(sb.MessageReactionAdd)
+	sb.DG.AddHandler(sb.MessageReactionRemove)
+	return nil
+}
+
+// loadApplication looks up the owner, ID and name of the application this bot belongs to. User accounts don't have one.
+func (sb *SweetieBot) loadApplication() error {
+	if sb.IsUserMode {
+		return nil
+	}
+	app, err := sb.DG.Application("@me")
+	if err != nil {
+		return err
+	}
+	sb.Owner = DiscordUser(app.Owner.ID)
+	sb.AppID = SBatoi(app.ID)
+	sb.AppName = app.Name
+	return nil
+}
+
+// startInstance launches the background processing loops of this instance and opens its websocket connection. The
+// application is loaded first, so the owner is already known to everything that runs once we're connected.
+func (sb *SweetieBot) startInstance() error {
+	if err := sb.loadApplication(); err != nil {
+		return err
+	}
+	atomic.StoreUint32(&sb.deadlockChecked, heartbeatClock()) // The deadlock detector waits a while before its first check
+	go sb.idleCheckLoop()
+	go sb.deadlockDetector()
+	sb.watchRateLimits()
+	return sb.DG.Open()
+}
+
+// stopWorkers stops the event queue of every guild on this instance. Anything still in them is thrown away.
+func (sb *SweetieBot) stopWorkers() {
+	sb.GuildsLock.RLock()
+	for _, guild := range sb.Guilds {
+		guild.stopWorker()
+	}
+	sb.GuildsLock.RUnlock()
+}
+
+// AllInstances returns every shard of every account this process runs, starting with our own shards
+func (sb *SweetieBot) AllInstances() []*SweetieBot {
+	all := append([]*SweetieBot{}, sb.Shards...)
+	for _, instance := range sb.Instances {
+		all = append(all, instance.Shards...)
+	}
+	return all
+}
+
+// FindGuild looks up a guild on any instance. If more than one instance is on the guild, the first one wins.
+func (sb *SweetieBot) FindGuild(id DiscordGuild) *GuildInfo {
+	for _, instance := range append([]*SweetieBot{sb}, sb.Instances...) {
+		if info := instance.getGuildFromID(id.String()); info != nil {
+			return info
+		}
+	}
+	return nil
+}
+
+// GuildShard returns the shard of this account that a guild belongs to, or nil if that shard runs in another process
+func (sb *SweetieBot) GuildShard(id DiscordGuild) *SweetieBot {
+	if sb.ShardCount <= 1 {
+		return sb
+	}
+	shard := int((id.Convert() >> 22) % uint64(sb.ShardCount))
+	for _, s := range sb.Shards {
+		if s.ShardID == shard {
+			return s
+		}
+	}
+	return nil
+}
+
+// GuildCount returns how many guilds all the shards of this account in this process are on
+func (sb *SweetieBot) GuildCount() (count int) {
+	for _, shard := range sb.Shards {
+		shard.GuildsLock.RLock()
+		count += len(shard.Guilds)
+		shard.GuildsLock.RUnlock()
+	}
+	return
 }
@@ -0,0 +1,110 @@
+package sweetiebot
+
+import (
+	"testing"
+)
+
+func TestNewInstances(t *testing.T) {
+	hostfile := []byte(`{
+		"token": " primary ",
+		"dbdriver": "sqlite3",
+		"dbauth": ":memory:",
+		"mainguildid": "1",
+		"maxconfigsize": 5000,
+		"instances": [
+			{"token": "partner", "mainguildid": "2", "debugchannels": {"2": "3"}}
+		]
+	}`)
+	mock = NewMock(t)
+	mock.Disable = true // Every session registers its event handlers on the mock
+	sb := NewFromSelfhost("", hostfile, func(guild *GuildInfo) []Module { return []Module{&InfoModule{}} })
+	if sb == nil {
+		t.Fatal("Failed to create bot")
+	}
+	defer sb.DB.Close()
+
+	Check(sb.Token, "primary", t)
+	Check(sb.MainGuildID, DiscordGuild("1"), t)
+	if !Check(len(sb.Instances), 1, t) {
+		t.FailNow()
+	}
+	instance := sb.Instances[0]
+	Check(instance.Token, "partner", t)
+	Check(instance.MainGuildID, DiscordGuild("2"), t)
+	Check(instance.DebugChannels[DiscordGuild("2")], DiscordChannel("3"), t)
+	Check(instance.AppName, "Sweetie Bot", t)
+	Check(instance.MaxConfigSize, 5000, t)
+	Check(instance.DB, sb.DB, t)
+	Check(instance.quit, sb.quit, t)
+	CheckNot(instance.DG, sb.DG, t)
+	Check(instance.EmptyGuild.Bot, instance, t)
+	Check(len(sb.AllInstances()), 2, t)
+
+	instance.Guilds[DiscordGuild("2")] = &GuildInfo{ID: "2", Bot: instance}
+	Check(sb.FindGuild(DiscordGuild("2")), instance.Guilds[DiscordGuild("2")], t)
+	Check(sb.FindGuild(DiscordGuild("4")), (*GuildInfo)(nil), t)
+}
+
+func TestShards(t *testing.T) {
+	hostfile := []byte(`{
+		"token": "primary",
+		"dbdriver": "sqlite3",
+		"dbauth": ":memory:",
+		"mainguildid": "1",
+		"shardcount": 4,
+		"shards": [1, 3]
+	}`)
+	mock = NewMock(t)
+	mock.Disable = true
+	sb := NewFromSelfhost("", hostfile, func(guild *GuildInfo) []Module { return []Module{&InfoModule{}} })
+	if sb == nil {
+		t.Fatal("Failed to create bot")
+	}
+	defer sb.DB.Close()
+
+	if !Check(len(sb.Shards), 2, t) {
+		t.FailNow()
+	}
+	shard := sb.Shards[1]
+	Check(sb.Shards[0], sb, t)
+	Check(sb.ShardID, 1, t)
+	Check(shard.ShardID, 3, t)
+	Check(sb.ShardCount, 4, t)
+	Check(shard.ShardCount, 4, t)
+	Check(sb.DG.ShardID, 1, t)
+	Check(shard.DG.ShardID, 3, t)
+	Check(sb.DG.ShardCount, 4, t)
+	Check(shard.DG.ShardCount, 4, t)
+	Check(shard.DB, sb.DB, t)
+	Check(shard.quit, sb.quit, t)
+	Check(len(shard.Shards), 2, t)
+	Check(shard.parent, (*SweetieBot)(nil), t)
+	CheckNot(shard.DG, sb.DG, t)
+	Check(len(sb.AllInstances()), 2, t)
+
+	one := DiscordGuild(SBitoa(1 << 22))
+	two := DiscordGuild(SBitoa(2 << 22))
+	three := DiscordGuild(SBitoa(3 << 22))
+	Check(sb.GuildShard(one), sb, t)
+	Check(sb.GuildShard(two), (*SweetieBot)(nil), t)
+	Check(sb.GuildShard(three), shard, t)
+	Check(shard.GuildShard(one), sb, t)
+
+	shard.Guilds[three] = &GuildInfo{ID: three.String(), Bot: shard}
+	Check(sb.getGuildFromID(three.String()), shard.Guilds[three], t)
+	Check(shard.getGuildFromID(three.String()), shard.Guilds[three], t)
+	Check(sb.getGuildFromID(two.String()), (*GuildInfo)(nil), t)
+	Check(sb.FindGuild(three), shard.Guilds[three], t)
+	Check(sb.GuildCount(), 1, t)
+}
+
+func TestBadShards(t *testing.T) {
+	hostfile := []byte(`{
+		"token": "primary",
+		"dbdriver": "sqlite3",
+		"dbauth": ":memory:",
+		"shardcount": 2,
+		"shards": [2]
+	}`)
+	Check(NewFromSelfhost("", hostfile, func(guild *GuildInfo) []Module { return nil }), (*SweetieBot)(nil), t)
+}
@@ -1,8 +1,14 @@
 package sweetiebot
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
 	"reflect"
+	"strconv"
 	"strings"
 
 	"github.com/blackhole12/discordgo"
@@ -23,6 +29,10 @@ func (w *ConfigModule) Commands() []Command {
 		&setConfigCommand{},
 		&getConfigCommand{},
 		&setupCommand{},
+		&configHistoryCommand{},
+		&configRollbackCommand{},
+		&exportConfigCommand{},
+		&importConfigCommand{},
 	}
 }
 
@@ -47,12 +57,13 @@ func (c *setConfigCommand) Process(args []string, msg *discordgo.Message, indice
 		return "```\nNo value to set!```", false, nil
 	}
 	var err error
-	args[0], err = FixRequest(args[0], reflect.ValueOf(&info.Config).Elem())
+	args[0], err = FixRequest(args[0], &info.Config)
 	if err != nil {
 		return ReturnError(err)
 	}
 	n, ok := info.Config.SetConfig(info, args, indices, msg.Content)
-	info.SaveConfig()
+	info.SaveConfigBy(DiscordUser(msg.Author.ID))
+	info.UpdateSlashCommands()
 	if ok {
 		return "```\nSuccessfully set " + args[0] + " to " + n + ".```", false, nil
 	}
@@ -82,38 +93,34 @@ func (c *getConfigCommand) Info() *CommandInfo {
 }
 
 func (c *getConfigCommand) Process(args []string, msg *discordgo.Message, indices []int, info *GuildInfo) (string, bool, *discordgo.MessageEmbed) {
-	t := reflect.ValueOf(&info.Config).Elem()
-	n := t.NumField()
+	groups := info.Config.groups()
 	if len(args) < 1 {
-		fields := make([]*discordgo.MessageEmbedField, 0, n)
-		for i := 0; i < n; i++ {
-			switch t.Field(i).Kind() {
-			case reflect.Struct:
-				f := t.Field(i)
-				s := make([]string, 0, f.NumField())
-				for j := 0; j < f.NumField(); j++ {
-					str := f.Type().Field(j).Name
-					switch f.Field(j).Kind() {
-					case reflect.Slice:
+		fields := make([]*discordgo.MessageEmbedField, 0, len(groups))
+		for _, g := range groups {
+			f := g.Value
+			s := make([]string, 0, f.NumField())
+			for j := 0; j < f.NumField(); j++ {
+				str := f.Type().Field(j).Name
+				switch f.Field(j).Kind() {
+				case reflect.Slice:
+					str += " [list]"
+				case reflect.Map:
+					if f.Field(j).Type().Elem() == reflect.TypeOf(true) {
 						str += " [list]"
-					case reflect.Map:
-						if f.Field(j).Type().Elem() == reflect.TypeOf(true) {
-							str += " [list]"
-						} else {
-							switch f.Field(j).Type().Elem().Kind() {
-							case reflect.Slice:
-								fallthrough
-							case reflect.Map:
-								str += " [maplist]"
-							default:
-								str += " [map]"
-							}
+					} else {
+						switch f.Field(j).Type().Elem().Kind() {
+						case reflect.Slice:
+							fallthrough
+						case reflect.Map:
+							str += " [maplist]"
+						default:
+							str += " [map]"
 						}
 					}
-					s = append(s, str)
 				}
-				fields = append(fields, &discordgo.MessageEmbedField{Name: t.Type().Field(i).Name, Value: strings.Join(s, "\n"), Inline: true})
+				s = append(s, str)
 			}
+			fields = append(fields, &discordgo.MessageEmbedField{Name: g.Name, Value: strings.Join(s, "\n"), Inline: true})
 		}
 		embed := &discordgo.MessageEmbed{
 			Type: "rich",
@@ -129,7 +136,7 @@ func (c *getConfigCommand) Process(args []string, msg *discordgo.Message, indice
 		return "", false, nil
 	}
 	var err error
-	args[0], err = FixRequest(args[0], t)
+	args[0], err = FixRequest(args[0], &info.Config)
 	if err != nil {
 		return ReturnError(err)
 	}
@@ -138,67 +145,65 @@ func (c *getConfigCommand) Process(args []string, msg *discordgo.Message, indice
 		arg = append(arg, args[1])
 	}
 
-	for i := 0; i < n; i++ {
-		if strings.ToLower(t.Type().Field(i).Name) == arg[0] {
-			switch t.Field(i).Kind() {
-			case reflect.Struct:
-				f := t.Field(i)
-				if len(arg) > 1 {
-					for j := 0; j < f.NumField(); j++ {
-						if strings.ToLower(f.Type().Field(j).Name) == arg[1] {
-							lines := getSubStruct(arg, f, j, info)
-							if len(lines) == 0 {
-								return fmt.Sprintf("```\n%s.%s: [empty]```", arg[0], arg[1]), false, nil
-							} else if len(lines) == 1 {
-								return fmt.Sprintf("```\n%s.%s: %s```", arg[0], arg[1], info.Sanitize(lines[0], CleanCodeBlock)), false, nil
-							}
-							return fmt.Sprintf("```\n--- %s.%s ---\n%s```", arg[0], arg[1], info.Sanitize(strings.Join(lines, "\n"), CleanCodeBlock)), false, nil
-						}
-					}
-				} else {
-					fields := make([]*discordgo.MessageEmbedField, 0, f.NumField())
-					dump := []string{}
-					for j := 0; j < f.NumField(); j++ {
-						desc, ok := getConfigHelp(t.Type().Field(i).Name, f.Type().Field(j).Name)
-						if !ok {
-							desc = "\u200b"
-						}
-						fields = append(fields, &discordgo.MessageEmbedField{Name: f.Type().Field(j).Name, Value: desc, Inline: false})
-
+	for _, g := range groups {
+		if strings.ToLower(g.Name) == arg[0] {
+			f := g.Value
+			if len(arg) > 1 {
+				for j := 0; j < f.NumField(); j++ {
+					if strings.ToLower(f.Type().Field(j).Name) == arg[1] {
 						lines := getSubStruct(arg, f, j, info)
 						if len(lines) == 0 {
-							dump = append(dump, fmt.Sprintf("%s: [empty]", f.Type().Field(j).Name))
+							return fmt.Sprintf("```\n%s.%s: [empty]```", arg[0], arg[1]), false, nil
 						} else if len(lines) == 1 {
-							dump = append(dump, fmt.Sprintf("%s: %s", f.Type().Field(j).Name, info.Sanitize(lines[0], CleanCodeBlock)))
-						} else {
-							dump = append(dump, fmt.Sprintf("%s: [%v items]", f.Type().Field(j).Name, len(lines)))
+							return fmt.Sprintf("```\n%s.%s: %s```", arg[0], arg[1], info.Sanitize(lines[0], CleanCodeBlock)), false, nil
 						}
+						return fmt.Sprintf("```\n--- %s.%s ---\n%s```", arg[0], arg[1], info.Sanitize(strings.Join(lines, "\n"), CleanCodeBlock)), false, nil
 					}
-					embed := &discordgo.MessageEmbed{
-						Type: "rich",
-						Author: &discordgo.MessageEmbedAuthor{
-							URL:     "https://sweetiebot.io/help/" + strings.ToLower(t.Type().Field(i).Name),
-							Name:    t.Type().Field(i).Name + " Config Category",
-							IconURL: fmt.Sprintf("https://cdn.discordapp.com/avatars/%v/%s.jpg", info.Bot.SelfID, info.Bot.SelfAvatar),
-						},
-						Description: "```\n" + strings.Join(dump, "\n") + "```",
-						Color:       0x3e92e5,
-						Fields:      fields,
+				}
+			} else {
+				fields := make([]*discordgo.MessageEmbedField, 0, f.NumField())
+				dump := []string{}
+				for j := 0; j < f.NumField(); j++ {
+					desc, ok := getConfigHelp(g.Name, f.Type().Field(j).Name)
+					if !ok {
+						desc = "\u200b"
 					}
-					return "", false, embed
+					fields = append(fields, &discordgo.MessageEmbedField{Name: f.Type().Field(j).Name, Value: desc, Inline: false})
+
+					lines := getSubStruct(arg, f, j, info)
+					if len(lines) == 0 {
+						dump = append(dump, fmt.Sprintf("%s: [empty]", f.Type().Field(j).Name))
+					} else if len(lines) == 1 {
+						dump = append(dump, fmt.Sprintf("%s: %s", f.Type().Field(j).Name, info.Sanitize(lines[0], CleanCodeBlock)))
+					} else {
+						dump = append(dump, fmt.Sprintf("%s: [%v items]", f.Type().Field(j).Name, len(lines)))
+					}
+				}
+				embed := &discordgo.MessageEmbed{
+					Type: "rich",
+					Author: &discordgo.MessageEmbedAuthor{
+						URL:     "https://sweetiebot.io/help/" + strings.ToLower(g.Name),
+						Name:    g.Name + " Config Category",
+						IconURL: fmt.Sprintf("https://cdn.discordapp.com/avatars/%v/%s.jpg", info.Bot.SelfID, info.Bot.SelfAvatar),
+					},
+					Description: "```\n" + strings.Join(dump, "\n") + "```",
+					Color:       0x3e92e5,
+					Fields:      fields,
 				}
+				return "", false, embed
 			}
 		}
 	}
 
-	return "```\nThat's not a recognized config option! Type " + info.Config.Basic.CommandPrefix + "getconfig without any arguments to list all possible config options. Use \".\" to specify which category of options you want - for example, \"Basic.ModChannel\". If the option is a map, you can specify the key as well: \"Help.Rules 1\". Using " + info.Config.Basic.CommandPrefix + "getconfig with just a category will list help for that category, e.g. \"" + info.Config.Basic.CommandPrefix + "getconfig Basic\".```", false, nil
+	prefix := info.Prefix(DiscordChannel(msg.ChannelID))
+	return "```\nThat's not a recognized config option! Type " + prefix + "getconfig without any arguments to list all possible config options. Use \".\" to specify which category of options you want - for example, \"Basic.ModChannel\". If the option is a map, you can specify the key as well: \"Help.Rules 1\". Using " + prefix + "getconfig with just a category will list help for that category, e.g. \"" + prefix + "getconfig Basic\".```", false, nil
 }
 func (c *getConfigCommand) Usage(info *GuildInfo) *CommandUsage {
 	return &CommandUsage{
 		Desc: "Displays a list of available configuration options or their values.",
 		Params: []CommandUsageParam{
 			{Name: "option", Desc: "The configuration option to display. Use `Help.Rules` to specify a config option in a category. If this is just a category, like `Basic`, lists help information for all config options in that category.", Optional: true},
-			{Name: "map key", Desc: "If the option is a map, this determines the particular key to display. For example: `" + info.Config.Basic.CommandPrefix + "getconfig Help.Rules 1` will return rule 1 in the rules map.", Optional: true},
+			{Name: "map key", Desc: "If the option is a map, this determines the particular key to display. For example: `" + info.Prefix(ChannelEmpty) + "getconfig Help.Rules 1` will return rule 1 in the rules map.", Optional: true},
 		},
 	}
 }
@@ -241,7 +246,7 @@ func (c *setupCommand) Process(args []string, msg *discordgo.Message, indices []
 	}
 	if info.Config.SetupDone {
 		if strings.ToLower(args[0]) != "override" {
-			return "```\nWARNING: This server has already been configured. If you run !setup again, it will reset ALL CONFIGURATION DATA to defaults! If you wish to proceed, use " + info.Config.Basic.CommandPrefix + "setup OVERRIDE <your arguments>```", false, nil
+			return "```\nWARNING: This server has already been configured. If you run " + info.Prefix(DiscordChannel(msg.ChannelID)) + "setup again, it will reset ALL CONFIGURATION DATA to defaults! If you wish to proceed, use " + info.Prefix(DiscordChannel(msg.ChannelID)) + "setup OVERRIDE <your arguments>```", false, nil
 		}
 		args = args[1:]
 		indices = indices[1:]
@@ -306,11 +311,13 @@ func (c *setupCommand) Process(args []string, msg *discordgo.Message, indices []
 	modname := info.Config.Basic.ModRole.Show(info)
 	modchannel := info.Config.Basic.ModChannel.Show(info)
 	logchannel := info.Config.Log.Channel.Show(info)
+	prefix := info.Prefix(DiscordChannel(msg.ChannelID))
 
 	info.setupSilenceRole()
 	info.Config.SetupDone = true
-	info.SaveConfig()
-	return fmt.Sprintf("```\nServer configured!\nModerator Role: %v\nMod Channel: %v\nLog Channel: %v```\nNow that you've done basic configuration on %s, here are some additional features you can enable. For additional help, type `"+info.Config.Basic.CommandPrefix+"help` for a list of commands and modules, or `"+info.Config.Basic.CommandPrefix+"getconfig` with no arguments for a list of configuration options. Using `"+info.Config.Basic.CommandPrefix+"help <module>` will display detailed help for that module and all its commands. Using `"+info.Config.Basic.CommandPrefix+"getconfig <group>` will display detailed help for all the configuration options in that configuration group. If you're still confused, please check the website: https://sweetiebot.io/\n\n**Bucket**\nIf you'd like to enable the bucket, use the command `"+info.Config.Basic.CommandPrefix+"enable Bucket`. It defaults to carrying a maximum of 10 items, but you can change this via the `Bucket.MaxItems` option.\n\n**Bored Module**\nIf you'd like "+info.GetBotName()+" to perform actions when the chat in a certain channel hasn't been active for a period of time, use `"+info.Config.Basic.CommandPrefix+"enable bored` followed by `"+info.Config.Basic.CommandPrefix+"setconfig modules.channels bored #yourchannel`, where `#yourchannel` is your general chat channel. The commands picked from are stored in `bored.commands`. By default, it will quote someone or attempt to throw an item out of the bucket.\n\n**Free Channels**\nIf you like, you can designate a channel to be free from command restrictions, so people can spam silly bot commands to their hearts content. If you had a channel called `#bot` for this, you can disable all command restrictions by using the command ```"+info.Config.Basic.CommandPrefix+"setconfig basic.freechannels #bot```.", modname, modchannel, logchannel, info.GetBotName()), false, nil
+	info.SaveConfigBy(DiscordUser(msg.Author.ID))
+	info.UpdateSlashCommands()
+	return fmt.Sprintf("```\nServer configured!\nModerator Role: %v\nMod Channel: %v\nLog Channel: %v```\nNow that you've done basic configuration on %s, here are some additional features you can enable. For additional help, type `"+prefix+"help` for a list of commands and modules, or `"+prefix+"getconfig` with no arguments for a list of configuration options. Using `"+prefix+"help <module>` will display detailed help for that module and all its commands. Using `"+prefix+"getconfig <group>` will display detailed help for all the configuration options in that configuration group. If you're still confused, please check the website: https://sweetiebot.io/\n\n**Bucket**\nIf you'd like to enable the bucket, use the command `"+prefix+"enable Bucket`. It defaults to carrying a maximum of 10 items, but you can change this via the `Bucket.MaxItems` option.\n\n**Bored Module**\nIf you'd like "+info.GetBotName()+" to perform actions when the chat in a certain channel hasn't been active for a period of time, use `"+prefix+"enable bored` followed by `"+prefix+"setconfig modules.channels bored #yourchannel`, where `#yourchannel` is your general chat channel. The commands picked from are stored in `bored.commands`. By default, it will quote someone or attempt to throw an item out of the bucket.\n\n**Free Channels**\nIf you like, you can designate a channel to be free from command restrictions, so people can spam silly bot commands to their hearts content. If you had a channel called `#bot` for this, you can disable all command restrictions by using the command ```"+prefix+"setconfig basic.freechannels #bot```.", modname, modchannel, logchannel, info.GetBotName()), false, nil
 }
 func (c *setupCommand) Usage(info *GuildInfo) *CommandUsage {
 	return &CommandUsage{
@@ -322,3 +329,158 @@ func (c *setupCommand) Usage(info *GuildInfo) *CommandUsage {
 		},
 	}
 }
+
+type configHistoryCommand struct {
+}
+
+func (c *configHistoryCommand) Info() *CommandInfo {
+	return &CommandInfo{
+		Name:      "ConfigHistory",
+		Usage:     "Lists recent changes to the configuration.",
+		Sensitive: true,
+	}
+}
+func (c *configHistoryCommand) Process(args []string, msg *discordgo.Message, indices []int, info *GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+	return info.ProcessTyped(c, args, msg, indices)
+}
+func (c *configHistoryCommand) ProcessArgs(args CommandArgs, msg *discordgo.Message, info *GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+	if !info.Bot.DB.CheckStatus() {
+		return "```\nA temporary database outage is preventing this command from being executed.```", false, nil
+	}
+	history := info.Bot.DB.GetConfigHistory(SBatoi(info.ID), 10, int(args.Int("skip", 0)))
+	if len(history) == 0 {
+		return "```\nNo configuration changes have been recorded on this server.```", false, nil
+	}
+	tz := info.GetTimezone(DiscordUser(msg.Author.ID))
+	lines := make([]string, 0, len(history)*2)
+	for _, v := range history {
+		author := info.GetBotName()
+		if v.Author != 0 {
+			author = info.GetUserName(DiscordUser(SBitoa(v.Author)))
+		}
+		lines = append(lines, fmt.Sprintf("Version %v [%s] by %s:", v.Version, v.Timestamp.In(tz).Format("Jan 2 2006 3:04pm"), author))
+		for _, change := range strings.Split(v.Changes, "\n") {
+			lines = append(lines, "  "+change)
+		}
+	}
+	return "```\n" + info.Sanitize(strings.Join(lines, "\n"), CleanCodeBlock) + "```", len(lines) > MaxPublicLines, nil
+}
+func (c *configHistoryCommand) Usage(info *GuildInfo) *CommandUsage {
+	return &CommandUsage{
+		Desc: "Lists the 10 most recent versions of the configuration, along with who saved them and every option they changed. Use `" + info.Prefix(ChannelEmpty) + "configrollback` to go back to one of them. Only the last " + strconv.Itoa(MaxConfigHistory) + " versions are kept.",
+		Params: []CommandUsageParam{
+			{Name: "skip", Desc: "How many of the most recent versions to skip, so you can look further back.", Optional: true, Type: ParamInt, Min: 0, Max: MaxConfigHistory},
+		},
+	}
+}
+
+type configRollbackCommand struct {
+}
+
+func (c *configRollbackCommand) Info() *CommandInfo {
+	return &CommandInfo{
+		Name:      "ConfigRollback",
+		Usage:     "Restores an earlier version of the configuration.",
+		Sensitive: true,
+	}
+}
+func (c *configRollbackCommand) Process(args []string, msg *discordgo.Message, indices []int, info *GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+	return info.ProcessTyped(c, args, msg, indices)
+}
+func (c *configRollbackCommand) ProcessArgs(args CommandArgs, msg *discordgo.Message, info *GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+	if !info.Bot.DB.CheckStatus() {
+		return "```\nA temporary database outage is preventing this command from being executed.```", false, nil
+	}
+	version := args.Int("version", 0)
+	config := info.Bot.DB.GetConfigVersion(SBatoi(info.ID), uint64(version))
+	if config == nil {
+		return fmt.Sprintf("```\nThere is no version %v in the config history. Use %sconfighistory to see which versions exist.```", version, info.Prefix(DiscordChannel(msg.ChannelID))), false, nil
+	}
+	if err := info.ReplaceConfig(config, DiscordUser(msg.Author.ID)); err != nil {
+		return "```\nFailed to restore version " + strconv.FormatInt(version, 10) + ": " + err.Error() + "```", false, nil
+	}
+	info.UpdateSlashCommands()
+	return "```\nRestored the configuration to version " + strconv.FormatInt(version, 10) + ".```", false, nil
+}
+func (c *configRollbackCommand) Usage(info *GuildInfo) *CommandUsage {
+	return &CommandUsage{
+		Desc: "Replaces the entire configuration with an earlier version from `" + info.Prefix(ChannelEmpty) + "confighistory`. The rollback is itself recorded as a new version, so it can be undone.",
+		Params: []CommandUsageParam{
+			{Name: "version", Desc: "The version number to go back to.", Optional: false, Type: ParamInt},
+		},
+	}
+}
+
+type exportConfigCommand struct {
+}
+
+func (c *exportConfigCommand) Info() *CommandInfo {
+	return &CommandInfo{
+		Name:      "ExportConfig",
+		Usage:     "Sends the configuration as a file.",
+		Sensitive: true,
+	}
+}
+func (c *exportConfigCommand) Process(args []string, msg *discordgo.Message, indices []int, info *GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+	info.ConfigLock.RLock()
+	data, err := json.MarshalIndent(info.Config, "", "  ")
+	info.ConfigLock.RUnlock()
+	if err != nil {
+		return ReturnError(err)
+	}
+	_, err = info.Bot.DG.ChannelMessageSendComplex(msg.ChannelID, &discordgo.MessageSend{
+		Content: "Configuration for " + info.Name + ". Use `" + info.Prefix(DiscordChannel(msg.ChannelID)) + "importconfig` with this file attached to load it again.",
+		File:    &discordgo.File{Name: info.ID + ".json", Reader: bytes.NewReader(data)},
+	})
+	if err != nil {
+		return "```\nFailed to send the configuration: " + err.Error() + "```", false, nil
+	}
+	return "", false, nil
+}
+func (c *exportConfigCommand) Usage(info *GuildInfo) *CommandUsage {
+	return &CommandUsage{
+		Desc: "Sends the entire configuration as a JSON file attached to a message, which can be kept as a backup or loaded on another server with `" + info.Prefix(ChannelEmpty) + "importconfig`.",
+	}
+}
+
+type importConfigCommand struct {
+}
+
+func (c *importConfigCommand) Info() *CommandInfo {
+	return &CommandInfo{
+		Name:      "ImportConfig",
+		Usage:     "Loads a configuration file.",
+		Sensitive: true,
+	}
+}
+func (c *importConfigCommand) Process(args []string, msg *discordgo.Message, indices []int, info *GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+	if len(msg.Attachments) == 0 {
+		return "```\nYou have to attach the configuration file to the message, like the one " + info.Prefix(DiscordChannel(msg.ChannelID)) + "exportconfig sends.```", false, nil
+	}
+	file := msg.Attachments[0]
+	if file.Size > info.Bot.MaxConfigSize {
+		return "```\n" + errConfigFileTooLarge.Error() + " Config files cannot exceed " + strconv.Itoa(info.Bot.MaxConfigSize) + " bytes.```", false, nil
+	}
+	resp, err := info.Bot.DG.Client.Get(file.URL)
+	if err != nil {
+		return "```\nFailed to download " + file.Filename + ": " + err.Error() + "```", false, nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "```\nFailed to download " + file.Filename + ": " + resp.Status + "```", false, nil
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, int64(info.Bot.MaxConfigSize)+1))
+	if err != nil {
+		return "```\nFailed to download " + file.Filename + ": " + err.Error() + "```", false, nil
+	}
+	if err = info.ReplaceConfig(data, DiscordUser(msg.Author.ID)); err != nil {
+		return "```\nFailed to import " + file.Filename + ": " + err.Error() + "```", false, nil
+	}
+	info.UpdateSlashCommands()
+	return "```\nImported the configuration from " + file.Filename + ". If this was a mistake, use " + info.Prefix(DiscordChannel(msg.ChannelID)) + "confighistory to find the previous version and " + info.Prefix(DiscordChannel(msg.ChannelID)) + "configrollback to restore it.```", false, nil
+}
+func (c *importConfigCommand) Usage(info *GuildInfo) *CommandUsage {
+	return &CommandUsage{
+		Desc: "Replaces the entire configuration with a JSON file attached to the message, usually one sent by `" + info.Prefix(ChannelEmpty) + "exportconfig`. Configs from older versions are upgraded the same way they would be when the bot starts. Nothing is changed if the file can't be read.",
+	}
+}
@@ -39,6 +39,8 @@ func (w *DebugModule) Commands() []Command {
 		&updateCommand{},
 		&dumpTablesCommand{},
 		&listGuildsCommand{},
+		&shardsCommand{},
+		&queuesCommand{},
 		&announceCommand{},
 		&removeAliasCommand{},
 		&getAuditCommand{},
@@ -67,7 +69,7 @@ func (w *DebugModule) OnTick(info *GuildInfo, t time.Time) {
 
 	if info.Bot.IsMainGuild(info) && t.Unix()-w.lastclean > CleanInterval {
 		go func() { // Getting the user list takes forever because of discord API limits, so we do this on a new thread
-			fmt.Println("CLEANING GUILDS")
+			info.Bot.Log.Info("Cleaning guilds")
 			w.lastclean = t.Unix()
 
 			lastid := ""
@@ -106,11 +108,7 @@ func (w *DebugModule) OnTick(info *GuildInfo, t time.Time) {
 
 							if ok || config.Expires == 0 {
 								config.Expires = timeNow + ExpireTime
-								info.Bot.GuildsLock.RLock()
-								guild, ok := info.Bot.Guilds[DiscordGuild(id)]
-								info.Bot.GuildsLock.RUnlock()
-
-								if ok {
+								if guild := info.Bot.getGuildFromID(id); guild != nil {
 									guild.ConfigLock.Lock()
 									guild.Config.Expires = config.Expires
 									guild.ConfigLock.Unlock()
@@ -118,16 +116,17 @@ func (w *DebugModule) OnTick(info *GuildInfo, t time.Time) {
 
 								if data, err := json.Marshal(&config); err == nil {
 									if err = ioutil.WriteFile(id+".json", data, 0664); err != nil {
-										info.Log("Error saving config file: ", err.Error())
+										info.LogError("Error saving config file: ", err)
 									}
 								} else {
-									info.Log("Error marshalling config file: ", err.Error())
+									info.LogError("Error marshalling config file: ", err)
 								}
 							} else if config.Expires < timeNow {
-								fmt.Println("Server ID " + id + " has expired.")
+								info.Bot.Log.With("guild", id).Info("Server ID " + id + " has expired.")
 								info.Bot.GuildsLock.Lock()
 								delete(info.Bot.Guilds, DiscordGuild(id))
 								info.Bot.GuildsLock.Unlock()
+								os.Remove(stateFile(id))
 								if err := os.Remove(id + ".json"); err == nil {
 									err = info.Bot.DB.RemoveGuild(SBatoi(id))
 								}
@@ -256,7 +255,7 @@ func (c *echoEmbedCommand) Usage(info *GuildInfo) *CommandUsage {
 
 func setCommandEnable(args []string, enable bool, success string, info *GuildInfo, msg *discordgo.Message) (string, bool, *discordgo.MessageEmbed) {
 	if len(args) == 0 {
-		return "```\nNo module or command specified.Use " + info.Config.Basic.CommandPrefix + "help with no arguments to list all modules and commands.```", false, nil
+		return "```\nNo module or command specified.Use " + info.Prefix(DiscordChannel(msg.ChannelID)) + "help with no arguments to list all modules and commands.```", false, nil
 	}
 	name := strings.ToLower(args[0])
 	for _, v := range info.Modules {
@@ -280,7 +279,8 @@ func setCommandEnable(args []string, enable bool, success string, info *GuildInf
 				}
 				info.Config.Modules.Disabled[ModuleID(name)] = true
 			}
-			info.SaveConfig()
+			info.SaveConfigBy(DiscordUser(msg.Author.ID))
+			info.UpdateSlashCommands()
 			return "", false, DumpCommandsModules(info, "", "**Success!** "+args[0]+success, msg)
 		}
 	}
@@ -292,11 +292,12 @@ func setCommandEnable(args []string, enable bool, success string, info *GuildInf
 				CheckMapNilBool(&info.Config.Modules.CommandDisabled)
 				info.Config.Modules.CommandDisabled[k] = true
 			}
-			info.SaveConfig()
+			info.SaveConfigBy(DiscordUser(msg.Author.ID))
+			info.UpdateSlashCommands()
 			return "", false, DumpCommandsModules(info, "", "**Success!** "+args[0]+success, msg)
 		}
 	}
-	return "```\nThe " + args[0] + " module/command does not exist. Use " + info.Config.Basic.CommandPrefix + "help with no arguments to list all modules and commands.```", false, nil
+	return "```\nThe " + args[0] + " module/command does not exist. Use " + info.Prefix(DiscordChannel(msg.ChannelID)) + "help with no arguments to list all modules and commands.```", false, nil
 }
 
 type disableCommand struct {
@@ -364,7 +365,7 @@ func (c *updateCommand) Process(args []string, msg *discordgo.Message, indices [
 		return "```\nThe bot is already checking for or downloading an update.```", false, nil
 	}
 	defer info.Bot.UpdateLock.Clear()
-	switch atomic.LoadUint32(&info.Bot.quit) {
+	switch atomic.LoadUint32(info.Bot.quit) {
 	case QuitNow:
 		return "```\nThe bot is shutting down.```", false, nil
 	case QuitRaid:
@@ -385,15 +386,17 @@ func (c *updateCommand) Process(args []string, msg *discordgo.Message, indices [
 		DownloadFile(UpdateEndpoint(file, info.Bot.Owner, 0), "~"+file, false)
 	}
 
-	info.Bot.GuildsLock.RLock()
-	defer info.Bot.GuildsLock.RUnlock()
-	for _, v := range info.Bot.Guilds {
-		if v.Config.Log.Channel != ChannelEmpty && v.Config.Log.Channel != ChannelExclusion && !v.Config.Log.Channel.Equals(msg.ChannelID) {
-			v.SendMessage(v.Config.Log.Channel, "```\nShutting down for update...```")
+	for _, shard := range info.Bot.Shards {
+		shard.GuildsLock.RLock()
+		for _, v := range shard.Guilds {
+			if v.Config.Log.Channel != ChannelEmpty && v.Config.Log.Channel != ChannelExclusion && !v.Config.Log.Channel.Equals(msg.ChannelID) {
+				v.SendMessage(v.Config.Log.Channel, "```\nShutting down for update...```")
+			}
 		}
+		shard.GuildsLock.RUnlock()
 	}
 
-	atomic.StoreUint32(&info.Bot.quit, QuitRaid) // Instead of trying to call a batch script, we run the bot inside an infinite loop batch script and just shut it off when we want to update
+	atomic.StoreUint32(info.Bot.quit, QuitRaid) // Instead of trying to call a batch script, we run the bot inside an infinite loop batch script and just shut it off when we want to update
 	return "```\nShutting down for update...```", false, nil
 }
 func (c *updateCommand) Usage(info *GuildInfo) *CommandUsage {
@@ -461,15 +464,18 @@ func (c *listGuildsCommand) Process(args []string, msg *discordgo.Message, indic
 	if !info.Bot.Owner.Equals(msg.Author.ID) {
 		return "```\nOnly the owner of the bot itself can call this!```", false, nil
 	}
-	info.Bot.DG.State.RLock()
-	guilds := append([]*discordgo.Guild{}, info.Bot.DG.State.Guilds...)
-	info.Bot.DG.State.RUnlock()
+	guilds := []*discordgo.Guild{}
+	for _, shard := range info.Bot.Shards {
+		shard.DG.State.RLock()
+		guilds = append(guilds, shard.DG.State.Guilds...)
+		shard.DG.State.RUnlock()
+	}
 	sort.Sort(guildSlice(guilds))
 	s := make([]string, 0, len(guilds))
 	private := 0
 	for _, v := range guilds {
 		username := "<@" + v.OwnerID + ">"
-		m, _ := info.Bot.DG.GetMember(DiscordUser(v.OwnerID), v.ID)
+		m, _ := info.Bot.GuildShard(DiscordGuild(v.ID)).DG.GetMember(DiscordUser(v.OwnerID), v.ID)
 		if m != nil {
 			username = m.User.Username + "#" + m.User.Discriminator
 		}
@@ -478,17 +484,94 @@ func (c *listGuildsCommand) Process(args []string, msg *discordgo.Message, indic
 			count = len(v.Members)
 		}
 		if count > 50 {
-			s = append(s, info.Sanitize(fmt.Sprintf("%v (%v) - %v", v.Name, count, username), CleanCodeBlock))
+			s = append(s, info.Sanitize(fmt.Sprintf("%v (%v) - %v", v.Name, count, username), CleanAll))
 		} else {
 			private++
 		}
 	}
-	return fmt.Sprintf("```\n%s has joined these servers:\n%s\n\n+ %v private servers (Basic.Importable is false)```", info.GetBotName(), strings.Join(s, "\n"), private), len(s) > 8, nil
+	embed := &discordgo.MessageEmbed{
+		Title:  info.GetBotName() + " has joined these servers:",
+		Color:  0x3e92e5,
+		Footer: &discordgo.MessageEmbedFooter{Text: fmt.Sprintf("+ %v private servers (Basic.Importable is false)", private)},
+	}
+	return "", false, info.Paginate(msg, PageLines(embed, s, 15))
 }
 func (c *listGuildsCommand) Usage(info *GuildInfo) *CommandUsage {
 	return &CommandUsage{Desc: "Lists the servers the bot is on."}
 }
 
+type shardsCommand struct {
+}
+
+func (c *shardsCommand) Info() *CommandInfo {
+	return &CommandInfo{
+		Name:              "Shards",
+		Usage:             "Lists the shards this process runs.",
+		Sensitive:         true,
+		ServerIndependent: true,
+	}
+}
+func (c *shardsCommand) Process(args []string, msg *discordgo.Message, indices []int, info *GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+	if !info.Bot.Owner.Equals(msg.Author.ID) {
+		return "```\nOnly the owner of the bot itself can call this!```", false, nil
+	}
+	s := make([]string, 0, len(info.Bot.Shards))
+	for _, shard := range info.Bot.Shards {
+		shard.GuildsLock.RLock()
+		count := len(shard.Guilds)
+		shard.GuildsLock.RUnlock()
+		latency, missed := shard.HeartbeatLatency()
+		line := fmt.Sprintf("Shard %v/%v: %s, heartbeat %v", shard.ShardID, shard.ShardCount, Pluralize(int64(count), " server"), latency)
+		if missed > 0 {
+			line += fmt.Sprintf(" (missed %v)", missed)
+		}
+		s = append(s, line)
+	}
+	return "```\n" + strings.Join(s, "\n") + "```", len(s) > 8, nil
+}
+func (c *shardsCommand) Usage(info *GuildInfo) *CommandUsage {
+	return &CommandUsage{Desc: "Lists each shard of " + info.GetBotName() + " running in this process, along with how many servers it's on, how long its last heartbeat took, and how many heartbeats it has missed in a row."}
+}
+
+type queuesCommand struct {
+}
+
+func (c *queuesCommand) Info() *CommandInfo {
+	return &CommandInfo{
+		Name:              "Queues",
+		Usage:             "Lists the servers with the longest event queues.",
+		Sensitive:         true,
+		ServerIndependent: true,
+	}
+}
+func (c *queuesCommand) Process(args []string, msg *discordgo.Message, indices []int, info *GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+	if !info.Bot.Owner.Equals(msg.Author.ID) {
+		return "```\nOnly the owner of the bot itself can call this!```", false, nil
+	}
+	stats := info.Bot.QueueStats()
+	queued := 0
+	var dropped uint32
+	for _, v := range stats {
+		queued += v.Depth
+		dropped += v.Dropped
+	}
+	s := []string{fmt.Sprintf("%v events queued across %s, %v dropped.", queued, Pluralize(int64(len(stats)), " server"), dropped)}
+	if len(stats) > 10 {
+		stats = stats[:10]
+	}
+	for _, v := range stats {
+		line := fmt.Sprintf("%s: %v queued (peak %v), %v processed, %v dropped", v.Guild.Name, v.Depth, v.Peak, v.Processed, v.Dropped)
+		if v.Busy >= time.Second {
+			line += ", busy for " + TimeDiff(v.Busy)
+		}
+		s = append(s, line)
+	}
+	return "```\n" + info.Sanitize(strings.Join(s, "\n"), CleanCodeBlock) + "```", len(s) > 8, nil
+}
+func (c *queuesCommand) Usage(info *GuildInfo) *CommandUsage {
+	return &CommandUsage{Desc: "Lists the 10 servers with the most events waiting to be processed, along with the deepest their queue has been, how many events they've processed and dropped, and how long the current event has been running if it's stuck."}
+}
+
 type announceCommand struct {
 }
 
@@ -507,12 +590,14 @@ func (c *announceCommand) Process(args []string, msg *discordgo.Message, indices
 	}
 
 	arg := msg.Content[indices[0]:]
-	info.Bot.GuildsLock.RLock()
-	defer info.Bot.GuildsLock.RUnlock()
-	for _, v := range info.Bot.Guilds {
-		if v.Config.Log.Channel != ChannelEmpty {
-			v.SendMessage(v.Config.Log.Channel, v.Config.Basic.ModRole.Display()+" "+arg)
+	for _, shard := range info.Bot.Shards {
+		shard.GuildsLock.RLock()
+		for _, v := range shard.Guilds {
+			if v.Config.Log.Channel != ChannelEmpty {
+				v.SendMessage(v.Config.Log.Channel, v.Config.Basic.ModRole.Display()+" "+arg)
+			}
 		}
+		shard.GuildsLock.RUnlock()
 	}
 
 	return "", false, nil
@@ -551,7 +636,7 @@ func (c *removeAliasCommand) Process(args []string, msg *discordgo.Message, indi
 		return "```\nA temporary database outage is preventing this command from being executed.```", false, nil
 	}
 	info.Bot.DB.RemoveAlias(PingAtoi(args[0]), msg.Content[indices[1]:])
-	return "```\nAttempted to remove the alias. Use " + info.Config.Basic.CommandPrefix + "aka to check if it worked.```", false, nil
+	return "```\nAttempted to remove the alias. Use " + info.Prefix(DiscordChannel(msg.ChannelID)) + "aka to check if it worked.```", false, nil
 }
 func (c *removeAliasCommand) Usage(info *GuildInfo) *CommandUsage {
 	return &CommandUsage{
@@ -627,13 +712,15 @@ func (c *getAuditCommand) Process(args []string, msg *discordgo.Message, indices
 	}
 
 	r := info.Bot.DB.GetAuditRows(low, high, user, search, SBatoi(info.ID))
-	ret := []string{"```\nMatching Audit Log entries:```"}
-
+	if len(r) == 0 {
+		return "```\nNo audit log entries match that.```", false, nil
+	}
+	ret := make([]string, 0, len(r))
 	for _, v := range r {
-		ret = append(ret, fmt.Sprintf("[%s] %s: %s", info.ApplyTimezone(v.Timestamp, DiscordUser(msg.Author.ID)).Format("1/2 3:04:05PM"), v.Author, v.Message))
+		ret = append(ret, info.Sanitize(fmt.Sprintf("[%s] %s: %s", info.ApplyTimezone(v.Timestamp, DiscordUser(msg.Author.ID)).Format("1/2 3:04:05PM"), v.Author, v.Message), CleanMost))
 	}
 
-	return info.Sanitize(strings.Join(ret, "\n"), CleanMost), len(ret) > 12, nil
+	return "", false, info.Paginate(msg, PageLines(&discordgo.MessageEmbed{Title: "Matching Audit Log entries", Color: 0x3e92e5}, ret, 10))
 }
 func (c *getAuditCommand) Usage(info *GuildInfo) *CommandUsage {
 	return &CommandUsage{
@@ -67,6 +67,22 @@ func (s *DiscordGoSession) ChannelMessageSendEmbed(channelID string, embed *disc
 	mock.Input(s.ChannelMessageSendEmbed, channelID, embed)
 	return
 }
+func (s *DiscordGoSession) ChannelMessageEditEmbed(channelID, messageID string, embed *discordgo.MessageEmbed) (m *discordgo.Message, err error) {
+	mock.Input(s.ChannelMessageEditEmbed, channelID, messageID, embed)
+	return
+}
+func (s *DiscordGoSession) MessageReactionAdd(channelID, messageID, emojiID string) (err error) {
+	mock.Input(s.MessageReactionAdd, channelID, messageID, emojiID)
+	return nil
+}
+func (s *DiscordGoSession) MessageReactionRemove(channelID, messageID, emojiID, userID string) (err error) {
+	mock.Input(s.MessageReactionRemove, channelID, messageID, emojiID, userID)
+	return nil
+}
+func (s *DiscordGoSession) MessageReactionsRemoveAll(channelID, messageID string) (err error) {
+	mock.Input(s.MessageReactionsRemoveAll, channelID, messageID)
+	return nil
+}
 func (s *DiscordGoSession) ChannelPermissionSet(channelID, targetID, targetType string, allow, deny int) (err error) {
 	mock.Input(s.ChannelPermissionSet, channelID, targetID, targetType, allow, deny)
 	return
@@ -18,23 +18,28 @@ import (
 
 // GuildInfo Stores state information about a guild
 type GuildInfo struct {
-	ID           string // Cache the ID because it doesn't change
-	Name         string // Cache the name to reduce locking
-	OwnerID      DiscordUser
-	BotNick      string     // If not empty, the nickname assigned to the bot in this server
-	Silver       AtomicBool // Has paid features (always true if selfhosting)
-	lastlogerr   int64
-	LastRaid     int64 // Last time a raid was recorded by the spam module (or any other module that records raids)
-	commandLock  sync.RWMutex
-	commandLast  map[string]int64
-	commandlimit *SaturationLimit
-	ConfigLock   sync.RWMutex
-	Config       BotConfig
-	hooks        moduleHooks
-	Modules      []Module
-	commands     map[CommandID]Command
-	commandmap   map[CommandID]ModuleID // Exists entirely so the help command can match commands to their parent module
-	Bot          *SweetieBot
+	ID            string // Cache the ID because it doesn't change
+	Name          string // Cache the name to reduce locking
+	OwnerID       DiscordUser
+	BotNick       string     // If not empty, the nickname assigned to the bot in this server
+	Silver        AtomicBool // Has paid features (always true if selfhosting)
+	lastlogerr    int64
+	lastRaid      uint32 // Unix time of the last RaidDetected event
+	commandLock   sync.RWMutex
+	cooldowns     map[cooldownKey]int64 // When each cooldown expires, in unix time
+	newCooldowns  []cooldownKey         // Cooldowns that started since they were last saved to the database
+	commandlimit  *SaturationLimit
+	ConfigLock    sync.RWMutex
+	Config        BotConfig
+	hooks         moduleHooks
+	events        eventBus
+	Modules       []Module
+	commands      map[CommandID]Command
+	commandmap    map[CommandID]ModuleID // Exists entirely so the help command can match commands to their parent module
+	slashLock     sync.Mutex
+	slashCommands []byte       // The last set of slash commands registered with discord, so we only update them when they change
+	worker        *guildWorker // Runs the events of this guild in order, or nil if they should run immediately
+	Bot           *SweetieBot
 }
 
 var errOwnerExclusive = errors.New("Only the owner of the bot can run this command!")
@@ -45,14 +50,15 @@ var errDisabled = errors.New("this command is disabled")
 var errSilenced = errors.New("silenced users cannot use commands")
 var errInvalidChannel = errors.New("Attempted to send message to channel on a different server.")
 var errConfigFileTooLarge = errors.New("Error saving config file: Config file is too large!")
+var errNotConnected = errors.New("not connected to discord yet")
 
 // NewGuildInfo spawns a new GuildInfo object with a default configuration
 func NewGuildInfo(sb *SweetieBot, g *discordgo.Guild) *GuildInfo {
-	return &GuildInfo{
+	info := &GuildInfo{
 		ID:           g.ID,
 		Name:         g.Name,
 		OwnerID:      DiscordUser(g.OwnerID),
-		commandLast:  make(map[string]int64),
+		cooldowns:    make(map[cooldownKey]int64),
 		commandlimit: &SaturationLimit{[]int64{}, 0, AtomicFlag{0}},
 		commands:     make(map[CommandID]Command),
 		commandmap:   make(map[CommandID]ModuleID),
@@ -60,6 +66,9 @@ func NewGuildInfo(sb *SweetieBot, g *discordgo.Guild) *GuildInfo {
 		Bot:          sb,
 		Config:       *DefaultConfig(),
 	}
+	info.SubscribeRaidDetected(recordRaid)
+	info.SubscribeMemberSilenced(countSpamSilence)
+	return info
 }
 
 // AddCommand adds a command to the guild
@@ -69,20 +78,52 @@ func (info *GuildInfo) AddCommand(c Command, m Module) {
 	info.commandmap[name] = ModuleID(strings.ToLower(m.Name()))
 }
 
+// RemoveCommand removes a command that was added while the bot was running
+func (info *GuildInfo) RemoveCommand(name CommandID) {
+	delete(info.commands, name)
+	delete(info.commandmap, name)
+}
+
+// HasCommand returns true if the guild has a command with the given lowercase name
+func (info *GuildInfo) HasCommand(name CommandID) bool {
+	_, ok := info.commands[name]
+	return ok
+}
+
 // SaveConfig saves the config file to disk
-func (info *GuildInfo) SaveConfig() (err error) {
+func (info *GuildInfo) SaveConfig() error {
+	return info.SaveConfigBy(UserEmpty)
+}
+
+// SaveConfigBy saves the config file to disk and records it in the config history as a change made by the given user.
+// Changes the bot makes on its own are saved by UserEmpty.
+func (info *GuildInfo) SaveConfigBy(user DiscordUser) error {
+	return info.saveConfig(user, true)
+}
+
+// SaveConfigWithoutHistory saves the config file to disk without recording a new version in the config history. This is
+// for options that change all the time without anyone configuring anything, like counters, which would otherwise push
+// every real change out of the history.
+func (info *GuildInfo) SaveConfigWithoutHistory() error {
+	return info.saveConfig(UserEmpty, false)
+}
+
+func (info *GuildInfo) saveConfig(user DiscordUser, record bool) (err error) {
 	data, err := json.Marshal(info.Config)
 	if err == nil {
 		if len(data) > info.Bot.MaxConfigSize {
-			info.Log("Error saving config file: Config file is too large! Config files cannot exceed " + strconv.Itoa(info.Bot.MaxConfigSize) + " bytes.")
+			info.Logger().Error("Error saving config file: Config file is too large! Config files cannot exceed " + strconv.Itoa(info.Bot.MaxConfigSize) + " bytes.")
 			err = errConfigFileTooLarge
 		} else {
+			old, _ := ioutil.ReadFile(info.ID + ".json")
 			if err = ioutil.WriteFile(info.ID+".json", data, 0664); err != nil {
-				info.Log("Error saving config file: ", err.Error())
+				info.LogError("Error saving config file: ", err)
+			} else if record {
+				info.recordConfig(old, data, user)
 			}
 		}
 	} else {
-		info.Log("Error writing json: ", err.Error())
+		info.LogError("Error writing json: ", err)
 	}
 	return
 }
@@ -90,7 +131,7 @@ func (info *GuildInfo) SaveConfig() (err error) {
 // SendEmbed sends an embed message to the channel, splitting it into multiple messages if necessary
 func (info *GuildInfo) SendEmbed(channelID DiscordChannel, embed *discordgo.MessageEmbed) error {
 	if channelID == "heartbeat" {
-		atomic.AddUint32(&info.Bot.heartbeat, 1)
+		info.Bot.beat()
 		return nil
 	}
 	if ch, private := info.Bot.ChannelIsPrivate(channelID); !private && (ch == nil || ch.GuildID != info.ID) {
@@ -173,44 +214,55 @@ func (info *GuildInfo) RequestPostWithBuffer(urlStr string, data *discordgo.Mess
 }
 
 func (info *GuildInfo) sendContent(channelID DiscordChannel, message string, minRequest int) {
+	atomic.AddInt32(&info.Bot.sending, 1)
+	defer atomic.AddInt32(&info.Bot.sending, -1)
 	_, err := info.RequestPostWithBuffer(discordgo.EndpointChannelMessages(channelID.String()), &discordgo.MessageSend{
 		Content: message,
 	}, minRequest)
 	if err != nil {
-		fmt.Println("Failed to send message: ", err.Error())
+		info.Bot.Log.With("guild", info.ID).With("channel", channelID).LogError("Failed to send message: ", err) // Not sent to the guild's log channel, because that might be what's failing
 	}
 }
 
 // SendMessage sends a message to the given channel, splitting it into multiple messages if necessary, and combining smaller messages if a rate limit is about to be hit
 func (info *GuildInfo) SendMessage(channelID DiscordChannel, message string) error {
 	if channelID == "heartbeat" {
-		atomic.AddUint32(&info.Bot.heartbeat, 1)
+		info.Bot.beat()
 		return nil
 	}
 	if ch, private := info.Bot.ChannelIsPrivate(channelID); !private && (ch == nil || ch.GuildID != info.ID) {
 		return errInvalidChannel
 	}
 
+	parts := splitMessage(message)
+	for _, part := range parts[:len(parts)-1] {
+		info.sendContent(channelID, part, 1)
+	}
+	info.sendContent(channelID, parts[len(parts)-1], 2)
+
+	return nil
+}
+
+// splitMessage breaks a message up into pieces that fit within discord's 2000 character limit, preserving code blocks
+func splitMessage(message string) (parts []string) {
 	for len(message) > 1999 { // discord has a 2000 character limit
 		if message[0:3] == "```" && message[len(message)-3:] == "```" {
 			index := strings.LastIndex(message[:1995], "\n")
 			if index < 10 { // Ensure we process at least 10 characters to prevent an infinite loop
 				index = 1995
 			}
-			info.sendContent(channelID, message[:index]+"```", 1)
+			parts = append(parts, message[:index]+"```")
 			message = "```\n" + message[index:]
 		} else {
 			index := strings.LastIndex(message[:1999], "\n")
 			if index < 10 {
 				index = 1999
 			}
-			info.sendContent(channelID, message[:index], 1)
+			parts = append(parts, message[:index])
 			message = message[index:]
 		}
 	}
-	info.sendContent(channelID, message, 2)
-
-	return nil
+	return append(parts, message)
 }
 
 // ProcessModule returns true if a module should process events on this channel
@@ -262,7 +314,7 @@ func (info *GuildInfo) userBulkUpdate(members []*discordgo.Member) {
 	}
 
 	stmt := fmt.Sprintf("INSERT IGNORE INTO users (ID, Username, Discriminator, Avatar, LastSeen, LastNameChange) VALUES %s", strings.Join(valueStrings, ","))
-	_, err := info.Bot.DB.db.Exec(stmt, valueArgs...)
+	_, err := info.Bot.DB.Exec(stmt, valueArgs...)
 	info.LogError("Error in UserBulkUpdate", err)
 }
 
@@ -275,7 +327,7 @@ func (info *GuildInfo) memberBulkUpdate(members []*discordgo.Member) {
 		valueArgs = append(valueArgs, SBatoi(m.User.ID), SBatoi(info.ID), GetJoinedAt(m), m.Nick)
 	}
 	stmt := fmt.Sprintf("INSERT IGNORE INTO members (ID, Guild, FirstSeen, Nickname) VALUES %s", strings.Join(valueStrings, ","))
-	_, err := info.Bot.DB.db.Exec(stmt, valueArgs...)
+	_, err := info.Bot.DB.Exec(stmt, valueArgs...)
 	info.LogError("Error in MemberBulkUpdate", err)
 }
 
@@ -321,20 +373,39 @@ func (info *GuildInfo) FindChannelID(name string) string {
 	return ""
 }
 
-// Log the given arguments to the server and the command line
+// Logger returns a logger that tags entries with this server and sends them to its debuglog table and log channel
+func (info *GuildInfo) Logger() *Logger {
+	if info == nil {
+		return defaultLogger
+	}
+	var l *Logger
+	if info.Bot != nil {
+		l = info.Bot.Log
+	}
+	return l.Guild(info)
+}
+
+// Log the given arguments to the server and the command line at the info level
 func (info *GuildInfo) Log(args ...interface{}) {
-	s := fmt.Sprint(args...)
-	fmt.Printf("[%s] %s\n", time.Now().Format(time.Stamp), s)
-	if info != nil && info.Config.Log.Channel != ChannelEmpty {
-		info.SendMessage(info.Config.Log.Channel, "```\n"+s+"```")
+	info.Logger().Info(args...)
+}
+
+// EventChannel returns the channel that logged events like message edits or voice activity are posted to, which is
+// log.eventchannel if it's set and log.channel otherwise. Returns ChannelEmpty if events shouldn't be posted anywhere.
+func (info *GuildInfo) EventChannel() DiscordChannel {
+	ch := info.Config.Log.EventChannel
+	if ch == ChannelEmpty {
+		ch = info.Config.Log.Channel
 	}
+	if ch == ChannelExclusion {
+		return ChannelEmpty
+	}
+	return ch
 }
 
 // LogError logs an error only if it exists
 func (info *GuildInfo) LogError(msg string, err error) {
-	if err != nil {
-		info.Log(msg, err.Error())
-	}
+	info.Logger().LogError(msg, err)
 }
 
 // SendError prints an error message with a saturation limit
@@ -459,26 +530,29 @@ func (info *GuildInfo) GetChannels(command CommandID) string {
 	return strings.Join(s, ", ")
 }
 
-// FormatUsage constructs a help string for the given command based on it's usage
-func (info *GuildInfo) FormatUsage(c Command, usage *CommandUsage) *discordgo.MessageEmbed {
+// FormatUsage constructs a help string for the given command based on it's usage, using the prefix of the channel it
+// will be shown in
+func (info *GuildInfo) FormatUsage(c Command, usage *CommandUsage, channel DiscordChannel) *discordgo.MessageEmbed {
 	name := CommandID(strings.ToLower(c.Info().Name))
 	r := info.GetRoles(name)
 	ch := info.GetChannels(name)
 	fields := make([]*discordgo.MessageEmbedField, 0, len(usage.Params))
-	use := "> " + info.Config.Basic.CommandPrefix + string(name)
+	use := "> " + info.usageLine(name, usage, channel)
 	for _, v := range usage.Params {
 		opt := ""
 		if v.Optional {
 			opt = " [OPTIONAL]"
-			use += fmt.Sprintf(" [%s]", v.Name)
-		} else {
-			use += fmt.Sprintf(" {%s}", v.Name)
 		}
 		if v.Variadic {
 			opt = " (...) " + opt
-			use += "..."
 		}
-		fields = append(fields, &discordgo.MessageEmbedField{Name: v.Name + opt, Value: v.Desc, Inline: false})
+		desc := v.Desc
+		if v.Type == ParamEnum && len(v.Values) > 0 {
+			desc += " Must be one of: `" + strings.Join(v.Values, "`, `") + "`"
+		} else if v.Type == ParamInt && (v.Min != 0 || v.Max != 0) {
+			desc += fmt.Sprintf(" Must be between %v and %v.", v.Min, v.Max)
+		}
+		fields = append(fields, &discordgo.MessageEmbedField{Name: v.Name + opt, Value: desc, Inline: false})
 	}
 
 	if len(ch) > 0 {
@@ -539,7 +613,7 @@ func (info *GuildInfo) setupSilenceRole() {
 	if info.Config.Basic.SilenceRole != RoleEmpty {
 		guild, err := info.GetGuild()
 		if err != nil {
-			info.Log("Failed to setup silence roles!")
+			info.Logger().Warning("Failed to setup silence roles!")
 			return
 		}
 		for _, ch := range guild.Channels {
@@ -635,6 +709,9 @@ func (info *GuildInfo) GetUserName(user DiscordUser) string {
 
 // GetGuild returns the guild object associated with this info object
 func (info *GuildInfo) GetGuild() (*discordgo.Guild, error) {
+	if info.Bot == nil || info.Bot.DG == nil { // Modules are loaded for the empty guild before the session exists
+		return nil, errNotConnected
+	}
 	return info.Bot.DG.State.Guild(info.ID)
 }
 
@@ -762,6 +839,16 @@ func (info *GuildInfo) Clean() {
 			delete(info.Config.Modules.CommandLimits, k)
 		}
 	}
+	for k := range info.Config.Modules.UserCooldowns {
+		if _, ok := info.commands[k]; !ok {
+			delete(info.Config.Modules.UserCooldowns, k)
+		}
+	}
+	for k := range info.Config.Modules.RoleCooldowns {
+		if _, ok := info.commands[k]; !ok {
+			delete(info.Config.Modules.RoleCooldowns, k)
+		}
+	}
 	for k := range info.Config.Modules.CommandDisabled {
 		if _, ok := info.commands[k]; !ok {
 			delete(info.Config.Modules.CommandDisabled, k)
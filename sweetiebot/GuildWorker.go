@@ -0,0 +1,222 @@
+package sweetiebot
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	guildQueueSize    = 256                            // How many events can wait on a single guild before senders start blocking
+	guildQueueTimeout = time.Duration(5 * time.Second) // How long a sender waits for room in a full queue before the event is dropped
+)
+
+var errQueueFull = errors.New("queue full")
+var errWorkerStopped = errors.New("worker stopped")
+
+// guildWorker runs the events of a single guild on its own goroutine in the order they were queued, so a slow module
+// on one server can't hold up any of the others.
+type guildWorker struct {
+	events    chan func()
+	done      chan struct{}
+	stopped   chan struct{} // Closed once the worker has finished its last event
+	stop      sync.Once
+	running   uint32 // 1 once the worker has been started
+	tick      uint32 // 1 while a tick is waiting in the queue, so a guild that can't keep up doesn't pile them up
+	peak      uint32 // Deepest the queue has ever been
+	processed uint32
+	dropped   uint32
+	started   uint32 // Unix time the running event started at, or 0 if the worker is idle
+}
+
+// QueueStats is a snapshot of the event queue of a guild
+type QueueStats struct {
+	Guild     *GuildInfo
+	Depth     int
+	Peak      int
+	Processed uint32
+	Dropped   uint32
+	Busy      time.Duration // How long the event that's currently running has been running for
+}
+
+func newGuildWorker() *guildWorker {
+	return &guildWorker{
+		events:  make(chan func(), guildQueueSize),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+}
+
+func (w *guildWorker) run() {
+	defer close(w.stopped)
+	for {
+		select {
+		case <-w.done: // Checked on its own first, so nothing else starts once the worker has been stopped
+			return
+		default:
+		}
+		select {
+		case f := <-w.events:
+			atomic.StoreUint32(&w.started, uint32(time.Now().UTC().Unix()))
+			f()
+			atomic.StoreUint32(&w.started, 0)
+			atomic.AddUint32(&w.processed, 1)
+		case <-w.done:
+			return
+		}
+	}
+}
+
+// push adds f to the end of the queue. If the queue is full, it blocks until there's room or the worker is stopped.
+// Unless wait is true, it also gives up after guildQueueTimeout, in which case f is dropped.
+func (w *guildWorker) push(f func(), wait bool) error {
+	select {
+	case <-w.done: // Checked on its own first, because select picks randomly when there's also room in the queue
+		return errWorkerStopped
+	default:
+	}
+	select {
+	case w.events <- f:
+	default:
+		var timeout <-chan time.Time // Stays nil when waiting, so it never fires
+		if !wait {
+			timer := time.NewTimer(guildQueueTimeout)
+			defer timer.Stop()
+			timeout = timer.C
+		}
+		select {
+		case w.events <- f:
+		case <-w.done:
+			return errWorkerStopped
+		case <-timeout:
+			atomic.AddUint32(&w.dropped, 1)
+			return errQueueFull
+		}
+	}
+	w.updatePeak()
+	return nil
+}
+
+func (w *guildWorker) updatePeak() {
+	depth := uint32(len(w.events))
+	for peak := atomic.LoadUint32(&w.peak); depth > peak; peak = atomic.LoadUint32(&w.peak) {
+		if atomic.CompareAndSwapUint32(&w.peak, peak, depth) {
+			break
+		}
+	}
+}
+
+func (info *GuildInfo) queue(f func(), wait bool) bool {
+	if info.worker == nil {
+		f()
+		return true
+	}
+	err := info.worker.push(f, wait)
+	if err == errQueueFull {
+		info.Logger().Warning("Dropped an event because the queue stayed full for ", guildQueueTimeout)
+	}
+	return err == nil
+}
+
+// Queue runs f on the worker of this guild after every event that was queued before it. If the queue stays full for
+// too long, f is dropped and Queue returns false. Guilds that don't have a worker run f immediately.
+func (info *GuildInfo) Queue(f func()) bool {
+	return info.queue(f, false)
+}
+
+// QueueCritical works like Queue, but waits for as long as it takes for room in the queue instead of dropping f. This
+// is for events the spam and raid filters can't afford to miss, like new messages and new members. Never call it from
+// the worker itself, because it would wait on its own queue forever.
+func (info *GuildInfo) QueueCritical(f func()) bool {
+	return info.queue(f, true)
+}
+
+// queueTick queues a tick unless the last one is still waiting. Ticks never block, because the next one will come
+// around soon enough anyway.
+func (info *GuildInfo) queueTick(f func()) {
+	w := info.worker
+	if w == nil {
+		f()
+		return
+	}
+	if !atomic.CompareAndSwapUint32(&w.tick, 0, 1) {
+		return
+	}
+	select {
+	case w.events <- func() {
+		atomic.StoreUint32(&w.tick, 0)
+		f()
+	}:
+		w.updatePeak()
+	default:
+		atomic.StoreUint32(&w.tick, 0)
+		atomic.AddUint32(&w.dropped, 1)
+		info.Logger().Warning("Dropped a tick because the queue is full")
+	}
+}
+
+// startWorker starts processing queued events. Anything queued before this waits until the guild has finished loading.
+func (info *GuildInfo) startWorker() {
+	if info.worker != nil {
+		atomic.StoreUint32(&info.worker.running, 1)
+		go info.worker.run()
+	}
+}
+
+// stopWorker stops the worker once its current event finishes. Events still in the queue are thrown away.
+func (info *GuildInfo) stopWorker() {
+	if w := info.worker; w != nil {
+		w.stop.Do(func() { close(w.done) })
+	}
+}
+
+// waitForWorker waits until a stopped worker has finished the event it was running, and returns false if that takes
+// longer than the timeout
+func (info *GuildInfo) waitForWorker(timeout time.Duration) bool {
+	w := info.worker
+	if w == nil || atomic.LoadUint32(&w.running) == 0 {
+		return true
+	}
+	select {
+	case <-w.stopped:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// QueueStats returns a snapshot of the event queue of this guild
+func (info *GuildInfo) QueueStats() QueueStats {
+	stats := QueueStats{Guild: info}
+	if w := info.worker; w != nil {
+		stats.Depth = len(w.events)
+		stats.Peak = int(atomic.LoadUint32(&w.peak))
+		stats.Processed = atomic.LoadUint32(&w.processed)
+		stats.Dropped = atomic.LoadUint32(&w.dropped)
+		if started := atomic.LoadUint32(&w.started); started != 0 {
+			stats.Busy = time.Since(time.Unix(int64(started), 0))
+		}
+	}
+	return stats
+}
+
+// QueueStats returns the event queues of every guild on every shard of this account, longest queue first
+func (sb *SweetieBot) QueueStats() []QueueStats {
+	stats := []QueueStats{}
+	for _, shard := range sb.Shards {
+		shard.GuildsLock.RLock()
+		for _, info := range shard.Guilds {
+			stats = append(stats, info.QueueStats())
+		}
+		shard.GuildsLock.RUnlock()
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Depth != stats[j].Depth {
+			return stats[i].Depth > stats[j].Depth
+		}
+		return stats[i].Busy > stats[j].Busy
+	})
+	return stats
+}
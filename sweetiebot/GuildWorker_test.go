@@ -0,0 +1,86 @@
+package sweetiebot
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestGuildWorker(t *testing.T) {
+	info := &GuildInfo{ID: "1", worker: newGuildWorker()}
+	order := []int{}
+	for i := 0; i < 10; i++ {
+		i := i
+		Check(info.Queue(func() { order = append(order, i) }), true, t)
+	}
+	ticks := 0
+	info.queueTick(func() { ticks++ })
+	info.queueTick(func() { ticks++ }) // Still waiting behind the first one, so this should be skipped
+
+	stats := info.QueueStats()
+	Check(stats.Depth, 11, t)
+	Check(stats.Peak, 11, t)
+	Check(stats.Processed, uint32(0), t)
+
+	done := make(chan bool)
+	info.Queue(func() { done <- true })
+	info.startWorker()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Worker never processed the queue")
+	}
+	Check(fmt.Sprint(order), "[0 1 2 3 4 5 6 7 8 9]", t)
+	Check(ticks, 1, t)
+	Check(info.QueueStats().Depth, 0, t)
+
+	info.stopWorker()
+	info.stopWorker()
+	Check(info.Queue(func() { order = append(order, 10) }), false, t)
+	Check(len(order), 10, t)
+}
+
+func TestGuildWorkerFull(t *testing.T) {
+	info := &GuildInfo{ID: "1", worker: newGuildWorker()}
+	for i := 0; i < guildQueueSize; i++ {
+		info.Queue(func() {})
+	}
+	info.queueTick(func() {})
+	Check(info.QueueStats().Dropped, uint32(1), t)
+
+	go info.stopWorker()
+	Check(info.Queue(func() {}), false, t) // Blocks until the worker is stopped
+}
+
+func TestGuildWorkerCritical(t *testing.T) {
+	info := &GuildInfo{ID: "1", worker: newGuildWorker()}
+	for i := 0; i < guildQueueSize; i++ {
+		info.Queue(func() {})
+	}
+	ran := make(chan bool, 1)
+	queued := make(chan bool)
+	go func() { queued <- info.QueueCritical(func() { ran <- true }) }()
+	select {
+	case <-queued:
+		t.Fatal("Critical event didn't wait for room in the queue")
+	case <-time.After(guildQueueTimeout + time.Second): // Long enough that a normal event would've been dropped
+	}
+
+	info.startWorker()
+	defer info.stopWorker()
+	Check(<-queued, true, t)
+	select {
+	case <-ran:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Worker never processed the critical event")
+	}
+	Check(info.QueueStats().Dropped, uint32(0), t)
+}
+
+func TestGuildWorkerInline(t *testing.T) {
+	info := &GuildInfo{ID: "1"}
+	ran := false
+	Check(info.Queue(func() { ran = true }), true, t)
+	Check(ran, true, t)
+	Check(info.QueueStats().Depth, 0, t)
+}
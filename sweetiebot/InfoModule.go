@@ -41,7 +41,7 @@ type helpCommand struct {
 func (c *helpCommand) Info() *CommandInfo {
 	return &CommandInfo{
 		Name:  "Help",
-		Usage: "[PM Only] Generates the list you are looking at right now.",
+		Usage: "Generates the list you are looking at right now.",
 	}
 }
 
@@ -93,8 +93,9 @@ func DumpCommandsModules(info *GuildInfo, footer string, description string, msg
 }
 
 func (c *helpCommand) Process(args []string, msg *discordgo.Message, indices []int, info *GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+	channel := DiscordChannel(msg.ChannelID)
 	if len(args) == 0 {
-		return "", true, DumpCommandsModules(info, "For more information on a specific command, type !help [command].", "", msg)
+		return "", false, info.Paginate(msg, PageFields(DumpCommandsModules(info, "For more information on a specific command, type "+info.Prefix(channel)+"help [command].", "", msg), 12))
 	}
 	arg := strings.ToLower(args[0])
 	for _, v := range info.Modules {
@@ -122,10 +123,10 @@ func (c *helpCommand) Process(args []string, msg *discordgo.Message, indices []i
 				Description: v.Description(),
 				Fields:      fields,
 				Footer: &discordgo.MessageEmbedFooter{
-					Text: "For more information on a specific command, type !help [command].",
+					Text: "For more information on a specific command, type " + info.Prefix(channel) + "help [command].",
 				},
 			}
-			return "", true, embed
+			return "", false, info.Paginate(msg, PageFields(embed, 8))
 		}
 	}
 	v, ok := info.commands[CommandID(arg)]
@@ -147,13 +148,13 @@ func (c *helpCommand) Process(args []string, msg *discordgo.Message, indices []i
 				return "", true, embed
 			}
 		}
-		return "```\n" + info.GetBotName() + " doesn't recognize that command, module or config option. You can check what commands " + info.GetBotName() + " knows by typing !help with no arguments.```", false, nil
+		return "```\n" + info.GetBotName() + " doesn't recognize that command, module or config option. You can check what commands " + info.GetBotName() + " knows by typing " + info.Prefix(channel) + "help with no arguments.```", false, nil
 	}
-	return "", true, info.FormatUsage(v, v.Usage(info))
+	return "", true, info.FormatUsage(v, v.Usage(info), channel)
 }
 func (c *helpCommand) Usage(info *GuildInfo) *CommandUsage {
 	return &CommandUsage{
-		Desc: "Lists all available commands " + info.GetBotName() + " knows, or gives information about the given command. Of course, you should have figured this out by now, since you just typed !help help for some reason.",
+		Desc: "Lists all available commands " + info.GetBotName() + " knows, or gives information about the given command. Of course, you should have figured this out by now, since you just typed " + info.Prefix(ChannelEmpty) + "help help for some reason.",
 		Params: []CommandUsageParam{
 			{Name: "command/module", Desc: "The command or module to display help for. You do not need to include a command's parent module, just the command name itself.", Optional: true},
 		},
@@ -187,7 +188,7 @@ func (c *aboutCommand) Process(args []string, msg *discordgo.Message, indices []
 			{Name: "Author", Value: "Blackhole#0173", Inline: true},
 			{Name: "Library", Value: "discordgo", Inline: true},
 			{Name: "Owner ID", Value: info.Bot.Owner.String(), Inline: true},
-			{Name: "Presence", Value: Pluralize(int64(len(info.Bot.Guilds)), " server"), Inline: true},
+			{Name: "Presence", Value: Pluralize(int64(info.Bot.GuildCount()), " server"), Inline: true},
 			{Name: "Uptime", Value: TimeDiff(time.Duration(GetTimestamp(msg).Unix()-info.Bot.StartTime) * time.Second), Inline: true},
 			{Name: "Messages Seen", Value: strconv.FormatUint(uint64(atomic.LoadUint32(&info.Bot.MessageCount)), 10), Inline: true},
 			{Name: "Github", Value: "https://github.com/blackhole12/sweetiebot", Inline: false},
@@ -218,10 +219,13 @@ func (c *rulesCommand) Name() string {
 	return "Rules"
 }
 func (c *rulesCommand) Process(args []string, msg *discordgo.Message, indices []int, info *GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+	return info.ProcessTyped(c, args, msg, indices)
+}
+func (c *rulesCommand) ProcessArgs(args CommandArgs, msg *discordgo.Message, info *GuildInfo) (string, bool, *discordgo.MessageEmbed) {
 	if len(info.Config.Information.Rules) == 0 {
 		return "```\nI don't know what the rules are in this server... ¯\\_(ツ)_/¯```", false, nil
 	}
-	if len(args) < 1 {
+	if !args.Has("index") {
 		rules := make([]string, 0, len(info.Config.Information.Rules)+1)
 		rules = append(rules, "Official rules of "+info.Name+":")
 		keys := MapIntToSlice(info.Config.Information.Rules)
@@ -235,10 +239,7 @@ func (c *rulesCommand) Process(args []string, msg *discordgo.Message, indices []
 		return strings.Join(rules, "\n"), len(rules) > maxPublicRules, nil
 	}
 
-	arg, err := strconv.Atoi(args[0])
-	if err != nil {
-		return "```\nRule index must be a number!```", false, nil
-	}
+	arg := int(args.Int("index", 0))
 	rule, ok := info.Config.Information.Rules[arg]
 	if !ok {
 		return "```\nThat's not a rule! Stop making things up!```", false, nil
@@ -247,9 +248,9 @@ func (c *rulesCommand) Process(args []string, msg *discordgo.Message, indices []
 }
 func (c *rulesCommand) Usage(info *GuildInfo) *CommandUsage {
 	return &CommandUsage{
-		Desc: "Lists all the rules in this server, or displays the specific rule requested, if it exists. Rules can be set using `" + info.Config.Basic.CommandPrefix + "setconfig rules 1 this is a rule`",
+		Desc: "Lists all the rules in this server, or displays the specific rule requested, if it exists. Rules can be set using `" + info.Prefix(ChannelEmpty) + "setconfig rules 1 this is a rule`",
 		Params: []CommandUsageParam{
-			{Name: "index", Desc: "Index of the rule to display. If omitted, displays all rules.", Optional: true},
+			{Name: "index", Desc: "Index of the rule to display. If omitted, displays all rules.", Optional: true, Type: ParamInt},
 		},
 	}
 }
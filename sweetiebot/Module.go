@@ -11,7 +11,6 @@ type Module interface {
 	Name() string
 	Commands() []Command
 	Description() string
-	//Config() interface{}
 }
 
 // Giving each possible hook function its own interface ensures each module
@@ -108,12 +107,31 @@ type ModuleOnTick interface {
 	OnTick(*GuildInfo, time.Time)
 }
 
-// CommandUsageParam describes a single parameter to a command
+// ModuleCommandMiddleware hook interface, for modules that want a say in how commands run. The middleware of every
+// module that is enabled in the channel runs after all the built-in checks, right before the command itself.
+type ModuleCommandMiddleware interface {
+	Module
+	CommandMiddleware(ctx *CommandContext, next func())
+}
+
+// ModuleSubscriber hook interface for modules that react to events published by other modules. Subscribe is called
+// once, when the module is registered.
+type ModuleSubscriber interface {
+	Module
+	Subscribe(*GuildInfo)
+}
+
+// CommandUsageParam describes a single parameter to a command. Type, Min, Max and Values are only used by commands
+// that implement TypedCommand.
 type CommandUsageParam struct {
 	Name     string
 	Desc     string
 	Optional bool
 	Variadic bool
+	Type     ParamType
+	Min      int64    // Smallest value a ParamInt accepts. If Min and Max are both 0, any integer is accepted.
+	Max      int64    // Largest value a ParamInt accepts
+	Values   []string // Every value a ParamEnum accepts
 }
 
 // CommandUsage defines the help parameters for a command
@@ -152,9 +170,11 @@ type moduleHooks struct {
 	OnGuildBanAdd       []ModuleOnGuildBanAdd
 	OnGuildBanRemove    []ModuleOnGuildBanRemove
 	OnGuildRoleDelete   []ModuleOnGuildRoleDelete
+	OnVoiceStateUpdate  []ModuleOnVoiceStateUpdate
 	OnCommand           []ModuleOnCommand
 	OnIdle              []ModuleOnIdle
 	OnTick              []ModuleOnTick
+	CommandMiddleware   []ModuleCommandMiddleware
 }
 
 // RegisterModule registers a module with this guild
@@ -192,6 +212,9 @@ func (info *GuildInfo) RegisterModule(m Module) {
 	if h, ok := m.(ModuleOnGuildRoleDelete); ok {
 		info.hooks.OnGuildRoleDelete = append(info.hooks.OnGuildRoleDelete, h)
 	}
+	if h, ok := m.(ModuleOnVoiceStateUpdate); ok {
+		info.hooks.OnVoiceStateUpdate = append(info.hooks.OnVoiceStateUpdate, h)
+	}
 	if h, ok := m.(ModuleOnCommand); ok {
 		info.hooks.OnCommand = append(info.hooks.OnCommand, h)
 	}
@@ -201,4 +224,10 @@ func (info *GuildInfo) RegisterModule(m Module) {
 	if h, ok := m.(ModuleOnTick); ok {
 		info.hooks.OnTick = append(info.hooks.OnTick, h)
 	}
+	if h, ok := m.(ModuleCommandMiddleware); ok {
+		info.hooks.CommandMiddleware = append(info.hooks.CommandMiddleware, h)
+	}
+	if h, ok := m.(ModuleSubscriber); ok {
+		h.Subscribe(info)
+	}
 }
@@ -0,0 +1,152 @@
+package sweetiebot
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/blackhole12/discordgo"
+)
+
+// CaseAction is the kind of moderation action a case records. These values are stored in the database, so new actions
+// must always be added to the end.
+type CaseAction uint8
+
+// Moderation actions that can be recorded as a case
+const (
+	CaseNote CaseAction = iota
+	CaseBan
+	CaseUnban
+	CaseSilence
+	CaseUnsilence
+	CaseWipe
+	CaseWarn
+)
+
+var caseActionNames = []string{"Note", "Ban", "Unban", "Silence", "Unsilence", "Wipe", "Warn"}
+
+func (a CaseAction) String() string {
+	if int(a) < len(caseActionNames) {
+		return caseActionNames[a]
+	}
+	return fmt.Sprintf("Unknown (%v)", uint8(a))
+}
+
+// MaxCaseReason is the longest reason the cases table can store
+const MaxCaseReason = 1000
+
+// AddCase records a moderation action against target, which is a user for most actions but a channel for wipes, and
+// returns the case number, or 0 if it couldn't be recorded. Any messages passed in are linked to the case.
+func (info *GuildInfo) AddCase(action CaseAction, target string, moderator DiscordUser, reason string, duration string, msgs ...*discordgo.Message) uint64 {
+	if !info.Bot.DB.Status.Get() {
+		return 0
+	}
+	if r := []rune(reason); len(r) > MaxCaseReason {
+		reason = string(r[:MaxCaseReason-3]) + "..."
+	}
+	c := &ModCase{
+		Action:    action,
+		Target:    SBatoi(target),
+		Moderator: moderator.Convert(),
+		Reason:    reason,
+		Duration:  duration,
+	}
+	for _, m := range msgs {
+		if m != nil && len(m.ChannelID) > 0 && len(m.ID) > 0 {
+			c.Messages = append(c.Messages, m.ChannelID+"/"+m.ID)
+		}
+	}
+	id, err := info.Bot.DB.AddCase(SBatoi(info.ID), c)
+	if err != nil {
+		info.LogError("Failed to record case: ", err)
+		return 0
+	}
+	return id
+}
+
+// CaseSuffix returns " (case #N)" so responses can mention the case they created, or nothing if there isn't one
+func CaseSuffix(id uint64) string {
+	if id == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (case #%v)", id)
+}
+
+// ShowCaseTarget displays whoever or whatever the case was against
+func (info *GuildInfo) ShowCaseTarget(c *ModCase) string {
+	if c.Action == CaseWipe {
+		return DiscordChannel(SBitoa(c.Target)).Show(info)
+	}
+	return info.GetUserName(DiscordUser(SBitoa(c.Target)))
+}
+
+// CaseLine formats a case as a single line for case listings
+func (info *GuildInfo) CaseLine(c *ModCase, tz *time.Location) string {
+	s := fmt.Sprintf("#%v [%s] %s %s by %s", c.ID, c.Timestamp.In(tz).Format("Jan 2 2006 3:04pm"), c.Action, info.ShowCaseTarget(c), info.GetUserName(DiscordUser(SBitoa(c.Moderator))))
+	if len(c.Duration) > 0 {
+		s += " for " + c.Duration
+	}
+	if c.Action == CaseWarn {
+		s += " (" + Pluralize(int64(c.Points), " point") + ")"
+	}
+	if len(c.Reason) > 0 {
+		s += ": " + c.Reason
+	}
+	return s
+}
+
+// CaseDetails formats everything recorded about a case, along with links to any messages attached to it
+func (info *GuildInfo) CaseDetails(c *ModCase, tz *time.Location) string {
+	reason := c.Reason
+	if len(reason) == 0 {
+		reason = "None given. Use " + info.Prefix(ChannelEmpty) + "reason " + SBitoa(c.ID) + " to add one."
+	}
+	duration := c.Duration
+	if len(duration) == 0 {
+		duration = "N/A"
+	}
+	s := fmt.Sprintf("      Case: #%v\n    Action: %s\n    Target: %s (%v)\n Moderator: %s\n      Date: %s\n  Duration: %s\n    Reason: %s",
+		c.ID,
+		c.Action,
+		info.ShowCaseTarget(c),
+		c.Target,
+		info.GetUserName(DiscordUser(SBitoa(c.Moderator))),
+		c.Timestamp.In(tz).Format(time.RFC822),
+		duration,
+		reason)
+	links := make([]string, 0, len(c.Messages))
+	for _, m := range c.Messages {
+		links = append(links, "https://discordapp.com/channels/"+info.ID+"/"+m)
+	}
+	if c.Action == CaseWarn {
+		s += fmt.Sprintf("\n    Points: %v", c.Points)
+	}
+	s = "```http\n" + info.Sanitize(s, CleanCodeBlock) + "```"
+	if len(links) > 0 {
+		s += "\n" + strings.Join(links, "\n")
+	}
+	return s
+}
+
+// WarningPoints adds up how many warning points someone has at the given time. One point decays every decay seconds,
+// starting from when it was given, and points never go below zero. If decay is 0, points never decay at all.
+func WarningPoints(warnings []Warning, decay int64, now time.Time) int {
+	var points float64
+	var last time.Time
+	elapse := func(t time.Time) {
+		if !t.After(last) { // A warning is stored a moment after the message that gave it, so now can be slightly behind
+			return
+		}
+		if decay > 0 && !last.IsZero() {
+			points = math.Max(0, points-t.Sub(last).Seconds()/float64(decay))
+		}
+		last = t
+	}
+	for _, w := range warnings {
+		elapse(w.Timestamp)
+		points += float64(w.Points)
+	}
+	elapse(now)
+	return int(math.Ceil(points))
+}
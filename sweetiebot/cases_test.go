@@ -0,0 +1,25 @@
+package sweetiebot
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWarningPoints(t *testing.T) {
+	start := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	day := int64(86400)
+	warnings := []Warning{{2, start}, {1, start.Add(24 * time.Hour)}}
+	Check(WarningPoints(nil, day, start), 0, t)
+	Check(WarningPoints(warnings[:1], day, start), 2, t)
+	Check(WarningPoints(warnings, day, start.Add(24*time.Hour)), 2, t)
+	Check(WarningPoints(warnings, day, start.Add(36*time.Hour)), 2, t)
+	Check(WarningPoints(warnings, day, start.Add(72*time.Hour)), 0, t)
+	Check(WarningPoints(warnings, 0, start.AddDate(1, 0, 0)), 3, t)
+
+	// Points can't go below zero, so old warnings don't cancel out new ones
+	old := []Warning{{1, start}, {2, start.AddDate(0, 0, 30)}}
+	Check(WarningPoints(old, day, start.AddDate(0, 0, 30)), 2, t)
+
+	// Time going backwards doesn't add points
+	Check(WarningPoints(warnings[:1], day, start.Add(-time.Second)), 2, t)
+}
@@ -0,0 +1,136 @@
+package sweetiebot
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// MaxConfigHistory is how many versions of each guild's config are kept around to be rolled back to
+const MaxConfigHistory = 200
+
+// maxConfigChanges is how many changed keys are described in a single version before the rest are summarized
+const maxConfigChanges = 20
+
+var errNotConfig = errors.New("that isn't a config file")
+var errConfigTooNew = errors.New("that config is from a newer version of the bot and can't be loaded")
+
+// flattenConfig splits a config into every key it has, like "basic.commandprefix", mapped to its JSON value
+func flattenConfig(config []byte) map[string]json.RawMessage {
+	top := make(map[string]json.RawMessage)
+	if json.Unmarshal(config, &top) != nil {
+		return top
+	}
+	r := make(map[string]json.RawMessage, len(top))
+	for k, v := range top {
+		group := make(map[string]json.RawMessage)
+		if bytes.HasPrefix(bytes.TrimSpace(v), []byte("{")) && json.Unmarshal(v, &group) == nil {
+			for option, value := range group {
+				r[k+"."+option] = value
+			}
+		} else {
+			r[k] = v
+		}
+	}
+	return r
+}
+
+func showConfigValue(v json.RawMessage) string {
+	if v == nil {
+		return "(none)"
+	}
+	var buf bytes.Buffer
+	if json.Compact(&buf, v) != nil {
+		return string(v)
+	}
+	if r := []rune(buf.String()); len(r) > 100 {
+		return string(r[:97]) + "..."
+	}
+	return buf.String()
+}
+
+// diffConfig describes every key that differs between two configs, sorted by key
+func diffConfig(old []byte, config []byte) []string {
+	before := flattenConfig(old)
+	after := flattenConfig(config)
+	keys := make([]string, 0, len(after))
+	for k, v := range after {
+		if !bytes.Equal(v, before[k]) {
+			keys = append(keys, k)
+		}
+	}
+	for k := range before {
+		if _, ok := after[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	changes := make([]string, 0, len(keys))
+	for _, k := range keys {
+		changes = append(changes, k+": "+showConfigValue(before[k])+" -> "+showConfigValue(after[k]))
+	}
+	return changes
+}
+
+// recordConfig adds a config that was just saved to the config history, as long as something actually changed. If this
+// is the first change we've seen, the config it replaced is recorded first, so the change can still be rolled back.
+func (info *GuildInfo) recordConfig(old []byte, config []byte, user DiscordUser) {
+	if len(old) == 0 || !info.Bot.DB.Status.Get() {
+		return
+	}
+	changes := diffConfig(old, config)
+	if len(changes) == 0 {
+		return
+	}
+	if len(changes) > maxConfigChanges {
+		changes = append(changes[:maxConfigChanges], fmt.Sprintf("...and %v more", len(changes)-maxConfigChanges))
+	}
+
+	guild := SBatoi(info.ID)
+	if len(info.Bot.DB.GetConfigHistory(guild, 1, 0)) == 0 {
+		if _, err := info.Bot.DB.AddConfigVersion(guild, 0, "Started keeping config history", old, MaxConfigHistory); err != nil {
+			info.LogError("Failed to record config history: ", err)
+			return
+		}
+	}
+	if _, err := info.Bot.DB.AddConfigVersion(guild, user.Convert(), strings.Join(changes, "\n"), config, MaxConfigHistory); err != nil {
+		info.LogError("Failed to record config history: ", err)
+	}
+}
+
+// ReplaceConfig loads an entire config, either from an export or from the config history, and saves it on behalf of
+// the given user. The config goes through the same migration as one loaded from disk, so older configs are brought up
+// to date, but the current config is only replaced if the new one can be read and isn't too large.
+func (info *GuildInfo) ReplaceConfig(config []byte, user DiscordUser) error {
+	if len(config) > info.Bot.MaxConfigSize {
+		return errConfigFileTooLarge
+	}
+	var version struct {
+		Version int `json:"version"`
+	}
+	if err := json.Unmarshal(config, &version); err != nil {
+		return err
+	}
+	if version.Version == 0 {
+		return errNotConfig
+	}
+	if version.Version > ConfigVersion {
+		return errConfigTooNew
+	}
+
+	// Migrate the config on a stand-in so a broken config can't leave the real one half overwritten
+	staging := &GuildInfo{ID: info.ID, Name: info.Name, OwnerID: info.OwnerID, Bot: info.Bot, Config: *DefaultConfig()}
+	if _, err := staging.migrateConfig(config); err != nil {
+		return err
+	}
+	staging.Config.FillConfig()
+
+	info.ConfigLock.Lock()
+	info.Config = staging.Config
+	info.ConfigLock.Unlock()
+	return info.SaveConfigBy(user)
+}
@@ -0,0 +1,196 @@
+package sweetiebot
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// ConfigSection describes a category of options that belongs to a module instead of being built into BotConfig. It's
+// saved in the config file under the lowercase version of its name, just like the built-in categories, and is edited
+// with the same commands.
+type ConfigSection struct {
+	Name    string                                               // Name of the category, like "Voice"
+	Version int                                                  // Increase this whenever Migrate has something new to do
+	Default func() interface{}                                   // Returns a pointer to a new struct holding the default value of every option
+	Help    map[string]string                                    // Help text for each option, keyed by the lowercase name of its field
+	Migrate func(info *GuildInfo, section interface{}, from int) // Optional. Brings a section saved by an older Version up to date. from is 0 if the config predates the section.
+}
+
+// ModuleConfig is implemented by modules that keep their options in their own config section
+type ModuleConfig interface {
+	Module
+	Config() *ConfigSection
+}
+
+var configSections struct {
+	sync.RWMutex
+	list []*ConfigSection
+}
+
+// RegisterConfigSection adds a section to every config loaded from now on. Registering a section with the same name as
+// an existing one replaces it.
+func RegisterConfigSection(section *ConfigSection) {
+	configSections.Lock()
+	defer configSections.Unlock()
+	for i, s := range configSections.list {
+		if strings.EqualFold(s.Name, section.Name) {
+			configSections.list[i] = section
+			return
+		}
+	}
+	configSections.list = append(configSections.list, section)
+}
+
+// ConfigSections returns every registered config section in the order they were registered
+func ConfigSections() []*ConfigSection {
+	configSections.RLock()
+	defer configSections.RUnlock()
+	return append([]*ConfigSection{}, configSections.list...)
+}
+
+func findConfigSection(name string) *ConfigSection {
+	for _, s := range ConfigSections() {
+		if strings.EqualFold(s.Name, name) {
+			return s
+		}
+	}
+	return nil
+}
+
+// registerModuleConfigs registers the config section of every module that has one
+func registerModuleConfigs(modules []Module) {
+	for _, m := range modules {
+		if c, ok := m.(ModuleConfig); ok {
+			RegisterConfigSection(c.Config())
+		}
+	}
+}
+
+// Section returns the options in the config section with the given name, or nil if there is no such section. The
+// result is the same type of pointer returned by the section's Default function.
+func (config *BotConfig) Section(name string) interface{} {
+	return config.sections[strings.ToLower(name)]
+}
+
+// fillSections gives the config the default options of every registered section it doesn't have yet
+func (config *BotConfig) fillSections() {
+	if config.sections == nil {
+		config.sections = make(map[string]interface{})
+	}
+	for _, s := range ConfigSections() {
+		if _, ok := config.sections[strings.ToLower(s.Name)]; !ok {
+			config.sections[strings.ToLower(s.Name)] = s.Default()
+		}
+	}
+}
+
+// configGroup is a category of options, either one of the structs in BotConfig or a module's config section
+type configGroup struct {
+	Name  string
+	Value reflect.Value // The struct holding the options
+}
+
+// groups returns every category of options, starting with the ones built into BotConfig
+func (config *BotConfig) groups() []configGroup {
+	t := reflect.ValueOf(config).Elem()
+	groups := []configGroup{}
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Kind() == reflect.Struct {
+			groups = append(groups, configGroup{t.Type().Field(i).Name, t.Field(i)})
+		}
+	}
+	for _, s := range ConfigSections() {
+		if section, ok := config.sections[strings.ToLower(s.Name)]; ok {
+			groups = append(groups, configGroup{s.Name, reflect.ValueOf(section).Elem()})
+		}
+	}
+	return groups
+}
+
+// group finds a category of options by name, ignoring case
+func (config *BotConfig) group(name string) (configGroup, bool) {
+	for _, g := range config.groups() {
+		if strings.EqualFold(g.Name, name) {
+			return g, true
+		}
+	}
+	return configGroup{}, false
+}
+
+// botConfigFields has the same fields as BotConfig but none of its methods, so it can be marshaled the normal way
+type botConfigFields BotConfig
+
+// MarshalJSON writes the config sections of modules next to the built-in categories
+func (config BotConfig) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(botConfigFields(config))
+	if err != nil || len(config.sections) == 0 {
+		return data, err
+	}
+	fields := make(map[string]json.RawMessage)
+	if err = json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	for name, section := range config.sections {
+		if fields[name], err = json.Marshal(section); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(fields)
+}
+
+// UnmarshalJSON reads every registered config section along with the built-in categories. Sections the data doesn't
+// have are left alone. A section that was saved before sections had versions counts as version 1.
+func (config *BotConfig) UnmarshalJSON(data []byte) error {
+	config.SectionVersions = nil // Versions always come from the data, so missing sections get migrated
+	if err := json.Unmarshal(data, (*botConfigFields)(config)); err != nil {
+		return err
+	}
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	config.fillSections()
+	for _, s := range ConfigSections() {
+		name := strings.ToLower(s.Name)
+		raw, ok := fields[name]
+		if !ok {
+			continue
+		}
+		if err := json.Unmarshal(raw, config.sections[name]); err != nil {
+			return err
+		}
+		if _, ok := config.SectionVersions[name]; !ok {
+			if config.SectionVersions == nil {
+				config.SectionVersions = make(map[string]int)
+			}
+			config.SectionVersions[name] = 1
+		}
+	}
+	return nil
+}
+
+// migrateSections brings every config section up to the version of the module that registered it, returning true if
+// any of them had to be migrated
+func (guild *GuildInfo) migrateSections() bool {
+	config := &guild.Config
+	config.fillSections()
+	if config.SectionVersions == nil {
+		config.SectionVersions = make(map[string]int)
+	}
+	migrated := false
+	for _, s := range ConfigSections() {
+		name := strings.ToLower(s.Name)
+		from := config.SectionVersions[name]
+		if from >= s.Version {
+			continue
+		}
+		if s.Migrate != nil {
+			s.Migrate(guild, config.sections[name], from)
+		}
+		config.SectionVersions[name] = s.Version
+		migrated = true
+	}
+	return migrated
+}
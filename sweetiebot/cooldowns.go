@@ -0,0 +1,118 @@
+package sweetiebot
+
+import (
+	"fmt"
+	"time"
+)
+
+// cooldownKey identifies a single cooldown. Scope is who the cooldown applies to: "channel:<id>", "user:<id>" or
+// "role:<id>".
+type cooldownKey struct {
+	Scope   string
+	Command CommandID
+}
+
+// cooldownScope is one of the cooldowns that applies to a command someone is trying to run
+type cooldownScope struct {
+	key      cooldownKey
+	duration int64
+	message  string // Told to the user when the cooldown stops them, with %s replaced by the duration
+}
+
+// cooldownScopes returns every cooldown that applies to the command in ctx. The user's highest role is only looked up
+// if the command actually has a role cooldown.
+func (info *GuildInfo) cooldownScopes(ctx *CommandContext) []cooldownScope {
+	scopes := []cooldownScope{}
+	if d := info.Config.Modules.CommandLimits[ctx.Name]; d > 0 {
+		scopes = append(scopes, cooldownScope{cooldownKey{"channel:" + ctx.Channel.String(), ctx.Name}, d, "That command can only be run once every %s in this channel!"})
+	}
+	user := DiscordUser(ctx.Message.Author.ID)
+	if d := info.Config.Modules.UserCooldowns[ctx.Name]; d > 0 {
+		scopes = append(scopes, cooldownScope{cooldownKey{"user:" + user.String(), ctx.Name}, d, "You can only run that command once every %s!"})
+	}
+	if d := info.Config.Modules.RoleCooldowns[ctx.Name]; d > 0 {
+		scopes = append(scopes, cooldownScope{cooldownKey{"role:" + info.highestRole(user).String(), ctx.Name}, d, "Everyone with your role shares a cooldown on that command, which can only be run once every %s!"})
+	}
+	return scopes
+}
+
+// highestRole returns the role of the user with the highest position, or the @everyone role if they don't have any
+func (info *GuildInfo) highestRole(user DiscordUser) DiscordRole {
+	top := DiscordRole(info.ID) // The @everyone role has the same ID as the guild
+	position := -1
+	if m, err := info.Bot.DG.GetMember(user, info.ID); err == nil {
+		for _, r := range m.Roles {
+			if role, err := info.Bot.DG.State.Role(info.ID, r); err == nil && role.Position > position {
+				top = DiscordRole(r)
+				position = role.Position
+			}
+		}
+	}
+	return top
+}
+
+// cooldownRemaining returns how many seconds are left before the cooldown expires, or 0 if it already has
+func (info *GuildInfo) cooldownRemaining(key cooldownKey, now int64) int64 {
+	info.commandLock.RLock()
+	expires := info.cooldowns[key]
+	info.commandLock.RUnlock()
+	if expires > now {
+		return expires - now
+	}
+	return 0
+}
+
+// startCooldown sets when a cooldown expires. It gets saved on the next tick, so restarting the bot doesn't reset it.
+func (info *GuildInfo) startCooldown(key cooldownKey, expires int64) {
+	info.commandLock.Lock()
+	info.cooldowns[key] = expires
+	info.newCooldowns = append(info.newCooldowns, key)
+	info.commandLock.Unlock()
+}
+
+// saveCooldowns saves every cooldown that started since the last time it ran, and forgets every cooldown that has
+// expired, both here and in the database. This runs on every tick instead of whenever a command starts a cooldown, so
+// commands never wait on the database.
+func (info *GuildInfo) saveCooldowns(now int64) {
+	info.commandLock.Lock()
+	started := make(map[cooldownKey]int64, len(info.newCooldowns))
+	for _, key := range info.newCooldowns {
+		started[key] = info.cooldowns[key]
+	}
+	info.newCooldowns = nil
+	expired := false
+	for key, expires := range info.cooldowns {
+		if expires <= now {
+			delete(info.cooldowns, key)
+			expired = true
+		}
+	}
+	info.commandLock.Unlock()
+
+	if info.Bot == nil || info.Bot.DB == nil || !info.Bot.DB.Status.Get() {
+		return
+	}
+	guild := SBatoi(info.ID)
+	for key, expires := range started {
+		if expires > now {
+			info.Bot.DB.SetCooldown(guild, key.Scope, string(key.Command), time.Unix(expires, 0).UTC())
+		}
+	}
+	if expired { // Every cooldown in the database is also in memory, so there's nothing to prune otherwise
+		info.Bot.DB.PruneCooldowns(guild, time.Unix(now, 0).UTC())
+	}
+}
+
+// loadCooldowns restores every cooldown that was saved before the bot restarted and hasn't expired yet
+func (info *GuildInfo) loadCooldowns() {
+	cooldowns := info.Bot.DB.GetCooldowns(SBatoi(info.ID), time.Now().UTC())
+	info.commandLock.Lock()
+	defer info.commandLock.Unlock()
+	for _, c := range cooldowns {
+		info.cooldowns[cooldownKey{c.Scope, CommandID(c.Command)}] = c.Expires.Unix()
+	}
+}
+
+func cooldownError(scope cooldownScope, remaining int64) string {
+	return fmt.Sprintf(scope.message, TimeDiff(time.Duration(scope.duration)*time.Second)) + " Try again in " + TimeDiff(time.Duration(remaining)*time.Second) + "."
+}
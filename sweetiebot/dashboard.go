@@ -0,0 +1,619 @@
+package sweetiebot
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blackhole12/discordgo"
+)
+
+// How long someone stays logged in to the dashboard
+const dashboardSessionLength = 24 * time.Hour
+
+const (
+	sessionCookie = "sbsession"
+	stateCookie   = "sbstate"
+)
+
+var errOAuthFailed = errors.New("discord didn't accept the login")
+
+// The ways a config option can be edited on the dashboard
+const (
+	configValue   = iota // A single value in a text box
+	configBool           // A checkbox
+	configList           // One item per line
+	configMap            // One "key = value" pair per line
+	configMapList        // One "key = item" pair per line, with a line for every item in each key's list
+)
+
+type dashboardSession struct {
+	user    *discordgo.User
+	csrf    string // Every form carries this token, so other sites can't submit changes on behalf of the user
+	expires time.Time
+}
+
+// dashboard lets server admins log in with their discord account and edit the config of any server they administrate.
+// Logins go through discord's OAuth2 flow using the bot's application, so selfhost.json needs the clientsecret of the
+// application, and the redirect URL (the /dashboard/callback page on the webdomain) has to be added to it on discord.
+type dashboard struct {
+	bot      *SweetieBot
+	sessions sync.Map // Session token to *dashboardSession
+}
+
+type dashboardField struct {
+	Name    string // The name !setconfig uses, like "spam.maxpressure"
+	Help    string
+	Kind    int
+	Long    bool   // Shown as a text area instead of a single line
+	Value   string // The value as it is edited, using IDs instead of names so it can be parsed back in
+	Display string // The value as !getconfig shows it
+	Error   string
+}
+
+type dashboardGroup struct {
+	Name   string
+	Fields []dashboardField
+	Saved  bool
+}
+
+type dashboardGuild struct {
+	ID   string
+	Name string
+}
+
+type dashboardPage struct {
+	Title   string
+	User    string
+	CSRF    string
+	Message string
+	Guild   dashboardGuild
+	Guilds  []dashboardGuild
+	Groups  []dashboardGroup
+}
+
+var dashboardTemplate = template.Must(template.New("dashboard").Funcs(template.FuncMap{
+	"parsemarkup": func(str string) template.HTML {
+		return template.HTML(codeBlockRegex.ReplaceAllStringFunc(template.HTMLEscapeString(str), func(s string) string { return "<code>" + s[1:len(s)-1] + "</code>" }))
+	},
+	"lines": func(str string) int { return strings.Count(str, "\n") + 2 },
+}).Parse(`{{define "header"}}<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<link rel="stylesheet" href="/web.css">
+</head>
+<body>
+<div id="dashboard">
+<p class="user">{{if .User}}Logged in as {{.User}} | <a href="/dashboard">Servers</a> | <a href="/dashboard/logout">Log out</a>{{end}}</p>
+<h1>{{.Title}}</h1>
+{{if .Message}}<p class="message">{{.Message}}</p>{{end}}
+{{end}}
+{{define "footer"}}</div>
+</body>
+</html>
+{{end}}
+{{define "message"}}{{template "header" .}}{{template "footer" .}}{{end}}
+{{define "login"}}{{template "header" .}}<p><a href="/dashboard/login">Log in with Discord</a> to manage the servers you administrate.</p>{{template "footer" .}}{{end}}
+{{define "guilds"}}{{template "header" .}}<ul>
+{{range .Guilds}}<li><a href="/dashboard/{{.ID}}">{{.Name}}</a></li>
+{{else}}<li>You aren't an administrator on any server the bot is in.</li>
+{{end}}</ul>
+{{template "footer" .}}{{end}}
+{{define "guild"}}{{template "header" .}}{{$csrf := .CSRF}}{{$guild := .Guild.ID}}
+{{range .Groups}}<form method="POST" action="/dashboard/{{$guild}}">
+<h2 id="{{.Name}}">{{.Name}}</h2>
+{{if .Saved}}<p class="message">Saved.</p>{{end}}
+<input type="hidden" name="csrf" value="{{$csrf}}">
+<input type="hidden" name="group" value="{{.Name}}">
+<table>
+{{range .Fields}}<tr>
+<td><label for="{{.Name}}">{{.Name}}</label></td>
+<td>{{if eq .Kind 1}}<input type="hidden" name="{{.Name}}" value="false"><input type="checkbox" id="{{.Name}}" name="{{.Name}}" value="true"{{if eq .Value "true"}} checked{{end}}>{{else if .Long}}<textarea id="{{.Name}}" name="{{.Name}}" rows="{{lines .Value}}">{{.Value}}</textarea>{{else}}<input type="text" id="{{.Name}}" name="{{.Name}}" value="{{.Value}}">{{end}}
+{{if .Error}}<p class="error">{{.Error}}</p>{{end}}</td>
+<td>{{parsemarkup .Help}}{{if and .Display (ne .Display .Value)}}<br>Currently: {{.Display}}{{end}}</td>
+</tr>
+{{end}}</table>
+<input type="submit" value="Save {{.Name}}">
+</form>
+{{end}}{{template "footer" .}}{{end}}`))
+
+func newToken() string {
+	b := make([]byte, 24)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func (d *dashboard) render(w http.ResponseWriter, status int, name string, page *dashboardPage) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if err := dashboardTemplate.ExecuteTemplate(w, name, page); err != nil {
+		d.bot.Log.With("page", name).LogError("Failed to render dashboard: ", err)
+	}
+}
+
+func (d *dashboard) fail(w http.ResponseWriter, s *dashboardSession, status int, message string) {
+	page := &dashboardPage{Title: "Dashboard", Message: message}
+	if s != nil {
+		page.User = s.user.Username
+	}
+	d.render(w, status, "message", page)
+}
+
+func (d *dashboard) setCookie(w http.ResponseWriter, name string, value string, expires time.Time) {
+	http.SetCookie(w, &http.Cookie{Name: name, Value: value, Path: "/dashboard", Expires: expires, HttpOnly: true, Secure: d.bot.WebSecure})
+}
+
+func (d *dashboard) session(r *http.Request) *dashboardSession {
+	c, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return nil
+	}
+	v, ok := d.sessions.Load(c.Value)
+	if !ok {
+		return nil
+	}
+	s := v.(*dashboardSession)
+	if time.Now().UTC().After(s.expires) {
+		d.sessions.Delete(c.Value)
+		return nil
+	}
+	return s
+}
+
+// redirectURL is where discord sends someone after they log in. It's built from webdomain rather than the request,
+// because behind a reverse proxy the request's host is the upstream one, and it has to match the redirect URL
+// registered on the application exactly.
+func (d *dashboard) redirectURL() string {
+	scheme := "http"
+	if d.bot.WebSecure {
+		scheme = "https"
+	}
+	return scheme + "://" + d.bot.WebDomain + "/dashboard/callback"
+}
+
+// ServeHTTP routes everything under /dashboard
+func (d *dashboard) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := splitURL(r.URL.Path)
+	switch {
+	case len(parts) == 1:
+		d.serveGuilds(w, r)
+	case len(parts) == 2 && parts[1] == "login":
+		d.login(w, r)
+	case len(parts) == 2 && parts[1] == "callback":
+		d.callback(w, r)
+	case len(parts) == 2 && parts[1] == "logout":
+		if c, err := r.Cookie(sessionCookie); err == nil {
+			d.sessions.Delete(c.Value)
+		}
+		d.setCookie(w, sessionCookie, "", time.Unix(0, 0))
+		http.Redirect(w, r, "/dashboard", http.StatusSeeOther)
+	case len(parts) == 2:
+		d.serveGuild(w, r, parts[1])
+	default:
+		http.Error(w, "Page not found", http.StatusNotFound)
+	}
+}
+
+func (d *dashboard) login(w http.ResponseWriter, r *http.Request) {
+	if len(d.bot.ClientSecret) == 0 || d.bot.AppID == 0 {
+		d.fail(w, nil, http.StatusServiceUnavailable, "The dashboard isn't available, because clientsecret hasn't been set in selfhost.json.")
+		return
+	}
+	state := newToken()
+	d.setCookie(w, stateCookie, state, time.Now().UTC().Add(10*time.Minute))
+	query := url.Values{
+		"client_id":     {SBitoa(d.bot.AppID)},
+		"redirect_uri":  {d.redirectURL()},
+		"response_type": {"code"},
+		"scope":         {"identify"},
+		"state":         {state},
+	}
+	http.Redirect(w, r, discordgo.EndpointAPI+"oauth2/authorize?"+query.Encode(), http.StatusSeeOther)
+}
+
+// authenticate trades the code discord gave the user for an access token, then asks discord who the token belongs to
+func (d *dashboard) authenticate(code string, redirect string) (*discordgo.User, error) {
+	resp, err := d.bot.DG.Client.PostForm(discordgo.EndpointAPI+"oauth2/token", url.Values{
+		"client_id":     {SBitoa(d.bot.AppID)},
+		"client_secret": {d.bot.ClientSecret},
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {redirect},
+		"scope":         {"identify"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var token struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+	}
+	if resp.StatusCode != http.StatusOK || json.NewDecoder(resp.Body).Decode(&token) != nil || len(token.AccessToken) == 0 {
+		return nil, errOAuthFailed
+	}
+
+	req, err := http.NewRequest("GET", discordgo.EndpointAPI+"users/@me", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	me, err := d.bot.DG.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer me.Body.Close()
+	user := &discordgo.User{}
+	if me.StatusCode != http.StatusOK || json.NewDecoder(me.Body).Decode(user) != nil || len(user.ID) == 0 {
+		return nil, errOAuthFailed
+	}
+	return user, nil
+}
+
+func (d *dashboard) callback(w http.ResponseWriter, r *http.Request) {
+	state, err := r.Cookie(stateCookie)
+	if err != nil || len(state.Value) == 0 || state.Value != r.FormValue("state") {
+		d.fail(w, nil, http.StatusBadRequest, "This login has expired. Please try logging in again.")
+		return
+	}
+	d.setCookie(w, stateCookie, "", time.Unix(0, 0))
+	user, err := d.authenticate(r.FormValue("code"), d.redirectURL())
+	if err != nil {
+		d.fail(w, nil, http.StatusUnauthorized, "Login failed: "+err.Error())
+		return
+	}
+
+	now := time.Now().UTC()
+	d.sessions.Range(func(k, v interface{}) bool {
+		if now.After(v.(*dashboardSession).expires) {
+			d.sessions.Delete(k)
+		}
+		return true
+	})
+	token := newToken()
+	s := &dashboardSession{user: user, csrf: newToken(), expires: now.Add(dashboardSessionLength)}
+	d.sessions.Store(token, s)
+	d.setCookie(w, sessionCookie, token, s.expires)
+	http.Redirect(w, r, "/dashboard", http.StatusSeeOther)
+}
+
+// canManage returns true if the user owns the server or is an administrator on it, the same people who can run !setup
+func canManage(info *GuildInfo, user DiscordUser) bool {
+	if info.OwnerID == user {
+		return true
+	}
+	perms, _ := info.Bot.DG.UserPermissions(user, info.ID)
+	return perms&discordgo.PermissionAdministrator != 0
+}
+
+func (d *dashboard) serveGuilds(w http.ResponseWriter, r *http.Request) {
+	s := d.session(r)
+	if s == nil {
+		d.render(w, http.StatusOK, "login", &dashboardPage{Title: "Dashboard"})
+		return
+	}
+	page := &dashboardPage{Title: "Your Servers", User: s.user.Username}
+	seen := make(map[string]bool)
+	for _, instance := range d.bot.AllInstances() {
+		instance.GuildsLock.RLock()
+		for _, info := range instance.Guilds {
+			if !seen[info.ID] && canManage(info, DiscordUser(s.user.ID)) {
+				seen[info.ID] = true
+				page.Guilds = append(page.Guilds, dashboardGuild{info.ID, info.Name})
+			}
+		}
+		instance.GuildsLock.RUnlock()
+	}
+	sort.Slice(page.Guilds, func(i, j int) bool {
+		return strings.ToLower(page.Guilds[i].Name) < strings.ToLower(page.Guilds[j].Name)
+	})
+	d.render(w, http.StatusOK, "guilds", page)
+}
+
+func (d *dashboard) serveGuild(w http.ResponseWriter, r *http.Request, guild string) {
+	s := d.session(r)
+	if s == nil {
+		http.Redirect(w, r, "/dashboard", http.StatusSeeOther)
+		return
+	}
+	info := d.bot.FindGuild(DiscordGuild(guild))
+	if info == nil {
+		d.fail(w, s, http.StatusNotFound, "The bot isn't on that server.")
+		return
+	}
+	if !canManage(info, DiscordUser(s.user.ID)) {
+		d.fail(w, s, http.StatusForbidden, "Only administrators of "+info.Name+" can change its configuration.")
+		return
+	}
+
+	page := &dashboardPage{Title: info.Name, User: s.user.Username, CSRF: s.csrf, Guild: dashboardGuild{info.ID, info.Name}}
+	status := http.StatusOK
+	switch r.Method {
+	case "GET":
+		info.ConfigLock.RLock()
+		page.Groups = configGroups(&info.Config, info, "", nil, nil)
+		info.ConfigLock.RUnlock()
+	case "POST":
+		if r.PostFormValue("csrf") != s.csrf {
+			d.fail(w, s, http.StatusForbidden, "This form has expired. Please reload the page and try again.")
+			return
+		}
+		group := r.PostFormValue("group")
+		errs, err := info.editConfig(group, r.PostForm, DiscordUser(s.user.ID))
+		if err != nil {
+			page.Message = "Failed to save " + group + ": " + err.Error()
+			status = http.StatusBadRequest
+		}
+		if len(errs) > 0 {
+			status = http.StatusBadRequest
+		}
+		info.ConfigLock.RLock()
+		page.Groups = configGroups(&info.Config, info, group, errs, r.PostForm)
+		info.ConfigLock.RUnlock()
+		if status == http.StatusOK {
+			for i := range page.Groups {
+				page.Groups[i].Saved = page.Groups[i].Name == group
+			}
+			info.UpdateSlashCommands()
+		}
+	default:
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	d.render(w, status, "guild", page)
+}
+
+// configKind figures out how an option is edited from its type. These match the types !setconfig accepts.
+func configKind(f reflect.Value) int {
+	switch f.Kind() {
+	case reflect.Bool:
+		return configBool
+	case reflect.Map:
+		switch f.Type().Elem().Kind() {
+		case reflect.Bool:
+			return configList
+		case reflect.Map, reflect.Slice:
+			return configMapList
+		}
+		return configMap
+	}
+	return configValue
+}
+
+func sortedText(items []string) string {
+	sort.Strings(items)
+	return strings.Join(items, "\n")
+}
+
+// configText writes out an option the way it's edited on the dashboard
+func configText(f reflect.Value) string {
+	switch configKind(f) {
+	case configList:
+		items := []string{}
+		for _, k := range f.MapKeys() {
+			items = append(items, fmt.Sprint(k.Interface()))
+		}
+		return sortedText(items)
+	case configMap:
+		items := []string{}
+		for _, k := range f.MapKeys() {
+			items = append(items, fmt.Sprintf("%v = %v", k.Interface(), f.MapIndex(k).Interface()))
+		}
+		return sortedText(items)
+	case configMapList:
+		keys := f.MapKeys()
+		sort.Sort(valueArray(keys))
+		lines := []string{}
+		for _, k := range keys {
+			v := f.MapIndex(k)
+			items := []string{}
+			if v.Kind() == reflect.Slice {
+				for i := 0; i < v.Len(); i++ {
+					items = append(items, fmt.Sprint(v.Index(i).Interface()))
+				}
+			} else {
+				for _, item := range v.MapKeys() {
+					items = append(items, fmt.Sprint(item.Interface()))
+				}
+				sort.Strings(items)
+			}
+			if len(items) == 0 {
+				items = append(items, "")
+			}
+			for _, item := range items {
+				lines = append(lines, strings.TrimSpace(fmt.Sprintf("%v = %s", k.Interface(), item)))
+			}
+		}
+		return strings.Join(lines, "\n")
+	}
+	return fmt.Sprint(f.Interface())
+}
+
+// configLines splits text from a form into its non-empty lines
+func configLines(text string) []string {
+	lines := []string{}
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); len(line) > 0 {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// formText gets the value of an option from a form. Checkboxes send a hidden "false" before their own value, so the
+// last value wins.
+func formText(form url.Values, name string) string {
+	values := form[name]
+	if len(values) == 0 {
+		return ""
+	}
+	return strings.Replace(values[len(values)-1], "\r\n", "\n", -1)
+}
+
+func splitConfigPair(line string) (string, string) {
+	i := strings.Index(line, "=")
+	if i < 0 {
+		return line, ""
+	}
+	return strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:])
+}
+
+// setConfigText parses an option edited on the dashboard, using the same validation as !setconfig
+func setConfigText(f reflect.Value, text string, info *GuildInfo) error {
+	switch configKind(f) {
+	case configBool:
+		b, err := strconv.ParseBool(text)
+		if err != nil {
+			return errors.New("must be either true or false")
+		}
+		f.SetBool(b)
+	case configList:
+		lines := configLines(text)
+		if len(lines) == 0 {
+			lines = []string{""}
+		}
+		if s, ok := setConfigList(f, lines, info); !ok {
+			return errors.New(s)
+		}
+	case configMap:
+		f.Set(reflect.MakeMap(f.Type()))
+		for _, line := range configLines(text) {
+			k, v := splitConfigPair(line)
+			if len(v) == 0 {
+				return fmt.Errorf("%s doesn't have a value", k)
+			}
+			if s, ok := setConfigKeyValue(f, strings.ToLower(k), v, info); !ok {
+				return errors.New(s)
+			}
+		}
+	case configMapList:
+		f.Set(reflect.MakeMap(f.Type()))
+		keys := []string{}
+		items := make(map[string][]string)
+		for _, line := range configLines(text) {
+			k, v := splitConfigPair(line)
+			k = strings.ToLower(k)
+			if _, ok := items[k]; !ok {
+				keys = append(keys, k)
+				items[k] = []string{}
+			}
+			if len(v) > 0 {
+				items[k] = append(items[k], v)
+			}
+		}
+		for _, k := range keys {
+			values := items[k]
+			if len(values) == 0 {
+				values = []string{""}
+			}
+			if s, ok := setConfigMapList(f, k, values, info); !ok {
+				return errors.New(s)
+			}
+		}
+	default:
+		return setConfigValue(f, strings.TrimSpace(text), info)
+	}
+	return nil
+}
+
+// configGroups describes every option in each config group. Errors are shown next to the options in the edited group,
+// along with the values that caused them, so they can be fixed instead of typed in again.
+func configGroups(config *BotConfig, info *GuildInfo, edited string, errs map[string]string, form url.Values) []dashboardGroup {
+	var state *discordgo.State
+	if info.Bot.DG != nil {
+		state = info.Bot.DG.State
+	}
+	groups := []dashboardGroup{}
+	for _, c := range config.groups() {
+		g := c.Value
+		group := dashboardGroup{Name: c.Name}
+		for j := 0; j < g.NumField(); j++ {
+			option := g.Type().Field(j).Name
+			f := g.Field(j)
+			help, _ := getConfigHelp(group.Name, option)
+			field := dashboardField{
+				Name:  strings.ToLower(group.Name + "." + option),
+				Help:  help,
+				Kind:  configKind(f),
+				Value: configText(f),
+			}
+			if state != nil && field.Kind == configValue {
+				field.Display = strings.Join(config.GetConfig(f, state, info.ID), "\n")
+			}
+			if e, ok := errs[field.Name]; ok && group.Name == edited {
+				field.Error = e
+				field.Value = formText(form, field.Name)
+			}
+			field.Long = field.Kind >= configList || strings.Contains(field.Value, "\n")
+			group.Fields = append(group.Fields, field)
+		}
+		groups = append(groups, group)
+	}
+	return groups
+}
+
+// editConfig applies the options from a dashboard form to a single config group, then saves the config on behalf of
+// the user. Only options that were actually changed are parsed, so values the form can't represent exactly (like
+// quotes spanning several lines) are left alone. If any option is invalid, nothing is saved, and the error for each
+// option is returned.
+func (info *GuildInfo) editConfig(group string, form url.Values, user DiscordUser) (map[string]string, error) {
+	info.ConfigLock.Lock()
+	defer info.ConfigLock.Unlock()
+
+	// Edit a copy, so an invalid option doesn't leave the config half changed
+	data, err := json.Marshal(info.Config)
+	if err != nil {
+		return nil, err
+	}
+	config := BotConfig{}
+	if err = json.Unmarshal(data, &config); err != nil {
+		return nil, err
+	}
+
+	c, ok := config.group(group)
+	if !ok {
+		return nil, errors.New("that isn't a config group")
+	}
+	g := c.Value
+	errs := make(map[string]string)
+	for j := 0; j < g.NumField(); j++ {
+		name := strings.ToLower(group + "." + g.Type().Field(j).Name)
+		if _, ok := form[name]; !ok {
+			continue
+		}
+		text := formText(form, name)
+		f := g.Field(j)
+		if strings.TrimSpace(text) == strings.TrimSpace(configText(f)) {
+			continue
+		}
+		if err := setConfigText(f, text, info); err != nil {
+			errs[name] = err.Error()
+		}
+	}
+	if len(errs) > 0 {
+		return errs, nil
+	}
+	data, err = json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > info.Bot.MaxConfigSize {
+		return nil, errConfigFileTooLarge
+	}
+
+	info.Config = config
+	return nil, info.SaveConfigBy(user)
+}
@@ -10,7 +10,6 @@ import (
 
 	"4d63.com/tz"
 	"github.com/blackhole12/discordgo"
-	"github.com/go-sql-driver/mysql" // Blank import is the correct way to import a sql driver
 )
 
 // ErrDuplicateEntry - Error 1062: Duplicate entry for unique key
@@ -24,109 +23,138 @@ type BotDB struct {
 	db                        *sql.DB
 	Status                    AtomicBool
 	lastattempt               time.Time
-	log                       logger
+	log                       *Logger
 	driver                    string
 	conn                      string
 	statuslock                AtomicFlag
-	sqlAddMessage             *sql.Stmt
-	sqlAddUser                *sql.Stmt
-	sqlAddMember              *sql.Stmt
-	sqlSawUser                *sql.Stmt
-	sqlSetUserAlias           *sql.Stmt
-	sqlRemoveMember           *sql.Stmt
-	sqlGetUser                *sql.Stmt
-	sqlGetMember              *sql.Stmt
-	sqlFindGuildUsers         *sql.Stmt
-	sqlFindUser               *sql.Stmt
-	sqlGetNewestUsers         *sql.Stmt
-	sqlGetRecentUsers         *sql.Stmt
-	sqlGetAliases             *sql.Stmt
-	sqlAddTranscript          *sql.Stmt
-	sqlGetTranscript          *sql.Stmt
-	sqlRemoveTranscript       *sql.Stmt
-	sqlAddMarkov              *sql.Stmt
-	sqlGetMarkovLine          *sql.Stmt
-	sqlGetMarkovLine2         *sql.Stmt
-	sqlGetMarkovWord          *sql.Stmt
-	sqlGetRandomQuoteInt      *sql.Stmt
-	sqlGetRandomQuote         *sql.Stmt
-	sqlGetSpeechQuoteInt      *sql.Stmt
-	sqlGetSpeechQuote         *sql.Stmt
-	sqlGetCharacterQuoteInt   *sql.Stmt
-	sqlGetCharacterQuote      *sql.Stmt
-	sqlGetRandomSpeakerInt    *sql.Stmt
-	sqlGetRandomSpeaker       *sql.Stmt
-	sqlGetRandomMemberInt     *sql.Stmt
-	sqlGetRandomMember        *sql.Stmt
-	sqlGetRandomWordInt       *sql.Stmt
-	sqlGetRandomWord          *sql.Stmt
-	sqlGetTableCounts         *sql.Stmt
-	sqlCountNewUsers          *sql.Stmt
-	sqlAudit                  *sql.Stmt
-	sqlGetAuditRows           *sql.Stmt
-	sqlGetAuditRowsUser       *sql.Stmt
-	sqlGetAuditRowsString     *sql.Stmt
-	sqlGetAuditRowsUserString *sql.Stmt
-	sqlResetMarkov            *sql.Stmt
-	sqlAddSchedule            *sql.Stmt
-	sqlAddScheduleRepeat      *sql.Stmt
-	sqlGetSchedule            *sql.Stmt
-	sqlRemoveSchedule         *sql.Stmt
-	sqlDeleteSchedule         *sql.Stmt
-	sqlCountEvents            *sql.Stmt
-	sqlGetEvent               *sql.Stmt
-	sqlGetEvents              *sql.Stmt
-	sqlGetEventsByType        *sql.Stmt
-	sqlGetNextEvent           *sql.Stmt
-	sqlGetReminders           *sql.Stmt
-	sqlGetScheduleDate        *sql.Stmt
-	sqlGetTimeZone            *sql.Stmt
-	sqlFindTimeZone           *sql.Stmt
-	sqlFindTimeZoneOffset     *sql.Stmt
-	sqlSetTimeZone            *sql.Stmt
-	sqlRemoveAlias            *sql.Stmt
-	sqlGetUserGuilds          *sql.Stmt
-	sqlFindEvent              *sql.Stmt
-	sqlSetDefaultServer       *sql.Stmt
-	sqlCheckOption            *sql.Stmt
-	sqlSentMessage            *sql.Stmt
-	sqlGetNewcomers           *sql.Stmt
-	sqlAddItem                *sql.Stmt
-	sqlGetItem                *sql.Stmt
-	sqlRemoveItem             *sql.Stmt
-	sqlAddTag                 *sql.Stmt
-	sqlRemoveTag              *sql.Stmt
-	sqlCreateTag              *sql.Stmt
-	sqlDeleteTag              *sql.Stmt
-	sqlGetTag                 *sql.Stmt
-	sqlCountTag               *sql.Stmt
-	sqlCountItems             *sql.Stmt
-	sqlGetItemTags            *sql.Stmt
-	sqlGetTags                *sql.Stmt
-	sqlImportTag              *sql.Stmt
-	sqlRemoveGuild            *sql.Stmt
-}
-
-func dbLoad(log logger, driver string, conn string) (*BotDB, error) {
-	cdb, err := sql.Open(driver, conn)
+	store                     Store
+	sqlSawUser                *statement
+	sqlSetUserAlias           *statement
+	sqlRemoveMember           *statement
+	sqlGetUser                *statement
+	sqlGetMember              *statement
+	sqlFindGuildUsers         *statement
+	sqlFindUser               *statement
+	sqlGetNewestUsers         *statement
+	sqlGetRecentUsers         *statement
+	sqlGetAliases             *statement
+	sqlAddTranscript          *statement
+	sqlGetTranscript          *statement
+	sqlRemoveTranscript       *statement
+	sqlGetMarkovWord          *statement
+	sqlGetRandomQuoteInt      *statement
+	sqlGetRandomQuote         *statement
+	sqlGetSpeechQuoteInt      *statement
+	sqlGetSpeechQuote         *statement
+	sqlGetCharacterQuoteInt   *statement
+	sqlGetCharacterQuote      *statement
+	sqlGetRandomSpeakerInt    *statement
+	sqlGetRandomSpeaker       *statement
+	sqlGetRandomMemberInt     *statement
+	sqlGetRandomMember        *statement
+	sqlGetRandomWordInt       *statement
+	sqlGetRandomWord          *statement
+	sqlGetTableCounts         *statement
+	sqlCountNewUsers          *statement
+	sqlAudit                  *statement
+	sqlGetAuditRows           *statement
+	sqlGetAuditRowsUser       *statement
+	sqlGetAuditRowsString     *statement
+	sqlGetAuditRowsUserString *statement
+	sqlAddSchedule            *statement
+	sqlAddScheduleRepeat      *statement
+	sqlGetSchedule            *statement
+	sqlDeleteSchedule         *statement
+	sqlCountEvents            *statement
+	sqlGetEvent               *statement
+	sqlGetEvents              *statement
+	sqlGetEventsByType        *statement
+	sqlGetNextEvent           *statement
+	sqlGetReminders           *statement
+	sqlGetScheduleDate        *statement
+	sqlGetTimeZone            *statement
+	sqlFindTimeZone           *statement
+	sqlFindTimeZoneOffset     *statement
+	sqlSetTimeZone            *statement
+	sqlRemoveAlias            *statement
+	sqlGetUserGuilds          *statement
+	sqlFindEvent              *statement
+	sqlSetDefaultServer       *statement
+	sqlCheckOption            *statement
+	sqlSentMessage            *statement
+	sqlGetNewcomers           *statement
+	sqlGetItem                *statement
+	sqlRemoveItem             *statement
+	sqlAddTag                 *statement
+	sqlRemoveTag              *statement
+	sqlCreateTag              *statement
+	sqlDeleteTag              *statement
+	sqlGetTag                 *statement
+	sqlCountTag               *statement
+	sqlPickTag                *statement
+	sqlCountItems             *statement
+	sqlGetItemTags            *statement
+	sqlGetTags                *statement
+	sqlImportTag              *statement
+	sqlNextCase               *statement
+	sqlAddCase                *statement
+	sqlGetCase                *statement
+	sqlGetCases               *statement
+	sqlGetTargetCases         *statement
+	sqlSetCaseReason          *statement
+	sqlGetWarnings            *statement
+	sqlGetMessage             *statement
+	sqlGetLastEdit            *statement
+	sqlNextConfigVersion      *statement
+	sqlAddConfigVersion       *statement
+	sqlGetConfigVersion       *statement
+	sqlGetConfigHistory       *statement
+	sqlPruneConfigHistory     *statement
+	sqlAddVoiceTime           *statement
+	sqlGetVoiceTime           *statement
+	sqlGetTopVoiceTime        *statement
+	sqlAddTempChannel         *statement
+	sqlRemoveTempChannel      *statement
+	sqlGetTempChannels        *statement
+	sqlSetCooldown            *statement
+	sqlGetCooldowns           *statement
+	sqlPruneCooldowns         *statement
+}
+
+func dbLoad(log *Logger, driver string, conn string) (*BotDB, error) {
+	store := newStore(driver)
+	cdb, err := store.Open(conn)
 	r := BotDB{
 		db:          cdb,
 		lastattempt: time.Now().UTC(),
 		log:         log,
 		driver:      driver,
 		conn:        conn,
+		store:       store,
 	}
 	r.Status.Set(err == nil)
 	if err != nil {
 		return &r, err
 	}
 
-	r.db.SetMaxOpenConns(70)
+	if driver != DriverSQLite { // SQLite only allows one writer at a time, so it manages its own connection limit
+		r.db.SetMaxOpenConns(70)
+	}
 	err = r.db.Ping()
+	if err == nil {
+		err = store.Init(r.db)
+	}
 	r.Status.Set(err == nil)
 	return &r, err
 }
 
+func (db *BotDB) backend() Store {
+	if db.store == nil {
+		db.store = newStore(db.driver)
+	}
+	return db.store
+}
+
 // Close destroys the database connection
 func (db *BotDB) Close() {
 	if db.db != nil {
@@ -139,26 +167,60 @@ func (db *BotDB) standardErr(err error) error {
 	if err == nil {
 		return nil
 	}
-	if mysqlErr, ok := err.(*mysql.MySQLError); ok {
-		switch mysqlErr.Number {
-		case 1062:
-			return ErrDuplicateEntry
-		case 1205:
-			return ErrLockWaitTimeout
-		}
-	}
-	return err
+	return db.backend().StandardErr(err)
 }
 
-// Prepare a sql statement and logs an error if it fails
+// Prepare a sql statement and logs an error if it fails. The statement is written in MySQL syntax and translated to whatever backend is in use.
 func (db *BotDB) Prepare(s string) (*sql.Stmt, error) {
-	statement, err := db.db.Prepare(s)
+	statement, err := db.db.Prepare(db.backend().Rewrite(s))
 	if err != nil {
-		fmt.Println("Preparing: ", s, "\nSQL Error: ", err.Error())
+		db.log.Error("Preparing: ", s, "\nSQL Error: ", err.Error())
 	}
 	return statement, err
 }
 
+// statement is a prepared statement that measures how long it takes to run
+type statement struct {
+	*sql.Stmt
+	name string
+}
+
+func (db *BotDB) prepare(name string, s string) (*statement, error) {
+	stmt, err := db.Prepare(s)
+	if err != nil {
+		return nil, err
+	}
+	return &statement{stmt, name}, nil
+}
+
+func (s *statement) observe(start time.Time) {
+	metricStatementDuration.Observe(time.Since(start).Seconds(), s.name)
+}
+
+// Exec executes the statement and measures how long it took
+func (s *statement) Exec(args ...interface{}) (sql.Result, error) {
+	defer s.observe(time.Now())
+	return s.Stmt.Exec(args...)
+}
+
+// Query executes the statement and measures how long it took to return the first row
+func (s *statement) Query(args ...interface{}) (*sql.Rows, error) {
+	defer s.observe(time.Now())
+	return s.Stmt.Query(args...)
+}
+
+// QueryRow executes the statement and measures how long it took. Errors are only returned once the row is scanned.
+func (s *statement) QueryRow(args ...interface{}) *sql.Row {
+	defer s.observe(time.Now())
+	return s.Stmt.QueryRow(args...)
+}
+
+// Exec executes a one-off MySQL statement, translated to the current backend
+func (db *BotDB) Exec(s string, args ...interface{}) (sql.Result, error) {
+	r, err := db.db.Exec(db.backend().Rewrite(s), args...)
+	return r, db.standardErr(err)
+}
+
 // DBReconnectTimeout is the reconnect time interval in seconds
 const DBReconnectTimeout = time.Duration(30) * time.Second
 
@@ -175,7 +237,7 @@ func (db *BotDB) CheckStatus() bool {
 		}
 
 		if db.lastattempt.Add(DBReconnectTimeout).Before(time.Now().UTC()) {
-			db.log.Log("Database failure detected! Attempting to reboot database connection...")
+			db.log.Warning("Database failure detected! Attempting to reboot database connection...")
 			db.lastattempt = time.Now().UTC()
 			err := db.db.Ping()
 			if err != nil {
@@ -185,7 +247,7 @@ func (db *BotDB) CheckStatus() bool {
 			err = db.LoadStatements()                       // If we re-establish connection, we must reload statements in case they were lost or never loaded in the first place
 			db.log.LogError("LoadStatements failed: ", err) // if loading the statements fails we're screwed anyway so we just log the error and keep going
 			db.Status.Set(true)                             // Only after loading the statements do we set status to true
-			db.log.Log("Reconnection succeeded, exiting out of No Database mode.")
+			db.log.Info("Reconnection succeeded, exiting out of No Database mode.")
 		} else { // If not, just fail
 			return false
 		}
@@ -197,83 +259,100 @@ func (db *BotDB) CheckStatus() bool {
 // LoadStatements loads all Prepared statements
 func (db *BotDB) LoadStatements() error {
 	var err error
-	db.sqlAddMessage, err = db.Prepare("CALL AddChat(?,?,?,?,?,?)")
-	db.sqlAddUser, err = db.Prepare("CALL AddUser(?,?,?,?,?)")
-	db.sqlAddMember, err = db.Prepare("CALL AddMember(?,?,?,?)")
-	db.sqlSawUser, err = db.Prepare("UPDATE users SET LastSeen = UTC_TIMESTAMP() WHERE ID = ?")
-	db.sqlSetUserAlias, err = db.Prepare("INSERT IGNORE INTO aliases (`User`, Alias, Duration, `Timestamp`)	VALUES (?, ?, 0, UTC_TIMESTAMP())")
-	db.sqlRemoveMember, err = db.Prepare("DELETE FROM `members` WHERE Guild = ? AND ID = ?")
-	db.sqlGetUser, err = db.Prepare("SELECT ID, Username, Discriminator, Avatar, LastSeen, Location, DefaultServer FROM users WHERE ID = ?")
-	db.sqlGetMember, err = db.Prepare("SELECT U.ID, U.Username, U.Discriminator, U.Avatar, U.LastSeen, M.Nickname, M.FirstSeen, M.FirstMessage FROM members M RIGHT OUTER JOIN users U ON U.ID = M.ID WHERE M.ID = ? AND M.Guild = ?")
-	db.sqlFindGuildUsers, err = db.Prepare("SELECT DISTINCT M.ID FROM members M LEFT OUTER JOIN aliases A ON A.User = M.ID WHERE M.Guild = ? AND (M.Nickname LIKE ? OR A.Alias LIKE ?) LIMIT ? OFFSET ?")
-	db.sqlFindUser, err = db.Prepare("SELECT DISTINCT U.ID FROM users U WHERE U.Discriminator = ? and U.Username LIKE ? LIMIT ? OFFSET ?")
-	db.sqlGetNewestUsers, err = db.Prepare("SELECT U.ID, U.Username, U.Avatar, M.FirstSeen FROM members M INNER JOIN users U ON M.ID = U.ID WHERE M.Guild = ? ORDER BY M.FirstSeen DESC LIMIT ?")
-	db.sqlGetRecentUsers, err = db.Prepare("SELECT U.ID, U.Username, U.Avatar FROM members M INNER JOIN users U ON M.ID = U.ID WHERE M.Guild = ? AND M.FirstSeen > ? ORDER BY M.FirstSeen DESC")
-	db.sqlGetAliases, err = db.Prepare("SELECT Alias FROM aliases WHERE User = ? ORDER BY Duration DESC LIMIT 10")
-	db.sqlAddTranscript, err = db.Prepare("INSERT INTO transcripts (Season, Episode, Line, Speaker, Text) VALUES (?,?,?,?,?)")
-	db.sqlGetTranscript, err = db.Prepare("SELECT Season, Episode, Line, Speaker, Text FROM transcripts WHERE Season = ? AND Episode = ? AND Line >= ? AND LINE <= ?")
-	db.sqlRemoveTranscript, err = db.Prepare("DELETE FROM transcripts WHERE Season = ? AND Episode = ? AND Line = ?")
-	db.sqlAddMarkov, err = db.Prepare("SELECT AddMarkov(?,?,?,?)")
-	db.sqlGetMarkovLine, err = db.Prepare("SELECT GetMarkovLine(?)")
-	db.sqlGetMarkovLine2, err = db.Prepare("SELECT GetMarkovLine2(?,?)")
-	db.sqlGetMarkovWord, err = db.Prepare("SELECT Phrase FROM markov_transcripts WHERE SpeakerID = (SELECT ID FROM markov_transcripts_speaker WHERE Speaker = ?) AND Phrase = ?")
-	db.sqlGetRandomQuoteInt, err = db.Prepare("SELECT FLOOR(RAND()*(SELECT COUNT(*) FROM transcripts WHERE Text != ''))")
-	db.sqlGetRandomQuote, err = db.Prepare("SELECT * FROM transcripts WHERE Text != '' LIMIT 1 OFFSET ?")
-	db.sqlGetSpeechQuoteInt, err = db.Prepare("SELECT FLOOR(RAND()*(SELECT COUNT(*) FROM transcripts WHERE Speaker != 'ACTION' AND Text != ''))")
-	db.sqlGetSpeechQuote, err = db.Prepare("SELECT * FROM transcripts WHERE Speaker != 'ACTION' AND Text != '' LIMIT 1 OFFSET ?")
-	db.sqlGetCharacterQuoteInt, err = db.Prepare("SELECT FLOOR(RAND()*(SELECT COUNT(*) FROM transcripts WHERE Speaker = ? AND Text != ''))")
-	db.sqlGetCharacterQuote, err = db.Prepare("SELECT * FROM transcripts WHERE Speaker = ? AND Text != '' LIMIT 1 OFFSET ?")
-	db.sqlGetRandomSpeakerInt, err = db.Prepare("SELECT FLOOR(RAND()*(SELECT COUNT(*) FROM markov_transcripts_speaker))")
-	db.sqlGetRandomSpeaker, err = db.Prepare("SELECT Speaker FROM markov_transcripts_speaker LIMIT 1 OFFSET ?")
-	db.sqlGetRandomMemberInt, err = db.Prepare("SELECT FLOOR(RAND()*(SELECT COUNT(*) FROM members WHERE Guild = ?))")
-	db.sqlGetRandomMember, err = db.Prepare("SELECT U.Username FROM members M INNER JOIN users U ON M.ID = U.ID WHERE M.Guild = ? LIMIT 1 OFFSET ?")
-	db.sqlGetRandomWordInt, err = db.Prepare("SELECT FLOOR(RAND()*(SELECT COUNT(*) FROM randomwords))")
-	db.sqlGetRandomWord, err = db.Prepare("SELECT Phrase FROM randomwords LIMIT 1 OFFSET ?;")
-	db.sqlGetTableCounts, err = db.Prepare("SELECT CONCAT('Chatlog: ', (SELECT COUNT(*) FROM chatlog), ' rows', '\nEditlog: ', (SELECT COUNT(*) FROM editlog), ' rows',  '\nAliases: ', (SELECT COUNT(*) FROM aliases), ' rows',  '\nDebuglog: ', (SELECT COUNT(*) FROM debuglog), ' rows',  '\nUsers: ', (SELECT COUNT(*) FROM users), ' rows',  '\nSchedule: ', (SELECT COUNT(*) FROM schedule), ' rows \nMembers: ', (SELECT COUNT(*) FROM members), ' rows \nItems: ', (SELECT COUNT(*) FROM items), ' rows \nTags: ', (SELECT COUNT(*) FROM tags), ' rows \nitemtags: ', (SELECT COUNT(*) FROM itemtags), ' rows');")
-	db.sqlCountNewUsers, err = db.Prepare("SELECT COUNT(*) FROM members WHERE FirstSeen > DATE_SUB(UTC_TIMESTAMP(), INTERVAL ? SECOND) AND Guild = ?")
-	db.sqlAudit, err = db.Prepare("INSERT INTO debuglog (Type, User, Message, Timestamp, Guild) VALUE(?, ?, ?, UTC_TIMESTAMP(), ?)")
-	db.sqlGetAuditRows, err = db.Prepare("SELECT U.Username, D.Message, D.Timestamp, U.ID FROM debuglog D INNER JOIN users U ON D.User = U.ID WHERE D.Type = ? AND D.Guild = ? ORDER BY D.Timestamp DESC LIMIT ? OFFSET ?")
-	db.sqlGetAuditRowsUser, err = db.Prepare("SELECT U.Username, D.Message, D.Timestamp, U.ID FROM debuglog D INNER JOIN users U ON D.User = U.ID WHERE D.Type = ? AND D.Guild = ? AND D.User = ? ORDER BY D.Timestamp DESC LIMIT ? OFFSET ?")
-	db.sqlGetAuditRowsString, err = db.Prepare("SELECT U.Username, D.Message, D.Timestamp, U.ID FROM debuglog D INNER JOIN users U ON D.User = U.ID WHERE D.Type = ? AND D.Guild = ? AND D.Message LIKE ? ORDER BY D.Timestamp DESC LIMIT ? OFFSET ?")
-	db.sqlGetAuditRowsUserString, err = db.Prepare("SELECT U.Username, D.Message, D.Timestamp, U.ID FROM debuglog D INNER JOIN users U ON D.User = U.ID WHERE D.Type = ? AND D.Guild = ? AND D.User = ? AND D.Message LIKE ? ORDER BY D.Timestamp DESC LIMIT ? OFFSET ?")
-	db.sqlResetMarkov, err = db.Prepare("CALL ResetMarkov()")
-	db.sqlAddSchedule, err = db.Prepare("INSERT INTO schedule (Guild, Date, Type, Data) VALUES (?, ?, ?, ?)")
-	db.sqlAddScheduleRepeat, err = db.Prepare("INSERT INTO schedule (Guild, Date, `RepeatInterval`, `Repeat`, Type, Data) VALUES (?, ?, ?, ?, ?, ?)")
-	db.sqlGetSchedule, err = db.Prepare("SELECT ID, Date, Type, Data FROM schedule WHERE Guild = ? AND Date <= UTC_TIMESTAMP() ORDER BY Date ASC")
-	db.sqlRemoveSchedule, err = db.Prepare("CALL RemoveSchedule(?)")
-	db.sqlDeleteSchedule, err = db.Prepare("DELETE FROM `schedule` WHERE ID = ?")
-	db.sqlCountEvents, err = db.Prepare("SELECT COUNT(*) FROM schedule WHERE Guild = ?")
-	db.sqlGetEvent, err = db.Prepare("SELECT ID, Date, Type, Data FROM schedule WHERE Guild = ? AND ID = ?")
-	db.sqlGetEvents, err = db.Prepare("SELECT ID, Date, Type, Data FROM schedule WHERE Guild = ? AND Type != 0 AND Type != 4 AND Type != 6 ORDER BY Date ASC LIMIT ?")
-	db.sqlGetEventsByType, err = db.Prepare("SELECT ID, Date, Type, Data FROM schedule WHERE Guild = ? AND Type = ? ORDER BY Date ASC LIMIT ?")
-	db.sqlGetNextEvent, err = db.Prepare("SELECT ID, Date, Type, Data FROM schedule WHERE Guild = ? AND Type = ? ORDER BY Date ASC LIMIT 1")
-	db.sqlGetReminders, err = db.Prepare("SELECT ID, Date, Type, Data FROM schedule WHERE Guild = ? AND Type = 6 AND Data LIKE ? ORDER BY Date ASC LIMIT ?")
-	db.sqlGetScheduleDate, err = db.Prepare("SELECT Date FROM schedule WHERE Guild = ? AND Type = ? AND Data = ?")
-	db.sqlGetTimeZone, err = db.Prepare("SELECT Location FROM users WHERE ID = ?")
-	db.sqlFindTimeZone, err = db.Prepare("SELECT Location FROM timezones WHERE Location LIKE ?")
-	db.sqlFindTimeZoneOffset, err = db.Prepare("SELECT Location FROM timezones WHERE Location LIKE ? AND (Offset = ? OR DST = ?)")
-	db.sqlSetTimeZone, err = db.Prepare("UPDATE users SET Location = ? WHERE ID = ?")
-	db.sqlRemoveAlias, err = db.Prepare("DELETE FROM aliases WHERE User = ? AND Alias = ?")
-	db.sqlGetUserGuilds, err = db.Prepare("SELECT Guild FROM members WHERE ID = ?")
-	db.sqlFindEvent, err = db.Prepare("SELECT ID FROM `schedule` WHERE `Type` = ? AND `Data` = ? AND `Guild` = ?")
-	db.sqlSetDefaultServer, err = db.Prepare("UPDATE users SET DefaultServer = ? WHERE ID = ?")
-	db.sqlSentMessage, err = db.Prepare("UPDATE `members` SET `FirstMessage` = UTC_TIMESTAMP() WHERE ID = ? AND Guild = ? AND `FirstMessage` IS NULL")
-	db.sqlGetNewcomers, err = db.Prepare("SELECT ID FROM `members` WHERE `Guild` = ? AND `FirstMessage` > DATE_SUB(UTC_TIMESTAMP(), INTERVAL ? SECOND)")
-	db.sqlAddItem, err = db.Prepare("SELECT AddItem(?)")
-	db.sqlGetItem, err = db.Prepare("SELECT ID FROM items WHERE Content = ?")
-	db.sqlRemoveItem, err = db.Prepare("DELETE M FROM itemtags M INNER JOIN tags T ON M.Tag = T.ID WHERE M.Item = ? AND T.Guild = ?")
-	db.sqlAddTag, err = db.Prepare("INSERT INTO itemtags (Item, Tag) VALUES (?, ?)")
-	db.sqlRemoveTag, err = db.Prepare("DELETE FROM itemtags WHERE Item = ? AND Tag = ?")
-	db.sqlCreateTag, err = db.Prepare("INSERT INTO tags (Name, Guild) VALUES (?, ?)")
-	db.sqlDeleteTag, err = db.Prepare("DELETE FROM tags WHERE Name = ? AND Guild = ?")
-	db.sqlGetTag, err = db.Prepare("SELECT ID FROM tags WHERE Name = ? AND Guild = ?")
-	db.sqlCountTag, err = db.Prepare("SELECT COUNT(*) FROM itemtags WHERE Tag = ?")
-	db.sqlCountItems, err = db.Prepare("SELECT COUNT(DISTINCT M.Item) FROM itemtags M INNER JOIN tags T ON M.Tag = T.ID WHERE T.Guild = ?")
-	db.sqlGetItemTags, err = db.Prepare("SELECT T.Name FROM itemtags M INNER JOIN tags T ON M.Tag = T.ID WHERE M.Item = ? AND T.Guild = ?")
-	db.sqlGetTags, err = db.Prepare("SELECT T.Name, COUNT(M.Item) FROM tags T LEFT OUTER JOIN itemtags M ON T.ID = M.Tag WHERE T.Guild = ? GROUP BY T.Name")
-	db.sqlImportTag, err = db.Prepare("INSERT IGNORE INTO itemtags (Item, Tag) SELECT Item, ? FROM itemtags WHERE Tag = ?")
-	db.sqlRemoveGuild, err = db.Prepare("CALL RemoveGuild(?)")
-	return err
+	db.sqlSawUser, err = db.prepare("SawUser", "UPDATE users SET LastSeen = UTC_TIMESTAMP() WHERE ID = ?")
+	db.sqlSetUserAlias, err = db.prepare("SetUserAlias", "INSERT IGNORE INTO aliases (`User`, Alias, Duration, `Timestamp`)	VALUES (?, ?, 0, UTC_TIMESTAMP())")
+	db.sqlRemoveMember, err = db.prepare("RemoveMember", "DELETE FROM `members` WHERE Guild = ? AND ID = ?")
+	db.sqlGetUser, err = db.prepare("GetUser", "SELECT ID, Username, Discriminator, Avatar, LastSeen, Location, DefaultServer FROM users WHERE ID = ?")
+	db.sqlGetMember, err = db.prepare("GetMember", "SELECT U.ID, U.Username, U.Discriminator, U.Avatar, U.LastSeen, M.Nickname, M.FirstSeen, M.FirstMessage FROM members M RIGHT OUTER JOIN users U ON U.ID = M.ID WHERE M.ID = ? AND M.Guild = ?")
+	db.sqlFindGuildUsers, err = db.prepare("FindGuildUsers", "SELECT DISTINCT M.ID FROM members M LEFT OUTER JOIN aliases A ON A.User = M.ID WHERE M.Guild = ? AND (M.Nickname LIKE ? OR A.Alias LIKE ?) LIMIT ? OFFSET ?")
+	db.sqlFindUser, err = db.prepare("FindUser", "SELECT DISTINCT U.ID FROM users U WHERE U.Discriminator = ? and U.Username LIKE ? LIMIT ? OFFSET ?")
+	db.sqlGetNewestUsers, err = db.prepare("GetNewestUsers", "SELECT U.ID, U.Username, U.Avatar, M.FirstSeen FROM members M INNER JOIN users U ON M.ID = U.ID WHERE M.Guild = ? ORDER BY M.FirstSeen DESC LIMIT ?")
+	db.sqlGetRecentUsers, err = db.prepare("GetRecentUsers", "SELECT U.ID, U.Username, U.Avatar FROM members M INNER JOIN users U ON M.ID = U.ID WHERE M.Guild = ? AND M.FirstSeen > ? ORDER BY M.FirstSeen DESC")
+	db.sqlGetAliases, err = db.prepare("GetAliases", "SELECT Alias FROM aliases WHERE User = ? ORDER BY Duration DESC LIMIT 10")
+	db.sqlAddTranscript, err = db.prepare("AddTranscript", "INSERT INTO transcripts (Season, Episode, Line, Speaker, Text) VALUES (?,?,?,?,?)")
+	db.sqlGetTranscript, err = db.prepare("GetTranscript", "SELECT Season, Episode, Line, Speaker, Text FROM transcripts WHERE Season = ? AND Episode = ? AND Line >= ? AND LINE <= ?")
+	db.sqlRemoveTranscript, err = db.prepare("RemoveTranscript", "DELETE FROM transcripts WHERE Season = ? AND Episode = ? AND Line = ?")
+	db.sqlGetMarkovWord, err = db.prepare("GetMarkovWord", "SELECT Phrase FROM markov_transcripts WHERE SpeakerID = (SELECT ID FROM markov_transcripts_speaker WHERE Speaker = ?) AND Phrase = ?")
+	db.sqlGetRandomQuoteInt, err = db.prepare("GetRandomQuoteInt", "SELECT FLOOR(RAND()*(SELECT COUNT(*) FROM transcripts WHERE Text != ''))")
+	db.sqlGetRandomQuote, err = db.prepare("GetRandomQuote", "SELECT * FROM transcripts WHERE Text != '' LIMIT 1 OFFSET ?")
+	db.sqlGetSpeechQuoteInt, err = db.prepare("GetSpeechQuoteInt", "SELECT FLOOR(RAND()*(SELECT COUNT(*) FROM transcripts WHERE Speaker != 'ACTION' AND Text != ''))")
+	db.sqlGetSpeechQuote, err = db.prepare("GetSpeechQuote", "SELECT * FROM transcripts WHERE Speaker != 'ACTION' AND Text != '' LIMIT 1 OFFSET ?")
+	db.sqlGetCharacterQuoteInt, err = db.prepare("GetCharacterQuoteInt", "SELECT FLOOR(RAND()*(SELECT COUNT(*) FROM transcripts WHERE Speaker = ? AND Text != ''))")
+	db.sqlGetCharacterQuote, err = db.prepare("GetCharacterQuote", "SELECT * FROM transcripts WHERE Speaker = ? AND Text != '' LIMIT 1 OFFSET ?")
+	db.sqlGetRandomSpeakerInt, err = db.prepare("GetRandomSpeakerInt", "SELECT FLOOR(RAND()*(SELECT COUNT(*) FROM markov_transcripts_speaker))")
+	db.sqlGetRandomSpeaker, err = db.prepare("GetRandomSpeaker", "SELECT Speaker FROM markov_transcripts_speaker LIMIT 1 OFFSET ?")
+	db.sqlGetRandomMemberInt, err = db.prepare("GetRandomMemberInt", "SELECT FLOOR(RAND()*(SELECT COUNT(*) FROM members WHERE Guild = ?))")
+	db.sqlGetRandomMember, err = db.prepare("GetRandomMember", "SELECT U.Username FROM members M INNER JOIN users U ON M.ID = U.ID WHERE M.Guild = ? LIMIT 1 OFFSET ?")
+	db.sqlGetRandomWordInt, err = db.prepare("GetRandomWordInt", "SELECT FLOOR(RAND()*(SELECT COUNT(*) FROM randomwords))")
+	db.sqlGetRandomWord, err = db.prepare("GetRandomWord", "SELECT Phrase FROM randomwords LIMIT 1 OFFSET ?;")
+	db.sqlGetTableCounts, err = db.prepare("GetTableCounts", "SELECT CONCAT('Chatlog: ', (SELECT COUNT(*) FROM chatlog), ' rows', '\nEditlog: ', (SELECT COUNT(*) FROM editlog), ' rows',  '\nAliases: ', (SELECT COUNT(*) FROM aliases), ' rows',  '\nDebuglog: ', (SELECT COUNT(*) FROM debuglog), ' rows',  '\nUsers: ', (SELECT COUNT(*) FROM users), ' rows',  '\nSchedule: ', (SELECT COUNT(*) FROM schedule), ' rows \nMembers: ', (SELECT COUNT(*) FROM members), ' rows \nItems: ', (SELECT COUNT(*) FROM items), ' rows \nTags: ', (SELECT COUNT(*) FROM tags), ' rows \nitemtags: ', (SELECT COUNT(*) FROM itemtags), ' rows');")
+	db.sqlCountNewUsers, err = db.prepare("CountNewUsers", "SELECT COUNT(*) FROM members WHERE FirstSeen > DATE_SUB(UTC_TIMESTAMP(), INTERVAL ? SECOND) AND Guild = ?")
+	db.sqlAudit, err = db.prepare("Audit", "INSERT INTO debuglog (Type, User, Message, Timestamp, Guild) VALUE(?, ?, ?, UTC_TIMESTAMP(), ?)")
+	db.sqlGetAuditRows, err = db.prepare("GetAuditRows", "SELECT U.Username, D.Message, D.Timestamp, U.ID FROM debuglog D INNER JOIN users U ON D.User = U.ID WHERE D.Type = ? AND D.Guild = ? ORDER BY D.Timestamp DESC LIMIT ? OFFSET ?")
+	db.sqlGetAuditRowsUser, err = db.prepare("GetAuditRowsUser", "SELECT U.Username, D.Message, D.Timestamp, U.ID FROM debuglog D INNER JOIN users U ON D.User = U.ID WHERE D.Type = ? AND D.Guild = ? AND D.User = ? ORDER BY D.Timestamp DESC LIMIT ? OFFSET ?")
+	db.sqlGetAuditRowsString, err = db.prepare("GetAuditRowsString", "SELECT U.Username, D.Message, D.Timestamp, U.ID FROM debuglog D INNER JOIN users U ON D.User = U.ID WHERE D.Type = ? AND D.Guild = ? AND D.Message LIKE ? ORDER BY D.Timestamp DESC LIMIT ? OFFSET ?")
+	db.sqlGetAuditRowsUserString, err = db.prepare("GetAuditRowsUserString", "SELECT U.Username, D.Message, D.Timestamp, U.ID FROM debuglog D INNER JOIN users U ON D.User = U.ID WHERE D.Type = ? AND D.Guild = ? AND D.User = ? AND D.Message LIKE ? ORDER BY D.Timestamp DESC LIMIT ? OFFSET ?")
+	db.sqlAddSchedule, err = db.prepare("AddSchedule", "INSERT INTO schedule (Guild, Date, Type, Data) VALUES (?, ?, ?, ?)")
+	db.sqlAddScheduleRepeat, err = db.prepare("AddScheduleRepeat", "INSERT INTO schedule (Guild, Date, `RepeatInterval`, `Repeat`, Type, Data) VALUES (?, ?, ?, ?, ?, ?)")
+	db.sqlGetSchedule, err = db.prepare("GetSchedule", "SELECT ID, Date, Type, Data FROM schedule WHERE Guild = ? AND Date <= UTC_TIMESTAMP() ORDER BY Date ASC")
+	db.sqlDeleteSchedule, err = db.prepare("DeleteSchedule", "DELETE FROM `schedule` WHERE ID = ?")
+	db.sqlCountEvents, err = db.prepare("CountEvents", "SELECT COUNT(*) FROM schedule WHERE Guild = ?")
+	db.sqlGetEvent, err = db.prepare("GetEvent", "SELECT ID, Date, Type, Data FROM schedule WHERE Guild = ? AND ID = ?")
+	db.sqlGetEvents, err = db.prepare("GetEvents", "SELECT ID, Date, Type, Data FROM schedule WHERE Guild = ? AND Type != 0 AND Type != 4 AND Type != 6 AND Type != 8 AND Type != 10 ORDER BY Date ASC LIMIT ?")
+	db.sqlGetEventsByType, err = db.prepare("GetEventsByType", "SELECT ID, Date, Type, Data FROM schedule WHERE Guild = ? AND Type = ? ORDER BY Date ASC LIMIT ?")
+	db.sqlGetNextEvent, err = db.prepare("GetNextEvent", "SELECT ID, Date, Type, Data FROM schedule WHERE Guild = ? AND Type = ? ORDER BY Date ASC LIMIT 1")
+	db.sqlGetReminders, err = db.prepare("GetReminders", "SELECT ID, Date, Type, Data FROM schedule WHERE Guild = ? AND Type = 6 AND Data LIKE ? ORDER BY Date ASC LIMIT ?")
+	db.sqlGetScheduleDate, err = db.prepare("GetScheduleDate", "SELECT Date FROM schedule WHERE Guild = ? AND Type = ? AND Data = ?")
+	db.sqlGetTimeZone, err = db.prepare("GetTimeZone", "SELECT Location FROM users WHERE ID = ?")
+	db.sqlFindTimeZone, err = db.prepare("FindTimeZone", "SELECT Location FROM timezones WHERE Location LIKE ?")
+	db.sqlFindTimeZoneOffset, err = db.prepare("FindTimeZoneOffset", "SELECT Location FROM timezones WHERE Location LIKE ? AND (Offset = ? OR DST = ?)")
+	db.sqlSetTimeZone, err = db.prepare("SetTimeZone", "UPDATE users SET Location = ? WHERE ID = ?")
+	db.sqlRemoveAlias, err = db.prepare("RemoveAlias", "DELETE FROM aliases WHERE User = ? AND Alias = ?")
+	db.sqlGetUserGuilds, err = db.prepare("GetUserGuilds", "SELECT Guild FROM members WHERE ID = ?")
+	db.sqlFindEvent, err = db.prepare("FindEvent", "SELECT ID FROM `schedule` WHERE `Type` = ? AND `Data` = ? AND `Guild` = ?")
+	db.sqlSetDefaultServer, err = db.prepare("SetDefaultServer", "UPDATE users SET DefaultServer = ? WHERE ID = ?")
+	db.sqlSentMessage, err = db.prepare("SentMessage", "UPDATE `members` SET `FirstMessage` = UTC_TIMESTAMP() WHERE ID = ? AND Guild = ? AND `FirstMessage` IS NULL")
+	db.sqlGetNewcomers, err = db.prepare("GetNewcomers", "SELECT ID FROM `members` WHERE `Guild` = ? AND `FirstMessage` > DATE_SUB(UTC_TIMESTAMP(), INTERVAL ? SECOND)")
+	db.sqlGetItem, err = db.prepare("GetItem", "SELECT ID FROM items WHERE Content = ?")
+	db.sqlRemoveItem, err = db.prepare("RemoveItem", "DELETE M FROM itemtags M INNER JOIN tags T ON M.Tag = T.ID WHERE M.Item = ? AND T.Guild = ?")
+	db.sqlAddTag, err = db.prepare("AddTag", "INSERT INTO itemtags (Item, Tag) VALUES (?, ?)")
+	db.sqlRemoveTag, err = db.prepare("RemoveTag", "DELETE FROM itemtags WHERE Item = ? AND Tag = ?")
+	db.sqlCreateTag, err = db.prepare("CreateTag", "INSERT INTO tags (Name, Guild) VALUES (?, ?)")
+	db.sqlDeleteTag, err = db.prepare("DeleteTag", "DELETE FROM tags WHERE Name = ? AND Guild = ?")
+	db.sqlGetTag, err = db.prepare("GetTag", "SELECT ID FROM tags WHERE Name = ? AND Guild = ?")
+	db.sqlCountTag, err = db.prepare("CountTag", "SELECT COUNT(*) FROM itemtags WHERE Tag = ?")
+	db.sqlPickTag, err = db.prepare("PickTag", "SELECT I.Content FROM itemtags M INNER JOIN tags T ON M.Tag = T.ID INNER JOIN items I ON M.Item = I.ID WHERE T.Name = ? AND T.Guild = ? ORDER BY RAND() LIMIT 1")
+	db.sqlCountItems, err = db.prepare("CountItems", "SELECT COUNT(DISTINCT M.Item) FROM itemtags M INNER JOIN tags T ON M.Tag = T.ID WHERE T.Guild = ?")
+	db.sqlGetItemTags, err = db.prepare("GetItemTags", "SELECT T.Name FROM itemtags M INNER JOIN tags T ON M.Tag = T.ID WHERE M.Item = ? AND T.Guild = ?")
+	db.sqlGetTags, err = db.prepare("GetTags", "SELECT T.Name, COUNT(M.Item) FROM tags T LEFT OUTER JOIN itemtags M ON T.ID = M.Tag WHERE T.Guild = ? GROUP BY T.Name")
+	db.sqlImportTag, err = db.prepare("ImportTag", "INSERT IGNORE INTO itemtags (Item, Tag) SELECT Item, ? FROM itemtags WHERE Tag = ?")
+	db.sqlNextCase, err = db.prepare("NextCase", "SELECT COALESCE(MAX(ID), 0) + 1 FROM cases WHERE Guild = ?")
+	db.sqlAddCase, err = db.prepare("AddCase", "INSERT INTO cases (Guild, ID, Action, Target, Moderator, Reason, Duration, Points, Messages, Timestamp) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, UTC_TIMESTAMP())")
+	db.sqlGetCase, err = db.prepare("GetCase", "SELECT ID, Action, Target, Moderator, Reason, Duration, Points, Messages, Timestamp FROM cases WHERE Guild = ? AND ID = ?")
+	db.sqlGetCases, err = db.prepare("GetCases", "SELECT ID, Action, Target, Moderator, Reason, Duration, Points, Messages, Timestamp FROM cases WHERE Guild = ? ORDER BY ID DESC LIMIT ? OFFSET ?")
+	db.sqlGetTargetCases, err = db.prepare("GetTargetCases", "SELECT ID, Action, Target, Moderator, Reason, Duration, Points, Messages, Timestamp FROM cases WHERE Guild = ? AND Target = ? ORDER BY ID DESC LIMIT ? OFFSET ?")
+	db.sqlSetCaseReason, err = db.prepare("SetCaseReason", "UPDATE cases SET Reason = ? WHERE Guild = ? AND ID = ?")
+	db.sqlGetWarnings, err = db.prepare("GetWarnings", "SELECT Points, Timestamp FROM cases WHERE Guild = ? AND Target = ? AND Action = ? ORDER BY ID ASC")
+	db.sqlGetMessage, err = db.prepare("GetMessage", "SELECT Author, Message, Channel, Timestamp FROM chatlog WHERE ID = ?")
+	db.sqlGetLastEdit, err = db.prepare("GetLastEdit", "SELECT Message FROM editlog WHERE ID = ? ORDER BY Timestamp DESC LIMIT 1")
+	db.sqlNextConfigVersion, err = db.prepare("NextConfigVersion", "SELECT COALESCE(MAX(Version), 0) + 1 FROM confighistory WHERE Guild = ?")
+	db.sqlAddConfigVersion, err = db.prepare("AddConfigVersion", "INSERT INTO confighistory (Guild, Version, Author, Changes, Config, Timestamp) VALUES (?, ?, ?, ?, ?, UTC_TIMESTAMP())")
+	db.sqlGetConfigVersion, err = db.prepare("GetConfigVersion", "SELECT Config FROM confighistory WHERE Guild = ? AND Version = ?")
+	db.sqlGetConfigHistory, err = db.prepare("GetConfigHistory", "SELECT Version, Author, Changes, Timestamp FROM confighistory WHERE Guild = ? ORDER BY Version DESC LIMIT ? OFFSET ?")
+	db.sqlPruneConfigHistory, err = db.prepare("PruneConfigHistory", "DELETE FROM confighistory WHERE Guild = ? AND Version <= ?")
+	db.sqlAddVoiceTime, err = db.prepare("AddVoiceTime", "INSERT INTO voicetime (Guild, ID, Seconds, Sessions, LastSeen) VALUES (?, ?, ?, 1, UTC_TIMESTAMP()) ON DUPLICATE KEY UPDATE Seconds = Seconds + ?, Sessions = Sessions + 1, LastSeen = UTC_TIMESTAMP()")
+	db.sqlGetVoiceTime, err = db.prepare("GetVoiceTime", "SELECT ID, Seconds, Sessions, LastSeen FROM voicetime WHERE Guild = ? AND ID = ?")
+	db.sqlGetTopVoiceTime, err = db.prepare("GetTopVoiceTime", "SELECT ID, Seconds, Sessions, LastSeen FROM voicetime WHERE Guild = ? ORDER BY Seconds DESC LIMIT ?")
+	db.sqlAddTempChannel, err = db.prepare("AddTempChannel", "INSERT INTO tempchannels (Channel, Guild, Owner, Created) VALUES (?, ?, ?, UTC_TIMESTAMP())")
+	db.sqlRemoveTempChannel, err = db.prepare("RemoveTempChannel", "DELETE FROM tempchannels WHERE Channel = ?")
+	db.sqlGetTempChannels, err = db.prepare("GetTempChannels", "SELECT Channel, Owner, Created FROM tempchannels WHERE Guild = ?")
+	db.sqlSetCooldown, err = db.prepare("SetCooldown", "REPLACE INTO cooldowns (Guild, Scope, Command, Expires) VALUES (?, ?, ?, ?)")
+	db.sqlGetCooldowns, err = db.prepare("GetCooldowns", "SELECT Scope, Command, Expires FROM cooldowns WHERE Guild = ? AND Expires > ?")
+	db.sqlPruneCooldowns, err = db.prepare("PruneCooldowns", "DELETE FROM cooldowns WHERE Guild = ? AND Expires <= ?")
+	if err != nil {
+		return err
+	}
+	return db.backend().Prepare(db)
 }
 
 // Audit types
@@ -299,6 +378,7 @@ func (db *BotDB) parseStringResults(q *sql.Rows) []string {
 // CheckError logs any unknown errors and pings the database to check if it's still there
 func (db *BotDB) CheckError(name string, err error) error {
 	if err != nil && err != sql.ErrNoRows && err != sql.ErrTxDone && err != ErrDuplicateEntry {
+		metricStatementErrors.Add(1, name)
 		if db.Status.Get() {
 			db.log.LogError(name+" error: ", err)
 		}
@@ -311,8 +391,36 @@ func (db *BotDB) CheckError(name string, err error) error {
 
 // AddMessage logs a message to the chatlog
 func (db *BotDB) AddMessage(id uint64, author *discordgo.User, message string, channel uint64, guild uint64) {
-	_, err := db.sqlAddMessage.Exec(id, SBatoi(author.ID), author.Username, message, channel, guild)
-	db.CheckError("AddMessage", err)
+	err := db.backend().AddChat(id, SBatoi(author.ID), author.Username, message, channel, guild)
+	db.CheckError("AddMessage", db.standardErr(err))
+}
+
+// LoggedMessage is a message as it was last stored in the chatlog
+type LoggedMessage struct {
+	Author    uint64
+	Message   string
+	Channel   uint64
+	Timestamp time.Time
+}
+
+// GetMessage gets a message from the chatlog, or nil if it was never logged
+func (db *BotDB) GetMessage(id uint64) *LoggedMessage {
+	m := &LoggedMessage{}
+	err := db.sqlGetMessage.QueryRow(id).Scan(&m.Author, &m.Message, &m.Channel, &m.Timestamp)
+	if err == sql.ErrNoRows || db.CheckError("GetMessage", err) != nil {
+		return nil
+	}
+	return m
+}
+
+// GetLastEdit gets what a message said before it was last edited, which the chatlog trigger saves in the editlog
+func (db *BotDB) GetLastEdit(id uint64) (string, bool) {
+	var message string
+	err := db.sqlGetLastEdit.QueryRow(id).Scan(&message)
+	if err == sql.ErrNoRows || db.CheckError("GetLastEdit", err) != nil {
+		return "", false
+	}
+	return message, true
 }
 
 // PingContext contains a simplified context for a message
@@ -324,14 +432,14 @@ type PingContext struct {
 
 // AddUser adds or updates user information
 func (db *BotDB) AddUser(id uint64, username string, discriminator int, avatar string, isonline bool) {
-	_, err := db.sqlAddUser.Exec(id, username, discriminator, avatar, isonline)
-	db.CheckError("AddUser", err)
+	err := db.backend().AddUser(id, username, discriminator, avatar, isonline)
+	db.CheckError("AddUser", db.standardErr(err))
 }
 
 // AddMember adds or updates guild-specific user information
 func (db *BotDB) AddMember(id uint64, guild uint64, firstseen time.Time, nickname string) {
-	_, err := db.sqlAddMember.Exec(id, guild, firstseen, nickname)
-	db.CheckError("AddMember", err)
+	err := db.backend().AddMember(id, guild, firstseen, nickname)
+	db.CheckError("AddMember", db.standardErr(err))
 }
 
 // SawUser updates a user's lastseen time
@@ -500,7 +608,7 @@ func (db *BotDB) Audit(ty uint8, user *discordgo.User, message string, guild uin
 	}
 
 	if err != nil && db.Status.Get() {
-		fmt.Println("Logger failed to log to database! ", err.Error())
+		db.log.console().LogError("Logger failed to log to database! ", err) // Logging this to the database would just fail again
 	}
 }
 
@@ -594,16 +702,19 @@ func (db *BotDB) RemoveTranscript(season int, episode int, line int) {
 
 // AddMarkov adds a line to the markov chain
 func (db *BotDB) AddMarkov(last uint64, last2 uint64, speaker string, text string) uint64 {
-	var id uint64
-	err := db.sqlAddMarkov.QueryRow(last, last2, speaker, text).Scan(&id)
-	db.CheckError("AddMarkov", err)
+	id, err := db.backend().AddMarkov(last, last2, speaker, text)
+	db.CheckError("AddMarkov", db.standardErr(err))
 	return id
 }
 
+// ResetMarkov clears the markov chain so it can be rebuilt
+func (db *BotDB) ResetMarkov() error {
+	return db.CheckError("ResetMarkov", db.standardErr(db.backend().ResetMarkov()))
+}
+
 // GetMarkovLine generates a line from the markov chain
 func (db *BotDB) GetMarkovLine(last uint64) (string, uint64) {
-	var r sql.NullString
-	err := db.sqlGetMarkovLine.QueryRow(last).Scan(&r)
+	r, err := db.backend().GetMarkovLine(last)
 	if db.CheckError("GetMarkovLine", err) != nil || !r.Valid {
 		return "", 0
 	}
@@ -616,8 +727,7 @@ func (db *BotDB) GetMarkovLine(last uint64) (string, uint64) {
 
 // GetMarkovLine2 generates a line from the markov chain
 func (db *BotDB) GetMarkovLine2(last uint64, last2 uint64) (string, uint64, uint64) {
-	var r sql.NullString
-	err := db.sqlGetMarkovLine2.QueryRow(last, last2).Scan(&r)
+	r, err := db.backend().GetMarkovLine2(last, last2)
 	if db.CheckError("GetMarkovLine2", err) != nil || !r.Valid {
 		return "", 0, 0
 	}
@@ -723,8 +833,8 @@ func (db *BotDB) CountNewUsers(seconds int64, guild uint64) int {
 
 // RemoveSchedule removes the event with the given ID and creates a new one after the repeat interval
 func (db *BotDB) RemoveSchedule(id uint64) error {
-	_, err := db.sqlRemoveSchedule.Exec(id)
-	return db.CheckError("RemoveSchedule", err)
+	err := db.backend().RemoveSchedule(id)
+	return db.CheckError("RemoveSchedule", db.standardErr(err))
 }
 
 // DeleteSchedule deletes the event with the given ID regardless of it's repeat interval or activation time.
@@ -733,6 +843,10 @@ func (db *BotDB) DeleteSchedule(id uint64) error {
 	return db.CheckError("DeleteSchedule", err)
 }
 
+// ScheduleUnsilence is the type of schedule event used for silence timeouts set by the spam filter. The spam module
+// adds them and the scheduler module lifts the silence once they're due.
+const ScheduleUnsilence = 10
+
 // AddSchedule adds an event to the schedule
 func (db *BotDB) AddSchedule(guild uint64, date time.Time, ty uint8, data string) error {
 	var i int
@@ -994,8 +1108,8 @@ func (db *BotDB) GetNewcomers(lookback int, guild uint64) []uint64 {
 
 // AddItem adds an item or just returns the ID if it already exists.
 func (db *BotDB) AddItem(item string) (uint64, error) {
-	var id uint64
-	err := db.standardErr(db.sqlAddItem.QueryRow(item).Scan(&id))
+	id, err := db.backend().AddItem(item)
+	err = db.standardErr(err)
 
 	if db.CheckError("AddItem", err) != nil {
 		return 0, err
@@ -1063,6 +1177,16 @@ func (db *BotDB) CountTag(tag uint64) (uint64, error) {
 	return n, nil
 }
 
+// PickTag returns a random item with the given tag on the given server, or sql.ErrNoRows if the tag has no items
+func (db *BotDB) PickTag(tag string, guild uint64) (string, error) {
+	var item string
+	err := db.standardErr(db.sqlPickTag.QueryRow(tag, guild).Scan(&item))
+	if err == sql.ErrNoRows || db.CheckError("PickTag", err) != nil {
+		return "", err
+	}
+	return item, nil
+}
+
 // CountItems returns the number of unique items for a given server
 func (db *BotDB) CountItems(guild uint64) (uint64, error) {
 	var n uint64
@@ -1131,7 +1255,284 @@ func (db *BotDB) ImportTag(srcTag uint64, destTag uint64) error {
 
 // RemoveGuild removes the given guild from the database, if it exists
 func (db *BotDB) RemoveGuild(guild uint64) error {
-	_, err := db.sqlRemoveGuild.Exec(guild)
-	err = db.standardErr(err)
+	err := db.standardErr(db.backend().RemoveGuild(guild))
 	return db.CheckError("RemoveGuild", err)
 }
+
+// ModCase is a single moderation action recorded in the cases table
+type ModCase struct {
+	ID        uint64
+	Action    CaseAction
+	Target    uint64 // The user the action was taken against, or the channel for wipes
+	Moderator uint64 // Either the moderator who took the action, or the bot itself
+	Reason    string
+	Duration  string
+	Points    int      // Warning points, which are only given by warnings
+	Messages  []string // Messages relevant to the case, stored as channel/message ID pairs
+	Timestamp time.Time
+}
+
+// AddCase records a moderation action and returns the case number it was given. Case numbers count up from 1 on
+// each guild, so if another case is added at the same time, we simply try the next number.
+func (db *BotDB) AddCase(guild uint64, c *ModCase) (uint64, error) {
+	var err error
+	for i := 0; i < 5; i++ {
+		var id uint64
+		err = db.sqlNextCase.QueryRow(guild).Scan(&id)
+		if db.CheckError("NextCase", err) != nil {
+			return 0, err
+		}
+		_, err = db.sqlAddCase.Exec(guild, id, c.Action, c.Target, c.Moderator, c.Reason, c.Duration, c.Points, strings.Join(c.Messages, " "))
+		err = db.standardErr(err)
+		if err != ErrDuplicateEntry {
+			return id, db.CheckError("AddCase", err)
+		}
+	}
+	return 0, err
+}
+
+func (db *BotDB) parseCases(q *sql.Rows) []ModCase {
+	r := make([]ModCase, 0, 5)
+	for q.Next() {
+		p := ModCase{}
+		var messages string
+		if err := q.Scan(&p.ID, &p.Action, &p.Target, &p.Moderator, &p.Reason, &p.Duration, &p.Points, &messages, &p.Timestamp); err == nil {
+			p.Messages = strings.Fields(messages)
+			r = append(r, p)
+		}
+	}
+	return r
+}
+
+// GetCase gets the given case number, or nil if it doesn't exist
+func (db *BotDB) GetCase(guild uint64, id uint64) *ModCase {
+	q, err := db.sqlGetCase.Query(guild, id)
+	if db.CheckError("GetCase", err) != nil {
+		return nil
+	}
+	defer q.Close()
+	r := db.parseCases(q)
+	if len(r) == 0 {
+		return nil
+	}
+	return &r[0]
+}
+
+// GetCases gets the most recent cases on a guild, or only the cases against target if it isn't 0
+func (db *BotDB) GetCases(guild uint64, target uint64, maxresults int, offset int) []ModCase {
+	var q *sql.Rows
+	var err error
+	if target != 0 {
+		q, err = db.sqlGetTargetCases.Query(guild, target, maxresults, offset)
+	} else {
+		q, err = db.sqlGetCases.Query(guild, maxresults, offset)
+	}
+	if db.CheckError("GetCases", err) != nil {
+		return []ModCase{}
+	}
+	defer q.Close()
+	return db.parseCases(q)
+}
+
+// SetCaseReason changes the reason given for a case
+func (db *BotDB) SetCaseReason(guild uint64, id uint64, reason string) error {
+	_, err := db.sqlSetCaseReason.Exec(reason, guild, id)
+	return db.CheckError("SetCaseReason", err)
+}
+
+// Warning is the number of points a single warning gave someone, and when
+type Warning struct {
+	Points    int
+	Timestamp time.Time
+}
+
+// GetWarnings gets every warning given to a user, oldest first
+func (db *BotDB) GetWarnings(guild uint64, user uint64) []Warning {
+	q, err := db.sqlGetWarnings.Query(guild, user, CaseWarn)
+	if db.CheckError("GetWarnings", err) != nil {
+		return []Warning{}
+	}
+	defer q.Close()
+	r := make([]Warning, 0, 5)
+	for q.Next() {
+		p := Warning{}
+		if err := q.Scan(&p.Points, &p.Timestamp); err == nil {
+			r = append(r, p)
+		}
+	}
+	return r
+}
+
+// ConfigRevision is a single snapshot in a guild's config history, without the config itself
+type ConfigRevision struct {
+	Version   uint64
+	Author    uint64 // The user who saved this version, or 0 if the bot saved it on its own
+	Changes   string // A description of every key that changed, one per line
+	Timestamp time.Time
+}
+
+// AddConfigVersion stores a snapshot of a guild's config and returns the version number it was given. Like case
+// numbers, version numbers count up from 1 on each guild. Once a guild has more than keep versions, the oldest are
+// deleted.
+func (db *BotDB) AddConfigVersion(guild uint64, author uint64, changes string, config []byte, keep uint64) (uint64, error) {
+	var err error
+	for i := 0; i < 5; i++ {
+		var version uint64
+		err = db.sqlNextConfigVersion.QueryRow(guild).Scan(&version)
+		if db.CheckError("NextConfigVersion", err) != nil {
+			return 0, err
+		}
+		_, err = db.sqlAddConfigVersion.Exec(guild, version, author, changes, string(config))
+		err = db.standardErr(err)
+		if err != ErrDuplicateEntry {
+			if err == nil && version > keep {
+				_, err = db.sqlPruneConfigHistory.Exec(guild, version-keep)
+			}
+			return version, db.CheckError("AddConfigVersion", err)
+		}
+	}
+	return 0, err
+}
+
+// GetConfigVersion gets the config that was saved as the given version, or nil if it doesn't exist
+func (db *BotDB) GetConfigVersion(guild uint64, version uint64) []byte {
+	var config string
+	err := db.sqlGetConfigVersion.QueryRow(guild, version).Scan(&config)
+	if err == sql.ErrNoRows || db.CheckError("GetConfigVersion", err) != nil {
+		return nil
+	}
+	return []byte(config)
+}
+
+// GetConfigHistory lists a guild's config versions, newest first
+func (db *BotDB) GetConfigHistory(guild uint64, maxresults int, offset int) []ConfigRevision {
+	q, err := db.sqlGetConfigHistory.Query(guild, maxresults, offset)
+	if db.CheckError("GetConfigHistory", err) != nil {
+		return []ConfigRevision{}
+	}
+	defer q.Close()
+	r := make([]ConfigRevision, 0, maxresults)
+	for q.Next() {
+		p := ConfigRevision{}
+		if err := q.Scan(&p.Version, &p.Author, &p.Changes, &p.Timestamp); err == nil {
+			r = append(r, p)
+		}
+	}
+	return r
+}
+
+// VoiceTime is how long a member has spent in voice channels on a guild
+type VoiceTime struct {
+	User     uint64
+	Seconds  uint64
+	Sessions uint64 // How many times they joined voice
+	LastSeen time.Time
+}
+
+// AddVoiceTime records a voice session that just ended
+func (db *BotDB) AddVoiceTime(guild uint64, user uint64, seconds uint64) error {
+	_, err := db.sqlAddVoiceTime.Exec(guild, user, seconds, seconds)
+	return db.CheckError("AddVoiceTime", err)
+}
+
+// GetVoiceTime gets a member's voice statistics, or nil if they've never been in a voice channel
+func (db *BotDB) GetVoiceTime(guild uint64, user uint64) *VoiceTime {
+	p := &VoiceTime{}
+	err := db.sqlGetVoiceTime.QueryRow(guild, user).Scan(&p.User, &p.Seconds, &p.Sessions, &p.LastSeen)
+	if err == sql.ErrNoRows || db.CheckError("GetVoiceTime", err) != nil {
+		return nil
+	}
+	return p
+}
+
+// GetTopVoiceTime lists the members who have spent the most time in voice channels
+func (db *BotDB) GetTopVoiceTime(guild uint64, maxresults int) []VoiceTime {
+	q, err := db.sqlGetTopVoiceTime.Query(guild, maxresults)
+	if db.CheckError("GetTopVoiceTime", err) != nil {
+		return []VoiceTime{}
+	}
+	defer q.Close()
+	r := make([]VoiceTime, 0, maxresults)
+	for q.Next() {
+		p := VoiceTime{}
+		if err := q.Scan(&p.User, &p.Seconds, &p.Sessions, &p.LastSeen); err == nil {
+			r = append(r, p)
+		}
+	}
+	return r
+}
+
+// TempChannel is a voice channel that was created for someone and should be deleted once it's empty
+type TempChannel struct {
+	Channel uint64
+	Owner   uint64
+	Created time.Time
+}
+
+// AddTempChannel remembers a temporary voice channel, so it can be cleaned up even after a restart
+func (db *BotDB) AddTempChannel(guild uint64, channel uint64, owner uint64) error {
+	_, err := db.sqlAddTempChannel.Exec(channel, guild, owner)
+	return db.CheckError("AddTempChannel", err)
+}
+
+// RemoveTempChannel forgets a temporary voice channel
+func (db *BotDB) RemoveTempChannel(channel uint64) error {
+	_, err := db.sqlRemoveTempChannel.Exec(channel)
+	return db.CheckError("RemoveTempChannel", err)
+}
+
+// GetTempChannels lists every temporary voice channel on a guild
+func (db *BotDB) GetTempChannels(guild uint64) []TempChannel {
+	q, err := db.sqlGetTempChannels.Query(guild)
+	if db.CheckError("GetTempChannels", err) != nil {
+		return []TempChannel{}
+	}
+	defer q.Close()
+	r := []TempChannel{}
+	for q.Next() {
+		p := TempChannel{}
+		if err := q.Scan(&p.Channel, &p.Owner, &p.Created); err == nil {
+			r = append(r, p)
+		}
+	}
+	return r
+}
+
+// Cooldown stores when a command can be used again by whoever the scope refers to
+type Cooldown struct {
+	Scope   string
+	Command string
+	Expires time.Time
+}
+
+// SetCooldown saves when a cooldown expires, replacing the previous one for the same scope and command
+func (db *BotDB) SetCooldown(guild uint64, scope string, command string, expires time.Time) error {
+	_, err := db.sqlSetCooldown.Exec(guild, scope, command, expires)
+	return db.CheckError("SetCooldown", err)
+}
+
+// PruneCooldowns deletes every cooldown on a guild that expired before now
+func (db *BotDB) PruneCooldowns(guild uint64, now time.Time) error {
+	_, err := db.sqlPruneCooldowns.Exec(guild, now)
+	return db.CheckError("PruneCooldowns", err)
+}
+
+// GetCooldowns deletes every cooldown on a guild that expired before now and returns the rest
+func (db *BotDB) GetCooldowns(guild uint64, now time.Time) []Cooldown {
+	if db.PruneCooldowns(guild, now) != nil {
+		return []Cooldown{}
+	}
+	q, err := db.sqlGetCooldowns.Query(guild, now)
+	if db.CheckError("GetCooldowns", err) != nil {
+		return []Cooldown{}
+	}
+	defer q.Close()
+	r := []Cooldown{}
+	for q.Next() {
+		p := Cooldown{}
+		if err := q.Scan(&p.Scope, &p.Command, &p.Expires); err == nil {
+			r = append(r, p)
+		}
+	}
+	return r
+}
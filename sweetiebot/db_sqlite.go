@@ -0,0 +1,518 @@
+package sweetiebot
+
+import (
+	"database/sql"
+	"math"
+	"math/rand"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+const sqliteDriverName = "sweetiebot_sqlite3"
+
+var registerSQLite sync.Once
+
+// sqliteTimeFormat matches the format go-sqlite3 uses when storing a time.Time, so values we generate inside SQL
+// compare correctly against values passed in as parameters.
+const sqliteTimeFormat = "2006-01-02 15:04:05.999999999-07:00"
+
+// The MySQL functions our statements rely on are registered as SQLite functions on every connection
+func sqliteUTCTimestamp() string {
+	return time.Now().UTC().Format(sqliteTimeFormat)
+}
+
+func sqliteUTCTimestampSub(amount int64, unit string) string {
+	t := time.Now().UTC()
+	switch strings.ToUpper(unit) {
+	case "SECOND":
+		t = t.Add(-time.Duration(amount) * time.Second)
+	case "MINUTE":
+		t = t.Add(-time.Duration(amount) * time.Minute)
+	case "HOUR":
+		t = t.Add(-time.Duration(amount) * time.Hour)
+	case "DAY":
+		t = t.AddDate(0, 0, -int(amount))
+	}
+	return t.Format(sqliteTimeFormat)
+}
+
+func sqliteConcat(args ...interface{}) string {
+	s := make([]string, len(args))
+	for i, v := range args {
+		switch x := v.(type) {
+		case nil:
+		case []byte:
+			s[i] = string(x)
+		case string:
+			s[i] = x
+		case int64:
+			s[i] = strconv.FormatInt(x, 10)
+		case float64:
+			s[i] = strconv.FormatFloat(x, 'f', -1, 64)
+		}
+	}
+	return strings.Join(s, "")
+}
+
+var sqliteRewrites = []struct {
+	regex   *regexp.Regexp
+	replace string
+}{
+	{regexp.MustCompile(`DATE_SUB\(UTC_TIMESTAMP\(\), INTERVAL (\?|[0-9]+) (SECOND|MINUTE|HOUR|DAY)\)`), "UTC_TIMESTAMP_SUB($1, '$2')"},
+	{regexp.MustCompile(`INSERT IGNORE`), "INSERT OR IGNORE"},
+	{regexp.MustCompile(`\bVALUE\(`), "VALUES("},
+}
+
+// Statements that can't be translated by simple substitution
+var sqliteOverrides = map[string]string{
+	"DELETE M FROM itemtags M INNER JOIN tags T ON M.Tag = T.ID WHERE M.Item = ? AND T.Guild = ?":                                                                                                            "DELETE FROM itemtags WHERE Item = ? AND Tag IN (SELECT ID FROM tags WHERE Guild = ?)",
+	"INSERT INTO voicetime (Guild, ID, Seconds, Sessions, LastSeen) VALUES (?, ?, ?, 1, UTC_TIMESTAMP()) ON DUPLICATE KEY UPDATE Seconds = Seconds + ?, Sessions = Sessions + 1, LastSeen = UTC_TIMESTAMP()": "INSERT INTO voicetime (Guild, ID, Seconds, Sessions, LastSeen) VALUES (?, ?, ?, 1, UTC_TIMESTAMP()) ON CONFLICT (Guild, ID) DO UPDATE SET Seconds = Seconds + ?, Sessions = Sessions + 1, LastSeen = UTC_TIMESTAMP()",
+}
+
+// sqliteSchema mirrors sweetiebot.sql. Every statement must be safe to run on an existing database, because it is
+// executed each time the bot starts.
+var sqliteSchema = []string{
+	"CREATE TABLE IF NOT EXISTS timezones (Location VARCHAR(40) NOT NULL PRIMARY KEY, Offset INTEGER NOT NULL, DST INTEGER NOT NULL)",
+	"CREATE TABLE IF NOT EXISTS users (ID BIGINT NOT NULL PRIMARY KEY, Username VARCHAR(128) NOT NULL DEFAULT '', Discriminator INTEGER NOT NULL DEFAULT 0, Avatar VARCHAR(512) NOT NULL DEFAULT '', LastSeen DATETIME NOT NULL, LastNameChange DATETIME NOT NULL, Location VARCHAR(40) DEFAULT NULL REFERENCES timezones (Location), DefaultServer BIGINT DEFAULT NULL)",
+	"CREATE INDEX IF NOT EXISTS INDEX_USERNAME ON users (Username)",
+	"CREATE TABLE IF NOT EXISTS aliases (User BIGINT NOT NULL REFERENCES users (ID), Alias VARCHAR(128) NOT NULL, Timestamp DATETIME NOT NULL, Duration BIGINT NOT NULL, PRIMARY KEY (User, Alias))",
+	"CREATE INDEX IF NOT EXISTS ALIASES_ALIAS ON aliases (Alias)",
+	"CREATE TABLE IF NOT EXISTS chatlog (ID BIGINT NOT NULL PRIMARY KEY, Author BIGINT NOT NULL REFERENCES users (ID), Message VARCHAR(2000) NOT NULL, Timestamp DATETIME NOT NULL, Channel BIGINT NOT NULL, Guild BIGINT NOT NULL)",
+	"CREATE INDEX IF NOT EXISTS CHATLOG_TIMESTAMP ON chatlog (Timestamp)",
+	"CREATE INDEX IF NOT EXISTS CHATLOG_CHANNEL ON chatlog (Channel)",
+	"CREATE TABLE IF NOT EXISTS editlog (ID BIGINT NOT NULL REFERENCES chatlog (ID), Timestamp DATETIME NOT NULL, Author BIGINT NOT NULL REFERENCES users (ID), Message VARCHAR(2000) NOT NULL, Channel BIGINT NOT NULL, Guild BIGINT NOT NULL, PRIMARY KEY (ID, Timestamp))",
+	"CREATE TABLE IF NOT EXISTS debuglog (ID INTEGER PRIMARY KEY AUTOINCREMENT, Type INTEGER NOT NULL, User BIGINT DEFAULT NULL REFERENCES users (ID), Message VARCHAR(4096) NOT NULL, Timestamp DATETIME NOT NULL, Guild BIGINT NOT NULL)",
+	"CREATE INDEX IF NOT EXISTS DEBUGLOG_TIMESTAMP ON debuglog (Timestamp)",
+	"CREATE TABLE IF NOT EXISTS items (ID INTEGER PRIMARY KEY AUTOINCREMENT, Content VARCHAR(500) NOT NULL)",
+	"CREATE INDEX IF NOT EXISTS CONTENT_INDEX ON items (Content)",
+	"CREATE TABLE IF NOT EXISTS tags (ID INTEGER PRIMARY KEY AUTOINCREMENT, Name VARCHAR(50) NOT NULL, Guild BIGINT NOT NULL, UNIQUE (Name, Guild))",
+	"CREATE TABLE IF NOT EXISTS itemtags (Item BIGINT NOT NULL REFERENCES items (ID), Tag BIGINT NOT NULL REFERENCES tags (ID), PRIMARY KEY (Item, Tag))",
+	"CREATE TABLE IF NOT EXISTS markov_transcripts_speaker (ID INTEGER PRIMARY KEY AUTOINCREMENT, Speaker VARCHAR(128) NOT NULL UNIQUE)",
+	"CREATE TABLE IF NOT EXISTS markov_transcripts (ID INTEGER PRIMARY KEY AUTOINCREMENT, SpeakerID INTEGER NOT NULL DEFAULT 0 REFERENCES markov_transcripts_speaker (ID), Phrase VARCHAR(64) NOT NULL, UNIQUE (SpeakerID, Phrase))",
+	"CREATE TABLE IF NOT EXISTS markov_transcripts_map (Prev INTEGER NOT NULL, Prev2 INTEGER NOT NULL, Next INTEGER NOT NULL, Count INTEGER NOT NULL DEFAULT 1, PRIMARY KEY (Prev, Next, Prev2))",
+	"CREATE TABLE IF NOT EXISTS members (ID BIGINT NOT NULL REFERENCES users (ID), Guild BIGINT NOT NULL, FirstSeen DATETIME NOT NULL, Nickname VARCHAR(128) NOT NULL DEFAULT '', FirstMessage DATETIME DEFAULT NULL, PRIMARY KEY (ID, Guild))",
+	"CREATE INDEX IF NOT EXISTS INDEX_GUILD_FIRSTSEEN ON members (Guild, FirstSeen)",
+	"CREATE TABLE IF NOT EXISTS schedule (ID INTEGER PRIMARY KEY AUTOINCREMENT, Guild BIGINT NOT NULL, Date DATETIME NOT NULL, RepeatInterval INTEGER DEFAULT NULL, `Repeat` INTEGER DEFAULT NULL, Type INTEGER NOT NULL, Data TEXT NOT NULL)",
+	"CREATE INDEX IF NOT EXISTS INDEX_GUILD_DATE_TYPE ON schedule (Date, Guild, Type)",
+	"CREATE TABLE IF NOT EXISTS cases (Guild BIGINT NOT NULL, ID BIGINT NOT NULL, Action INTEGER NOT NULL, Target BIGINT NOT NULL, Moderator BIGINT NOT NULL, Reason VARCHAR(1000) NOT NULL DEFAULT '', Duration VARCHAR(64) NOT NULL DEFAULT '', Points INTEGER NOT NULL DEFAULT 0, Messages TEXT NOT NULL, Timestamp DATETIME NOT NULL, PRIMARY KEY (Guild, ID))",
+	"CREATE INDEX IF NOT EXISTS INDEX_GUILD_TARGET ON cases (Guild, Target)",
+	"CREATE TABLE IF NOT EXISTS confighistory (Guild BIGINT NOT NULL, Version BIGINT NOT NULL, Author BIGINT NOT NULL, Changes TEXT NOT NULL, Config TEXT NOT NULL, Timestamp DATETIME NOT NULL, PRIMARY KEY (Guild, Version))",
+	"CREATE TABLE IF NOT EXISTS voicetime (Guild BIGINT NOT NULL, ID BIGINT NOT NULL, Seconds BIGINT NOT NULL DEFAULT 0, Sessions INTEGER NOT NULL DEFAULT 0, LastSeen DATETIME NOT NULL, PRIMARY KEY (Guild, ID))",
+	"CREATE TABLE IF NOT EXISTS tempchannels (Channel BIGINT NOT NULL PRIMARY KEY, Guild BIGINT NOT NULL, Owner BIGINT NOT NULL, Created DATETIME NOT NULL)",
+	"CREATE INDEX IF NOT EXISTS TEMPCHANNELS_GUILD ON tempchannels (Guild)",
+	"CREATE TABLE IF NOT EXISTS cooldowns (Guild BIGINT NOT NULL, Scope VARCHAR(64) NOT NULL, Command VARCHAR(64) NOT NULL, Expires DATETIME NOT NULL, PRIMARY KEY (Guild, Scope, Command))",
+	"CREATE TABLE IF NOT EXISTS transcripts (Season INTEGER NOT NULL, Episode INTEGER NOT NULL, Line INTEGER NOT NULL, Speaker VARCHAR(128) NOT NULL, Text VARCHAR(2000) NOT NULL, PRIMARY KEY (Season, Episode, Line))",
+	"CREATE TRIGGER IF NOT EXISTS chatlog_before_update BEFORE UPDATE ON chatlog FOR EACH ROW BEGIN INSERT OR REPLACE INTO editlog (ID, Timestamp, Author, Message, Channel, Guild) VALUES (OLD.ID, OLD.Timestamp, OLD.Author, OLD.Message, OLD.Channel, OLD.Guild); END",
+	"CREATE TRIGGER IF NOT EXISTS itemtags_after_delete AFTER DELETE ON itemtags FOR EACH ROW WHEN (SELECT COUNT(*) FROM itemtags WHERE Item = OLD.Item) = 0 BEGIN DELETE FROM items WHERE ID = OLD.Item; END",
+	"CREATE TRIGGER IF NOT EXISTS tags_before_delete BEFORE DELETE ON tags FOR EACH ROW BEGIN DELETE FROM itemtags WHERE Tag = OLD.ID; END",
+	"CREATE TRIGGER IF NOT EXISTS users_before_delete BEFORE DELETE ON users FOR EACH ROW BEGIN DELETE FROM aliases WHERE User = OLD.ID; DELETE FROM debuglog WHERE User = OLD.ID; END",
+	"CREATE VIEW IF NOT EXISTS randomwords AS SELECT Phrase FROM markov_transcripts WHERE Phrase NOT IN ('.', '!', '?', 'the', 'of', 'a', 'to', 'too', 'as', 'at', 'an', 'am', 'and', 'be', 'he', 'she', '')",
+}
+
+// sqliteStore is an embedded backend for small selfhosts and tests that don't want to run MariaDB. The connection
+// string is simply the path to the database file, or ":memory:".
+type sqliteStore struct {
+	db *sql.DB
+}
+
+func (s *sqliteStore) Open(conn string) (*sql.DB, error) {
+	registerSQLite.Do(func() {
+		sql.Register(sqliteDriverName, &sqlite3.SQLiteDriver{
+			ConnectHook: func(c *sqlite3.SQLiteConn) error {
+				if err := c.RegisterFunc("UTC_TIMESTAMP", sqliteUTCTimestamp, false); err != nil {
+					return err
+				}
+				if err := c.RegisterFunc("UTC_TIMESTAMP_SUB", sqliteUTCTimestampSub, false); err != nil {
+					return err
+				}
+				if err := c.RegisterFunc("RAND", rand.Float64, false); err != nil {
+					return err
+				}
+				if err := c.RegisterFunc("FLOOR", math.Floor, true); err != nil {
+					return err
+				}
+				if err := c.RegisterFunc("CONCAT", sqliteConcat, true); err != nil {
+					return err
+				}
+				_, err := c.Exec("PRAGMA foreign_keys = ON", nil)
+				return err
+			},
+		})
+	})
+	if !strings.Contains(conn, "?") {
+		conn += "?_busy_timeout=5000"
+	}
+	db, err := sql.Open(sqliteDriverName, conn)
+	if err == nil && strings.HasPrefix(conn, ":memory:") {
+		db.SetMaxOpenConns(1) // An in-memory database only exists on the connection that created it
+	}
+	s.db = db
+	return db, err
+}
+
+func (s *sqliteStore) Init(db *sql.DB) error {
+	for _, statement := range sqliteSchema {
+		if _, err := db.Exec(statement); err != nil {
+			return err
+		}
+	}
+	var speakers int
+	if err := db.QueryRow("SELECT COUNT(*) FROM markov_transcripts_speaker").Scan(&speakers); err != nil {
+		return err
+	}
+	if speakers == 0 {
+		if err := s.ResetMarkov(); err != nil {
+			return err
+		}
+	}
+	var zones int
+	if err := db.QueryRow("SELECT COUNT(*) FROM timezones").Scan(&zones); err != nil {
+		return err
+	}
+	if _, err := os.Stat("sweetiebot_tz.sql"); zones == 0 && err == nil {
+		return ExecuteSQLFile(db, "sweetiebot_tz.sql")
+	}
+	return nil
+}
+
+func (s *sqliteStore) Prepare(db *BotDB) error {
+	s.db = db.db
+	return nil
+}
+
+func (s *sqliteStore) Rewrite(query string) string {
+	if q, ok := sqliteOverrides[query]; ok {
+		return q
+	}
+	for _, v := range sqliteRewrites {
+		query = v.regex.ReplaceAllString(query, v.replace)
+	}
+	return query
+}
+
+func (s *sqliteStore) StandardErr(err error) error {
+	if sqliteErr, ok := err.(sqlite3.Error); ok {
+		switch {
+		case sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique || sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey:
+			return ErrDuplicateEntry
+		case sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked:
+			return ErrLockWaitTimeout
+		}
+	}
+	return err
+}
+
+func (s *sqliteStore) transaction(fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	if err = fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *sqliteStore) AddChat(id uint64, author uint64, username string, message string, channel uint64, guild uint64) error {
+	return s.transaction(func(tx *sql.Tx) error {
+		now := time.Now().UTC()
+		_, err := tx.Exec("INSERT INTO users (ID, Username, Avatar, LastSeen, LastNameChange) VALUES (?, ?, '', ?, ?) ON CONFLICT (ID) DO UPDATE SET LastSeen = excluded.LastSeen", author, username, now, now)
+		if err != nil {
+			return err
+		}
+		if _, err = tx.Exec("INSERT OR IGNORE INTO aliases (User, Alias, Duration, Timestamp) VALUES (?, ?, 0, ?)", author, username, now); err != nil {
+			return err
+		}
+		_, err = tx.Exec("INSERT INTO chatlog (ID, Author, Message, Timestamp, Channel, Guild) VALUES (?, ?, ?, ?, ?, ?) ON CONFLICT (ID) DO UPDATE SET Message = excluded.Message, Timestamp = excluded.Timestamp", id, author, message, now, channel, guild)
+		return err
+	})
+}
+
+func (s *sqliteStore) AddUser(id uint64, username string, discriminator int, avatar string, isonline bool) error {
+	return s.transaction(func(tx *sql.Tx) error {
+		now := time.Now().UTC()
+		var oldname string
+		err := tx.QueryRow("SELECT Username FROM users WHERE ID = ?", id).Scan(&oldname)
+		if err == sql.ErrNoRows {
+			_, err = tx.Exec("INSERT INTO users (ID, Username, Discriminator, Avatar, LastSeen, LastNameChange) VALUES (?, ?, ?, ?, ?, ?)", id, username, discriminator, avatar, now, now)
+		} else if err == nil {
+			_, err = tx.Exec(`UPDATE users SET
+				Username = CASE WHEN ? = '' THEN Username ELSE ? END,
+				Discriminator = CASE WHEN ? = 0 THEN Discriminator ELSE ? END,
+				Avatar = CASE WHEN ? = '' THEN Avatar ELSE ? END,
+				LastSeen = CASE WHEN ? THEN ? ELSE LastSeen END,
+				LastNameChange = CASE WHEN ? != '' AND ? != Username THEN ? ELSE LastNameChange END
+				WHERE ID = ?`,
+				username, username, discriminator, discriminator, avatar, avatar, isonline, now, username, username, now, id)
+		}
+		if err != nil || len(username) == 0 {
+			return err
+		}
+		if len(oldname) > 0 {
+			_, err = tx.Exec("INSERT INTO aliases (User, Alias, Duration, Timestamp) VALUES (?, ?, 0, ?) ON CONFLICT (User, Alias) DO UPDATE SET Duration = Duration + (strftime('%s', excluded.Timestamp) - strftime('%s', Timestamp)), Timestamp = excluded.Timestamp", id, oldname, now)
+			if err != nil {
+				return err
+			}
+		}
+		if username != oldname {
+			_, err = tx.Exec("INSERT INTO aliases (User, Alias, Duration, Timestamp) VALUES (?, ?, 0, ?) ON CONFLICT (User, Alias) DO UPDATE SET Timestamp = excluded.Timestamp", id, username, now)
+		}
+		return err
+	})
+}
+
+func (s *sqliteStore) AddMember(id uint64, guild uint64, firstseen time.Time, nickname string) error {
+	_, err := s.db.Exec("INSERT INTO members (ID, Guild, FirstSeen, Nickname) VALUES (?, ?, ?, ?) ON CONFLICT (ID, Guild) DO UPDATE SET FirstSeen = MIN(FirstSeen, excluded.FirstSeen), Nickname = excluded.Nickname", id, guild, firstseen.UTC(), nickname)
+	return err
+}
+
+func (s *sqliteStore) AddMarkov(last uint64, last2 uint64, speaker string, text string) (uint64, error) {
+	var id uint64
+	err := s.transaction(func(tx *sql.Tx) error {
+		if _, err := tx.Exec("INSERT OR IGNORE INTO markov_transcripts_speaker (Speaker) VALUES (?)", speaker); err != nil {
+			return err
+		}
+		var speakerid uint64
+		if err := tx.QueryRow("SELECT ID FROM markov_transcripts_speaker WHERE Speaker = ?", speaker).Scan(&speakerid); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("INSERT OR IGNORE INTO markov_transcripts (SpeakerID, Phrase) VALUES (?, ?)", speakerid, text); err != nil {
+			return err
+		}
+		if err := tx.QueryRow("SELECT ID FROM markov_transcripts WHERE SpeakerID = ? AND Phrase = ?", speakerid, text).Scan(&id); err != nil {
+			return err
+		}
+		_, err := tx.Exec("INSERT INTO markov_transcripts_map (Prev, Prev2, Next) VALUES (?, ?, ?) ON CONFLICT (Prev, Next, Prev2) DO UPDATE SET Count = Count + 1", last, last2, id)
+		return err
+	})
+	return id, err
+}
+
+// getMarkov picks a weighted random successor, returning false if there isn't one
+func (s *sqliteStore) getMarkov(query string, args ...interface{}) (uint64, bool, error) {
+	q, err := s.db.Query(query, args...)
+	if err != nil {
+		return 0, false, err
+	}
+	defer q.Close()
+	next := []uint64{}
+	counts := []int{}
+	total := 0
+	for q.Next() {
+		var n uint64
+		var c int
+		if err := q.Scan(&n, &c); err != nil {
+			return 0, false, err
+		}
+		next = append(next, n)
+		counts = append(counts, c)
+		total += c
+	}
+	if total == 0 {
+		return 0, false, q.Err()
+	}
+	weight := rand.Intn(total)
+	for i, c := range counts {
+		if weight < c {
+			return next[i], true, nil
+		}
+		weight -= c
+	}
+	return next[len(next)-1], true, nil
+}
+
+func (s *sqliteStore) getPhrase(id uint64) (uint64, string, string, error) {
+	var speakerid uint64
+	var speaker, phrase string
+	err := s.db.QueryRow("SELECT T.SpeakerID, S.Speaker, T.Phrase FROM markov_transcripts T INNER JOIN markov_transcripts_speaker S ON T.SpeakerID = S.ID WHERE T.ID = ?", id).Scan(&speakerid, &speaker, &phrase)
+	return speakerid, speaker, phrase, err
+}
+
+func capitalizePhrase(s string) string {
+	if len(s) == 0 {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+func isPunctuation(s string) bool {
+	return s == "." || s == "!" || s == "?"
+}
+
+// buildMarkovLine reimplements the GetMarkovLine and GetMarkovLine2 stored functions. If prev2 is nil, the chain is
+// only keyed on the previous word.
+func (s *sqliteStore) buildMarkovLine(prev uint64, prev2 *uint64) (sql.NullString, error) {
+	pick := func() (uint64, bool, error) {
+		if prev2 == nil {
+			return s.getMarkov("SELECT Next, Count FROM markov_transcripts_map WHERE Prev = ?", prev)
+		}
+		return s.getMarkov("SELECT Next, Count FROM markov_transcripts_map WHERE Prev = ? AND Prev2 = ?", prev, *prev2)
+	}
+	suffix := func() string {
+		if prev2 == nil {
+			return "|" + strconv.FormatUint(prev, 10)
+		}
+		return "|" + strconv.FormatUint(prev, 10) + "|" + strconv.FormatUint(*prev2, 10)
+	}
+	advance := func(next uint64) {
+		if prev2 != nil {
+			*prev2 = prev
+		}
+		prev = next
+	}
+
+	next, ok, err := pick()
+	if err != nil || !ok {
+		return sql.NullString{String: "|", Valid: true}, err
+	}
+	advance(next)
+	speakerid, speaker, phrase, err := s.getPhrase(prev)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+
+	line := ""
+	if len(speaker) == 0 {
+		if len(phrase) == 0 {
+			return sql.NullString{String: suffix(), Valid: true}, nil
+		}
+		line = "[" + phrase
+	} else {
+		line = "**" + speaker + ":** " + capitalizePhrase(phrase)
+	}
+
+	for i := 0; i <= 300; i++ {
+		capitalize := isPunctuation(phrase)
+		next, ok, err = pick()
+		if err != nil {
+			return sql.NullString{}, err
+		}
+		if !ok {
+			break
+		}
+		ns, _, nextphrase, err := s.getPhrase(next)
+		if err != nil {
+			return sql.NullString{}, err
+		}
+		if ns != speakerid {
+			break
+		}
+		advance(next)
+		phrase = nextphrase
+		if isPunctuation(phrase) || phrase == "," {
+			line += phrase
+		} else if capitalize {
+			line += " " + capitalizePhrase(phrase)
+		} else {
+			line += " " + phrase
+		}
+	}
+
+	if len(speaker) == 0 {
+		line += "]"
+	}
+	return sql.NullString{String: line + suffix(), Valid: true}, nil
+}
+
+func (s *sqliteStore) GetMarkovLine(last uint64) (sql.NullString, error) {
+	return s.buildMarkovLine(last, nil)
+}
+
+func (s *sqliteStore) GetMarkovLine2(last uint64, last2 uint64) (sql.NullString, error) {
+	return s.buildMarkovLine(last, &last2)
+}
+
+func (s *sqliteStore) ResetMarkov() error {
+	return s.transaction(func(tx *sql.Tx) error {
+		for _, statement := range []string{
+			"DELETE FROM markov_transcripts_map",
+			"DELETE FROM markov_transcripts",
+			"DELETE FROM markov_transcripts_speaker",
+			"DELETE FROM sqlite_sequence WHERE name IN ('markov_transcripts', 'markov_transcripts_speaker')",
+			"INSERT INTO markov_transcripts_speaker (ID, Speaker) VALUES (1, '')",
+			"INSERT INTO markov_transcripts (ID, SpeakerID, Phrase) VALUES (0, 1, '')",
+		} {
+			if _, err := tx.Exec(statement); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *sqliteStore) RemoveSchedule(id uint64) error {
+	return s.transaction(func(tx *sql.Tx) error {
+		var date time.Time
+		var interval, repeat sql.NullInt64
+		err := tx.QueryRow("SELECT Date, RepeatInterval, `Repeat` FROM schedule WHERE ID = ?", id).Scan(&date, &interval, &repeat)
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if date.After(time.Now().UTC()) || (!interval.Valid && !repeat.Valid) {
+			_, err = tx.Exec("DELETE FROM schedule WHERE ID = ?", id)
+			return err
+		}
+		n := int(repeat.Int64)
+		switch interval.Int64 {
+		case 1:
+			date = date.Add(time.Duration(n) * time.Second)
+		case 2:
+			date = date.Add(time.Duration(n) * time.Minute)
+		case 3:
+			date = date.Add(time.Duration(n) * time.Hour)
+		case 4:
+			date = date.AddDate(0, 0, n)
+		case 5:
+			date = date.AddDate(0, 0, n*7)
+		case 6:
+			date = date.AddDate(0, n, 0)
+		case 7:
+			date = date.AddDate(0, n*3, 0)
+		case 8:
+			date = date.AddDate(n, 0, 0)
+		default:
+			return nil
+		}
+		_, err = tx.Exec("UPDATE schedule SET Date = ? WHERE ID = ?", date.UTC(), id)
+		return err
+	})
+}
+
+func (s *sqliteStore) AddItem(item string) (uint64, error) {
+	var id uint64
+	err := s.transaction(func(tx *sql.Tx) error {
+		err := tx.QueryRow("SELECT ID FROM items WHERE Content = ?", item).Scan(&id)
+		if err != sql.ErrNoRows {
+			return err
+		}
+		r, err := tx.Exec("INSERT INTO items (Content) VALUES (?)", item)
+		if err != nil {
+			return err
+		}
+		last, err := r.LastInsertId()
+		id = uint64(last)
+		return err
+	})
+	return id, err
+}
+
+func (s *sqliteStore) RemoveGuild(guild uint64) error {
+	return s.transaction(func(tx *sql.Tx) error {
+		for _, table := range []string{"members", "schedule", "editlog", "chatlog", "debuglog", "tags", "cases", "confighistory", "voicetime", "tempchannels", "cooldowns"} {
+			if _, err := tx.Exec("DELETE FROM "+table+" WHERE Guild = ?", guild); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
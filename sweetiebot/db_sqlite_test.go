@@ -0,0 +1,269 @@
+package sweetiebot
+
+import (
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/blackhole12/discordgo"
+)
+
+func sqliteBotDB(t *testing.T) *BotDB {
+	db, err := dbLoad(NewLogger(ioutil.Discard, LogInfo, false), DriverSQLite, ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = db.LoadStatements(); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestSQLiteRewrite(t *testing.T) {
+	s := &sqliteStore{}
+	Check(s.Rewrite("SELECT COUNT(*) FROM members WHERE FirstSeen > DATE_SUB(UTC_TIMESTAMP(), INTERVAL ? SECOND) AND Guild = ?"), "SELECT COUNT(*) FROM members WHERE FirstSeen > UTC_TIMESTAMP_SUB(?, 'SECOND') AND Guild = ?", t)
+	Check(s.Rewrite("INSERT IGNORE INTO itemtags (Item, Tag) VALUES (?, ?)"), "INSERT OR IGNORE INTO itemtags (Item, Tag) VALUES (?, ?)", t)
+	Check(s.Rewrite("INSERT INTO debuglog (Type) VALUE(?)"), "INSERT INTO debuglog (Type) VALUES(?)", t)
+	Check(s.Rewrite("SELECT 1"), "SELECT 1", t)
+}
+
+func TestSQLiteStore(t *testing.T) {
+	db := sqliteBotDB(t)
+	defer db.Close()
+
+	db.AddUser(1, "Sweetie", 1234, "", true)
+	db.AddUser(1, "Belle", 0, "", true)
+	u, _, _, _ := db.GetUser(1)
+	if u == nil {
+		t.Fatal("User was not added")
+	}
+	Check(u.Username, "Belle", t)
+	Check(u.Discriminator, "1234", t)
+	Check(len(db.GetAliases(1)), 2, t)
+
+	db.AddMember(1, 5, time.Now().UTC(), "nick")
+	db.AddMember(1, 5, time.Now().UTC().Add(time.Hour), "nick2")
+	Check(db.CountNewUsers(60, 5), 1, t)
+	Check(len(db.GetUserGuilds(1)), 1, t)
+
+	db.AddMessage(100, &discordgo.User{ID: "1", Username: "Belle"}, "hello", 7, 5)
+	db.AddMessage(100, &discordgo.User{ID: "1", Username: "Belle"}, "edited", 7, 5)
+	var edits int
+	db.db.QueryRow("SELECT COUNT(*) FROM editlog").Scan(&edits)
+	Check(edits, 1, t)
+	m := db.GetMessage(100)
+	if m == nil {
+		t.Fatal("Message was not logged")
+	}
+	Check(m.Message, "edited", t)
+	Check(m.Author, uint64(1), t)
+	Check(m.Channel, uint64(7), t)
+	before, ok := db.GetLastEdit(100)
+	Check(ok, true, t)
+	Check(before, "hello", t)
+	Check(db.GetMessage(101), (*LoggedMessage)(nil), t)
+	_, ok = db.GetLastEdit(101)
+	Check(ok, false, t)
+
+	a, err := db.AddItem("item")
+	Check(err, nil, t)
+	b, err := db.AddItem("item")
+	Check(err, nil, t)
+	Check(a, b, t)
+	Check(db.CreateTag("tag", 5), nil, t)
+	Check(db.CreateTag("tag", 5), ErrDuplicateEntry, t)
+	_, err = db.PickTag("tag", 5)
+	Check(err, sql.ErrNoRows, t)
+	tag, err := db.GetTag("tag", 5)
+	Check(err, nil, t)
+	Check(db.AddTag(a, tag), nil, t)
+	item, err := db.PickTag("tag", 5)
+	Check(err, nil, t)
+	Check(item, "item", t)
+
+	Check(db.AddScheduleRepeat(5, time.Now().UTC().Add(-time.Minute), 4, 1, 2, "repeat"), nil, t)
+	Check(db.AddSchedule(5, time.Now().UTC().Add(-time.Minute), 2, "once"), nil, t)
+	events := db.GetSchedule(5)
+	Check(len(events), 2, t)
+	for _, e := range events {
+		db.RemoveSchedule(e.ID)
+	}
+	Check(len(db.GetSchedule(5)), 0, t)
+	Check(len(db.GetEvents(5, 10)), 1, t)
+
+	Check(db.RemoveGuild(5), nil, t)
+	Check(len(db.GetUserGuilds(1)), 0, t)
+}
+
+func TestSQLiteMarkov(t *testing.T) {
+	db := sqliteBotDB(t)
+	defer db.Close()
+
+	line, _ := db.GetMarkovLine(0)
+	Check(line, "", t)
+	first := db.AddMarkov(0, 0, "Sweetie", "hello")
+	second := db.AddMarkov(first, 0, "Sweetie", "there")
+	db.AddMarkov(second, first, "Sweetie", ".")
+	line, last := db.GetMarkovLine(0)
+	Check(line, "**Sweetie:** Hello there.", t)
+	CheckNot(last, uint64(0), t)
+	Check(db.ResetMarkov(), nil, t)
+	line, _ = db.GetMarkovLine(0)
+	Check(line, "", t)
+}
+
+func TestSQLiteCases(t *testing.T) {
+	db := sqliteBotDB(t)
+	defer db.Close()
+
+	id, err := db.AddCase(5, &ModCase{Action: CaseBan, Target: 1, Moderator: 2, Reason: "rude", Duration: "2 days", Messages: []string{"3/4", "3/5"}})
+	Check(err, nil, t)
+	Check(id, uint64(1), t)
+	id, err = db.AddCase(5, &ModCase{Action: CaseNote, Target: 6, Moderator: 2})
+	Check(err, nil, t)
+	Check(id, uint64(2), t)
+	id, err = db.AddCase(7, &ModCase{Action: CaseSilence, Target: 1, Moderator: 2})
+	Check(err, nil, t)
+	Check(id, uint64(1), t) // Case numbers are counted separately on each guild
+
+	c := db.GetCase(5, 1)
+	if c == nil {
+		t.Fatal("Case was not added")
+	}
+	Check(c.Action, CaseBan, t)
+	Check(c.Target, uint64(1), t)
+	Check(c.Reason, "rude", t)
+	Check(c.Duration, "2 days", t)
+	Check(len(c.Messages), 2, t)
+	Check(c.Messages[1], "3/5", t)
+	Check(db.GetCase(5, 3), (*ModCase)(nil), t)
+
+	Check(len(db.GetCases(5, 0, 10, 0)), 2, t)
+	Check(len(db.GetCases(5, 1, 10, 0)), 1, t)
+	Check(db.GetCases(5, 0, 10, 0)[0].ID, uint64(2), t)
+
+	Check(db.SetCaseReason(5, 2, "watch this one"), nil, t)
+	Check(db.GetCase(5, 2).Reason, "watch this one", t)
+
+	_, err = db.AddCase(5, &ModCase{Action: CaseWarn, Target: 6, Moderator: 2, Points: 2})
+	Check(err, nil, t)
+	_, err = db.AddCase(5, &ModCase{Action: CaseWarn, Target: 6, Moderator: 2, Points: 3})
+	Check(err, nil, t)
+	warnings := db.GetWarnings(5, 6)
+	Check(len(warnings), 2, t)
+	Check(warnings[0].Points, 2, t)
+	Check(warnings[1].Points, 3, t)
+	Check(db.GetCase(5, 4).Points, 3, t)
+	Check(len(db.GetWarnings(5, 1)), 0, t)
+
+	Check(db.RemoveGuild(5), nil, t)
+	Check(len(db.GetCases(5, 0, 10, 0)), 0, t)
+	Check(len(db.GetCases(7, 0, 10, 0)), 1, t)
+}
+
+func TestSQLiteConfigHistory(t *testing.T) {
+	db := sqliteBotDB(t)
+	defer db.Close()
+
+	for i := 1; i <= 4; i++ {
+		v, err := db.AddConfigVersion(5, 2, "change", []byte(fmt.Sprintf(`{"version":%v}`, i)), 3)
+		Check(err, nil, t)
+		Check(v, uint64(i), t)
+	}
+	_, err := db.AddConfigVersion(7, 2, "other guild", []byte("{}"), 3)
+	Check(err, nil, t)
+
+	history := db.GetConfigHistory(5, 10, 0)
+	Check(len(history), 3, t) // Only the last 3 versions are kept
+	Check(history[0].Version, uint64(4), t)
+	Check(history[0].Author, uint64(2), t)
+	Check(history[0].Changes, "change", t)
+	Check(len(db.GetConfigHistory(5, 10, 1)), 2, t)
+	Check(string(db.GetConfigVersion(5, 2)), `{"version":2}`, t)
+	Check(db.GetConfigVersion(5, 1) == nil, true, t)
+
+	Check(db.RemoveGuild(5), nil, t)
+	Check(len(db.GetConfigHistory(5, 10, 0)), 0, t)
+	Check(len(db.GetConfigHistory(7, 10, 0)), 1, t)
+}
+
+func TestSQLiteVoice(t *testing.T) {
+	db := sqliteBotDB(t)
+	defer db.Close()
+
+	Check(db.GetVoiceTime(5, 1), (*VoiceTime)(nil), t)
+	Check(db.AddVoiceTime(5, 1, 60), nil, t)
+	Check(db.AddVoiceTime(5, 1, 30), nil, t)
+	Check(db.AddVoiceTime(5, 2, 300), nil, t)
+	Check(db.AddVoiceTime(7, 1, 1000), nil, t)
+	v := db.GetVoiceTime(5, 1)
+	if v == nil {
+		t.Fatal("Voice time was not recorded")
+	}
+	Check(v.Seconds, uint64(90), t)
+	Check(v.Sessions, uint64(2), t)
+	top := db.GetTopVoiceTime(5, 10)
+	Check(len(top), 2, t)
+	Check(top[0].User, uint64(2), t)
+
+	Check(db.AddTempChannel(5, 10, 1), nil, t)
+	Check(db.AddTempChannel(5, 11, 2), nil, t)
+	Check(db.RemoveTempChannel(10), nil, t)
+	channels := db.GetTempChannels(5)
+	if Check(len(channels), 1, t) {
+		Check(channels[0].Channel, uint64(11), t)
+		Check(channels[0].Owner, uint64(2), t)
+	}
+
+	Check(db.RemoveGuild(5), nil, t)
+	Check(db.GetVoiceTime(5, 1), (*VoiceTime)(nil), t)
+	Check(len(db.GetTempChannels(5)), 0, t)
+	Check(db.GetVoiceTime(7, 1).Seconds, uint64(1000), t)
+}
+
+func TestSQLiteCooldowns(t *testing.T) {
+	db := sqliteBotDB(t)
+	defer db.Close()
+
+	now := time.Unix(1500000000, 0).UTC()
+	Check(db.SetCooldown(5, "user:1", "about", now.Add(time.Minute)), nil, t)
+	Check(db.SetCooldown(5, "user:1", "about", now.Add(2*time.Minute)), nil, t)
+	Check(db.SetCooldown(5, "channel:2", "about", now.Add(-time.Minute)), nil, t)
+	Check(db.SetCooldown(7, "role:3", "search", now.Add(time.Hour)), nil, t)
+	cooldowns := db.GetCooldowns(5, now)
+	if Check(len(cooldowns), 1, t) {
+		Check(cooldowns[0].Scope, "user:1", t)
+		Check(cooldowns[0].Command, "about", t)
+		Check(cooldowns[0].Expires.Unix(), now.Add(2*time.Minute).Unix(), t)
+	}
+	Check(len(db.GetCooldowns(5, now.Add(-time.Hour))), 1, t) // The expired cooldown was pruned by the first call
+
+	Check(db.RemoveGuild(5), nil, t)
+	Check(len(db.GetCooldowns(5, now)), 0, t)
+	Check(len(db.GetCooldowns(7, now)), 1, t)
+}
+
+func TestSQLiteSaveCooldowns(t *testing.T) {
+	db := sqliteBotDB(t)
+	defer db.Close()
+
+	now := time.Unix(1500000000, 0).UTC()
+	info := &GuildInfo{ID: "5", cooldowns: make(map[cooldownKey]int64), Bot: &SweetieBot{DB: db}}
+	info.startCooldown(cooldownKey{"user:1", "about"}, now.Unix()+60)
+	info.startCooldown(cooldownKey{"channel:2", "about"}, now.Unix()+10)
+	Check(len(db.GetCooldowns(5, now)), 0, t) // Nothing is saved until the next tick
+
+	info.saveCooldowns(now.Unix())
+	Check(len(info.newCooldowns), 0, t)
+	Check(len(db.GetCooldowns(5, now)), 2, t)
+
+	info.saveCooldowns(now.Unix() + 30)
+	Check(len(info.cooldowns), 1, t)
+	Check(info.cooldownRemaining(cooldownKey{"user:1", "about"}, now.Unix()+30), int64(30), t)
+	cooldowns := db.GetCooldowns(5, now) // Would still return the expired cooldown if it hadn't been pruned
+	if Check(len(cooldowns), 1, t) {
+		Check(cooldowns[0].Scope, "user:1", t)
+	}
+}
@@ -0,0 +1,158 @@
+package sweetiebot
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/blackhole12/discordgo"
+)
+
+// PressureAdded is published whenever the spam module adds pressure to someone
+type PressureAdded struct {
+	User     DiscordUser
+	Message  *discordgo.Message // The message that caused the pressure
+	Pressure float32            // How much pressure was added
+	Total    float32            // How much pressure they have now
+	Reason   string
+}
+
+// MemberSilenced is published whenever the bot silences someone, whether a moderator asked it to or not
+type MemberSilenced struct {
+	User      DiscordUser
+	Moderator DiscordUser // The bot itself if it silenced them on its own
+	Reason    string
+	Case      uint64 // 0 if no case was recorded
+	Spam      bool   // True if the spam module silenced them for sending too much pressure
+}
+
+// RaidDetected is published when a group of people join the server at the same time
+type RaidDetected struct {
+	Users []*discordgo.User // Everyone who joined during the raid
+	Time  time.Time
+}
+
+// FilterTriggered is published when a message matches one of the filters
+type FilterTriggered struct {
+	Filter   string
+	Message  *discordgo.Message
+	Pressure float32 // The pressure the filter is configured to add, if any
+}
+
+// CommandExecuted is published after a command has been run and has returned its result
+type CommandExecuted struct {
+	Command CommandID
+	Args    []string
+	Message *discordgo.Message
+}
+
+// Event is one of the events modules can publish. Each event type has its own Subscribe function on GuildInfo, so a
+// handler that takes the wrong arguments doesn't compile.
+type Event interface {
+	publish(info *GuildInfo)
+}
+
+// eventBus holds the event handlers of a guild, one list per type of event
+type eventBus struct {
+	lock            sync.RWMutex
+	pressureAdded   []func(*GuildInfo, *PressureAdded)
+	memberSilenced  []func(*GuildInfo, *MemberSilenced)
+	raidDetected    []func(*GuildInfo, *RaidDetected)
+	filterTriggered []func(*GuildInfo, *FilterTriggered)
+	commandExecuted []func(*GuildInfo, *CommandExecuted)
+}
+
+// SubscribePressureAdded calls handler every time the spam module adds pressure to someone on this guild
+func (info *GuildInfo) SubscribePressureAdded(handler func(*GuildInfo, *PressureAdded)) {
+	info.events.lock.Lock()
+	defer info.events.lock.Unlock()
+	info.events.pressureAdded = append(info.events.pressureAdded, handler)
+}
+
+// SubscribeMemberSilenced calls handler every time someone is silenced on this guild
+func (info *GuildInfo) SubscribeMemberSilenced(handler func(*GuildInfo, *MemberSilenced)) {
+	info.events.lock.Lock()
+	defer info.events.lock.Unlock()
+	info.events.memberSilenced = append(info.events.memberSilenced, handler)
+}
+
+// SubscribeRaidDetected calls handler every time a raid is detected on this guild
+func (info *GuildInfo) SubscribeRaidDetected(handler func(*GuildInfo, *RaidDetected)) {
+	info.events.lock.Lock()
+	defer info.events.lock.Unlock()
+	info.events.raidDetected = append(info.events.raidDetected, handler)
+}
+
+// SubscribeFilterTriggered calls handler every time a message matches one of the filters on this guild
+func (info *GuildInfo) SubscribeFilterTriggered(handler func(*GuildInfo, *FilterTriggered)) {
+	info.events.lock.Lock()
+	defer info.events.lock.Unlock()
+	info.events.filterTriggered = append(info.events.filterTriggered, handler)
+}
+
+// SubscribeCommandExecuted calls handler every time a command has been run on this guild
+func (info *GuildInfo) SubscribeCommandExecuted(handler func(*GuildInfo, *CommandExecuted)) {
+	info.events.lock.Lock()
+	defer info.events.lock.Unlock()
+	info.events.commandExecuted = append(info.events.commandExecuted, handler)
+}
+
+// Publish calls every handler subscribed to the type of e, in the order they subscribed. Handlers run on the goroutine
+// that published the event, so by the time Publish returns, every module has seen it.
+func (info *GuildInfo) Publish(e Event) {
+	e.publish(info)
+}
+
+func (e *PressureAdded) publish(info *GuildInfo) {
+	info.events.lock.RLock()
+	handlers := info.events.pressureAdded
+	info.events.lock.RUnlock()
+	for _, f := range handlers {
+		f(info, e)
+	}
+}
+
+func (e *MemberSilenced) publish(info *GuildInfo) {
+	info.events.lock.RLock()
+	handlers := info.events.memberSilenced
+	info.events.lock.RUnlock()
+	for _, f := range handlers {
+		f(info, e)
+	}
+}
+
+func (e *RaidDetected) publish(info *GuildInfo) {
+	info.events.lock.RLock()
+	handlers := info.events.raidDetected
+	info.events.lock.RUnlock()
+	for _, f := range handlers {
+		f(info, e)
+	}
+}
+
+func (e *FilterTriggered) publish(info *GuildInfo) {
+	info.events.lock.RLock()
+	handlers := info.events.filterTriggered
+	info.events.lock.RUnlock()
+	for _, f := range handlers {
+		f(info, e)
+	}
+}
+
+func (e *CommandExecuted) publish(info *GuildInfo) {
+	info.events.lock.RLock()
+	handlers := info.events.commandExecuted
+	info.events.lock.RUnlock()
+	for _, f := range handlers {
+		f(info, e)
+	}
+}
+
+// LastRaid returns the unix time of the last raid detected on this guild, or 0 if there hasn't been one
+func (info *GuildInfo) LastRaid() int64 {
+	return int64(atomic.LoadUint32(&info.lastRaid))
+}
+
+func recordRaid(info *GuildInfo, e *RaidDetected) {
+	atomic.StoreUint32(&info.lastRaid, uint32(e.Time.Unix()))
+}
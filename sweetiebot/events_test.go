@@ -0,0 +1,32 @@
+package sweetiebot
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/blackhole12/discordgo"
+)
+
+func TestEvents(t *testing.T) {
+	t.Parallel()
+
+	info := NewGuildInfo(nil, &discordgo.Guild{ID: "1"})
+	order := []string{}
+	info.SubscribeFilterTriggered(func(g *GuildInfo, e *FilterTriggered) {
+		Check(g, info, t)
+		order = append(order, "first "+e.Filter)
+		g.Publish(&PressureAdded{Pressure: e.Pressure})
+	})
+	info.SubscribeFilterTriggered(func(g *GuildInfo, e *FilterTriggered) { order = append(order, "second "+e.Filter) })
+	info.SubscribePressureAdded(func(g *GuildInfo, e *PressureAdded) { order = append(order, fmt.Sprint("pressure ", e.Pressure)) })
+
+	info.Publish(&FilterTriggered{Filter: "spoilers", Pressure: 5})
+	info.Publish(&CommandExecuted{Command: "about"}) // Nobody is listening to this
+	Check(fmt.Sprint(order), "[first spoilers pressure 5 second spoilers]", t)
+
+	Check(info.LastRaid(), int64(0), t)
+	raid := time.Unix(1500000000, 0)
+	info.Publish(&RaidDetected{Time: raid})
+	Check(info.LastRaid(), raid.Unix(), t)
+}
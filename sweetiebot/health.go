@@ -0,0 +1,142 @@
+package sweetiebot
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	healthMissedHeartbeats = 3                              // The deadlock detector gives up at 5, so this lets an orchestrator restart the bot first
+	healthWorkerTimeout    = time.Duration(5 * time.Minute) // How long a guild worker can spend on a single event before it counts as stuck
+)
+
+// LoopHealth is whether one of the background loops of a shard is still making progress
+type LoopHealth struct {
+	Name  string  `json:"name"`
+	Age   float64 `json:"age"` // Seconds since the loop last made progress, or -1 if it never started
+	Alive bool    `json:"alive"`
+}
+
+// ShardHealth is the state of a single shard, as reported by /healthz and /readyz
+type ShardHealth struct {
+	Instance         int          `json:"instance"`
+	Shard            int          `json:"shard"`
+	Connected        bool         `json:"connected"`     // True while the gateway connection is up
+	HeartbeatAge     float64      `json:"heartbeat_age"` // Seconds since a heartbeat last made it through the command pipeline, or -1 if none has yet
+	HeartbeatsMissed uint32       `json:"heartbeats_missed"`
+	Loops            []LoopHealth `json:"loops"`
+}
+
+// Health is a snapshot of everything /healthz and /readyz check. The bot is healthy as long as its heartbeats get
+// through and its loops keep running, and it's only ready if it's also connected to discord with a working database.
+type Health struct {
+	Healthy  bool          `json:"healthy"`
+	Ready    bool          `json:"ready"`
+	Database bool          `json:"database"`
+	Problems []string      `json:"problems,omitempty"`
+	Shards   []ShardHealth `json:"shards"`
+}
+
+// clockAge turns a time from heartbeatClock into how long ago it was
+func clockAge(t uint32) time.Duration {
+	return time.Duration(heartbeatClock()-t) * time.Millisecond
+}
+
+// healthLoopTimeout is how long a loop that runs every interval can go without running before it counts as stuck. A
+// slow database reconnect can hold up a pass, so this is never less than a minute.
+func healthLoopTimeout(interval time.Duration) time.Duration {
+	if timeout := 3 * interval; timeout > time.Minute {
+		return timeout
+	}
+	return time.Minute
+}
+
+func clockLoop(name string, last uint32, timeout time.Duration) LoopHealth {
+	if last == 0 {
+		return LoopHealth{name, -1, false}
+	}
+	age := clockAge(last)
+	return LoopHealth{name, age.Seconds(), age < timeout}
+}
+
+// health checks the connection, heartbeat and loops of this shard. Guild events and member lists are processed by
+// the guild workers, so the one that has been stuck on a single event the longest stands in for all of them.
+func (sb *SweetieBot) health(instance int) ShardHealth {
+	s := ShardHealth{Instance: instance, Shard: sb.ShardID, Connected: sb.connected.Get(), HeartbeatAge: -1}
+	s.HeartbeatsMissed = atomic.LoadUint32(&sb.heartbeatMissed)
+	if last := atomic.LoadUint32(&sb.heartbeatLast); last != 0 {
+		s.HeartbeatAge = clockAge(last).Seconds()
+	}
+
+	var busy time.Duration
+	sb.GuildsLock.RLock()
+	for _, info := range sb.Guilds {
+		if stats := info.QueueStats(); stats.Busy > busy {
+			busy = stats.Busy
+		}
+	}
+	sb.GuildsLock.RUnlock()
+
+	s.Loops = []LoopHealth{
+		clockLoop("idlecheck", atomic.LoadUint32(&sb.idleChecked), healthLoopTimeout(sb.TickInterval)),
+		clockLoop("deadlockdetector", atomic.LoadUint32(&sb.deadlockChecked), healthLoopTimeout(heartbeatInterval)),
+		{"guildworkers", busy.Seconds(), busy < healthWorkerTimeout},
+	}
+	return s
+}
+
+// Health checks every shard of every instance, along with the database
+func (sb *SweetieBot) Health() *Health {
+	h := &Health{Healthy: true, Database: sb.DB.CheckStatus(), Shards: []ShardHealth{}}
+	connected := true
+	if !h.Database {
+		h.Problems = append(h.Problems, "The database is down")
+	}
+	for i, instance := range append([]*SweetieBot{sb}, sb.Instances...) {
+		for _, shard := range instance.Shards {
+			s := shard.health(i)
+			h.Shards = append(h.Shards, s)
+			prefix := fmt.Sprintf("Instance %v shard %v: ", i, s.Shard)
+			if !s.Connected {
+				connected = false
+				h.Problems = append(h.Problems, prefix+"not connected to discord")
+			}
+			if s.HeartbeatsMissed >= healthMissedHeartbeats {
+				h.Healthy = false
+				h.Problems = append(h.Problems, prefix+fmt.Sprintf("missed %v heartbeats in a row", s.HeartbeatsMissed))
+			}
+			for _, loop := range s.Loops {
+				if !loop.Alive {
+					h.Healthy = false
+					h.Problems = append(h.Problems, prefix+loop.Name+" is stuck")
+				}
+			}
+		}
+	}
+	h.Ready = h.Healthy && h.Database && connected
+	return h
+}
+
+func writeHealth(w http.ResponseWriter, h *Health, ok bool) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if !ok {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(h)
+}
+
+// serveHealth responds with 503 if the bot is wedged and should be restarted
+func (sb *SweetieBot) serveHealth(w http.ResponseWriter, r *http.Request) {
+	h := sb.Health()
+	writeHealth(w, h, h.Healthy)
+}
+
+// serveReady responds with 503 if the bot can't serve commands right now, even if it might recover on its own
+func (sb *SweetieBot) serveReady(w http.ResponseWriter, r *http.Request) {
+	h := sb.Health()
+	writeHealth(w, h, h.Ready)
+}
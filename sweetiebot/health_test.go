@@ -0,0 +1,55 @@
+package sweetiebot
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHealth(t *testing.T) {
+	sb := &SweetieBot{DB: sqliteBotDB(t), TickInterval: time.Second, Guilds: make(map[DiscordGuild]*GuildInfo)}
+	defer sb.DB.Close()
+	sb.Shards = []*SweetieBot{sb}
+	sb.connected.Set(true)
+	atomic.StoreUint32(&sb.idleChecked, heartbeatClock())
+	atomic.StoreUint32(&sb.deadlockChecked, heartbeatClock())
+
+	h := sb.Health()
+	Check(h.Healthy, true, t)
+	Check(h.Ready, true, t)
+	Check(len(h.Problems), 0, t)
+	Check(h.Shards[0].HeartbeatAge, -1.0, t)
+
+	sb.connected.Set(false)
+	h = sb.Health()
+	Check(h.Healthy, true, t)
+	Check(h.Ready, false, t)
+	sb.connected.Set(true)
+
+	atomic.StoreUint32(&sb.idleChecked, heartbeatClock()-uint32(2*time.Minute/time.Millisecond))
+	atomic.StoreUint32(&sb.heartbeatMissed, healthMissedHeartbeats)
+	h = sb.Health()
+	Check(h.Healthy, false, t)
+	Check(h.Ready, false, t)
+	Check(h.Shards[0].Loops[0].Alive, false, t)
+	Check(h.Shards[0].Loops[1].Alive, true, t)
+	Check(strings.Join(h.Problems, "\n"), "Instance 0 shard 0: missed 3 heartbeats in a row\nInstance 0 shard 0: idlecheck is stuck", t)
+
+	w := httptest.NewRecorder()
+	sb.serveHealth(w, httptest.NewRequest("GET", "/healthz", nil))
+	Check(w.Code, http.StatusServiceUnavailable, t)
+	result := Health{}
+	Check(json.Unmarshal(w.Body.Bytes(), &result), nil, t)
+	Check(result.Healthy, false, t)
+	Check(len(result.Shards), 1, t)
+
+	atomic.StoreUint32(&sb.idleChecked, heartbeatClock())
+	atomic.StoreUint32(&sb.heartbeatMissed, 0)
+	w = httptest.NewRecorder()
+	sb.serveReady(w, httptest.NewRequest("GET", "/readyz", nil))
+	Check(w.Code, http.StatusOK, t)
+}
@@ -0,0 +1,417 @@
+package sweetiebot
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/blackhole12/discordgo"
+)
+
+// Our discordgo fork predates application commands, so the interaction types are defined here and decoded straight
+// from the raw gateway event.
+const (
+	interactionTypeApplicationCommand = 2
+	interactionResponseDeferred       = 5
+	appCommandOptionString            = 3
+	appCommandOptionInteger           = 4
+	appCommandOptionUser              = 6
+	appCommandOptionChannel           = 7
+	appCommandOptionRole              = 8
+	maxSlashChoices                   = 25
+	maxSlashCommands                  = 100
+	maxSlashOptions                   = 25
+	maxSlashName                      = 32
+	maxSlashDescription               = 100
+)
+
+// InteractionOption is a single value passed to a slash command
+type InteractionOption struct {
+	Name  string      `json:"name"`
+	Type  int         `json:"type"`
+	Value interface{} `json:"value"`
+}
+
+// InteractionData holds the name and options of the slash command that was used
+type InteractionData struct {
+	ID      string              `json:"id"`
+	Name    string              `json:"name"`
+	Options []InteractionOption `json:"options"`
+}
+
+// Interaction is sent by discord when someone uses one of our slash commands
+type Interaction struct {
+	ID            string            `json:"id"`
+	ApplicationID string            `json:"application_id"`
+	Type          int               `json:"type"`
+	Data          InteractionData   `json:"data"`
+	GuildID       string            `json:"guild_id"`
+	ChannelID     string            `json:"channel_id"`
+	Member        *discordgo.Member `json:"member"`
+	User          *discordgo.User   `json:"user"`
+	Token         string            `json:"token"`
+}
+
+// ApplicationCommandChoice is one of the fixed values a slash command option can take
+type ApplicationCommandChoice struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// ApplicationCommandOption describes a single parameter of a slash command
+type ApplicationCommandOption struct {
+	Type        int                        `json:"type"`
+	Name        string                     `json:"name"`
+	Description string                     `json:"description"`
+	Required    bool                       `json:"required"`
+	Choices     []ApplicationCommandChoice `json:"choices,omitempty"`
+	MinValue    *int64                     `json:"min_value,omitempty"`
+	MaxValue    *int64                     `json:"max_value,omitempty"`
+}
+
+// ApplicationCommand is a slash command definition as registered with discord
+type ApplicationCommand struct {
+	Name        string                     `json:"name"`
+	Description string                     `json:"description"`
+	Options     []ApplicationCommandOption `json:"options,omitempty"`
+}
+
+type interactionCallback struct {
+	Type int `json:"type"`
+}
+
+type webhookMessage struct {
+	Content string                    `json:"content,omitempty"`
+	Embeds  []*discordgo.MessageEmbed `json:"embeds,omitempty"`
+}
+
+func endpointGuildCommands(appID string, guildID string) string {
+	return discordgo.EndpointAPI + "applications/" + appID + "/guilds/" + guildID + "/commands"
+}
+
+func endpointInteractionCallback(interactionID string, token string) string {
+	return discordgo.EndpointAPI + "interactions/" + interactionID + "/" + token + "/callback"
+}
+
+func endpointInteractionWebhook(appID string, token string) string {
+	return discordgo.EndpointAPI + "webhooks/" + appID + "/" + token
+}
+
+func endpointInteractionOriginal(appID string, token string) string {
+	return endpointInteractionWebhook(appID, token) + "/messages/@original"
+}
+
+// slashName turns a command or parameter name into something discord accepts as a slash command name
+func slashName(s string) string {
+	name := make([]byte, 0, len(s))
+	for _, c := range []byte(strings.ToLower(s)) {
+		if (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '-' || c == '_' {
+			name = append(name, c)
+		}
+	}
+	if len(name) > maxSlashName {
+		name = name[:maxSlashName]
+	}
+	return string(name)
+}
+
+// slashDescription trims a description to discord's limit, falling back to the name if it's empty
+func slashDescription(desc string, fallback string) string {
+	desc = strings.TrimSpace(strings.Replace(desc, "`", "", -1))
+	if len(desc) == 0 {
+		desc = fallback
+	}
+	if utf8.RuneCountInString(desc) > maxSlashDescription {
+		desc = string([]rune(desc)[:maxSlashDescription-3]) + "..."
+	}
+	return desc
+}
+
+// slashOptionNames maps each parameter of a command to a unique option name
+func slashOptionNames(params []CommandUsageParam) []string {
+	names := make([]string, len(params))
+	used := make(map[string]bool)
+	for i, p := range params {
+		name := slashName(p.Name)
+		if len(name) == 0 || used[name] {
+			name = slashName(fmt.Sprintf("%s%v", name, i+1))
+		}
+		used[name] = true
+		names[i] = name
+	}
+	return names
+}
+
+// slashOption turns a command parameter into a slash command option. Typed parameters get the matching option type,
+// so discord can offer a user, role or channel picker instead of a text box.
+func slashOption(p CommandUsageParam, name string, required bool) ApplicationCommandOption {
+	option := ApplicationCommandOption{
+		Type:        appCommandOptionString,
+		Name:        name,
+		Description: slashDescription(p.Desc, p.Name),
+		Required:    required,
+	}
+	switch p.Type {
+	case ParamUser:
+		option.Type = appCommandOptionUser
+	case ParamRole:
+		option.Type = appCommandOptionRole
+	case ParamChannel:
+		option.Type = appCommandOptionChannel
+	case ParamInt:
+		option.Type = appCommandOptionInteger
+		if p.Min != 0 || p.Max != 0 {
+			min, max := p.Min, p.Max
+			option.MinValue = &min
+			option.MaxValue = &max
+		}
+	case ParamEnum:
+		if len(p.Values) <= maxSlashChoices {
+			for _, v := range p.Values {
+				option.Choices = append(option.Choices, ApplicationCommandChoice{v, v})
+			}
+		}
+	}
+	return option
+}
+
+// slashCommandEnabled returns true if the command should be offered as a slash command on this guild
+func (info *GuildInfo) slashCommandEnabled(name CommandID, c Command) bool {
+	if c.Info().Restricted || (c.Info().MainInstance && !info.Bot.IsMainGuild(info)) {
+		return false
+	}
+	if _, disabled := info.Config.Modules.CommandDisabled[name]; disabled {
+		return false
+	}
+	_, disabled := info.Config.Modules.Disabled[info.commandmap[name]]
+	return !disabled
+}
+
+// SlashCommands generates the slash command definitions for every enabled command on this guild
+func (info *GuildInfo) SlashCommands() []ApplicationCommand {
+	cmds := []ApplicationCommand{}
+	if !info.Config.Basic.SlashCommands {
+		return cmds
+	}
+	names := make([]string, 0, len(info.commands))
+	for k := range info.commands {
+		names = append(names, string(k))
+	}
+	sort.Strings(names) // Keeps the generated list stable so we don't re-register commands that haven't changed
+
+	for _, k := range names {
+		c := info.commands[CommandID(k)]
+		name := slashName(k)
+		if len(name) == 0 || name != k || !info.slashCommandEnabled(CommandID(k), c) {
+			continue
+		}
+		if len(cmds) >= maxSlashCommands {
+			break // Discord won't accept any more than this
+		}
+		cmd := ApplicationCommand{Name: name, Description: slashDescription(c.Info().Usage, c.Info().Name)}
+		if usage := c.Usage(info); usage != nil {
+			optional := false
+			params := usage.Params
+			if len(params) > maxSlashOptions {
+				params = params[:maxSlashOptions]
+			}
+			for i, option := range slashOptionNames(params) {
+				optional = optional || params[i].Optional // discord requires all required options to come first
+				cmd.Options = append(cmd.Options, slashOption(params[i], option, !optional))
+			}
+		}
+		cmds = append(cmds, cmd)
+	}
+	return cmds
+}
+
+// UpdateSlashCommands registers this guild's slash commands with discord if they have changed since the last time
+// they were registered.
+func (info *GuildInfo) UpdateSlashCommands() error {
+	if info.Bot.AppID == 0 {
+		return nil // We'll try again once the bot has found out what its application ID is
+	}
+	body, err := json.Marshal(info.SlashCommands())
+	if err != nil {
+		return err
+	}
+
+	info.slashLock.Lock()
+	defer info.slashLock.Unlock()
+	if info.slashCommands == nil && !info.Config.Basic.SlashCommands {
+		return nil // Nothing was ever registered, so there's nothing to remove
+	}
+	if bytes.Equal(info.slashCommands, body) {
+		return nil
+	}
+	if _, err = info.Bot.DG.RequestWithBucketID("PUT", endpointGuildCommands(SBitoa(info.Bot.AppID), info.ID), json.RawMessage(body), endpointGuildCommands("", "")); err != nil {
+		info.LogError("Failed to register slash commands: ", err)
+		return err
+	}
+	info.slashCommands = body
+	return nil
+}
+
+// InteractionCreate discord hook. Our discordgo fork doesn't know about interactions, so we have to pick them out of
+// the raw event stream.
+func (sb *SweetieBot) InteractionCreate(s *discordgo.Session, e *discordgo.Event) {
+	if e.Type != "INTERACTION_CREATE" {
+		return
+	}
+	i := &Interaction{}
+	if err := json.Unmarshal(e.RawData, i); err != nil || i.Type != interactionTypeApplicationCommand || i.Member == nil || i.Member.User == nil {
+		return
+	}
+	info := sb.getChannelGuild(i.ChannelID)
+	if info == nil || !info.Config.Basic.SlashCommands || boolXOR(sb.Debug, info.IsDebug(DiscordChannel(i.ChannelID))) {
+		return
+	}
+
+	// Discord only gives us 3 seconds to acknowledge an interaction, which isn't enough for some commands.
+	body, _ := json.Marshal(interactionCallback{interactionResponseDeferred})
+	if _, err := sb.DG.RequestWithBucketID("POST", endpointInteractionCallback(i.ID, i.Token), json.RawMessage(body), endpointInteractionCallback("", "")); err != nil {
+		info.Logger().With("channel", i.ChannelID).LogError("Failed to acknowledge interaction: ", err)
+		return
+	}
+
+	r := &interactionResponse{interaction: i}
+	m := &discordgo.Message{
+		ID:        i.ID,
+		ChannelID: i.ChannelID,
+		Content:   info.slashContent(i),
+		Timestamp: discordgo.Timestamp(time.Now().UTC().Format(time.RFC3339)),
+		Author:    i.Member.User,
+		Type:      discordgo.MessageTypeDefault,
+	}
+	sb.processCommand(m, info, time.Now().UTC().Unix(), info.IsDebug(DiscordChannel(i.ChannelID)), false, r)
+	r.finish(sb)
+}
+
+// slashContent turns an interaction back into the command it stands for, so it can be processed like any other
+func (info *GuildInfo) slashContent(i *Interaction) string {
+	content := info.Prefix(DiscordChannel(i.ChannelID)) + i.Data.Name
+	c, ok := info.commands[CommandID(i.Data.Name)]
+	if !ok {
+		return content
+	}
+	usage := c.Usage(info)
+	if usage == nil {
+		return content
+	}
+
+	values := make(map[string]string)
+	for _, option := range i.Data.Options {
+		if f, ok := option.Value.(float64); ok { // Integers are decoded as floats, and fmt would print big ones as 1e+06
+			values[option.Name] = strconv.FormatFloat(f, 'f', -1, 64)
+		} else {
+			values[option.Name] = fmt.Sprint(option.Value)
+		}
+	}
+	for k, name := range slashOptionNames(usage.Params) {
+		v, ok := values[name]
+		if !ok || len(v) == 0 {
+			continue
+		}
+		switch usage.Params[k].Type { // Discord gives us IDs, which have to be turned back into mentions
+		case ParamUser:
+			v = "<@" + v + ">"
+		case ParamRole:
+			v = "<@&" + v + ">"
+		case ParamChannel:
+			v = "<#" + v + ">"
+		}
+		if !usage.Params[k].Variadic && strings.ContainsAny(v, " \t\n") && !strings.Contains(v, "\"") {
+			v = "\"" + v + "\""
+		}
+		content += " " + v
+	}
+	return content
+}
+
+// interactionResponse sends anything a command posts to the channel it was used in as the response to the interaction.
+// The first message replaces the deferred response, and everything after it is sent as a followup.
+type interactionResponse struct {
+	interaction *Interaction
+	lock        sync.Mutex
+	replied     bool
+}
+
+func (r *interactionResponse) SendError(info *GuildInfo, channelID DiscordChannel, message string, t int64) {
+	if !channelID.Equals(r.interaction.ChannelID) {
+		info.SendError(channelID, message, t)
+		return
+	}
+	r.SendMessage(info, channelID, "```\n"+message+"```") // Always answer the interaction, even if normal errors are on cooldown
+}
+
+func (r *interactionResponse) SendMessage(info *GuildInfo, channelID DiscordChannel, message string) error {
+	if !channelID.Equals(r.interaction.ChannelID) {
+		return info.SendMessage(channelID, message)
+	}
+	for _, part := range splitMessage(message) {
+		if _, err := r.send(info, &webhookMessage{Content: part}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *interactionResponse) SendEmbed(info *GuildInfo, channelID DiscordChannel, embed *discordgo.MessageEmbed) error {
+	if !channelID.Equals(r.interaction.ChannelID) {
+		return info.SendEmbed(channelID, embed)
+	}
+	fields := embed.Fields
+	for len(fields) > 25 {
+		embed.Fields = fields[:25]
+		fields = fields[25:]
+		if _, err := r.send(info, &webhookMessage{Embeds: []*discordgo.MessageEmbed{embed}}); err != nil {
+			return err
+		}
+	}
+	embed.Fields = fields
+	_, err := r.send(info, &webhookMessage{Embeds: []*discordgo.MessageEmbed{embed}})
+	return err
+}
+
+func (r *interactionResponse) SendPage(info *GuildInfo, channelID DiscordChannel, embed *discordgo.MessageEmbed) (*discordgo.Message, error) {
+	if !channelID.Equals(r.interaction.ChannelID) {
+		return info.sendPage(channelID, embed)
+	}
+	return r.send(info, &webhookMessage{Embeds: []*discordgo.MessageEmbed{embed}})
+}
+
+// send returns the message that was sent, so a paginated result can be edited later. Discord only returns followup
+// messages if we ask it to wait for them.
+func (r *interactionResponse) send(info *GuildInfo, data *webhookMessage) (m *discordgo.Message, err error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	i := r.interaction
+	var body []byte
+	if r.replied {
+		body, err = info.Bot.DG.RequestWithBucketID("POST", endpointInteractionWebhook(i.ApplicationID, i.Token)+"?wait=true", data, endpointInteractionWebhook(i.ApplicationID, ""))
+	} else {
+		body, err = info.Bot.DG.RequestWithBucketID("PATCH", endpointInteractionOriginal(i.ApplicationID, i.Token), data, endpointInteractionOriginal(i.ApplicationID, ""))
+	}
+	if err == nil {
+		r.replied = true
+		m = &discordgo.Message{}
+		err = json.Unmarshal(body, m)
+	}
+	return
+}
+
+// finish removes the deferred response if the command never said anything, otherwise it would say it's thinking forever
+func (r *interactionResponse) finish(sb *SweetieBot) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if !r.replied {
+		i := r.interaction
+		sb.DG.RequestWithBucketID("DELETE", endpointInteractionOriginal(i.ApplicationID, i.Token), nil, endpointInteractionOriginal(i.ApplicationID, ""))
+	}
+}
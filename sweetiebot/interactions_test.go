@@ -0,0 +1,52 @@
+package sweetiebot
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSlashName(t *testing.T) {
+	Check(slashName("SetConfig"), "setconfig", t)
+	Check(slashName("tag(s)"), "tags", t)
+	Check(slashName("Moderator Role"), "moderatorrole", t)
+	Check(slashName("-_-"), "-_-", t)
+	Check(slashName("???"), "", t)
+	Check(slashName(strings.Repeat("a", 40)), strings.Repeat("a", 32), t)
+}
+
+func TestSlashDescription(t *testing.T) {
+	Check(slashDescription("", "fallback"), "fallback", t)
+	Check(slashDescription(" `code` ", "fallback"), "code", t)
+	long := slashDescription(strings.Repeat("a", 150), "")
+	Check(len(long), 100, t)
+	Check(long[97:], "...", t)
+}
+
+func TestSlashOptionNames(t *testing.T) {
+	names := slashOptionNames([]CommandUsageParam{{Name: "user"}, {Name: "User"}, {Name: "???"}, {Name: "tag(s)"}})
+	Check(len(names), 4, t)
+	Check(names[0], "user", t)
+	Check(names[1], "user2", t)
+	Check(names[2], "3", t)
+	Check(names[3], "tags", t)
+}
+
+func TestSlashCommands(t *testing.T) {
+	sb, _, _ := MockSweetieBot(t)
+
+	for _, info := range sb.Guilds {
+		Check(len(info.SlashCommands()), 0, t)
+		info.Config.Basic.SlashCommands = true
+		for _, c := range info.SlashCommands() {
+			Check(c.Name, slashName(c.Name), t)
+			optional := false
+			for _, o := range c.Options {
+				if optional {
+					Check(o.Required, false, t)
+				}
+				optional = !o.Required
+			}
+		}
+		info.Config.Basic.SlashCommands = false
+	}
+}
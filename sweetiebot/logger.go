@@ -1,24 +1,203 @@
 package sweetiebot
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
-type logger interface {
-	Log(args ...interface{})
-	LogError(msg string, err error)
+// LogLevel is how important a log entry is. Everywhere logs go has a lowest level it accepts, so less important
+// entries can be filtered out.
+type LogLevel int
+
+// Log levels, from least to most important
+const (
+	LogDebug LogLevel = iota
+	LogInfo
+	LogWarning
+	LogError
+)
+
+var logLevelNames = []string{"debug", "info", "warning", "error"}
+
+func (l LogLevel) String() string {
+	if l < LogDebug || int(l) >= len(logLevelNames) {
+		return strconv.Itoa(int(l))
+	}
+	return logLevelNames[l]
 }
 
-type emptyLog struct{}
+// ParseLogLevel turns the name of a log level, like "warning", back into a LogLevel
+func ParseLogLevel(s string) (LogLevel, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "warn" {
+		return LogWarning, nil
+	}
+	for i, name := range logLevelNames {
+		if s == name {
+			return LogLevel(i), nil
+		}
+	}
+	return LogInfo, fmt.Errorf("%s is not a log level! Use one of: %s", s, strings.Join(logLevelNames, ", "))
+}
+
+// MarshalText stores the level by name in config files
+func (l LogLevel) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+// UnmarshalText reads a level stored by MarshalText
+func (l *LogLevel) UnmarshalText(b []byte) error {
+	level, err := ParseLogLevel(string(b))
+	if err == nil {
+		*l = level
+	}
+	return err
+}
+
+// LogField is a piece of information attached to a log entry, like the guild or command it came from
+type LogField struct {
+	Key   string
+	Value interface{}
+}
+
+// Logger writes log entries to the console. A logger for a guild also saves them to the debuglog table and posts them
+// in log.channel, depending on log.level and log.channellevel. A nil Logger writes to stdout.
+type Logger struct {
+	out    *logOutput
+	guild  *GuildInfo
+	fields []LogField
+}
+
+// logOutput is where entries are written to the console. It's shared by a logger and everything derived from it.
+type logOutput struct {
+	lock  sync.Mutex
+	w     io.Writer
+	level LogLevel
+	json  bool
+}
+
+var defaultLogger = NewLogger(os.Stdout, LogInfo, false)
+
+// NewLogger creates a logger that writes every entry at or above level to w, either as text or as one JSON object
+// per line
+func NewLogger(w io.Writer, level LogLevel, json bool) *Logger {
+	return &Logger{out: &logOutput{w: w, level: level, json: json}}
+}
+
+func (l *Logger) get() *Logger {
+	if l == nil {
+		return defaultLogger
+	}
+	return l
+}
 
-func (log *emptyLog) Log(args ...interface{}) {
-	s := fmt.Sprint(args...)
-	fmt.Printf("[%s] %s\n", time.Now().Format(time.Stamp), s)
+// With returns a logger that adds a field to every entry it writes
+func (l *Logger) With(key string, value interface{}) *Logger {
+	l = l.get()
+	fields := make([]LogField, len(l.fields), len(l.fields)+1)
+	copy(fields, l.fields)
+	return &Logger{l.out, l.guild, append(fields, LogField{key, value})}
 }
 
-func (log *emptyLog) LogError(msg string, err error) {
+// Guild returns a logger that adds the guild to every entry and also sends them to the guild's own logs
+func (l *Logger) Guild(info *GuildInfo) *Logger {
+	g := l.With("guild", info.ID)
+	g.guild = info
+	return g
+}
+
+// console returns a logger with the same fields that only writes to the console, for errors that happen while sending
+// log entries somewhere else
+func (l *Logger) console() *Logger {
+	l = l.get()
+	return &Logger{l.out, nil, l.fields}
+}
+
+// Debug logs information that is only useful when tracking down a problem
+func (l *Logger) Debug(args ...interface{}) {
+	l.write(LogDebug, fmt.Sprint(args...))
+}
+
+// Info logs something that happened normally
+func (l *Logger) Info(args ...interface{}) {
+	l.write(LogInfo, fmt.Sprint(args...))
+}
+
+// Warning logs something that went wrong, but that the bot can carry on from
+func (l *Logger) Warning(args ...interface{}) {
+	l.write(LogWarning, fmt.Sprint(args...))
+}
+
+// Error logs a failure
+func (l *Logger) Error(args ...interface{}) {
+	l.write(LogError, fmt.Sprint(args...))
+}
+
+// LogError logs an error only if it exists
+func (l *Logger) LogError(msg string, err error) {
 	if err != nil {
-		log.Log(msg, err.Error())
+		l.write(LogError, msg+err.Error())
+	}
+}
+
+func (l *Logger) write(level LogLevel, msg string) {
+	l = l.get()
+	if level >= l.out.level {
+		l.out.write(time.Now(), level, msg, l.fields)
+	}
+	info := l.guild
+	if info == nil {
+		return
+	}
+	if level >= info.Config.Log.Level && info.Bot != nil && info.Bot.DB != nil && info.Bot.DB.Status.Get() {
+		fields := []LogField{}
+		for _, f := range l.fields {
+			if f.Key != "guild" { // The debuglog table already has a column for the guild
+				fields = append(fields, f)
+			}
+		}
+		info.Bot.DB.Audit(AuditTypeLog, nil, formatLogText(level, msg, fields), SBatoi(info.ID))
+	}
+	if level >= info.Config.Log.ChannelLevel && info.Config.Log.Channel != ChannelEmpty {
+		info.SendMessage(info.Config.Log.Channel, "```\n"+msg+"```")
+	}
+}
+
+func (o *logOutput) write(t time.Time, level LogLevel, msg string, fields []LogField) {
+	var line string
+	if o.json {
+		entry := map[string]interface{}{"time": t.UTC().Format(time.RFC3339), "level": level.String(), "msg": msg}
+		for _, f := range fields {
+			entry[f.Key] = f.Value
+		}
+		data, err := json.Marshal(entry)
+		if err != nil {
+			data, _ = json.Marshal(map[string]string{"time": t.UTC().Format(time.RFC3339), "level": level.String(), "msg": msg})
+		}
+		line = string(data) + "\n"
+	} else {
+		line = "[" + t.Format(time.Stamp) + "] " + formatLogText(level, msg, fields) + "\n"
+	}
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	io.WriteString(o.w, line)
+}
+
+// formatLogText writes an entry as a single line, with fields at the end like guild=1234
+func formatLogText(level LogLevel, msg string, fields []LogField) string {
+	s := strings.ToUpper(level.String()) + " " + msg
+	for _, f := range fields {
+		v := fmt.Sprint(f.Value)
+		if len(v) == 0 || strings.ContainsAny(v, " \"=\n") {
+			v = strconv.Quote(v)
+		}
+		s += " " + f.Key + "=" + v
 	}
+	return s
 }
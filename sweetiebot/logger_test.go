@@ -0,0 +1,71 @@
+package sweetiebot
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestLoggerText(t *testing.T) {
+	t.Parallel()
+
+	var b bytes.Buffer
+	l := NewLogger(&b, LogInfo, false)
+	l.Debug("hidden")
+	l.With("guild", "1234").With("command", "pick").Warning("Something ", 2, " happened")
+	line := b.String()
+	Check(strings.Count(line, "\n"), 1, t)
+	Check(strings.HasSuffix(line, "] WARNING Something 2 happened guild=1234 command=pick\n"), true, t)
+
+	b.Reset()
+	l.With("msg", "two words").LogError("Failed: ", nil)
+	Check(b.Len(), 0, t)
+	l.With("msg", "two words").LogError("Failed", errConfigFileTooLarge)
+	Check(strings.HasSuffix(b.String(), " ERROR Failed"+errConfigFileTooLarge.Error()+" msg=\"two words\"\n"), true, t)
+}
+
+func TestLoggerJSON(t *testing.T) {
+	t.Parallel()
+
+	var b bytes.Buffer
+	l := NewLogger(&b, LogDebug, true)
+	parent := l.With("guild", "1234")
+	parent.With("channel", "5678").Debug("Hello")
+	parent.Info("World")
+
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	Check(len(lines), 2, t)
+	entry := map[string]interface{}{}
+	Check(json.Unmarshal([]byte(lines[0]), &entry), nil, t)
+	Check(entry["level"], "debug", t)
+	Check(entry["msg"], "Hello", t)
+	Check(entry["guild"], "1234", t)
+	Check(entry["channel"], "5678", t)
+	entry = map[string]interface{}{}
+	Check(json.Unmarshal([]byte(lines[1]), &entry), nil, t)
+	Check(entry["msg"], "World", t)
+	_, ok := entry["channel"]
+	Check(ok, false, t)
+}
+
+func TestParseLogLevel(t *testing.T) {
+	t.Parallel()
+
+	for _, c := range []struct {
+		s     string
+		level LogLevel
+	}{{"debug", LogDebug}, {"Info", LogInfo}, {"warn", LogWarning}, {" WARNING ", LogWarning}, {"error", LogError}} {
+		level, err := ParseLogLevel(c.s)
+		Check(err, nil, t)
+		Check(level, c.level, t)
+	}
+	_, err := ParseLogLevel("loud")
+	CheckNot(err, nil, t)
+
+	var level LogLevel
+	Check(json.Unmarshal([]byte(`"error"`), &level), nil, t)
+	Check(level, LogError, t)
+	data, _ := json.Marshal(LogWarning)
+	Check(string(data), `"warning"`, t)
+}
@@ -0,0 +1,243 @@
+package sweetiebot
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// metricVec is a family of counters or histograms that share a name and are told apart by the values of their labels.
+// It is written out in the prometheus text format by /metrics.
+type metricVec struct {
+	name    string
+	help    string
+	kind    string // "counter" or "histogram"
+	labels  []string
+	buckets []float64 // Upper bound of each histogram bucket, not counting +Inf
+	lock    sync.Mutex
+	series  map[string]*metricSeries
+}
+
+type metricSeries struct {
+	values  []string
+	sum     float64  // The value of a counter, or the sum of every observation of a histogram
+	count   uint64   // How many observations a histogram has seen
+	buckets []uint64 // How many observations fell into each bucket. They are only added up when written.
+}
+
+var commandBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+var statementBuckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5}
+var waitBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+var metricCommands = newCounter("sweetiebot_commands_total", "Commands run, by command and outcome. The outcome is ok if the command ran, or the name of the middleware that stopped it.", "command", "outcome")
+var metricCommandDuration = newHistogram("sweetiebot_command_duration_seconds", "How long commands took to go through the middleware, including sending the response.", commandBuckets, "command")
+var metricStatementDuration = newHistogram("sweetiebot_db_statement_duration_seconds", "How long each prepared database statement took to run.", statementBuckets, "statement")
+var metricStatementErrors = newCounter("sweetiebot_db_errors_total", "Unexpected database errors, by the statement that caused them.", "statement")
+var metricRateLimitWaits = newHistogram("sweetiebot_ratelimit_wait_seconds", "How long discord told us to wait before sending more requests. The reason is exhausted when a bucket ran out, ratelimited when a request was rejected, or global when every request was rejected.", waitBuckets, "reason")
+var metricSpamSilences = newCounter("sweetiebot_spam_silences_total", "People the spam module silenced, by guild.", "guild")
+
+var allMetrics = []*metricVec{metricCommands, metricCommandDuration, metricStatementDuration, metricStatementErrors, metricRateLimitWaits, metricSpamSilences}
+
+func newCounter(name string, help string, labels ...string) *metricVec {
+	return &metricVec{name: name, help: help, kind: "counter", labels: labels, series: make(map[string]*metricSeries)}
+}
+
+func newHistogram(name string, help string, buckets []float64, labels ...string) *metricVec {
+	return &metricVec{name: name, help: help, kind: "histogram", labels: labels, buckets: buckets, series: make(map[string]*metricSeries)}
+}
+
+// get returns the series with the given label values, creating it if needed. The caller must hold the lock.
+func (m *metricVec) get(values []string) *metricSeries {
+	key := strings.Join(values, "\xff")
+	s, ok := m.series[key]
+	if !ok {
+		s = &metricSeries{values: values, buckets: make([]uint64, len(m.buckets))}
+		m.series[key] = s
+	}
+	return s
+}
+
+// Add adds delta to the counter with the given label values
+func (m *metricVec) Add(delta float64, values ...string) {
+	m.lock.Lock()
+	m.get(values).sum += delta
+	m.lock.Unlock()
+}
+
+// Observe records a single value in the histogram with the given label values
+func (m *metricVec) Observe(v float64, values ...string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	s := m.get(values)
+	s.sum += v
+	s.count++
+	for i, bound := range m.buckets {
+		if v <= bound {
+			s.buckets[i]++
+			break
+		}
+	}
+}
+
+func (m *metricVec) write(w io.Writer) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	writeMetricHeader(w, m.name, m.help, m.kind)
+	keys := make([]string, 0, len(m.series))
+	for k := range m.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		s := m.series[k]
+		if m.kind != "histogram" {
+			writeSample(w, m.name, m.labels, s.values, s.sum)
+			continue
+		}
+		labels := append(append([]string{}, m.labels...), "le")
+		var total uint64
+		for i, bound := range m.buckets {
+			total += s.buckets[i]
+			writeSample(w, m.name+"_bucket", labels, append(append([]string{}, s.values...), formatFloat(bound)), float64(total))
+		}
+		writeSample(w, m.name+"_bucket", labels, append(append([]string{}, s.values...), "+Inf"), float64(s.count))
+		writeSample(w, m.name+"_sum", m.labels, s.values, s.sum)
+		writeSample(w, m.name+"_count", m.labels, s.values, float64(s.count))
+	}
+}
+
+func writeMetricHeader(w io.Writer, name string, help string, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+var labelEscaper = strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "\n", "\\n")
+
+func writeSample(w io.Writer, name string, labels []string, values []string, v float64) {
+	io.WriteString(w, name)
+	if len(labels) > 0 {
+		pairs := make([]string, len(labels))
+		for i := range labels {
+			pairs[i] = labels[i] + "=\"" + labelEscaper.Replace(values[i]) + "\""
+		}
+		io.WriteString(w, "{"+strings.Join(pairs, ",")+"}")
+	}
+	io.WriteString(w, " "+formatFloat(v)+"\n")
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// commandMetrics counts every command by how far it got through the middleware, and measures how long it took. The
+// heartbeat is left out, because it would drown out how often !about is actually used.
+func commandMetrics(ctx *CommandContext, next func()) {
+	if ctx.Message.ChannelID == "heartbeat" {
+		next()
+		return
+	}
+	start := time.Now()
+	next()
+	outcome := ctx.stage
+	if outcome == "run" {
+		outcome = "ok"
+	}
+	metricCommands.Add(1, string(ctx.Name), outcome)
+	metricCommandDuration.Observe(time.Since(start).Seconds(), string(ctx.Name))
+}
+
+func countSpamSilence(info *GuildInfo, e *MemberSilenced) {
+	if e.Spam {
+		metricSpamSilences.Add(1, info.ID)
+	}
+}
+
+// rateLimitTransport watches the responses discord sends for rate limits. discordgo waits on its own, so this only
+// records how long it was told to wait.
+type rateLimitTransport struct {
+	base http.RoundTripper
+}
+
+func (t *rateLimitTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	resp, err := base.RoundTrip(r)
+	if err == nil {
+		observeRateLimit(resp.StatusCode, resp.Header)
+	}
+	return resp, err
+}
+
+func observeRateLimit(status int, header http.Header) {
+	if status == http.StatusTooManyRequests {
+		reason := "ratelimited"
+		if header.Get("X-RateLimit-Global") == "true" {
+			reason = "global"
+		}
+		wait, _ := strconv.ParseFloat(header.Get("Retry-After"), 64)
+		metricRateLimitWaits.Observe(wait, reason)
+	} else if header.Get("X-RateLimit-Remaining") == "0" {
+		if wait, err := strconv.ParseFloat(header.Get("X-RateLimit-Reset-After"), 64); err == nil {
+			metricRateLimitWaits.Observe(wait, "exhausted")
+		}
+	}
+}
+
+// watchRateLimits makes every request this instance sends to discord go through rateLimitTransport
+func (sb *SweetieBot) watchRateLimits() {
+	if sb.DG.Client == nil {
+		sb.DG.Client = &http.Client{Timeout: 20 * time.Second}
+	}
+	if _, ok := sb.DG.Client.Transport.(*rateLimitTransport); !ok {
+		sb.DG.Client.Transport = &rateLimitTransport{sb.DG.Client.Transport}
+	}
+}
+
+// serveMetrics writes every metric in the prometheus text format. Metrics that already exist elsewhere, like the
+// message count and the heartbeat, are read when they are asked for instead of being tracked twice.
+func (sb *SweetieBot) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	var messages uint64
+	for _, shard := range sb.AllInstances() {
+		messages += uint64(atomic.LoadUint32(&shard.MessageCount))
+	}
+	writeMetricHeader(w, "sweetiebot_messages_total", "Messages the bot has processed.", "counter")
+	writeSample(w, "sweetiebot_messages_total", nil, nil, float64(messages))
+
+	up := 0.0
+	if sb.DB.Status.Get() {
+		up = 1
+	}
+	writeMetricHeader(w, "sweetiebot_db_up", "1 if the database is working, or 0 if the bot is in No Database mode.", "gauge")
+	writeSample(w, "sweetiebot_db_up", nil, nil, up)
+	writeMetricHeader(w, "sweetiebot_start_time_seconds", "When the bot started, in unix time.", "gauge")
+	writeSample(w, "sweetiebot_start_time_seconds", nil, nil, float64(sb.StartTime))
+
+	labels := []string{"instance", "shard"}
+	writeMetricHeader(w, "sweetiebot_heartbeat_seconds", "How long the last heartbeat took to get through the command pipeline of each shard.", "gauge")
+	for i, instance := range append([]*SweetieBot{sb}, sb.Instances...) {
+		for _, shard := range instance.Shards {
+			latency, _ := shard.HeartbeatLatency()
+			writeSample(w, "sweetiebot_heartbeat_seconds", labels, []string{strconv.Itoa(i), strconv.Itoa(shard.ShardID)}, latency.Seconds())
+		}
+	}
+	writeMetricHeader(w, "sweetiebot_heartbeats_missed", "How many heartbeats each shard has missed in a row.", "gauge")
+	for i, instance := range append([]*SweetieBot{sb}, sb.Instances...) {
+		for _, shard := range instance.Shards {
+			_, missed := shard.HeartbeatLatency()
+			writeSample(w, "sweetiebot_heartbeats_missed", labels, []string{strconv.Itoa(i), strconv.Itoa(shard.ShardID)}, float64(missed))
+		}
+	}
+
+	for _, m := range allMetrics {
+		m.write(w)
+	}
+}
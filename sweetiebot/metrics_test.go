@@ -0,0 +1,74 @@
+package sweetiebot
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/blackhole12/discordgo"
+)
+
+func TestMetricsFormat(t *testing.T) {
+	t.Parallel()
+
+	counter := newCounter("test_total", "A counter.", "name")
+	counter.Add(1, "b")
+	counter.Add(2, "a\"\n")
+	counter.Add(1, "b")
+	histogram := newHistogram("test_seconds", "A histogram.", []float64{0.5, 1}, "name")
+	histogram.Observe(0.25, "x")
+	histogram.Observe(0.75, "x")
+	histogram.Observe(3, "x")
+
+	var b bytes.Buffer
+	counter.write(&b)
+	histogram.write(&b)
+	Check(b.String(), `# HELP test_total A counter.
+# TYPE test_total counter
+test_total{name="a\"\n"} 2
+test_total{name="b"} 2
+# HELP test_seconds A histogram.
+# TYPE test_seconds histogram
+test_seconds_bucket{name="x",le="0.5"} 1
+test_seconds_bucket{name="x",le="1"} 2
+test_seconds_bucket{name="x",le="+Inf"} 3
+test_seconds_sum{name="x"} 4
+test_seconds_count{name="x"} 3
+`, t)
+}
+
+func TestObserveRateLimit(t *testing.T) {
+	t.Parallel()
+
+	count := func(reason string) uint64 {
+		metricRateLimitWaits.lock.Lock()
+		defer metricRateLimitWaits.lock.Unlock()
+		return metricRateLimitWaits.get([]string{reason}).count
+	}
+	exhausted, global := count("exhausted"), count("global")
+	observeRateLimit(http.StatusOK, http.Header{"X-Ratelimit-Remaining": {"1"}, "X-Ratelimit-Reset-After": {"1.5"}})
+	Check(count("exhausted"), exhausted, t)
+	observeRateLimit(http.StatusOK, http.Header{"X-Ratelimit-Remaining": {"0"}, "X-Ratelimit-Reset-After": {"1.5"}})
+	Check(count("exhausted"), exhausted+1, t)
+	observeRateLimit(http.StatusTooManyRequests, http.Header{"X-Ratelimit-Global": {"true"}, "Retry-After": {"2"}})
+	Check(count("global"), global+1, t)
+}
+
+func TestCommandMetrics(t *testing.T) {
+	t.Parallel()
+
+	r := &recordResponse{}
+	info := &GuildInfo{Config: *DefaultConfig()}
+	info.Config.SetupDone = false
+	ctx := &CommandContext{Info: info, Message: &discordgo.Message{ChannelID: "1"}, Name: "metricstest", response: r}
+	runCommandStages(ctx, []commandStage{{"metrics", commandMetrics}, {"setup", commandSetup}, {"run", func(*CommandContext, func()) {}}})
+	Check(ctx.stage, "setup", t)
+	info.Config.SetupDone = true
+	runCommandStages(ctx, []commandStage{{"metrics", commandMetrics}, {"setup", commandSetup}, {"run", func(*CommandContext, func()) {}}})
+
+	var b bytes.Buffer
+	metricCommands.write(&b)
+	Check(strings.Contains(b.String(), `sweetiebot_commands_total{command="metricstest",outcome="setup"} 1`), true, t)
+	Check(strings.Contains(b.String(), `sweetiebot_commands_total{command="metricstest",outcome="ok"} 1`), true, t)
+}
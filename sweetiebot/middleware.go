@@ -0,0 +1,332 @@
+package sweetiebot
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/blackhole12/discordgo"
+)
+
+// CommandContext carries a single command through the middleware that decides whether, where and how it runs
+type CommandContext struct {
+	Bot      *SweetieBot
+	Info     *GuildInfo // The server the command runs on. Private messages may not have one until the command is resolved.
+	Message  *discordgo.Message
+	Prefix   string   // The prefix the command was typed with, which may be a mention of the bot
+	Args     []string // Args[0] is the name of the command as it was typed
+	Indices  []int
+	Command  Command   // The command that will run, or nil if it hasn't been resolved yet
+	Name     CommandID // Lowercase name of Command
+	Channel  DiscordChannel
+	Time     int64
+	Private  bool
+	Debug    bool
+	Free     bool  // Commands in free channels and private messages aren't rate limited
+	Bypass   bool  // True for users that ignore rate limits, like administrators and the bot owner
+	Denied   error // Why the user isn't allowed to run the command, if they aren't
+	Result   string
+	UsePM    bool
+	Embed    *discordgo.MessageEmbed
+	response commandResponse
+	stage    string // The last middleware the command reached
+}
+
+// SendError replies with an error, but only if the server hasn't seen too many errors recently
+func (ctx *CommandContext) SendError(message string) {
+	ctx.response.SendError(ctx.Info, ctx.Channel, message, ctx.Time)
+}
+
+// SendMessage replies to wherever the command came from
+func (ctx *CommandContext) SendMessage(message string) error {
+	return ctx.response.SendMessage(ctx.Info, ctx.Channel, message)
+}
+
+// Logger returns a logger for the server the command runs on that records which channel and command it came from
+func (ctx *CommandContext) Logger() *Logger {
+	return ctx.Info.Logger().With("channel", ctx.Channel).With("command", ctx.Name)
+}
+
+// CommandMiddleware is a single step a command passes through before it runs. Calling next passes the command on to
+// the next step, so a middleware can stop a command by returning without calling it, or do something after it has run
+// by looking at the result once next returns.
+type CommandMiddleware func(ctx *CommandContext, next func())
+
+type commandStage struct {
+	name string
+	run  CommandMiddleware
+}
+
+// defaultCommandStages is every step a command goes through, in order
+var defaultCommandStages = []commandStage{
+	{"resolve", commandResolve},
+	{"metrics", commandMetrics},
+	{"audit", commandAudit},
+	{"setup", commandSetup},
+	{"permissions", commandPermissions},
+	{"saturation", commandSaturation},
+	{"denied", commandDenied},
+	{"silver", commandSilver},
+	{"cooldown", commandCooldown},
+	{"respond", commandRespond},
+	{"modules", commandModules},
+	{"run", commandRun},
+}
+
+// AddCommandMiddleware inserts a middleware before the step called before, or right before the command runs if before
+// is empty. Every other step can be found by name the same way. This affects every shard and instance, and should be
+// called before the bot connects.
+func (sb *SweetieBot) AddCommandMiddleware(name string, before string, m CommandMiddleware) error {
+	if len(before) == 0 {
+		before = "run"
+	}
+	for _, instance := range sb.AllInstances() {
+		stages := instance.commandStages
+		if stages == nil {
+			stages = defaultCommandStages
+		}
+		i := 0
+		for i < len(stages) && stages[i].name != before {
+			i++
+		}
+		if i == len(stages) {
+			return fmt.Errorf("there is no command middleware called %s", before)
+		}
+		result := make([]commandStage, 0, len(stages)+1)
+		result = append(result, stages[:i]...)
+		result = append(result, commandStage{name, m})
+		instance.commandStages = append(result, stages[i:]...)
+	}
+	return nil
+}
+
+func runCommandStages(ctx *CommandContext, stages []commandStage) {
+	var step func(i int)
+	step = func(i int) {
+		if i < len(stages) {
+			ctx.stage = stages[i].name
+			stages[i].run(ctx, func() { step(i + 1) })
+		}
+	}
+	step(0)
+}
+
+// commandResolve finds the server and the command, following aliases
+func commandResolve(ctx *CommandContext, next func()) {
+	sb := ctx.Bot
+	arg := CommandID(strings.ToLower(ctx.Args[0]))
+	authorid := SBatoi(ctx.Message.Author.ID)
+	if ctx.Info == nil {
+		ctx.Info = sb.GetDefaultServer(authorid)
+	}
+	if ctx.Info == nil {
+		gIDs := []uint64{}
+		if _, independent := sb.EmptyGuild.commands[arg]; !independent {
+			if !sb.DB.Status.Get() {
+				sb.DG.ChannelMessageSend(ctx.Message.ChannelID, "```\nA temporary database error means I can't process any private message commands right now.```")
+				return
+			}
+			gIDs = sb.DB.GetUserGuilds(authorid)
+			if len(gIDs) != 1 {
+				sb.DG.ChannelMessageSend(ctx.Message.ChannelID, "```\nCannot determine what server you belong to! Use !defaultserver to set which server I should use when you PM me.```")
+				return
+			}
+		} else if sb.DB.Status.Get() {
+			gIDs = sb.DB.GetUserGuilds(authorid)
+		}
+
+		if len(gIDs) == 1 {
+			ctx.Info = sb.getGuildFromID(SBitoa(gIDs[0]))
+		}
+
+		if ctx.Info == nil {
+			ctx.Info = sb.EmptyGuild
+		}
+	}
+
+	info := ctx.Info
+	m := ctx.Message
+	c, ok := info.commands[arg] // First, we check if this matches an existing command so you can't alias yourself into a hole
+	if !ok {
+		if alias, aliasok := info.Config.Basic.Aliases[string(arg)]; aliasok {
+			if len(ctx.Indices) > 1 {
+				m.Content = ctx.Prefix + alias + " " + m.Content[ctx.Indices[1]:]
+			} else {
+				m.Content = ctx.Prefix + alias
+			}
+			ctx.Args, ctx.Indices = parseCommand(m.Content, len(ctx.Prefix))
+			arg = CommandID(strings.ToLower(ctx.Args[0]))
+			c, ok = info.commands[arg]
+		}
+	}
+	if !ok {
+		if !info.Config.Basic.IgnoreInvalidCommands && (ctx.Private || !info.checkOnCommand(m)) {
+			ctx.SendError("Sorry, " + ctx.Args[0] + " is not a valid command.\nFor a list of valid commands, type " + info.Prefix(ctx.Channel) + "help.")
+		}
+		return
+	}
+	ctx.Command = c
+	ctx.Name = CommandID(strings.ToLower(c.Info().Name))
+	next()
+}
+
+func commandAudit(ctx *CommandContext, next func()) {
+	if ctx.Bot.DB.Status.Get() && !ctx.Bot.SelfID.Equals(ctx.Message.Author.ID) {
+		ctx.Bot.DB.Audit(AuditTypeCommand, ctx.Message.Author, ctx.Message.Content, SBatoi(ctx.Info.ID))
+	}
+	next()
+}
+
+func commandSetup(ctx *CommandContext, next func()) {
+	if ctx.Message.ChannelID != "heartbeat" && !ctx.Info.Config.SetupDone && ctx.Name != CommandID("setup") {
+		ctx.SendError("You haven't set up the bot yet! Run the !setup command first and follow the instructions.")
+		return
+	}
+	next()
+}
+
+// commandPermissions checks whether the user can run the command here. Errors that should be shown to the user are
+// only reported after the saturation check, so denied commands still count towards the limit.
+func commandPermissions(ctx *CommandContext, next func()) {
+	info := ctx.Info
+	ignore := false
+	if !ctx.Private {
+		ignore = info.checkOnCommand(ctx.Message)
+	}
+
+	cch := info.Config.Modules.CommandChannels[ctx.Name]
+	if !ctx.Private && len(cch) > 0 {
+		_, reverse := cch["!"]
+		_, ok := cch[ctx.Channel]
+		ignore = ignore || ok == reverse
+	}
+
+	bypass, err := info.UserCanUseCommand(DiscordUser(ctx.Message.Author.ID), ctx.Command, ignore) // Bypass is true for administrators, mods, and the bot owner
+	if ctx.Message.ChannelID == "heartbeat" {                                                      // The heartbeat can never be ignored or disabled
+		bypass = true
+		err = nil
+	} else if err == errDisabled || err == errIgnored || err == errSilenced || err == errMainGuild {
+		return
+	}
+	ctx.Bypass = bypass
+	ctx.Denied = err
+	next()
+}
+
+// commandSaturation limits how many commands can be run on the whole server in a given amount of time
+func commandSaturation(ctx *CommandContext, next func()) {
+	info := ctx.Info
+	if !ctx.Debug && !ctx.Free && !ctx.Bypass && info.Config.Modules.CommandPerDuration > 0 { // debug channels aren't limited
+		if len(info.commandlimit.times) < info.Config.Modules.CommandPerDuration*2 { // Check if we need to re-allocate the array because the configuration changed
+			info.commandlimit.times = make([]int64, info.Config.Modules.CommandPerDuration*2, info.Config.Modules.CommandPerDuration*2)
+		}
+		if info.commandlimit.check(info.Config.Modules.CommandPerDuration, info.Config.Modules.CommandMaxDuration, ctx.Time) { // if we've hit the saturation limit, post an error (which itself will only post if the error saturation limit hasn't been hit)
+			ctx.SendError(fmt.Sprintf("You can't input more than %v commands every %s!%s", info.Config.Modules.CommandPerDuration, TimeDiff(time.Duration(info.Config.Modules.CommandMaxDuration)*time.Second), ctx.Bot.getAddMsg(info)))
+			return
+		}
+		info.commandlimit.append(ctx.Time)
+	}
+	next()
+}
+
+func commandDenied(ctx *CommandContext, next func()) {
+	if ctx.Denied != nil {
+		ctx.SendError(ctx.Denied.Error())
+		return
+	}
+	next()
+}
+
+func commandSilver(ctx *CommandContext, next func()) {
+	if ctx.Command.Info().Silver && !ctx.Info.Silver.Get() {
+		ctx.SendError("That command is for Silver supporters only. Server owners can donate $1 a month to gain access: " + PatreonURL + ". Visit the support channel for help if you already donated.")
+		return
+	}
+	next()
+}
+
+// commandCooldown enforces the channel, user and role cooldowns in the modules config. The command only runs if none of
+// them are active, and then all of them are started at once.
+func commandCooldown(ctx *CommandContext, next func()) {
+	info := ctx.Info
+	if !ctx.Free && !ctx.Bypass {
+		scopes := info.cooldownScopes(ctx)
+		for _, scope := range scopes {
+			if remaining := info.cooldownRemaining(scope.key, ctx.Time); remaining > 0 {
+				ctx.SendError(cooldownError(scope, remaining) + ctx.Bot.getAddMsg(info))
+				return
+			}
+		}
+		for _, scope := range scopes {
+			info.startCooldown(scope.key, ctx.Time+scope.duration)
+		}
+	}
+	next()
+}
+
+// commandRespond sends the result of the command once it has run, moving it to a private message if the command asked
+func commandRespond(ctx *CommandContext, next func()) {
+	next()
+	pages := ctx.Info.Bot.takePaginator(ctx.Message.ID)
+	if len(ctx.Result) == 0 && ctx.Embed == nil {
+		return
+	}
+	sb := ctx.Bot
+	r := ctx.response
+	targetchannel := ctx.Channel
+	if ctx.UsePM && !ctx.Private {
+		channel, err := sb.DG.UserChannelCreate(ctx.Message.Author.ID)
+		if err == nil {
+			targetchannel = DiscordChannel(channel.ID)
+			ctx.Private = true
+			if rand.Float32() < 0.01 {
+				ctx.SendMessage("Check your ~~privilege~~ Private Messages for my reply!")
+			} else {
+				ctx.SendMessage("```\nCheck your Private Messages for my reply!```")
+			}
+		} else {
+			ctx.SendError("I tried to send you a Private Message, but it failed! Try PMing me the command directly.")
+		}
+	}
+
+	if pages != nil && ctx.Embed != nil {
+		ctx.sendPages(targetchannel, pages)
+	} else if ctx.Embed != nil {
+		if err := r.SendEmbed(ctx.Info, targetchannel, ctx.Embed); err != nil {
+			ctx.Logger().LogError("Failed to send embed: ", err)
+		}
+	} else if err := r.SendMessage(ctx.Info, targetchannel, ctx.Result); err != nil {
+		ctx.Logger().LogError("Failed to send command result: ", err)
+	}
+}
+
+// commandModules runs the command middleware of every module that is enabled in this channel
+func commandModules(ctx *CommandContext, next func()) {
+	hooks := []ModuleCommandMiddleware{}
+	for _, h := range ctx.Info.hooks.CommandMiddleware {
+		if ctx.Private || ctx.Info.ProcessModule(ctx.Channel, h) {
+			hooks = append(hooks, h)
+		}
+	}
+	var step func(i int)
+	step = func(i int) {
+		if i < len(hooks) {
+			hooks[i].CommandMiddleware(ctx, func() { step(i + 1) })
+		} else {
+			next()
+		}
+	}
+	step(0)
+}
+
+func commandRun(ctx *CommandContext, next func()) {
+	info := ctx.Info
+	if typed, ok := ctx.Command.(TypedCommand); ok {
+		ctx.Result, ctx.UsePM, ctx.Embed = info.ProcessTyped(typed, ctx.Args[1:], ctx.Message, ctx.Indices[1:])
+	} else {
+		ctx.Result, ctx.UsePM, ctx.Embed = ctx.Command.Process(ctx.Args[1:], ctx.Message, ctx.Indices[1:], info)
+	}
+	info.Publish(&CommandExecuted{Command: ctx.Name, Args: ctx.Args[1:], Message: ctx.Message})
+	next()
+}
@@ -0,0 +1,105 @@
+package sweetiebot
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/blackhole12/discordgo"
+)
+
+type recordResponse struct {
+	sent []string
+}
+
+func (r *recordResponse) SendError(info *GuildInfo, channelID DiscordChannel, message string, t int64) {
+	r.sent = append(r.sent, "error: "+message)
+}
+func (r *recordResponse) SendMessage(info *GuildInfo, channelID DiscordChannel, message string) error {
+	r.sent = append(r.sent, message)
+	return nil
+}
+func (r *recordResponse) SendEmbed(info *GuildInfo, channelID DiscordChannel, embed *discordgo.MessageEmbed) error {
+	r.sent = append(r.sent, "embed: "+embed.Title)
+	return nil
+}
+func (r *recordResponse) SendPage(info *GuildInfo, channelID DiscordChannel, embed *discordgo.MessageEmbed) (*discordgo.Message, error) {
+	r.sent = append(r.sent, "page: "+embed.Footer.Text)
+	return &discordgo.Message{ID: "page", ChannelID: channelID.String()}, nil
+}
+
+func TestCommandStages(t *testing.T) {
+	t.Parallel()
+
+	order := []string{}
+	step := func(name string, pass bool) commandStage {
+		return commandStage{name, func(ctx *CommandContext, next func()) {
+			order = append(order, name)
+			if pass {
+				next()
+				order = append(order, "/"+name)
+			}
+		}}
+	}
+	runCommandStages(&CommandContext{}, []commandStage{step("a", true), step("b", true), step("c", false), step("d", true)})
+	Check(fmt.Sprint(order), "[a b c /b /a]", t)
+}
+
+func TestAddCommandMiddleware(t *testing.T) {
+	t.Parallel()
+
+	sb := &SweetieBot{}
+	sb.Shards = []*SweetieBot{sb}
+	nop := func(ctx *CommandContext, next func()) { next() }
+	Check(sb.AddCommandMiddleware("maintenance", "audit", nop), nil, t)
+	Check(sb.AddCommandMiddleware("trace", "", nop), nil, t)
+	Check(sb.AddCommandMiddleware("first", "maintenance", nop), nil, t)
+	CheckNot(sb.AddCommandMiddleware("nowhere", "asdf", nop), nil, t)
+
+	names := []string{}
+	for _, s := range sb.commandStages {
+		names = append(names, s.name)
+	}
+	Check(strings.Join(names, " "), "resolve metrics first maintenance audit setup permissions saturation denied silver cooldown respond modules trace run", t)
+	Check(len(defaultCommandStages), 12, t)
+}
+
+func TestCommandCooldown(t *testing.T) {
+	t.Parallel()
+
+	info := &GuildInfo{cooldowns: make(map[cooldownKey]int64), Config: *DefaultConfig(), Bot: &SweetieBot{DB: &BotDB{}}}
+	info.Config.Modules.CommandLimits = map[CommandID]int64{"about": 30}
+	info.Config.Modules.UserCooldowns = map[CommandID]int64{"about": 60}
+	r := &recordResponse{}
+	ran := 0
+	run := func(t int64, user string, channel DiscordChannel, bypass bool) {
+		m := &discordgo.Message{Author: &discordgo.User{ID: user}}
+		ctx := &CommandContext{Bot: info.Bot, Info: info, Message: m, Name: "about", Channel: channel, Time: t, Bypass: bypass, response: r}
+		commandCooldown(ctx, func() { ran++ })
+	}
+	run(1000, "1", "1", false)
+	run(1010, "2", "1", false)
+	run(1020, "2", "1", true)
+	run(1031, "2", "1", false)
+	run(1040, "1", "2", false)
+	run(1060, "1", "2", false)
+	Check(ran, 4, t)
+	if Check(len(r.sent), 2, t) {
+		Check(r.sent[0], "error: That command can only be run once every 30 seconds in this channel! Try again in 20 seconds.", t)
+		Check(r.sent[1], "error: You can only run that command once every 60 seconds! Try again in 20 seconds.", t)
+	}
+	Check(info.cooldownRemaining(cooldownKey{"channel:1", "about"}, 1061), int64(0), t)
+	Check(info.cooldownRemaining(cooldownKey{"user:2", "about"}, 1060), int64(31), t)
+}
+
+func TestCommandRespond(t *testing.T) {
+	t.Parallel()
+
+	r := &recordResponse{}
+	ctx := &CommandContext{Info: &GuildInfo{Bot: &SweetieBot{}}, Message: &discordgo.Message{}, response: r}
+	commandRespond(ctx, func() {})
+	Check(len(r.sent), 0, t)
+	commandRespond(ctx, func() { ctx.Result = "result" })
+	commandRespond(ctx, func() { ctx.Embed = &discordgo.MessageEmbed{Title: "title"} })
+	Check(fmt.Sprint(r.sent), "[result embed: title]", t)
+}
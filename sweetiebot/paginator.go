@@ -0,0 +1,229 @@
+package sweetiebot
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/blackhole12/discordgo"
+)
+
+const (
+	pagePrevious  = "\u25c0"                       // ◀
+	pageNext      = "\u25b6"                       // ▶
+	pageTimeout   = time.Duration(3 * time.Minute) // How long a result stays paginated after the last time someone flipped through it
+	maxPageLength = 2000                           // Discord won't accept an embed description longer than 2048 characters
+	maxPageFields = 25                             // Discord won't accept more fields than this in a single embed
+)
+
+// paginator shows a long command result one page at a time. Only the person who ran the command can flip through it.
+type paginator struct {
+	lock    sync.Mutex // Held while the page is changed, so two reactions in a row can't edit the message out of order
+	pages   []*discordgo.MessageEmbed
+	page    int
+	user    DiscordUser
+	channel DiscordChannel
+	message string // The message showing the current page, or empty if the result hasn't been sent yet
+	expires int64  // Unix time when the reactions are removed again. Guarded by the pagesLock of the bot.
+}
+
+// render returns the current page with the page number added to its footer
+func (p *paginator) render() *discordgo.MessageEmbed {
+	embed := *p.pages[p.page]
+	text := fmt.Sprintf("Page %v of %v", p.page+1, len(p.pages))
+	if embed.Footer != nil && len(embed.Footer.Text) > 0 {
+		footer := *embed.Footer
+		footer.Text += " | " + text
+		embed.Footer = &footer
+	} else {
+		embed.Footer = &discordgo.MessageEmbedFooter{Text: text}
+	}
+	return &embed
+}
+
+// PageLines splits a long list into pages for Paginate. Each page is a copy of embed with up to perPage of the lines
+// as its description, or fewer if they wouldn't fit.
+func PageLines(embed *discordgo.MessageEmbed, lines []string, perPage int) []*discordgo.MessageEmbed {
+	if perPage < 1 {
+		perPage = 1
+	}
+	pages := []*discordgo.MessageEmbed{}
+	page := []string{}
+	length := 0
+	flush := func() {
+		e := *embed
+		e.Description = strings.Join(page, "\n")
+		pages = append(pages, &e)
+		page = []string{}
+		length = 0
+	}
+	for _, line := range lines {
+		if utf8.RuneCountInString(line) > maxPageLength {
+			line = string([]rune(line)[:maxPageLength-3]) + "..."
+		}
+		n := utf8.RuneCountInString(line) + 1
+		if len(page) > 0 && (len(page) >= perPage || length+n > maxPageLength) {
+			flush()
+		}
+		page = append(page, line)
+		length += n
+	}
+	if len(page) > 0 || len(pages) == 0 {
+		flush()
+	}
+	return pages
+}
+
+// PageFields splits the fields of embed into pages for Paginate, with up to perPage fields on each page
+func PageFields(embed *discordgo.MessageEmbed, perPage int) []*discordgo.MessageEmbed {
+	if perPage < 1 || perPage > maxPageFields {
+		perPage = maxPageFields
+	}
+	pages := []*discordgo.MessageEmbed{}
+	fields := embed.Fields
+	for len(pages) == 0 || len(fields) > 0 {
+		n := perPage
+		if n > len(fields) {
+			n = len(fields)
+		}
+		e := *embed
+		e.Fields = fields[:n]
+		fields = fields[n:]
+		pages = append(pages, &e)
+	}
+	return pages
+}
+
+// Paginate lets a command return more than fits in a single message. The command returns the embed this gives back,
+// and once it has been sent, whoever ran the command can flip through the rest of the pages by reacting with ◀ and
+// ▶. A result that fits on one page is sent like any other embed.
+func (info *GuildInfo) Paginate(msg *discordgo.Message, pages []*discordgo.MessageEmbed) *discordgo.MessageEmbed {
+	if len(pages) == 0 {
+		return nil
+	}
+	if len(pages) == 1 {
+		return pages[0]
+	}
+	p := &paginator{pages: pages, user: DiscordUser(msg.Author.ID)}
+	info.Bot.addPaginator(msg.ID, p) // The command hasn't been answered yet, so this is found by the message that ran it
+	return p.render()
+}
+
+func (sb *SweetieBot) addPaginator(id string, p *paginator) {
+	sb.pagesLock.Lock()
+	defer sb.pagesLock.Unlock()
+	if sb.pages == nil {
+		sb.pages = make(map[string]*paginator)
+	}
+	p.expires = time.Now().UTC().Add(pageTimeout).Unix()
+	sb.pages[id] = p
+}
+
+// takePaginator returns the pages a command asked for, if it called Paginate
+func (sb *SweetieBot) takePaginator(id string) *paginator {
+	sb.pagesLock.Lock()
+	defer sb.pagesLock.Unlock()
+	p := sb.pages[id]
+	if p != nil && len(p.message) == 0 {
+		delete(sb.pages, id)
+		return p
+	}
+	return nil
+}
+
+// sendPages sends the first page of a paginated result and adds the reactions used to flip through it
+func (ctx *CommandContext) sendPages(channelID DiscordChannel, p *paginator) {
+	m, err := ctx.response.SendPage(ctx.Info, channelID, p.render())
+	if err != nil {
+		ctx.Logger().LogError("Failed to send embed: ", err)
+		return
+	}
+	if m == nil {
+		return
+	}
+	p.channel = channelID
+	p.message = m.ID
+	ctx.Bot.addPaginator(m.ID, p) // Reactions arrive on the shard that got the command, which matters for private messages
+	for _, emoji := range []string{pagePrevious, pageNext} {
+		if err := ctx.Bot.DG.MessageReactionAdd(channelID.String(), m.ID, emoji); err != nil {
+			ctx.Logger().LogError("Failed to add page reactions: ", err)
+			return
+		}
+	}
+}
+
+// sendPage sends a single page of a paginated result. Unlike SendEmbed, it never splits the embed up, and it returns
+// the message so the page can be changed later.
+func (info *GuildInfo) sendPage(channelID DiscordChannel, embed *discordgo.MessageEmbed) (*discordgo.Message, error) {
+	if channelID == "heartbeat" {
+		info.Bot.beat()
+		return nil, nil
+	}
+	if ch, private := info.Bot.ChannelIsPrivate(channelID); !private && (ch == nil || ch.GuildID != info.ID) {
+		return nil, errInvalidChannel
+	}
+	return info.Bot.DG.ChannelMessageSendEmbed(channelID.String(), embed)
+}
+
+// flipPage turns the page when the person who ran the command reacts to it. Removing a reaction counts too, because we
+// can't take their reaction away again in a private message or without the manage messages permission.
+func (sb *SweetieBot) flipPage(r *discordgo.MessageReaction) {
+	step := 0
+	switch strings.TrimSuffix(r.Emoji.Name, "\ufe0f") {
+	case pagePrevious:
+		step = -1
+	case pageNext:
+		step = 1
+	default:
+		return
+	}
+	sb.pagesLock.Lock()
+	p := sb.pages[r.MessageID]
+	if p == nil || len(p.message) == 0 || !p.user.Equals(r.UserID) {
+		sb.pagesLock.Unlock()
+		return
+	}
+	p.expires = time.Now().UTC().Add(pageTimeout).Unix()
+	sb.pagesLock.Unlock()
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.page = (p.page + step + len(p.pages)) % len(p.pages)
+	if _, err := sb.DG.ChannelMessageEditEmbed(p.channel.String(), p.message, p.render()); err != nil {
+		sb.Log.With("channel", p.channel).LogError("Failed to change page: ", err)
+	}
+}
+
+// expirePages stops paginating results nobody has flipped through in a while and removes the reactions from them
+func (sb *SweetieBot) expirePages(now int64) {
+	expired := []*paginator{}
+	sb.pagesLock.Lock()
+	for id, p := range sb.pages {
+		if p.expires <= now {
+			delete(sb.pages, id)
+			if len(p.message) > 0 {
+				expired = append(expired, p)
+			}
+		}
+	}
+	sb.pagesLock.Unlock()
+
+	for _, p := range expired {
+		if sb.DG.MessageReactionsRemoveAll(p.channel.String(), p.message) != nil { // We can only remove our own reactions without the manage messages permission
+			sb.DG.MessageReactionRemove(p.channel.String(), p.message, pagePrevious, "@me")
+			sb.DG.MessageReactionRemove(p.channel.String(), p.message, pageNext, "@me")
+		}
+	}
+}
+
+// MessageReactionAdd discord hook
+func (sb *SweetieBot) MessageReactionAdd(s *discordgo.Session, r *discordgo.MessageReactionAdd) {
+	sb.flipPage(r.MessageReaction)
+}
+
+// MessageReactionRemove discord hook
+func (sb *SweetieBot) MessageReactionRemove(s *discordgo.Session, r *discordgo.MessageReactionRemove) {
+	sb.flipPage(r.MessageReaction)
+}
@@ -0,0 +1,87 @@
+package sweetiebot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/blackhole12/discordgo"
+)
+
+func TestPageLines(t *testing.T) {
+	t.Parallel()
+
+	template := &discordgo.MessageEmbed{Title: "List"}
+	lines := []string{}
+	for i := 0; i < 25; i++ {
+		lines = append(lines, strconv.Itoa(i))
+	}
+	pages := PageLines(template, lines, 10)
+	Check(len(pages), 3, t)
+	Check(pages[0].Title, "List", t)
+	Check(pages[2].Description, "20\n21\n22\n23\n24", t)
+	Check(len(template.Description), 0, t)
+
+	long := strings.Repeat("a", 1500)
+	pages = PageLines(template, []string{long, long, strings.Repeat("b", 3000)}, 10) // Too long to share a page
+	Check(len(pages), 3, t)
+	Check(utf8.RuneCountInString(pages[2].Description), maxPageLength, t)
+	Check(len(PageLines(template, []string{}, 10)), 1, t)
+}
+
+func TestPageFields(t *testing.T) {
+	t.Parallel()
+
+	embed := &discordgo.MessageEmbed{Footer: &discordgo.MessageEmbedFooter{Text: "footer"}}
+	for i := 0; i < 30; i++ {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{Name: strconv.Itoa(i)})
+	}
+	pages := PageFields(embed, 100)
+	Check(len(pages), 2, t)
+	Check(len(pages[0].Fields), maxPageFields, t)
+	Check(pages[1].Fields[0].Name, "25", t)
+
+	p := &paginator{pages: pages, page: 1}
+	Check(p.render().Footer.Text, "footer | Page 2 of 2", t)
+	Check(pages[1].Footer.Text, "footer", t)
+}
+
+func TestPaginator(t *testing.T) {
+	sb, _, _ := MockSweetieBot(t)
+	info := sb.Guilds[NewDiscordGuild(uint64(TestServer))]
+	msg := MockMessage("!list", TestChannel, 0, TestUserBoring, 0)
+	channel := msg.ChannelID
+
+	Check(info.Paginate(msg, PageLines(&discordgo.MessageEmbed{}, []string{"a"}, 1)).Description, "a", t)
+	Check(sb.takePaginator(msg.ID) == nil, true, t)
+
+	first := info.Paginate(msg, PageLines(&discordgo.MessageEmbed{}, []string{"a", "b", "c"}, 1))
+	Check(first.Footer.Text, "Page 1 of 3", t)
+	mock.Expect(sb.DG.MessageReactionAdd, channel, "page", pagePrevious)
+	mock.Expect(sb.DG.MessageReactionAdd, channel, "page", pageNext)
+	r := &recordResponse{}
+	ctx := &CommandContext{Bot: sb, Info: info, Message: msg, Channel: DiscordChannel(channel), response: r}
+	commandRespond(ctx, func() { ctx.Embed = first })
+	Check(fmt.Sprint(r.sent), "[page: Page 1 of 3]", t)
+
+	reaction := func(user int, emoji string) *discordgo.MessageReaction {
+		return &discordgo.MessageReaction{UserID: strconv.Itoa(user), MessageID: "page", ChannelID: channel, Emoji: discordgo.Emoji{Name: emoji}}
+	}
+	sb.flipPage(reaction(TestUserNonAssign, pageNext)) // Only the person who ran the command can flip through it
+	sb.flipPage(reaction(TestUserBoring, "x"))
+	mock.Expect(sb.DG.ChannelMessageEditEmbed, channel, "page", MockAny{})
+	sb.flipPage(reaction(TestUserBoring, pagePrevious+"\ufe0f"))
+	Check(sb.pages["page"].page, 2, t)
+	Check(sb.pages["page"].render().Description, "c", t)
+
+	now := time.Now().UTC()
+	sb.expirePages(now.Unix())
+	Check(len(sb.pages), 1, t)
+	mock.Expect(sb.DG.MessageReactionsRemoveAll, channel, "page")
+	sb.expirePages(now.Add(pageTimeout + time.Minute).Unix())
+	Check(len(sb.pages), 0, t)
+	Check(mock.Check(), true, t)
+}
@@ -0,0 +1,382 @@
+package sweetiebot
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/blackhole12/discordgo"
+)
+
+// ParamType tells the command dispatcher how to parse a parameter before the command is run
+type ParamType uint8
+
+// Parameter types. Most consume a single argument, but durations and dates can span several, and variadic
+// parameters consume everything up to the next duration (for user names with spaces in them) or the end of the message.
+const (
+	ParamString   ParamType = iota // Passed through unchanged. If variadic, collects every remaining argument.
+	ParamUser                      // A ping, ID or username, resolved with ParseUser
+	ParamRole                      // A ping, ID or role name, resolved with ParseRole
+	ParamChannel                   // A channel link, ID or name, resolved with ParseChannel
+	ParamDuration                  // A number and a unit like "5 minutes", preceded by "for:" unless nothing can follow it
+	ParamDate                      // Anything ParseCommonTime understands, in the timezone of whoever ran the command
+	ParamInt                       // An integer between Min and Max, if either is set
+	ParamEnum                      // One of Values, ignoring case
+	ParamRest                      // The rest of the message, exactly as it was typed
+)
+
+var errMissingParam = errors.New("missing")
+var errUnexpectedArg = errors.New("unexpected")
+
+// ParamError is returned when the arguments given to a typed command don't match its parameters
+type ParamError struct {
+	Param string
+	Err   error
+}
+
+func (e *ParamError) Error() string {
+	if e.Err == errMissingParam {
+		return "Missing required parameter: " + e.Param
+	}
+	if e.Err == errUnexpectedArg {
+		return "Unexpected argument: " + e.Param
+	}
+	return "Invalid " + e.Param + ": " + e.Err.Error()
+}
+
+// Interval is an amount of time in calendar units, so adding a month always lands on the same day of the next month
+type Interval struct {
+	Count int
+	Unit  uint8 // One of the units returned by ParseRepeatInterval
+}
+
+var intervalNames = []string{"", "second", "minute", "hour", "day", "week", "month", "quarter", "year"}
+
+// Add returns the time that is one interval after t
+func (d Interval) Add(t time.Time) time.Time {
+	switch d.Unit {
+	case 1:
+		return t.Add(time.Duration(d.Count) * time.Second)
+	case 2:
+		return t.Add(time.Duration(d.Count) * time.Minute)
+	case 3:
+		return t.Add(time.Duration(d.Count) * time.Hour)
+	case 4:
+		return t.AddDate(0, 0, d.Count)
+	case 5:
+		return t.AddDate(0, 0, d.Count*7)
+	case 6:
+		return t.AddDate(0, d.Count, 0)
+	case 7:
+		return t.AddDate(0, d.Count*3, 0)
+	case 8:
+		return t.AddDate(d.Count, 0, 0)
+	}
+	return t
+}
+
+func (d Interval) String() string {
+	if int(d.Unit) >= len(intervalNames) {
+		return strconv.Itoa(d.Count)
+	}
+	return Pluralize(int64(d.Count), " "+intervalNames[d.Unit])
+}
+
+// ParseInterval parses a duration of the form "5 minutes" from the start of args, returning how many arguments it used
+func ParseInterval(args []string) (Interval, int, error) {
+	if len(args) < 2 {
+		return Interval{}, 0, errors.New("durations look like `5 minutes` or `2 days`")
+	}
+	count, err := strconv.Atoi(args[0])
+	if err != nil {
+		return Interval{}, 0, errors.New(args[0] + " is not a whole number")
+	}
+	if count <= 0 {
+		return Interval{}, 0, errors.New("durations must be positive")
+	}
+	unit := ParseRepeatInterval(args[1])
+	if unit == 0 || int(unit) >= len(intervalNames) {
+		return Interval{}, 0, errors.New(args[1] + " is not a unit of time I know of. Use seconds, minutes, hours, days, weeks, months, quarters or years")
+	}
+	return Interval{count, unit}, 2, nil
+}
+
+// CommandArgs holds the values of a typed command's parameters, keyed by parameter name. Optional parameters that
+// weren't provided are missing from the map.
+type CommandArgs map[string]interface{}
+
+// Has returns true if the parameter was provided
+func (a CommandArgs) Has(name string) bool {
+	_, ok := a[name]
+	return ok
+}
+
+// String returns the value of a string, enum or rest-of-line parameter
+func (a CommandArgs) String(name string) string {
+	s, _ := a[name].(string)
+	return s
+}
+
+// Strings returns every argument collected by a variadic string parameter
+func (a CommandArgs) Strings(name string) []string {
+	s, _ := a[name].([]string)
+	return s
+}
+
+// User returns the value of a user parameter
+func (a CommandArgs) User(name string) DiscordUser {
+	u, ok := a[name].(DiscordUser)
+	if !ok {
+		return UserEmpty
+	}
+	return u
+}
+
+// Role returns the value of a role parameter
+func (a CommandArgs) Role(name string) DiscordRole {
+	r, ok := a[name].(DiscordRole)
+	if !ok {
+		return RoleEmpty
+	}
+	return r
+}
+
+// Channel returns the value of a channel parameter
+func (a CommandArgs) Channel(name string) DiscordChannel {
+	ch, ok := a[name].(DiscordChannel)
+	if !ok {
+		return ChannelEmpty
+	}
+	return ch
+}
+
+// Interval returns the value of a duration parameter
+func (a CommandArgs) Interval(name string) Interval {
+	d, _ := a[name].(Interval)
+	return d
+}
+
+// Time returns the value of a date parameter
+func (a CommandArgs) Time(name string) time.Time {
+	t, _ := a[name].(time.Time)
+	return t
+}
+
+// Int returns the value of an integer parameter, or def if it wasn't provided
+func (a CommandArgs) Int(name string, def int64) int64 {
+	i, ok := a[name].(int64)
+	if !ok {
+		return def
+	}
+	return i
+}
+
+// TypedCommand is a command that gives every parameter in its usage a Type. ProcessCommand parses and validates the
+// arguments before calling ProcessArgs, so the command itself only ever sees valid values.
+type TypedCommand interface {
+	Command
+	ProcessArgs(CommandArgs, *discordgo.Message, *GuildInfo) (string, bool, *discordgo.MessageEmbed)
+}
+
+// ProcessTyped parses the arguments of a typed command and runs it. If the arguments don't parse, it returns an error
+// along with how the command should be used.
+func (info *GuildInfo) ProcessTyped(c TypedCommand, args []string, msg *discordgo.Message, indices []int) (string, bool, *discordgo.MessageEmbed) {
+	usage := c.Usage(info)
+	values, err := info.ParseArgs(usage, args, indices, msg)
+	if err != nil {
+		return "```\n" + err.Error() + "\nUsage: " + info.usageLine(CommandID(strings.ToLower(c.Info().Name)), usage, DiscordChannel(msg.ChannelID)) + "```", false, nil
+	}
+	return c.ProcessArgs(values, msg, info)
+}
+
+// ParseArgs parses a command's arguments according to the parameter types declared in its usage. Optional parameters
+// that don't match the next argument are skipped, so "!ban user reason" and "!ban user for: 2 days reason" both work.
+// Arguments left over once every parameter has been parsed are an error, so nothing the user typed is silently dropped.
+func (info *GuildInfo) ParseArgs(usage *CommandUsage, args []string, indices []int, msg *discordgo.Message) (CommandArgs, error) {
+	values := make(CommandArgs)
+	if usage == nil {
+		return values, nil
+	}
+	i := 0
+	for k, p := range usage.Params {
+		if i >= len(args) {
+			if !p.Optional {
+				return nil, &ParamError{p.Name, errMissingParam}
+			}
+			continue
+		}
+		if p.Type == ParamDuration && needsFor(usage.Params, k, args[i:]) && strings.ToLower(args[i]) != "for:" {
+			continue
+		}
+		end := len(args)
+		if p.Variadic && p.Type == ParamUser && isUserID(args[i]) {
+			end = i + 1 // A ping or ID is always a single user, so whatever comes after it belongs to the next parameter
+		} else if p.Variadic {
+			end = i + variadicEnd(args[i:], usage.Params[k+1:])
+		}
+		v, n, committed, err := info.parseParam(p, args[i:end], indices[i:], msg)
+		if err != nil {
+			if p.Optional && !committed && k+1 < len(usage.Params) {
+				continue
+			}
+			return nil, &ParamError{p.Name, err}
+		}
+		values[p.Name] = v
+		i += n
+	}
+	if i < len(args) {
+		return nil, &ParamError{args[i], errUnexpectedArg}
+	}
+	return values, nil
+}
+
+// needsFor returns true if the duration parameter at index k can only be given with "for:". Without it, an optional
+// duration followed by a reason would swallow the start of the reason, turning "!ban user 1 day of spamming" into a
+// temporary ban. A duration that is all that's left of the message can't be the start of a reason, so "!silence user
+// 2 hours" works without it.
+func needsFor(params []CommandUsageParam, k int, args []string) bool {
+	if _, n, err := ParseInterval(args); err == nil && n == len(args) {
+		return false
+	}
+	return params[k].Optional && k+1 < len(params)
+}
+
+// isUserID returns true if arg is a ping or the ID of a user, rather than part of their name
+func isUserID(arg string) bool {
+	if m := UserRegex.FindString(arg); len(m) > 0 {
+		return m == arg
+	}
+	_, err := strconv.ParseUint(arg, 10, 64)
+	return err == nil
+}
+
+// variadicEnd finds where a variadic parameter should stop, which is right before anything that looks like a duration
+// if one of the parameters after it is a duration.
+func variadicEnd(args []string, rest []CommandUsageParam) int {
+	for k, p := range rest {
+		if p.Type != ParamDuration {
+			continue
+		}
+		for i := 1; i < len(args); i++ {
+			if strings.ToLower(args[i]) == "for:" {
+				return i
+			}
+			if _, _, err := ParseInterval(args[i:]); err == nil && !needsFor(rest, k, args[i:]) {
+				return i
+			}
+		}
+	}
+	return len(args)
+}
+
+// parseParam parses a single parameter from the start of args, where indices holds the positions of every remaining
+// argument in the message. It returns the value, how many arguments were used, and whether the arguments were
+// unambiguously meant for this parameter, in which case errors are never skipped.
+func (info *GuildInfo) parseParam(p CommandUsageParam, args []string, indices []int, msg *discordgo.Message) (interface{}, int, bool, error) {
+	// Variadic parameters that span more than one argument are parsed from the original text, so quotes and spacing are
+	// preserved the same way the user typed them.
+	arg := args[0]
+	n := 1
+	if p.Variadic {
+		n = len(args)
+		if n > 1 {
+			arg = strings.TrimSpace(msg.Content[indices[0]:textEnd(msg.Content, indices, n)])
+		}
+	}
+
+	switch p.Type {
+	case ParamUser:
+		u, err := ParseUser(arg, info)
+		if err == errNotUser {
+			err = errors.New("Could not find any usernames or aliases matching " + arg + "!")
+		}
+		return u, n, false, err
+	case ParamRole:
+		g, _ := info.GetGuild()
+		r, err := ParseRole(arg, g)
+		if err == nil && r == RoleEmpty {
+			err = errNotRole
+		}
+		if err == errNotRole {
+			err = errors.New("Could not find any roles matching " + arg + "!")
+		}
+		return r, n, false, err
+	case ParamChannel:
+		g, _ := info.GetGuild()
+		ch, err := ParseChannel(arg, g)
+		if err == nil && ch == ChannelEmpty {
+			err = errNotChannel
+		}
+		if err == errNotChannel {
+			err = errors.New("Could not find any channels matching " + arg + "!")
+		}
+		return ch, n, false, err
+	case ParamDuration:
+		skip := 0
+		if strings.ToLower(args[0]) == "for:" {
+			skip = 1
+		}
+		d, used, err := ParseInterval(args[skip:])
+		return d, used + skip, skip > 0, err
+	case ParamDate:
+		for used := len(args); used > 0; used-- {
+			if used > 6 { // No date format we understand has more than 6 parts
+				continue
+			}
+			t, err := info.ParseCommonTime(strings.Join(args[:used], " "), DiscordUser(msg.Author.ID), GetTimestamp(msg))
+			if err == nil {
+				return t, used, false, nil
+			}
+		}
+		return nil, 0, false, errors.New(arg + " is not a date I understand. Try something like `2 Jan 2006 3:04pm`")
+	case ParamInt:
+		i, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil {
+			return nil, 0, false, errors.New(arg + " is not a whole number")
+		}
+		if (p.Min != 0 || p.Max != 0) && (i < p.Min || i > p.Max) {
+			return nil, 0, true, fmt.Errorf("must be between %v and %v", p.Min, p.Max)
+		}
+		return i, 1, false, nil
+	case ParamEnum:
+		for _, v := range p.Values {
+			if strings.EqualFold(v, arg) {
+				return v, n, false, nil
+			}
+		}
+		return nil, 0, false, errors.New("must be one of: " + strings.Join(p.Values, ", "))
+	case ParamRest:
+		return strings.TrimSpace(msg.Content[indices[0]:]), len(args), true, nil
+	}
+
+	if p.Variadic {
+		return append([]string{}, args...), n, false, nil
+	}
+	return arg, 1, false, nil
+}
+
+// textEnd finds where the nth argument ends in the original message
+func textEnd(content string, indices []int, n int) int {
+	if n < len(indices) {
+		return indices[n]
+	}
+	return len(content)
+}
+
+// usageLine renders how a command is used, like "!ban {user} [for: duration] [reason]"
+func (info *GuildInfo) usageLine(name CommandID, usage *CommandUsage, channel DiscordChannel) string {
+	use := info.Prefix(channel) + string(name)
+	for _, v := range usage.Params {
+		if v.Optional {
+			use += fmt.Sprintf(" [%s]", v.Name)
+		} else {
+			use += fmt.Sprintf(" {%s}", v.Name)
+		}
+		if v.Variadic {
+			use += "..."
+		}
+	}
+	return use
+}
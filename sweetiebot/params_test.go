@@ -0,0 +1,141 @@
+package sweetiebot
+
+import (
+	"testing"
+	"time"
+
+	"github.com/blackhole12/discordgo"
+)
+
+func TestParseInterval(t *testing.T) {
+	d, n, err := ParseInterval([]string{"5", "minutes", "extra"})
+	Check(err, nil, t)
+	Check(n, 2, t)
+	Check(d, Interval{5, 2}, t)
+	Check(d.String(), "5 minutes", t)
+
+	start := time.Date(2018, 1, 31, 0, 0, 0, 0, time.UTC)
+	Check(Interval{1, 3}.Add(start), start.Add(time.Hour), t)
+	Check(Interval{2, 5}.Add(start), start.AddDate(0, 0, 14), t)
+	Check(Interval{1, 8}.Add(start), start.AddDate(1, 0, 0), t)
+
+	bad := [][]string{{}, {"5"}, {"five", "minutes"}, {"0", "minutes"}, {"-3", "days"}, {"5", "fortnights"}}
+	for _, v := range bad {
+		_, _, err := ParseInterval(v)
+		CheckNot(err, nil, t)
+	}
+}
+
+func TestVariadicEnd(t *testing.T) {
+	duration := []CommandUsageParam{{Name: "reason", Type: ParamRest}, {Name: "for: duration", Type: ParamDuration}}
+	Check(variadicEnd([]string{"Sweetie", "Belle"}, duration), 2, t)
+	Check(variadicEnd([]string{"Sweetie", "Belle", "for:", "2", "days"}, duration), 2, t)
+	Check(variadicEnd([]string{"Sweetie", "Belle", "2", "days"}, duration), 2, t)
+	Check(variadicEnd([]string{"Sweetie", "Belle", "2", "days"}, duration[:1]), 4, t)
+	Check(variadicEnd([]string{"for:", "days"}, duration), 2, t)
+
+	reason := []CommandUsageParam{{Name: "for: duration", Type: ParamDuration, Optional: true}, {Name: "reason", Type: ParamRest, Optional: true}}
+	Check(variadicEnd([]string{"Sweetie", "Belle", "2", "days"}, reason), 2, t)
+	Check(variadicEnd([]string{"Sweetie", "Belle", "2", "days", "of", "spamming"}, reason), 6, t)
+	Check(variadicEnd([]string{"Sweetie", "Belle", "for:", "2", "days"}, reason), 2, t)
+}
+
+func parseTestArgs(info *GuildInfo, usage *CommandUsage, content string) (CommandArgs, error) {
+	args, indices := ParseArguments(content)
+	msg := &discordgo.Message{Content: "!" + content, Author: &discordgo.User{ID: "1"}, Timestamp: discordgo.Timestamp(time.Now().UTC().Format(time.RFC3339))}
+	return info.ParseArgs(usage, args[1:], indices[1:], msg)
+}
+
+func TestParseArgs(t *testing.T) {
+	sb, _, _ := MockSweetieBot(t)
+	var info *GuildInfo
+	for _, v := range sb.Guilds {
+		info = v
+		break
+	}
+
+	ban := &CommandUsage{Params: []CommandUsageParam{
+		{Name: "user", Type: ParamUser},
+		{Name: "for: duration", Type: ParamDuration, Optional: true},
+		{Name: "reason", Type: ParamRest, Optional: true},
+	}}
+	args, err := parseTestArgs(info, ban, "ban <@123> for: 2 days being \"rude\"")
+	Check(err, nil, t)
+	Check(args.User("user"), DiscordUser("123"), t)
+	Check(args.Interval("for: duration"), Interval{2, 4}, t)
+	Check(args.String("reason"), "being \"rude\"", t)
+
+	args, err = parseTestArgs(info, ban, "ban <@123> being rude")
+	Check(err, nil, t)
+	Check(args.Has("for: duration"), false, t)
+	Check(args.String("reason"), "being rude", t)
+
+	args, err = parseTestArgs(info, ban, "ban <@123> 1 day of spamming")
+	Check(err, nil, t)
+	Check(args.Has("for: duration"), false, t)
+	Check(args.String("reason"), "1 day of spamming", t)
+
+	args, err = parseTestArgs(info, ban, "ban <@123>")
+	Check(err, nil, t)
+	Check(args.Has("reason"), false, t)
+
+	_, err = parseTestArgs(info, ban, "ban")
+	Check(err.Error(), "Missing required parameter: user", t)
+	_, err = parseTestArgs(info, ban, "ban <@123> for: 2 fortnights")
+	CheckNot(err, nil, t)
+
+	silence := &CommandUsage{Params: []CommandUsageParam{
+		{Name: "user", Type: ParamUser, Variadic: true},
+		{Name: "for: duration", Type: ParamDuration, Optional: true},
+		{Name: "reason", Type: ParamRest, Optional: true},
+	}}
+	args, err = parseTestArgs(info, silence, "silence <@123> 2 hours")
+	Check(err, nil, t)
+	Check(args.User("user"), DiscordUser("123"), t)
+	Check(args.Interval("for: duration"), Interval{2, 3}, t)
+	Check(args.Has("reason"), false, t)
+
+	args, err = parseTestArgs(info, silence, "silence <@123> posting memes")
+	Check(err, nil, t)
+	Check(args.User("user"), DiscordUser("123"), t)
+	Check(args.Has("for: duration"), false, t)
+	Check(args.String("reason"), "posting memes", t)
+
+	args, err = parseTestArgs(info, silence, "silence <@!123> for: 2 hours posting memes")
+	Check(err, nil, t)
+	Check(args.User("user"), DiscordUser("123"), t)
+	Check(args.Interval("for: duration"), Interval{2, 3}, t)
+	Check(args.String("reason"), "posting memes", t)
+
+	unsilence := &CommandUsage{Params: []CommandUsageParam{{Name: "user", Type: ParamUser, Variadic: true}}}
+	_, err = parseTestArgs(info, unsilence, "unsilence <@123> please")
+	Check(err.Error(), "Unexpected argument: please", t)
+
+	wipe := &CommandUsage{Params: []CommandUsageParam{
+		{Name: "seconds", Type: ParamInt, Min: 1, Max: 100},
+		{Name: "MESSAGES", Type: ParamEnum, Optional: true, Values: []string{"messages"}},
+	}}
+	args, err = parseTestArgs(info, wipe, "wipe 50 Messages")
+	Check(err, nil, t)
+	Check(args.Int("seconds", 0), int64(50), t)
+	Check(args.String("MESSAGES"), "messages", t)
+	_, err = parseTestArgs(info, wipe, "wipe 500")
+	Check(err.Error(), "Invalid seconds: must be between 1 and 100", t)
+	_, err = parseTestArgs(info, wipe, "wipe fifty")
+	Check(err.Error(), "Invalid seconds: fifty is not a whole number", t)
+	_, err = parseTestArgs(info, wipe, "wipe 50 seconds")
+	CheckNot(err, nil, t)
+	_, err = parseTestArgs(info, wipe, "wipe 50 messages now")
+	Check(err.Error(), "Unexpected argument: now", t)
+}
+
+func TestUsageLine(t *testing.T) {
+	info := &GuildInfo{Config: *DefaultConfig()}
+	usage := &CommandUsage{Params: []CommandUsageParam{
+		{Name: "user", Variadic: true},
+		{Name: "for: duration", Optional: true},
+	}}
+	Check(info.usageLine("silence", usage, ChannelEmpty), "!silence {user}... [for: duration]", t)
+	info.Config.Basic.ChannelPrefixes = map[DiscordChannel]string{"5": "sb?"}
+	Check(info.usageLine("silence", usage, "5"), "sb?silence {user}... [for: duration]", t)
+}
@@ -0,0 +1,84 @@
+package sweetiebot
+
+import (
+	"sort"
+	"strings"
+)
+
+// Prefix returns the command prefix that should be shown to users in the given channel. Pass ChannelEmpty to get the
+// prefix used everywhere that doesn't override it.
+func (info *GuildInfo) Prefix(channel DiscordChannel) string {
+	if p, ok := info.Config.Basic.ChannelPrefixes[channel]; ok && len(p) > 0 {
+		return p
+	}
+	if len(info.Config.Basic.CommandPrefix) > 0 {
+		return info.Config.Basic.CommandPrefix
+	}
+	return "!"
+}
+
+// prefixes returns every prefix that starts a command in the given channel, longest first, so that "!!" is matched
+// before "!" if both are prefixes.
+func (info *GuildInfo) prefixes(channel DiscordChannel) []string {
+	if p, ok := info.Config.Basic.ChannelPrefixes[channel]; ok && len(p) > 0 {
+		return []string{p}
+	}
+	prefixes := []string{info.Prefix(ChannelEmpty)}
+	for p := range info.Config.Basic.ExtraPrefixes {
+		if len(p) > 0 && p != prefixes[0] {
+			prefixes = append(prefixes, p)
+		}
+	}
+	sort.SliceStable(prefixes, func(i, j int) bool { return len(prefixes[i]) > len(prefixes[j]) })
+	return prefixes
+}
+
+// isCommand returns true if the first word of content is the name of a command or an alias on this guild
+func (info *GuildInfo) isCommand(content string) bool {
+	args, _ := ParseArguments(content)
+	if info == nil || len(args) == 0 {
+		return false
+	}
+	name := strings.ToLower(args[0])
+	if _, ok := info.commands[CommandID(name)]; ok {
+		return true
+	}
+	_, ok := info.Config.Basic.Aliases[name]
+	return ok
+}
+
+// matchPrefix returns the prefix that content starts with if it is a command, or an empty string if it isn't. A
+// mention of the bot works as a prefix even in channels that override the prefix, but only if it's followed by a
+// command, so people can still talk to the bot without getting an error back. A prefix typed twice, like "!!", isn't
+// a command, because people use that to shout.
+func (sb *SweetieBot) matchPrefix(info *GuildInfo, channel DiscordChannel, content string) string {
+	guild := info
+	if guild == nil { // Private messages can run any command that doesn't need a server
+		guild = sb.EmptyGuild
+	}
+	for _, mention := range []string{"<@" + sb.SelfID.String() + ">", "<@!" + sb.SelfID.String() + ">"} {
+		if strings.HasPrefix(content, mention) && guild.isCommand(content[len(mention):]) {
+			return mention
+		}
+	}
+	prefixes := []string{"!"}
+	if info != nil {
+		prefixes = info.prefixes(channel)
+	}
+	for _, p := range prefixes {
+		if len(content) > len(p) && strings.HasPrefix(content, p) && !strings.HasPrefix(content[len(p):], p) {
+			return p
+		}
+	}
+	return ""
+}
+
+// parseCommand splits a command into its arguments after skipping a prefix of the given length. The indices point into
+// content, so commands can slice the original message no matter how long the prefix was.
+func parseCommand(content string, prefix int) ([]string, []int) {
+	args, indices := ParseArguments(content[prefix:])
+	for i := range indices {
+		indices[i] += prefix - 1 // ParseArguments already accounts for a prefix of 1 character
+	}
+	return args, indices
+}
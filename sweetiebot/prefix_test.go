@@ -0,0 +1,57 @@
+package sweetiebot
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestMatchPrefix(t *testing.T) {
+	t.Parallel()
+
+	sb := &SweetieBot{BotInstance: BotInstance{SelfID: "10"}}
+	info := &GuildInfo{Config: *DefaultConfig()}
+	info.Config.Basic.CommandPrefix = "sb!"
+	info.Config.Basic.ExtraPrefixes = map[string]bool{"?": true, "✨": true}
+	info.Config.Basic.ChannelPrefixes = map[DiscordChannel]string{"2": "$"}
+	info.Config.Basic.Aliases = map[string]string{"calc": "roll"}
+	info.commands = map[CommandID]Command{"about": nil}
+
+	Check(sb.matchPrefix(info, "1", "sb!about"), "sb!", t)
+	Check(sb.matchPrefix(info, "1", "?about"), "?", t)
+	Check(sb.matchPrefix(info, "1", "✨about"), "✨", t)
+	Check(sb.matchPrefix(info, "1", "!about"), "", t)
+	Check(sb.matchPrefix(info, "1", "??"), "", t)
+	Check(sb.matchPrefix(info, "1", "?"), "", t)
+	Check(sb.matchPrefix(info, "1", "<@10> about"), "<@10>", t)
+	Check(sb.matchPrefix(info, "1", "<@!10>about"), "<@!10>", t)
+	Check(sb.matchPrefix(info, "1", "<@10> "), "", t)
+	Check(sb.matchPrefix(info, "1", "<@11> about"), "", t)
+	Check(sb.matchPrefix(info, "1", "<@10> ABOUT"), "<@10>", t)
+	Check(sb.matchPrefix(info, "1", "<@10> calc 2+2"), "<@10>", t)
+	Check(sb.matchPrefix(info, "1", "<@10> hi there"), "", t)
+	Check(sb.matchPrefix(info, "1", "<@10>"), "", t)
+	Check(sb.matchPrefix(info, "2", "$about"), "$", t)
+	Check(sb.matchPrefix(info, "2", "sb!about"), "", t)
+	Check(sb.matchPrefix(info, "2", "<@10> about"), "<@10>", t)
+	Check(sb.matchPrefix(nil, "3", "!about"), "!", t)
+	Check(sb.matchPrefix(nil, "3", "<@10> about"), "", t)
+	sb.EmptyGuild = info
+	Check(sb.matchPrefix(nil, "3", "<@10> about"), "<@10>", t)
+
+	Check(info.Prefix("1"), "sb!", t)
+	Check(info.Prefix("2"), "$", t)
+	info.Config.Basic.CommandPrefix = ""
+	Check(info.Prefix("1"), "!", t)
+}
+
+func TestParseCommand(t *testing.T) {
+	t.Parallel()
+
+	for _, prefix := range []string{"!", "sb!", "<@10> "} {
+		content := prefix + "addrole \"cool kids\" extra"
+		args, indices := parseCommand(content, len(prefix))
+		Check(fmt.Sprint(args), "[addrole cool kids extra]", t)
+		Check(content[indices[1]:], "\"cool kids\" extra", t)
+		Check(content[indices[2]:], "extra", t)
+	}
+}
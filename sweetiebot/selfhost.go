@@ -125,7 +125,7 @@ func (b *SelfhostBase) DoUpdate(dbauth string, token string) error {
 	}
 
 	if check, update := b.CheckForUpdate(ownerid, BotVersion.Integer()); check > 0 {
-		fmt.Println("Update found, downloading files:", update.Files)
+		defaultLogger.Info("Update found, downloading files: ", update.Files)
 		dir, _ := GetCurrentDir()
 		for _, file := range update.Files {
 			url := UpdateEndpoint(file, ownerid, 0)
@@ -138,7 +138,7 @@ func (b *SelfhostBase) DoUpdate(dbauth string, token string) error {
 			case "sweetie.exe":
 			default:
 				if err := os.Rename(filepath.Join(dir, "~"+file), filepath.Join(dir, file)); err != nil {
-					fmt.Println(err)
+					defaultLogger.LogError("Failed to replace "+file+": ", err)
 				}
 			}
 		}
@@ -202,7 +202,7 @@ func (b *SelfhostBase) UpgradeDatabase(scriptdir string, dbauth string) error {
 	// Find all SQL migration scripts with a version above our current one
 	files := FindUpgradeFiles(scriptdir, b.Version)
 
-	fmt.Println(files)
+	defaultLogger.Debug("Upgrade scripts to run: ", files)
 	// If there are any migration scripts we need to run, sort them and then execute them
 	if len(files) > 0 {
 		sort.Ints(files)
@@ -210,7 +210,7 @@ func (b *SelfhostBase) UpgradeDatabase(scriptdir string, dbauth string) error {
 			if err := ExecuteSQLFile(db, filepath.Join(scriptdir, fmt.Sprintf("sql_%v.sql", v))); err != nil {
 				return fmt.Errorf("Error executing SQL file: %s", err.Error())
 			}
-			fmt.Printf("Applied sql_%v.sql\n", v)
+			defaultLogger.Info(fmt.Sprintf("Applied sql_%v.sql", v))
 		}
 	}
 
@@ -243,9 +243,9 @@ func RunCommand(path string, dir string, args ...string) (string, error) {
 	c.Dir = dir
 	out, err := c.CombinedOutput()
 	if err != nil {
-		fmt.Println(err.Error())
+		defaultLogger.LogError("Failed to run "+c.Path+": ", err)
 	}
-	fmt.Println(string(out))
+	defaultLogger.Info(string(out))
 	return string(out), err
 }
 
@@ -255,9 +255,9 @@ func ExecCommand(c *exec.Cmd, dir string, env ...string) (string, error) {
 	c.Dir = dir
 	out, err := c.CombinedOutput()
 	if err != nil {
-		fmt.Println(err.Error())
+		defaultLogger.LogError("Failed to run "+c.Path+": ", err)
 	}
-	fmt.Println(string(out))
+	defaultLogger.Info(string(out))
 	return string(out), err
 }
 
@@ -0,0 +1,152 @@
+package sweetiebot
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// shutdownTimeout is how long shutting down waits for guild queues and pending messages before giving up on them
+const shutdownTimeout = time.Duration(15 * time.Second)
+
+// shutdownGrace is how long a guild that missed the shutdown deadline gets to finish the event it's running. Its state
+// can't be saved while an event might still be changing it.
+const shutdownGrace = time.Duration(5 * time.Second)
+
+// ModuleState is implemented by modules that keep something in memory that has to survive a restart, like a lockdown
+// that still needs to be lifted. SaveState is called when the bot shuts down, once the guild's worker won't run any
+// more events, and can return nil if there's nothing worth saving. LoadState is called with whatever it returned once
+// the module has been registered after the next start, before the guild processes any events.
+type ModuleState interface {
+	Module
+	SaveState(info *GuildInfo) ([]byte, error)
+	LoadState(info *GuildInfo, data []byte) error
+}
+
+// guildState is the snapshot of every module of a guild, stored next to its config until the bot starts again
+type guildState struct {
+	Saved   int64                      `json:"saved"`
+	Modules map[string]json.RawMessage `json:"modules"` // Lowercase module name to whatever that module saved
+}
+
+func stateFile(id string) string {
+	return id + ".state.json"
+}
+
+// SaveState writes the state of every module that has one to disk, replacing any older snapshot. If there's nothing to
+// save, the old snapshot is deleted instead.
+func (info *GuildInfo) SaveState() error {
+	state := guildState{Saved: time.Now().UTC().Unix(), Modules: make(map[string]json.RawMessage)}
+	for _, m := range info.Modules {
+		if s, ok := m.(ModuleState); ok {
+			data, err := s.SaveState(info)
+			if err != nil {
+				info.LogError("Failed to save the state of the "+m.Name()+" module: ", err)
+			} else if data != nil {
+				state.Modules[strings.ToLower(m.Name())] = data
+			}
+		}
+	}
+	if len(state.Modules) == 0 {
+		if err := os.Remove(stateFile(info.ID)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	data, err := json.Marshal(&state)
+	if err == nil {
+		err = ioutil.WriteFile(stateFile(info.ID), data, 0664)
+	}
+	return err
+}
+
+// loadState gives every module the state it saved when the bot last shut down. The snapshot is deleted afterwards, so
+// a crash later on can't restore it a second time.
+func (info *GuildInfo) loadState() {
+	data, err := ioutil.ReadFile(stateFile(info.ID))
+	if err != nil {
+		return
+	}
+	defer os.Remove(stateFile(info.ID))
+	state := guildState{}
+	if err = json.Unmarshal(data, &state); err != nil {
+		info.LogError("Failed to read saved state: ", err)
+		return
+	}
+	for _, m := range info.Modules {
+		if s, ok := m.(ModuleState); ok {
+			if data, ok := state.Modules[strings.ToLower(m.Name())]; ok {
+				info.LogError("Failed to restore the state of the "+m.Name()+" module: ", s.LoadState(info, data))
+			}
+		}
+	}
+}
+
+// drain runs every event that is still waiting in the guild's queue and then saves its state, as long as that finishes
+// before the deadline. Otherwise, the worker is stopped and the state is saved once the event it was running finishes,
+// so nothing can change the state while it's being saved.
+func (info *GuildInfo) drain(deadline time.Time) {
+	var once sync.Once
+	save := func() {
+		once.Do(func() {
+			info.saveCooldowns(time.Now().UTC().Unix())
+			info.LogError("Failed to save state: ", info.SaveState())
+		})
+	}
+	saved := make(chan struct{})
+	failed := make(chan struct{})
+	go func() {
+		if !info.Queue(func() { save(); close(saved) }) {
+			close(failed) // The queue was stopped or stayed full, so there's nothing to wait for
+		}
+	}()
+	select {
+	case <-saved:
+		return
+	case <-failed:
+	case <-time.After(time.Until(deadline)):
+		info.Logger().Warning("Timed out waiting for the event queue to drain")
+	}
+
+	info.stopWorker()
+	if info.waitForWorker(shutdownGrace) {
+		save()
+	} else {
+		info.Logger().Warning("Gave up waiting for the running event to finish, so the state of this guild wasn't saved")
+	}
+}
+
+// drain drains the queue of every guild on this shard at the same time
+func (sb *SweetieBot) drain(deadline time.Time) {
+	sb.GuildsLock.RLock()
+	guilds := make([]*GuildInfo, 0, len(sb.Guilds))
+	for _, info := range sb.Guilds {
+		guilds = append(guilds, info)
+	}
+	sb.GuildsLock.RUnlock()
+
+	var wg sync.WaitGroup
+	for _, info := range guilds {
+		wg.Add(1)
+		go func(info *GuildInfo) {
+			defer wg.Done()
+			info.drain(deadline)
+		}(info)
+	}
+	wg.Wait()
+}
+
+// waitForMessages waits until every message that is being held back by a rate limit has been sent
+func (sb *SweetieBot) waitForMessages(deadline time.Time) {
+	for atomic.LoadInt32(&sb.sending) > 0 {
+		if time.Now().After(deadline) {
+			sb.Log.Warning("Timed out waiting for ", atomic.LoadInt32(&sb.sending), " messages to be sent")
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
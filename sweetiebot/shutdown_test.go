@@ -0,0 +1,76 @@
+package sweetiebot
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+)
+
+type stateModule struct {
+	value string
+}
+
+func (m *stateModule) Name() string        { return "State" }
+func (m *stateModule) Commands() []Command { return []Command{} }
+func (m *stateModule) Description() string { return "Remembers a value across restarts" }
+func (m *stateModule) SaveState(info *GuildInfo) ([]byte, error) {
+	if len(m.value) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(m.value)
+}
+func (m *stateModule) LoadState(info *GuildInfo, data []byte) error {
+	return json.Unmarshal(data, &m.value)
+}
+
+func TestModuleState(t *testing.T) {
+	const id = "9876543210"
+	defer os.Remove(stateFile(id))
+
+	saved := &stateModule{}
+	info := &GuildInfo{ID: id, worker: newGuildWorker(), Modules: []Module{saved}}
+	info.Queue(func() { saved.value = "lockdown" }) // The state is only saved once everything queued before it has run
+	info.startWorker()
+	info.drain(time.Now().Add(5 * time.Second))
+	info.stopWorker()
+
+	restored := &stateModule{}
+	info = &GuildInfo{ID: id, Modules: []Module{restored}}
+	info.loadState()
+	Check(restored.value, "lockdown", t)
+	_, err := os.Stat(stateFile(id))
+	Check(os.IsNotExist(err), true, t)
+
+	Check(info.SaveState(), nil, t)
+	_, err = os.Stat(stateFile(id))
+	Check(err, nil, t)
+	restored.value = ""
+	Check(info.SaveState(), nil, t)
+	_, err = os.Stat(stateFile(id))
+	Check(os.IsNotExist(err), true, t) // Nothing left to save, so the old snapshot can't be restored by mistake
+}
+
+func TestDrainTimeout(t *testing.T) {
+	const id = "9876543211"
+	defer os.Remove(stateFile(id))
+
+	block := make(chan struct{})
+	stuck := &stateModule{}
+	info := &GuildInfo{ID: id, worker: newGuildWorker(), Modules: []Module{stuck}}
+	info.Queue(func() {
+		<-block
+		stuck.value = "unstuck"
+	})
+	info.Queue(func() { stuck.value = "never runs" }) // The worker is stopped before it gets to this one
+	info.startWorker()
+	time.AfterFunc(100*time.Millisecond, func() { close(block) })
+	start := time.Now()
+	info.drain(time.Now().Add(50 * time.Millisecond))
+	Check(time.Since(start) < time.Second, true, t)
+
+	restored := &stateModule{}
+	info = &GuildInfo{ID: id, Modules: []Module{restored}}
+	info.loadState()
+	Check(restored.value, "unstuck", t) // Saved only after the running event finished
+}
@@ -0,0 +1,156 @@
+package sweetiebot
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// Store implements the parts of the database that depend on which SQL backend is being used. All of BotDB's ordinary
+// statements are written in MySQL syntax and passed through Rewrite, while everything that used to be a stored
+// procedure or function on MariaDB goes through the Store, so backends without stored procedures can implement them in Go.
+type Store interface {
+	// Open creates the connection pool for this backend
+	Open(conn string) (*sql.DB, error)
+	// Init creates any tables the backend is responsible for. MariaDB is set up by the installer scripts instead.
+	Init(db *sql.DB) error
+	// Prepare prepares any statements the backend needs for its stored procedure replacements
+	Prepare(db *BotDB) error
+	// Rewrite translates a MySQL statement into this backend's dialect
+	Rewrite(query string) string
+	// StandardErr maps backend specific errors to ErrDuplicateEntry or ErrLockWaitTimeout
+	StandardErr(err error) error
+
+	AddChat(id uint64, author uint64, username string, message string, channel uint64, guild uint64) error
+	AddUser(id uint64, username string, discriminator int, avatar string, isonline bool) error
+	AddMember(id uint64, guild uint64, firstseen time.Time, nickname string) error
+	AddMarkov(last uint64, last2 uint64, speaker string, text string) (uint64, error)
+	GetMarkovLine(last uint64) (sql.NullString, error)
+	GetMarkovLine2(last uint64, last2 uint64) (sql.NullString, error)
+	ResetMarkov() error
+	RemoveSchedule(id uint64) error
+	AddItem(item string) (uint64, error)
+	RemoveGuild(guild uint64) error
+}
+
+// Store drivers that can be specified in the "dbdriver" field of selfhost.json
+const (
+	DriverMySQL  = "mysql"
+	DriverSQLite = "sqlite3"
+)
+
+func newStore(driver string) Store {
+	switch driver {
+	case DriverSQLite:
+		return &sqliteStore{}
+	}
+	return &mariaDBStore{}
+}
+
+// mariaDBStore is the original backend, which relies on the stored procedures defined in sweetiebot.sql
+type mariaDBStore struct {
+	sqlAddMessage     *sql.Stmt
+	sqlAddUser        *sql.Stmt
+	sqlAddMember      *sql.Stmt
+	sqlAddMarkov      *sql.Stmt
+	sqlGetMarkovLine  *sql.Stmt
+	sqlGetMarkovLine2 *sql.Stmt
+	sqlResetMarkov    *sql.Stmt
+	sqlRemoveSchedule *sql.Stmt
+	sqlAddItem        *sql.Stmt
+	sqlRemoveGuild    *sql.Stmt
+}
+
+func (s *mariaDBStore) Open(conn string) (*sql.DB, error) {
+	return sql.Open(DriverMySQL, conn)
+}
+
+func (s *mariaDBStore) Init(db *sql.DB) error {
+	return nil
+}
+
+func (s *mariaDBStore) Prepare(db *BotDB) error {
+	var err error
+	s.sqlAddMessage, err = db.Prepare("CALL AddChat(?,?,?,?,?,?)")
+	s.sqlAddUser, err = db.Prepare("CALL AddUser(?,?,?,?,?)")
+	s.sqlAddMember, err = db.Prepare("CALL AddMember(?,?,?,?)")
+	s.sqlAddMarkov, err = db.Prepare("SELECT AddMarkov(?,?,?,?)")
+	s.sqlGetMarkovLine, err = db.Prepare("SELECT GetMarkovLine(?)")
+	s.sqlGetMarkovLine2, err = db.Prepare("SELECT GetMarkovLine2(?,?)")
+	s.sqlResetMarkov, err = db.Prepare("CALL ResetMarkov()")
+	s.sqlRemoveSchedule, err = db.Prepare("CALL RemoveSchedule(?)")
+	s.sqlAddItem, err = db.Prepare("SELECT AddItem(?)")
+	s.sqlRemoveGuild, err = db.Prepare("CALL RemoveGuild(?)")
+	return err
+}
+
+func (s *mariaDBStore) Rewrite(query string) string {
+	return query
+}
+
+func (s *mariaDBStore) StandardErr(err error) error {
+	if mysqlErr, ok := err.(*mysql.MySQLError); ok {
+		switch mysqlErr.Number {
+		case 1062:
+			return ErrDuplicateEntry
+		case 1205:
+			return ErrLockWaitTimeout
+		}
+	}
+	return err
+}
+
+func (s *mariaDBStore) AddChat(id uint64, author uint64, username string, message string, channel uint64, guild uint64) error {
+	_, err := s.sqlAddMessage.Exec(id, author, username, message, channel, guild)
+	return err
+}
+
+func (s *mariaDBStore) AddUser(id uint64, username string, discriminator int, avatar string, isonline bool) error {
+	_, err := s.sqlAddUser.Exec(id, username, discriminator, avatar, isonline)
+	return err
+}
+
+func (s *mariaDBStore) AddMember(id uint64, guild uint64, firstseen time.Time, nickname string) error {
+	_, err := s.sqlAddMember.Exec(id, guild, firstseen, nickname)
+	return err
+}
+
+func (s *mariaDBStore) AddMarkov(last uint64, last2 uint64, speaker string, text string) (uint64, error) {
+	var id uint64
+	err := s.sqlAddMarkov.QueryRow(last, last2, speaker, text).Scan(&id)
+	return id, err
+}
+
+func (s *mariaDBStore) GetMarkovLine(last uint64) (sql.NullString, error) {
+	var r sql.NullString
+	err := s.sqlGetMarkovLine.QueryRow(last).Scan(&r)
+	return r, err
+}
+
+func (s *mariaDBStore) GetMarkovLine2(last uint64, last2 uint64) (sql.NullString, error) {
+	var r sql.NullString
+	err := s.sqlGetMarkovLine2.QueryRow(last, last2).Scan(&r)
+	return r, err
+}
+
+func (s *mariaDBStore) ResetMarkov() error {
+	_, err := s.sqlResetMarkov.Exec()
+	return err
+}
+
+func (s *mariaDBStore) RemoveSchedule(id uint64) error {
+	_, err := s.sqlRemoveSchedule.Exec(id)
+	return err
+}
+
+func (s *mariaDBStore) AddItem(item string) (uint64, error) {
+	var id uint64
+	err := s.sqlAddItem.QueryRow(item).Scan(&id)
+	return id, err
+}
+
+func (s *mariaDBStore) RemoveGuild(guild uint64) error {
+	_, err := s.sqlRemoveGuild.Exec(guild)
+	return err
+}
@@ -0,0 +1,55 @@
+package sweetiebot
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+// removeScheduleCases are the events RemoveSchedule is given on both backends. An event is only deleted once it's due if
+// it has neither a repeat interval nor a repeat count, otherwise it is kept and moved forward by however much it repeats.
+var removeScheduleCases = []struct {
+	date     time.Duration
+	interval interface{}
+	repeat   interface{}
+	kept     bool
+	moved    time.Duration
+}{
+	{-time.Minute, nil, nil, false, 0},
+	{time.Minute, 4, 1, false, 0},
+	{-time.Minute, 4, 1, true, 24 * time.Hour},
+	{-time.Minute, 4, nil, true, 0},
+	{-time.Minute, nil, 1, true, 0},
+}
+
+func TestRemoveSchedule(t *testing.T) {
+	db := sqliteBotDB(t)
+	defer db.Close()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	for i, c := range removeScheduleCases {
+		r, err := db.db.Exec("INSERT INTO schedule (Guild, Date, RepeatInterval, `Repeat`, Type, Data) VALUES (5, ?, ?, ?, 0, '')", now.Add(c.date), c.interval, c.repeat)
+		Check(err, nil, t)
+		id, _ := r.LastInsertId()
+		Check(db.RemoveSchedule(uint64(id)), nil, t)
+
+		var date time.Time
+		err = db.db.QueryRow("SELECT Date FROM schedule WHERE ID = ?", id).Scan(&date)
+		if kept := err != sql.ErrNoRows; kept != c.kept {
+			t.Errorf("Case %v should have been kept: %v", i, c.kept)
+		} else if c.kept && !date.Equal(now.Add(c.date+c.moved)) {
+			t.Errorf("Case %v was moved to %v instead of %v", i, date, now.Add(c.date+c.moved))
+		}
+	}
+
+	// MariaDB does the same thing in the RemoveSchedule procedure, so all we can check is that every event is handed to it
+	mdb, dbmock := mockBotDB()
+	defer mdb.Close()
+	for i := range removeScheduleCases {
+		dbmock.ExpectExec("CALL RemoveSchedule.*").WithArgs(uint64(i + 1)).WillReturnResult(sqlmock.NewResult(0, 1))
+		Check(mdb.RemoveSchedule(uint64(i+1)), nil, t)
+	}
+	Check(dbmock.ExpectationsWereMet(), nil, t)
+}
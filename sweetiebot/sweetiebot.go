@@ -36,7 +36,7 @@ var guildfileregex = regexp.MustCompile("^([0-9]+)[.]json$")
 const DiscordEpoch uint64 = 1420070400000
 
 // BotVersion stores the current version of sweetiebot
-var BotVersion = Version{0, 9, 9, 25}
+var BotVersion = Version{0, 9, 9, 26}
 
 const (
 	MaxPublicLines    = 12
@@ -56,28 +56,19 @@ const (
 	heartbeatInterval = time.Duration(20 * time.Second)
 )
 
-type deferPair struct {
-	data interface{}
-	info *GuildInfo
-}
-
 // SweetieBot is the primary bot object containing the bot state
 type SweetieBot struct {
+	BotInstance
 	DB              *BotDB
-	DG              *DiscordGoSession
 	Debug           bool `json:"debug"`
 	changelog       map[int]string
-	SelfID          DiscordUser
-	SelfAvatar      string
-	SelfName        string
-	AppID           uint64
-	AppName         string
-	Owner           DiscordUser
-	Token           string                          `json:"token"`
-	DBAuth          string                          `json:"dbauth"`
-	MainGuildID     DiscordGuild                    `json:"mainguildid"`
-	DebugChannels   map[DiscordGuild]DiscordChannel `json:"debugchannels"`
-	quit            uint32                          // QuitNone means to keep running. QuitNow means to quit immediately. QuitRaid means to wait until no raids have occurred before quitting
+	DBAuth          string        `json:"dbauth"`
+	DBDriver        string        `json:"dbdriver"`  // Either "mysql" (the default) or "sqlite3", in which case dbauth is the path to the database file
+	InstanceConfigs []BotInstance `json:"instances"` // Additional bot accounts to run alongside this one
+	Instances       []*SweetieBot // The first shard of each of InstanceConfigs, only set on the bot that loaded selfhost.json
+	Shards          []*SweetieBot // Every shard of this account that runs in this process, including this one
+	parent          *SweetieBot   // The bot that loaded selfhost.json if this runs one of InstanceConfigs, otherwise nil
+	quit            *uint32       // QuitNone means to keep running. QuitNow means to quit immediately. QuitRaid means to wait until no raids have occurred before quitting. Shared by all instances.
 	Guilds          map[DiscordGuild]*GuildInfo
 	GuildsLock      sync.RWMutex
 	LastMessages    map[DiscordChannel]int64
@@ -87,16 +78,29 @@ type SweetieBot struct {
 	StartTime       int64
 	MessageCount    uint32 // 32-bit so we can do atomic ops on a 32-bit platform
 	heartbeat       uint32 // perpetually incrementing heartbeat counter to detect deadlock
+	heartbeatSent   uint32 // When the last heartbeat was sent, in milliseconds. Wraps around, but we only ever subtract it.
+	heartbeatTime   uint32 // How long it took the last heartbeat to get through the command pipeline, in milliseconds
+	heartbeatMissed uint32 // How many heartbeats in a row were missed
+	heartbeatLast   uint32 // When a heartbeat last made it through the command pipeline, in milliseconds
+	idleChecked     uint32 // When idleCheckLoop last started a pass, in milliseconds
+	deadlockChecked uint32 // When deadlockDetector last woke up, in milliseconds
+	connected       AtomicBool
+	sending         int32 // How many messages are waiting on a rate limit before they can be sent
 	locknumber      uint32
+	pagesLock       sync.Mutex
+	pages           map[string]*paginator // Paginated command results, by the message that shows them
 	loader          func(*GuildInfo) []Module
-	memberChan      chan *GuildInfo
-	deferChan       chan deferPair
+	commandStages   []commandStage // Every step a command goes through, or nil for defaultCommandStages
 	Selfhoster      *Selfhost
-	IsUserMode      bool       `json:"runasuser"` // True if running as a user for some godawful reason
-	WebSecure       bool       `json:"websecure"`
-	WebDomain       string     `json:"webdomain"`
-	WebPort         string     `json:"webport"`
-	EmptyGuild      *GuildInfo // Holds an empty GuildInfo for running server independent commands
+	Log             *Logger       // Writes to the console. Anything about a particular server should use GuildInfo.Logger instead.
+	WebSecure       bool          `json:"websecure"`
+	WebDomain       string        `json:"webdomain"`
+	WebPort         string        `json:"webport"`
+	ClientSecret    string        `json:"clientsecret"` // OAuth2 secret of the bot's application, used to log in to the dashboard
+	LogLevel        LogLevel      `json:"loglevel"`     // Lowest level of log entries written to the console
+	LogJSON         bool          `json:"logjson"`      // Write console log entries as JSON objects instead of text
+	TickInterval    time.Duration // How often idle checks and module OnTick hooks are run
+	EmptyGuild      *GuildInfo    // Holds an empty GuildInfo for running server independent commands
 	UpdateLock      AtomicFlag
 }
 
@@ -118,28 +122,37 @@ func (sb *SweetieBot) ChannelIsPrivate(channelID DiscordChannel) (*discordgo.Cha
 	return nil, true
 }
 
+// OnResumed discord hook
+func (sb *SweetieBot) OnResumed(s *discordgo.Session, r *discordgo.Resumed) {
+	sb.connected.Set(true)
+}
+
+// OnDisconnect discord hook
+func (sb *SweetieBot) OnDisconnect(s *discordgo.Session, d *discordgo.Disconnect) {
+	sb.connected.Set(false)
+}
+
 //func (sb *SweetieBot) OnEvent(s *discordgo.Session, e *discordgo.Event) { ApplyFuncRange(len(info.hooks.OnEvent), func(i int) { if(ProcessModule("", info.hooks.OnEvent[i])) { info.hooks.OnEvent[i].OnEvent(s, e) } }) }
 
 // OnReady discord hook
 func (sb *SweetieBot) OnReady(s *discordgo.Session, r *discordgo.Ready) {
-	fmt.Println("Ready message receieved, waiting for guilds...")
+	sb.Log.Info("Ready message received, waiting for guilds...")
+	sb.connected.Set(true)
 	sb.SelfID = DiscordUser(r.User.ID)
 	sb.SelfAvatar = r.User.Avatar
 	sb.SelfName = r.User.Username
-	sb.AppName = sb.SelfName
+	if sb.IsUserMode { // Bots got their name from their application before connecting
+		sb.AppName = sb.SelfName
+	}
 	if r.Guilds != nil && sb.IsUserMode {
 		for _, G := range r.Guilds {
 			sb.AttachToGuild(G)
 		}
 	}
-	app, err := s.Application("@me")
-	if err == nil {
-		sb.Owner = DiscordUser(app.Owner.ID)
-		sb.AppID = SBatoi(app.ID)
-		sb.AppName = app.Name
-	}
 
-	sb.Selfhoster.SelfUpdate(sb.Owner)
+	if sb.parent == nil && sb == sb.Shards[0] {
+		sb.Selfhoster.SelfUpdate(sb.Owner)
+	}
 }
 
 type moduleArray []Module
@@ -174,14 +187,15 @@ func (sb *SweetieBot) AttachToGuild(g *discordgo.Guild) {
 			sb.Guilds[DiscordGuild(g.ID)] = guild
 			guild.ProcessGuild(g)
 			sb.GuildsLock.Unlock()
-			sb.memberChan <- guild
+			guild.Queue(func() { sb.ingestMembers(guild, "") })
 			fmt.Println("Processed", g.Name)*/
 			return
 		}
 	}
 
-	fmt.Println("Initializing " + g.Name)
+	sb.Log.With("guild", g.ID).Info("Initializing " + g.Name)
 	guild = NewGuildInfo(sb, g)
+	guild.worker = newGuildWorker()
 	for _, m := range g.Members {
 		if sb.SelfID.Equals(m.User.ID) {
 			guild.BotNick = m.Nick
@@ -190,7 +204,7 @@ func (sb *SweetieBot) AttachToGuild(g *discordgo.Guild) {
 	config, err := ioutil.ReadFile(g.ID + ".json")
 	disableall := false
 	if err != nil {
-		fmt.Println("New Guild Detected: " + g.Name)
+		sb.Log.With("guild", g.ID).Info("New Guild Detected: " + g.Name)
 
 		ch, e := sb.DG.UserChannelCreate(g.OwnerID)
 		if e == nil {
@@ -222,25 +236,26 @@ func (sb *SweetieBot) AttachToGuild(g *discordgo.Guild) {
 				sb.DG.ChannelMessageSend(ch.ID, warning)
 			}
 		} else {
-			fmt.Println("Error sending introductory PM: ", e)
+			sb.Log.With("guild", g.ID).LogError("Error sending introductory PM: ", e)
 		}
 		disableall = true
 	} else if err := guild.MigrateSettings(config); err != nil {
-		fmt.Println("Error reading config file for "+g.Name+": ", err.Error())
+		sb.Log.With("guild", g.ID).LogError("Error reading config file for "+g.Name+": ", err)
 	}
 
 	guild.Config.FillConfig()
 	if sb.MainGuildID.Equals(g.ID) {
 		guild.Silver.Set(true)
 	}
+	if sb.DB.Status.Get() {
+		guild.loadCooldowns()
+	}
 
 	sb.GuildsLock.Lock()
 	sb.Guilds[DiscordGuild(g.ID)] = guild
 	guild.ProcessGuild(g) // This can be done outside of the guild lock, but it puts a lot of pressure on the database
 	sb.Selfhoster.CheckGuilds(map[DiscordGuild]*GuildInfo{DiscordGuild(g.ID): guild})
-	if atomic.LoadUint32(&sb.quit) == QuitNone { // Check this inside the GuildsLock to ensure we cannot ever send a message to a closed channel
-		sb.memberChan <- guild // Do this concurrently because it just has to happen eventually.
-	}
+	guild.Queue(func() { sb.ingestMembers(guild, "") }) // This runs once the guild has finished loading, because it just has to happen eventually.
 	sb.GuildsLock.Unlock()
 
 	guild.Modules = sb.loader(guild)
@@ -254,12 +269,13 @@ func (sb *SweetieBot) AttachToGuild(g *discordgo.Guild) {
 		}
 	}
 
+	guild.loadState()
 	guild.Clean()
 	if sb.Debug {
 		for _, v := range guild.Modules {
 			_, ok := guild.commands[CommandID(strings.ToLower(v.Name()))]
 			if ok {
-				fmt.Println("WARNING: Ambiguous module/command name ", v.Name())
+				sb.Log.With("guild", g.ID).Warning("Ambiguous module/command name ", v.Name())
 			}
 		}
 	}
@@ -274,8 +290,8 @@ func (sb *SweetieBot) AttachToGuild(g *discordgo.Guild) {
 		delete(guild.Config.Modules.CommandDisabled, "about")
 		guild.SaveConfig()
 	}
-	if sb.IsMainGuild(guild) {
-		sb.DB.log = guild
+	if sb.IsMainGuild(guild) && sb.parent == nil {
+		sb.DB.log = guild.Logger()
 	}
 
 	debug := "."
@@ -297,20 +313,29 @@ func (sb *SweetieBot) AttachToGuild(g *discordgo.Guild) {
 			changes += "\n\nPlease consider donating $1 to help pay for hosting costs: " + PatreonURL
 		}
 	}
+	guild.UpdateSlashCommands()
 	guild.Log(sb.AppName+" version ", BotVersion.String(), " successfully loaded on ", g.Name, debug, changes)
+	guild.startWorker()
 }
 func (sb *SweetieBot) getChannelGuild(id string) *GuildInfo {
 	c, err := sb.DG.State.Channel(id)
+	for i := 0; err != nil && i < len(sb.Shards); i++ { // Each shard only knows the channels of its own guilds
+		c, err = sb.Shards[i].DG.State.Channel(id)
+	}
 	if err != nil {
-		fmt.Println("Failed to get channel " + id)
+		sb.Log.Warning("Failed to get channel " + id)
 		return nil
 	}
 	return sb.getGuildFromID(c.GuildID)
 }
 func (sb *SweetieBot) getGuildFromID(id string) *GuildInfo {
-	sb.GuildsLock.RLock()
-	g, ok := sb.Guilds[DiscordGuild(id)]
-	sb.GuildsLock.RUnlock()
+	shard := sb.GuildShard(DiscordGuild(id))
+	if shard == nil {
+		return nil
+	}
+	shard.GuildsLock.RLock()
+	g, ok := shard.Guilds[DiscordGuild(id)]
+	shard.GuildsLock.RUnlock()
 	if !ok {
 		return nil
 	}
@@ -326,164 +351,70 @@ func (sb *SweetieBot) getAddMsg(info *GuildInfo) string {
 	return ""
 }
 
+// commandResponse decides where the output of a command ends up. Commands typed into a channel reply to that channel,
+// but slash commands have to reply through their interaction.
+type commandResponse interface {
+	SendError(info *GuildInfo, channelID DiscordChannel, message string, t int64)
+	SendMessage(info *GuildInfo, channelID DiscordChannel, message string) error
+	SendEmbed(info *GuildInfo, channelID DiscordChannel, embed *discordgo.MessageEmbed) error
+	SendPage(info *GuildInfo, channelID DiscordChannel, embed *discordgo.MessageEmbed) (*discordgo.Message, error)
+}
+
+type channelResponse struct{}
+
+func (r channelResponse) SendError(info *GuildInfo, channelID DiscordChannel, message string, t int64) {
+	info.SendError(channelID, message, t)
+}
+func (r channelResponse) SendMessage(info *GuildInfo, channelID DiscordChannel, message string) error {
+	return info.SendMessage(channelID, message)
+}
+func (r channelResponse) SendEmbed(info *GuildInfo, channelID DiscordChannel, embed *discordgo.MessageEmbed) error {
+	return info.SendEmbed(channelID, embed)
+}
+func (r channelResponse) SendPage(info *GuildInfo, channelID DiscordChannel, embed *discordgo.MessageEmbed) (*discordgo.Message, error) {
+	return info.sendPage(channelID, embed)
+}
+
 // ProcessCommand processes a command given to sweetiebot in the form "!command"
 func (sb *SweetieBot) ProcessCommand(m *discordgo.Message, info *GuildInfo, t int64, isdebug bool, private bool) {
-	var prefix byte = '!'
-	if info != nil && len(info.Config.Basic.CommandPrefix) == 1 {
-		prefix = info.Config.Basic.CommandPrefix[0]
+	sb.processCommand(m, info, t, isdebug, private, channelResponse{})
+}
+
+func (sb *SweetieBot) processCommand(m *discordgo.Message, info *GuildInfo, t int64, isdebug bool, private bool, r commandResponse) {
+	channelID := DiscordChannel(m.ChannelID)
+	prefix := sb.matchPrefix(info, channelID, m.Content)
+	var args []string
+	var indices []int
+	if len(prefix) > 0 {
+		args, indices = parseCommand(m.Content, len(prefix))
 	}
 
 	// Check if this is a command. If it is, process it as a command, otherwise process it with our modules.
-	if len(m.Content) > 1 && m.Content[0] == prefix && (len(m.Content) < 2 || m.Content[1] != prefix) { // We check for > 1 here because a single character can't possibly be a valid command
+	if len(args) > 0 {
 		isfree := private
-		authorid := SBatoi(m.Author.ID)
-		channelID := DiscordChannel(m.ChannelID)
 		if info != nil {
 			_, isfree = info.Config.Basic.FreeChannels[channelID]
 		}
 
-		// command := strings.ToLower(strings.SplitN(m.Content[1:], " ", 2)[0])
-		args, indices := ParseArguments(m.Content[1:])
-		arg := CommandID(strings.ToLower(args[0]))
-		if info == nil {
-			info = sb.GetDefaultServer(authorid)
-		}
-		if info == nil {
-			gIDs := []uint64{}
-			if _, independent := sb.EmptyGuild.commands[arg]; !independent {
-				if !sb.DB.Status.Get() {
-					sb.DG.ChannelMessageSend(m.ChannelID, "```\nA temporary database error means I can't process any private message commands right now.```")
-					return
-				}
-				gIDs = sb.DB.GetUserGuilds(authorid)
-				if len(gIDs) != 1 {
-					sb.DG.ChannelMessageSend(m.ChannelID, "```\nCannot determine what server you belong to! Use !defaultserver to set which server I should use when you PM me.```")
-					return
-				}
-			} else if sb.DB.Status.Get() {
-				gIDs = sb.DB.GetUserGuilds(authorid)
-			}
-
-			if len(gIDs) == 1 {
-				sb.GuildsLock.RLock()
-				info = sb.Guilds[NewDiscordGuild(gIDs[0])]
-				sb.GuildsLock.RUnlock()
-			}
-
-			if info == nil {
-				info = sb.EmptyGuild
-			}
+		ctx := &CommandContext{
+			Bot:      sb,
+			Info:     info,
+			Message:  m,
+			Prefix:   prefix,
+			Args:     args,
+			Indices:  indices,
+			Channel:  channelID,
+			Time:     t,
+			Private:  private,
+			Debug:    isdebug,
+			Free:     isfree,
+			response: r,
 		}
-
-		c, ok := info.commands[arg] // First, we check if this matches an existing command so you can't alias yourself into a hole
-		if !ok {
-			if alias, aliasok := info.Config.Basic.Aliases[string(arg)]; aliasok {
-				if len(indices) > 1 {
-					m.Content = info.Config.Basic.CommandPrefix + alias + " " + m.Content[indices[1]:]
-				} else {
-					m.Content = info.Config.Basic.CommandPrefix + alias
-				}
-				args, indices = ParseArguments(m.Content[1:])
-				arg = CommandID(strings.ToLower(args[0]))
-				c, ok = info.commands[arg]
-			}
-		}
-		if ok {
-			if sb.DB.Status.Get() && !sb.SelfID.Equals(m.Author.ID) {
-				sb.DB.Audit(AuditTypeCommand, m.Author, m.Content, SBatoi(info.ID))
-			}
-			cmdname := CommandID(strings.ToLower(c.Info().Name))
-			if m.ChannelID != "heartbeat" && !info.Config.SetupDone && cmdname != CommandID("setup") {
-				info.SendError(channelID, "You haven't set up the bot yet! Run the !setup command first and follow the instructions.", t)
-				return
-			}
-
-			ignore := false
-			if !private {
-				ignore = info.checkOnCommand(m)
-			}
-
-			cch := info.Config.Modules.CommandChannels[cmdname]
-			if !private && len(cch) > 0 {
-				_, reverse := cch["!"]
-				_, ok = cch[channelID]
-				ignore = ignore || ok == reverse
-			}
-
-			bypass, err := info.UserCanUseCommand(DiscordUser(m.Author.ID), c, ignore) // Bypass is true for administrators, mods, and the bot owner
-			if m.ChannelID == "heartbeat" {                                            // The heartbeat can never be ignored or disabled
-				bypass = true
-				err = nil
-			} else if err == errDisabled || err == errIgnored || err == errSilenced || err == errMainGuild {
-				return
-			}
-
-			if !isdebug && !isfree && !bypass && info.Config.Modules.CommandPerDuration > 0 { // debug channels aren't limited
-				if len(info.commandlimit.times) < info.Config.Modules.CommandPerDuration*2 { // Check if we need to re-allocate the array because the configuration changed
-					info.commandlimit.times = make([]int64, info.Config.Modules.CommandPerDuration*2, info.Config.Modules.CommandPerDuration*2)
-				}
-				if info.commandlimit.check(info.Config.Modules.CommandPerDuration, info.Config.Modules.CommandMaxDuration, t) { // if we've hit the saturation limit, post an error (which itself will only post if the error saturation limit hasn't been hit)
-					info.SendError(channelID, fmt.Sprintf("You can't input more than %v commands every %s!%s", info.Config.Modules.CommandPerDuration, TimeDiff(time.Duration(info.Config.Modules.CommandMaxDuration)*time.Second), sb.getAddMsg(info)), t)
-					return
-				}
-				info.commandlimit.append(t)
-			}
-			if err != nil {
-				info.SendError(channelID, err.Error(), t)
-				return
-			}
-
-			if c.Info().Silver && !info.Silver.Get() {
-				info.SendError(channelID, "That command is for Silver supporters only. Server owners can donate $1 a month to gain access: "+PatreonURL+". Visit the support channel for help if you already donated.", t)
-				return
-			}
-
-			cmdlimit := info.Config.Modules.CommandLimits[cmdname]
-			if !isfree && cmdlimit > 0 && !bypass {
-				cmdhash := channelID.String() + string(cmdname)
-				info.commandLock.RLock()
-				lastcmd := info.commandLast[cmdhash]
-				info.commandLock.RUnlock()
-				if !RateLimit(&lastcmd, cmdlimit, t) {
-					info.SendError(channelID, fmt.Sprintf("You can only run that command once every %s!%s", TimeDiff(time.Duration(cmdlimit)*time.Second), sb.getAddMsg(info)), t)
-					return
-				}
-				info.commandLock.Lock()
-				info.commandLast[cmdhash] = t
-				info.commandLock.Unlock()
-			}
-
-			result, usepm, resultembed := c.Process(args[1:], m, indices[1:], info)
-			if len(result) > 0 || resultembed != nil {
-				targetchannel := channelID
-				if usepm && !private {
-					channel, err := sb.DG.UserChannelCreate(m.Author.ID)
-					if err == nil {
-						targetchannel = DiscordChannel(channel.ID)
-						private = true
-						if rand.Float32() < 0.01 {
-							info.SendMessage(channelID, "Check your ~~privilege~~ Private Messages for my reply!")
-						} else {
-							info.SendMessage(channelID, "```\nCheck your Private Messages for my reply!```")
-						}
-					} else {
-						info.SendError(channelID, "I tried to send you a Private Message, but it failed! Try PMing me the command directly.", t)
-					}
-				}
-
-				if resultembed != nil {
-					if err := info.SendEmbed(targetchannel, resultembed); err != nil {
-						fmt.Println(err)
-					}
-				} else if err := info.SendMessage(targetchannel, result); err != nil {
-					fmt.Println(err)
-				}
-			}
-		} else if !info.Config.Basic.IgnoreInvalidCommands {
-			if private || !info.checkOnCommand(m) {
-				info.SendError(channelID, "Sorry, "+args[0]+" is not a valid command.\nFor a list of valid commands, type !help.", t)
-			}
+		stages := sb.commandStages
+		if stages == nil {
+			stages = defaultCommandStages
 		}
+		runCommandStages(ctx, stages)
 	} else if info != nil { // If info is nil this was sent through a private message so just ignore it completely
 		for _, h := range info.hooks.OnMessageCreate {
 			if info.ProcessModule(DiscordChannel(m.ChannelID), h) {
@@ -510,7 +441,12 @@ func (sb *SweetieBot) MessageCreate(s *discordgo.Session, m *discordgo.MessageCr
 	_, private := sb.ChannelIsPrivate(channelID)
 	var info *GuildInfo
 	isdebug := false
-	if !private {
+	if m.ChannelID == "heartbeat" {
+		info = sb.heartbeatGuild()
+		if info == nil {
+			sb.Log.Error("Failed to get main guild during heartbeat test!")
+		}
+	} else if !private {
 		info = sb.getChannelGuild(m.ChannelID)
 		if info == nil {
 			return
@@ -521,14 +457,24 @@ func (sb *SweetieBot) MessageCreate(s *discordgo.Session, m *discordgo.MessageCr
 	if isdebug && !sb.Debug {
 		return // we do this up here so the release build doesn't log messages in bot-debug, but debug builds still log messages from the rest of the channels
 	}
+	if info == nil { // Private messages don't belong to any guild, so there's no queue to wait in
+		sb.processMessage(m, info, t, isdebug, private)
+	} else {
+		info.QueueCritical(func() { sb.processMessage(m, info, t, isdebug, private) })
+	}
+}
+
+// processMessage logs a message and then runs it through the command pipeline
+func (sb *SweetieBot) processMessage(m *discordgo.MessageCreate, info *GuildInfo, t int64, isdebug bool, private bool) {
 	if m.ChannelID != "heartbeat" {
 		if info != nil && info.Silver.Get() && sb.DB.CheckStatus() { // Log message on silver guilds
-			if channelID != info.Config.Log.Channel {
-				sb.deferChan <- deferPair{m, info}
+			if !info.Config.Log.Channel.Equals(m.ChannelID) {
+				sb.DB.AddMessage(SBatoi(m.ID), m.Author, info.Sanitize(m.Content, CleanMentions|CleanPings), SBatoi(m.ChannelID), SBatoi(info.ID))
 			}
 		}
 		if info != nil {
-			sb.deferChan <- deferPair{m.Author, info}
+			sb.DB.SentMessage(SBatoi(m.Author.ID), SBatoi(info.ID))
+			sb.DB.SawUser(SBatoi(m.Author.ID), m.Author.Username)
 		}
 		if sb.SelfID.Equals(m.Author.ID) { // discard all our own messages (unless this is a heartbeat message)
 			return
@@ -539,13 +485,6 @@ func (sb *SweetieBot) MessageCreate(s *discordgo.Session, m *discordgo.MessageCr
 		if boolXOR(sb.Debug, isdebug) { // debug builds only respond to the debug channel, and release builds ignore it
 			return
 		}
-	} else {
-		sb.GuildsLock.RLock()
-		info, _ = sb.Guilds[sb.MainGuildID]
-		sb.GuildsLock.RUnlock()
-		if info == nil {
-			fmt.Println("Failed to get main guild during heartbeat test!")
-		}
 	}
 
 	sb.ProcessCommand(m.Message, info, t, isdebug, private)
@@ -565,23 +504,25 @@ func (sb *SweetieBot) MessageUpdate(s *discordgo.Session, m *discordgo.MessageUp
 		return // Because this only happens for media links and causes all sorts of problems, we just don't process messages without an author.
 	}
 
-	ch, err := sb.DG.State.Channel(m.ChannelID)
-	info.LogError("Error retrieving channel ID "+m.ChannelID+": ", err)
-	private := true
-	if err == nil {
-		private = typeIsPrivate(ch.Type)
-	}
-	if channelID != info.Config.Log.Channel && !private && info.Silver.Get() && sb.DB.CheckStatus() { // Always ignore messages from the log channel
-		sb.DB.AddMessage(SBatoi(m.ID), m.Author, info.Sanitize(m.Content, CleanMentions|CleanPings), channelID.Convert(), SBatoi(ch.GuildID))
-	}
-	if sb.SelfID.Equals(m.Author.ID) {
-		return
-	}
-	for _, h := range info.hooks.OnMessageUpdate {
-		if info.ProcessModule(channelID, h) {
-			h.OnMessageUpdate(info, m.Message)
+	info.Queue(func() {
+		ch, err := sb.DG.State.Channel(m.ChannelID)
+		info.LogError("Error retrieving channel ID "+m.ChannelID+": ", err)
+		private := true
+		if err == nil {
+			private = typeIsPrivate(ch.Type)
 		}
-	}
+		if channelID != info.Config.Log.Channel && !private && info.Silver.Get() && sb.DB.CheckStatus() { // Always ignore messages from the log channel
+			sb.DB.AddMessage(SBatoi(m.ID), m.Author, info.Sanitize(m.Content, CleanMentions|CleanPings), channelID.Convert(), SBatoi(ch.GuildID))
+		}
+		if sb.SelfID.Equals(m.Author.ID) {
+			return
+		}
+		for _, h := range info.hooks.OnMessageUpdate {
+			if info.ProcessModule(channelID, h) {
+				h.OnMessageUpdate(info, m.Message)
+			}
+		}
+	})
 }
 
 // MessageDelete discord hook
@@ -594,16 +535,18 @@ func (sb *SweetieBot) MessageDelete(s *discordgo.Session, m *discordgo.MessageDe
 	if boolXOR(sb.Debug, info.IsDebug(channelID)) {
 		return
 	}
-	for _, h := range info.hooks.OnMessageDelete {
-		if info.ProcessModule(channelID, h) {
-			h.OnMessageDelete(info, m.Message)
+	info.Queue(func() {
+		for _, h := range info.hooks.OnMessageDelete {
+			if info.ProcessModule(channelID, h) {
+				h.OnMessageDelete(info, m.Message)
+			}
 		}
-	}
+	})
 }
 
 // UserUpdate discord hook
 func (sb *SweetieBot) UserUpdate(s *discordgo.Session, u *discordgo.UserUpdate) {
-	sb.deferChan <- deferPair{u, nil}
+	sb.ProcessUser(u.User)
 }
 
 // GuildUpdate discord hook
@@ -612,7 +555,7 @@ func (sb *SweetieBot) GuildUpdate(s *discordgo.Session, m *discordgo.GuildUpdate
 	if info == nil {
 		return
 	}
-	fmt.Println("Guild update detected, updating", m.Name)
+	info.Logger().Debug("Guild update detected, updating " + m.Name)
 	sb.Selfhoster.CheckGuilds(map[DiscordGuild]*GuildInfo{DiscordGuild(info.ID): info})
 	info.ProcessGuild(m.Guild)
 
@@ -629,18 +572,21 @@ func (sb *SweetieBot) GuildMemberAdd(s *discordgo.Session, m *discordgo.GuildMem
 	if info == nil {
 		return
 	}
-	info.ProcessMember(m.Member)
+	t := time.Now().UTC()
+	info.QueueCritical(func() {
+		info.ProcessMember(m.Member)
 
-	if info.ID == SilverServerID && sb.Selfhoster.CheckDonor(m.Member) {
-		sb.GuildsLock.RLock()
-		sb.Selfhoster.CheckGuilds(sb.Guilds)
-		sb.GuildsLock.RUnlock()
-	}
-	for _, h := range info.hooks.OnGuildMemberAdd {
-		if info.ProcessModule("", h) {
-			h.OnGuildMemberAdd(info, m.Member, time.Now().UTC())
+		if info.ID == SilverServerID && sb.Selfhoster.CheckDonor(m.Member) {
+			sb.GuildsLock.RLock()
+			sb.Selfhoster.CheckGuilds(sb.Guilds)
+			sb.GuildsLock.RUnlock()
 		}
-	}
+		for _, h := range info.hooks.OnGuildMemberAdd {
+			if info.ProcessModule("", h) {
+				h.OnGuildMemberAdd(info, m.Member, t)
+			}
+		}
+	})
 }
 
 // GuildMemberRemove discord hook
@@ -650,30 +596,36 @@ func (sb *SweetieBot) GuildMemberRemove(s *discordgo.Session, m *discordgo.Guild
 		return
 	}
 	userID := DiscordUser(m.User.ID)
-	if sb.DB.CheckStatus() {
-		sb.DB.RemoveMember(userID.Convert(), SBatoi(info.ID))
-	}
+	t := time.Now().UTC()
+	info.Queue(func() {
+		if sb.DB.CheckStatus() {
+			sb.DB.RemoveMember(userID.Convert(), SBatoi(info.ID))
+		}
 
-	if info.ID == SilverServerID {
-		if _, check := sb.Selfhoster.Donors.Load(m.User.ID); check {
-			sb.Selfhoster.Donors.Delete(m.User.ID)
-			sb.GuildsLock.RLock()
-			sb.Selfhoster.CheckGuilds(sb.Guilds)
-			sb.GuildsLock.RUnlock()
+		if info.ID == SilverServerID {
+			if _, check := sb.Selfhoster.Donors.Load(m.User.ID); check {
+				sb.Selfhoster.Donors.Delete(m.User.ID)
+				sb.GuildsLock.RLock()
+				sb.Selfhoster.CheckGuilds(sb.Guilds)
+				sb.GuildsLock.RUnlock()
+			}
 		}
-	}
 
-	for _, h := range info.hooks.OnGuildMemberRemove {
-		if info.ProcessModule("", h) {
-			h.OnGuildMemberRemove(info, m.Member, time.Now().UTC())
+		for _, h := range info.hooks.OnGuildMemberRemove {
+			if info.ProcessModule("", h) {
+				h.OnGuildMemberRemove(info, m.Member, t)
+			}
 		}
-	}
+	})
 
 	if userID == sb.SelfID {
-		fmt.Println("Sweetie was removed from", info.Name)
+		sb.Log.With("guild", info.ID).Info("Sweetie was removed from " + info.Name)
 		sb.GuildsLock.Lock()
 		delete(sb.Guilds, DiscordGuild(info.ID))
 		sb.GuildsLock.Unlock()
+		if !info.Queue(info.stopWorker) {
+			info.stopWorker()
+		}
 	}
 }
 
@@ -686,18 +638,21 @@ func (sb *SweetieBot) GuildMemberUpdate(s *discordgo.Session, m *discordgo.Guild
 	if sb.SelfID.Equals(m.User.ID) && len(m.Nick) > 0 {
 		info.BotNick = m.Nick
 	}
-	sb.deferChan <- deferPair{m, info}
-	if info.ID == SilverServerID && sb.Selfhoster.CheckDonor(m.Member) {
-		sb.GuildsLock.RLock()
-		sb.Selfhoster.CheckGuilds(sb.Guilds)
-		sb.GuildsLock.RUnlock()
-	}
+	t := time.Now().UTC()
+	info.Queue(func() {
+		info.ProcessMember(m.Member)
+		if info.ID == SilverServerID && sb.Selfhoster.CheckDonor(m.Member) {
+			sb.GuildsLock.RLock()
+			sb.Selfhoster.CheckGuilds(sb.Guilds)
+			sb.GuildsLock.RUnlock()
+		}
 
-	for _, h := range info.hooks.OnGuildMemberUpdate {
-		if info.ProcessModule("", h) {
-			h.OnGuildMemberUpdate(info, m.Member, time.Now().UTC())
+		for _, h := range info.hooks.OnGuildMemberUpdate {
+			if info.ProcessModule("", h) {
+				h.OnGuildMemberUpdate(info, m.Member, t)
+			}
 		}
-	}
+	})
 }
 
 // GuildBanAdd discord hook
@@ -707,11 +662,13 @@ func (sb *SweetieBot) GuildBanAdd(s *discordgo.Session, m *discordgo.GuildBanAdd
 		return
 	}
 
-	for _, h := range info.hooks.OnGuildBanAdd {
-		if info.ProcessModule("", h) {
-			h.OnGuildBanAdd(info, m)
+	info.Queue(func() {
+		for _, h := range info.hooks.OnGuildBanAdd {
+			if info.ProcessModule("", h) {
+				h.OnGuildBanAdd(info, m)
+			}
 		}
-	}
+	})
 }
 
 // GuildBanRemove discord hook
@@ -721,11 +678,13 @@ func (sb *SweetieBot) GuildBanRemove(s *discordgo.Session, m *discordgo.GuildBan
 		return
 	}
 
-	for _, h := range info.hooks.OnGuildBanRemove {
-		if info.ProcessModule("", h) {
-			h.OnGuildBanRemove(info, m)
+	info.Queue(func() {
+		for _, h := range info.hooks.OnGuildBanRemove {
+			if info.ProcessModule("", h) {
+				h.OnGuildBanRemove(info, m)
+			}
 		}
-	}
+	})
 }
 
 // GuildRoleDelete discord hook
@@ -735,11 +694,29 @@ func (sb *SweetieBot) GuildRoleDelete(s *discordgo.Session, m *discordgo.GuildRo
 		return
 	}
 
-	for _, h := range info.hooks.OnGuildRoleDelete {
-		if info.ProcessModule("", h) {
-			h.OnGuildRoleDelete(info, m)
+	info.Queue(func() {
+		for _, h := range info.hooks.OnGuildRoleDelete {
+			if info.ProcessModule("", h) {
+				h.OnGuildRoleDelete(info, m)
+			}
 		}
+	})
+}
+
+// VoiceStateUpdate discord hook
+func (sb *SweetieBot) VoiceStateUpdate(s *discordgo.Session, m *discordgo.VoiceStateUpdate) {
+	info := sb.getGuildFromID(m.GuildID)
+	if info == nil {
+		return
 	}
+
+	info.Queue(func() {
+		for _, h := range info.hooks.OnVoiceStateUpdate {
+			if info.ProcessModule("", h) {
+				h.OnVoiceStateUpdate(info, m.VoiceState)
+			}
+		}
+	})
 }
 
 // GuildCreate discord hook
@@ -750,10 +727,14 @@ func (sb *SweetieBot) GuildCreate(s *discordgo.Session, m *discordgo.GuildCreate
 // GuildDelete discord hook
 func (sb *SweetieBot) GuildDelete(s *discordgo.Session, m *discordgo.GuildDelete) {
 	if !m.Unavailable {
-		fmt.Println("Sweetie was deleted from", m.Guild.Name)
+		sb.Log.With("guild", m.Guild.ID).Info("Sweetie was deleted from " + m.Guild.Name)
 		sb.GuildsLock.Lock()
+		info := sb.Guilds[DiscordGuild(m.Guild.ID)]
 		delete(sb.Guilds, DiscordGuild(m.Guild.ID))
 		sb.GuildsLock.Unlock()
+		if info != nil {
+			info.stopWorker()
+		}
 	}
 }
 
@@ -772,10 +753,7 @@ func (sb *SweetieBot) FindServers(name string, guilds []uint64) []*GuildInfo {
 	name = strings.ToLower(name)
 	info := make([]*GuildInfo, 0, len(guilds))
 	for _, g := range guilds {
-		sb.GuildsLock.RLock()
-		guild, ok := sb.Guilds[NewDiscordGuild(g)]
-		sb.GuildsLock.RUnlock()
-		if ok {
+		if guild := sb.getGuildFromID(SBitoa(g)); guild != nil {
 			n := strings.ToLower(guild.Name)
 			if len(n) > 0 {
 				if n == name { // if these are an EXACT match, throw away the other results and just return this
@@ -801,13 +779,7 @@ func (sb *SweetieBot) GetDefaultServer(user uint64) *GuildInfo {
 	if server == nil {
 		return nil
 	}
-	sb.GuildsLock.RLock()
-	defer sb.GuildsLock.RUnlock()
-	info, ok := sb.Guilds[NewDiscordGuild(*server)]
-	if !ok {
-		return nil
-	}
-	return info
+	return sb.getGuildFromID(SBitoa(*server))
 }
 
 // ProcessUser adds a user to the database
@@ -820,54 +792,32 @@ func (sb *SweetieBot) ProcessUser(u *discordgo.User) uint64 {
 	return id
 }
 
-func (sb *SweetieBot) memberIngestionLoop() {
-	for atomic.LoadUint32(&sb.quit) != QuitNow {
-		guild, more := <-sb.memberChan
-		if !more {
-			return
-		}
-		fmt.Println("Member processing for: " + guild.Name)
-		members := []*discordgo.Member{}
-		lastid := ""
-		for {
-			m, err := sb.DG.GuildMembers(guild.ID, lastid, 999)
-			if err != nil || len(m) == 0 {
-				break
-			}
-			members = append(members, m...)
-			lastid = m[len(m)-1].User.ID
-		}
+// ingestMembers loads one page of the members of a guild into the state, then queues the next page behind anything
+// that happened in the meantime, so large guilds keep responding while their member list loads.
+func (sb *SweetieBot) ingestMembers(guild *GuildInfo, lastid string) {
+	if len(lastid) == 0 {
+		sb.Log.With("guild", guild.ID).Debug("Member processing for: " + guild.Name)
+	}
+	members, err := sb.DG.GuildMembers(guild.ID, lastid, 999)
+	if err != nil || len(members) == 0 {
 		if guild.ID == SilverServerID {
-			for _, m := range members {
-				sb.Selfhoster.CheckDonor(m)
-			}
 			sb.GuildsLock.RLock()
 			sb.Selfhoster.CheckGuilds(sb.Guilds)
 			sb.GuildsLock.RUnlock()
 		}
-		for i := range members { // Put the guildID back in because discord is stupid
-			members[i].GuildID = guild.ID
-			sb.DG.State.MemberAdd(members[i])
-		}
+		return
 	}
-}
-
-func (sb *SweetieBot) deferProcessing() {
-	for atomic.LoadUint32(&sb.quit) != QuitNow {
-		pair := <-sb.deferChan
-		switch v := pair.data.(type) {
-		case *discordgo.MessageCreate:
-			sb.DB.AddMessage(SBatoi(v.ID), v.Author, pair.info.Sanitize(v.Content, CleanMentions|CleanPings), SBatoi(v.ChannelID), SBatoi(pair.info.ID))
-		case *discordgo.User:
-			sb.DB.SentMessage(SBatoi(v.ID), SBatoi(pair.info.ID))
-			sb.DB.SawUser(SBatoi(v.ID), v.Username)
-		case *discordgo.UserUpdate:
-			sb.ProcessUser(v.User)
-		case *discordgo.GuildMemberUpdate:
-			pair.info.ProcessMember(v.Member)
+	for i := range members { // Put the guildID back in because discord is stupid
+		members[i].GuildID = guild.ID
+		sb.DG.State.MemberAdd(members[i])
+		if guild.ID == SilverServerID {
+			sb.Selfhoster.CheckDonor(members[i])
 		}
 	}
+	next := members[len(members)-1].User.ID
+	go guild.Queue(func() { sb.ingestMembers(guild, next) }) // Can't block on our own queue
 }
+
 func (sb *SweetieBot) idleCheck(info *GuildInfo, guild *discordgo.Guild) {
 	sb.DG.State.RLock()
 	channels := guild.Channels
@@ -907,7 +857,8 @@ func (sb *SweetieBot) idleCheck(info *GuildInfo, guild *discordgo.Guild) {
 }
 
 func (sb *SweetieBot) idleCheckLoop() {
-	for atomic.LoadUint32(&sb.quit) != QuitNow {
+	for atomic.LoadUint32(sb.quit) != QuitNow {
+		atomic.StoreUint32(&sb.idleChecked, heartbeatClock())
 		sb.DB.CheckStatus()
 		sb.GuildsLock.RLock()
 		infos := make([]*GuildInfo, 0, len(sb.Guilds))
@@ -916,15 +867,18 @@ func (sb *SweetieBot) idleCheckLoop() {
 		}
 		sb.GuildsLock.RUnlock()
 		for _, info := range infos {
-			guild, err := sb.DG.State.Guild(info.ID)
-			if err != nil {
-				continue
-			}
-			sb.idleCheck(info, guild)
+			info := info
+			info.queueTick(func() {
+				if guild, err := sb.DG.State.Guild(info.ID); err == nil {
+					sb.idleCheck(info, guild)
+				}
+				info.saveCooldowns(time.Now().UTC().Unix())
+			})
 		}
 
-		fmt.Println("Idle Check: ", time.Now())
-		time.Sleep(20 * time.Second)
+		sb.expirePages(time.Now().UTC().Unix())
+		sb.Log.Debug("Idle Check")
+		time.Sleep(sb.TickInterval)
 	}
 }
 
@@ -941,6 +895,44 @@ func (sb *SweetieBot) deadlockTestFunc(s *discordgo.Session, m *discordgo.Messag
 	sb.MessageCreate(&sb.DG.Session, m)
 }
 
+// heartbeatGuild returns the guild this shard sends its heartbeats through. This is the main guild if the shard has
+// it, otherwise sharded sessions fall back to any of their own guilds so every shard gets monitored.
+func (sb *SweetieBot) heartbeatGuild() *GuildInfo {
+	sb.GuildsLock.RLock()
+	defer sb.GuildsLock.RUnlock()
+	if info, ok := sb.Guilds[sb.MainGuildID]; ok {
+		return info
+	}
+	if sb.ShardCount > 1 {
+		for _, info := range sb.Guilds {
+			return info
+		}
+	}
+	return nil
+}
+
+// beat registers a heartbeat that made it through the command pipeline and measures how long it took
+func (sb *SweetieBot) beat() {
+	atomic.StoreUint32(&sb.heartbeatLast, heartbeatClock())
+	atomic.StoreUint32(&sb.heartbeatTime, heartbeatClock()-atomic.LoadUint32(&sb.heartbeatSent))
+	atomic.AddUint32(&sb.heartbeat, 1)
+}
+
+// HeartbeatLatency returns how long the last heartbeat took to get through the command pipeline of this shard, and
+// how many heartbeats in a row have been missed.
+func (sb *SweetieBot) HeartbeatLatency() (time.Duration, uint32) {
+	if atomic.LoadUint32(&sb.heartbeatSent) == 0 {
+		return 0, 0
+	}
+	return time.Duration(atomic.LoadUint32(&sb.heartbeatTime)) * time.Millisecond, atomic.LoadUint32(&sb.heartbeatMissed)
+}
+
+// heartbeatClock returns the current time in milliseconds, truncated to 32 bits so it can be stored atomically on
+// 32-bit platforms
+func heartbeatClock() uint32 {
+	return uint32(time.Now().UnixNano() / int64(time.Millisecond))
+}
+
 func (sb *SweetieBot) deadlockDetector() {
 	var counter = sb.heartbeat
 	var missed = 0
@@ -948,21 +940,23 @@ func (sb *SweetieBot) deadlockDetector() {
 	time.Sleep(heartbeatInterval) // Give sweetie time to load everything first before initiating heartbeats
 
 	for {
-		sb.GuildsLock.RLock()
-		info, _ = sb.Guilds[sb.MainGuildID]
-		sb.GuildsLock.RUnlock()
-
-		if info != nil {
+		atomic.StoreUint32(&sb.deadlockChecked, heartbeatClock())
+		if info = sb.heartbeatGuild(); info != nil {
 			break
 		}
 
-		fmt.Println(sb.MainGuildID, "MAIN GUILD CANNOT BE FOUND! Deadlock detector is nonfunctional until this is addressed.")
+		if sb.ShardCount > 1 {
+			sb.Log.With("shard", sb.ShardID).Warning("Shard has no guilds! Deadlock detector is nonfunctional on this shard until it joins one.")
+		} else {
+			sb.Log.With("guild", sb.MainGuildID).Warning("Main guild cannot be found! Deadlock detector is nonfunctional until this is addressed.")
+		}
 		time.Sleep(heartbeatInterval)
 	}
 
-	for atomic.LoadUint32(&sb.quit) != QuitNow {
+	for atomic.LoadUint32(sb.quit) != QuitNow {
+		atomic.StoreUint32(&sb.deadlockChecked, heartbeatClock())
 		m := discordgo.MessageCreate{
-			&discordgo.Message{ChannelID: "heartbeat", Content: info.Config.Basic.CommandPrefix + "about",
+			&discordgo.Message{ChannelID: "heartbeat", Content: info.Prefix(ChannelEmpty) + "about",
 				Author: &discordgo.User{
 					ID:       sb.SelfID.String(),
 					Verified: true,
@@ -972,6 +966,7 @@ func (sb *SweetieBot) deadlockDetector() {
 			},
 		}
 		sb.locknumber = 0
+		atomic.StoreUint32(&sb.heartbeatSent, heartbeatClock())
 		go sb.deadlockTestFunc(&sb.DG.Session, &m) // Do this in another thread so the deadlock detector doesn't deadlock
 		time.Sleep(heartbeatInterval)
 		if atomic.LoadUint32(&sb.heartbeat) == counter+1 {
@@ -979,11 +974,12 @@ func (sb *SweetieBot) deadlockDetector() {
 			missed = 0
 		} else {
 			missed++
-			fmt.Println("MISSED HEARTBEAT SIGNAL ", missed, " TIMES IN A ROW")
+			sb.Log.With("shard", sb.ShardID).Warning("Missed heartbeat signal ", missed, " times in a row")
 			counter = atomic.LoadUint32(&sb.heartbeat)
 		}
+		atomic.StoreUint32(&sb.heartbeatMissed, uint32(missed))
 		if missed >= 5 {
-			fmt.Println("FATAL ERROR: DEADLOCK DETECTED! (", sb.locknumber, ") TERMINATING PROGRAM...")
+			sb.Log.With("shard", sb.ShardID).Error("FATAL ERROR: DEADLOCK DETECTED! (", sb.locknumber, ") TERMINATING PROGRAM...")
 			name := fmt.Sprintf("stacktrace_%v.txt", time.Now().UTC().Unix())
 			if f, err := os.Create(name); err == nil {
 				pprof.Lookup("goroutine").WriteTo(f, 1)
@@ -1003,9 +999,6 @@ func (sb *SweetieBot) deadlockDetector() {
 // New creates and initializes a new instance of Sweetiebot that's ready to connect. Returns nil on error.
 func New(token string, loader func(*GuildInfo) []Module) *SweetieBot {
 	path, _ := GetCurrentDir()
-	selfhoster := &Selfhost{SelfhostBase{BotVersion.Integer()}, AtomicBool{0}, sync.Map{}}
-	rand.Seed(time.Now().UTC().Unix())
-
 	hostfile, gerr := ioutil.ReadFile("selfhost.json")
 	if gerr != nil {
 
@@ -1017,14 +1010,25 @@ func New(token string, loader func(*GuildInfo) []Module) *SweetieBot {
 				os.Exit(1)
 			}
 		}()
-		Install(path, selfhoster)
+		Install(path, &Selfhost{SelfhostBase{BotVersion.Integer()}, AtomicBool{0}, sync.Map{}})
 	}
 
+	return NewFromSelfhost(token, hostfile, loader)
+}
+
+// NewFromSelfhost creates a new sweetiebot instance using the given contents of a selfhost.json file instead of reading it from disk
+func NewFromSelfhost(token string, hostfile []byte, loader func(*GuildInfo) []Module) *SweetieBot {
+	selfhoster := &Selfhost{SelfhostBase{BotVersion.Integer()}, AtomicBool{0}, sync.Map{}}
+	rand.Seed(time.Now().UTC().Unix())
+
 	sb := &SweetieBot{
-		Token:          token,
-		SelfName:       "Sweetie Bot",
-		AppName:        "Sweetie Bot",
-		DebugChannels:  make(map[DiscordGuild]DiscordChannel),
+		BotInstance: BotInstance{
+			Token:         token,
+			SelfName:      "Sweetie Bot",
+			AppName:       "Sweetie Bot",
+			DebugChannels: make(map[DiscordGuild]DiscordChannel),
+		},
+		quit:           new(uint32),
 		Guilds:         make(map[DiscordGuild]*GuildInfo),
 		LastMessages:   make(map[DiscordChannel]int64),
 		MaxConfigSize:  1000000,
@@ -1032,13 +1036,14 @@ func New(token string, loader func(*GuildInfo) []Module) *SweetieBot {
 		StartTime:      time.Now().UTC().Unix(),
 		heartbeat:      4294967290,
 		loader:         loader,
-		memberChan:     make(chan *GuildInfo, 1500),
-		deferChan:      make(chan deferPair, 2000),
 		Selfhoster:     selfhoster,
 		WebSecure:      false,
 		WebDomain:      "localhost",
 		WebPort:        ":80",
+		LogLevel:       LogInfo,
+		TickInterval:   time.Duration(20 * time.Second),
 		changelog: map[int]string{
+			AssembleVersion(0, 9, 9, 26): "- Added moderation cases. Bans, silences, wipes and the spam filter now record a numbered case, which can be looked up with !case, listed with !cases, and given a reason afterwards with !reason.\n- Added !note to record notes in a user's moderation history.\n- Added !warn, which gives out warning points that decay over time. Reaching the thresholds in the new warnings config group automatically silences or temporarily bans someone.\n- Silence timeouts from the spam filter are now kept in the schedule, so they survive restarts. Moderators can see them with !schedule timeouts, and !silence accepts a duration without for: when it ends the message, like !silence @user 2 hours.\n- Added the logging module, which posts message edits and deletes, joins, leaves, and nickname and role changes to log.eventchannel (or log.channel). Use modules.channels to exclude channels from it. It starts disabled on existing servers; use !enable logging to turn it on.\n- Every config change is now recorded in a config history. Use !confighistory to see who changed what, and !configrollback to restore an earlier version. !exportconfig and !importconfig send and load the whole config as a file.\n- Added a web dashboard at /dashboard where server admins can log in with discord and edit any config option. Selfhosters need to set clientsecret in selfhost.json to enable it.\n- Added the Voice module, which logs voice channel activity, tracks time spent in voice (see !voicetime), and can create temporary voice channels for anyone joining one of voice.tempchannels. It is disabled by default on existing servers.\n- Large bots can split their connection into shards with `shardcount` and `shards` in selfhost.json. Use !shards to check the heartbeat and server count of each shard.\n- Every server now processes its events in order on its own queue, so a slow server no longer holds up the others. Use !queues to see which servers are falling behind.\n- Modules can now register their own configuration categories, along with their defaults, help text and migrations. So far only the voice and customcommands categories work this way; every other category is still part of the core config.\n- Modules now talk to each other through events published on the server instead of calling each other directly. Filters add pressure by publishing an event the spam module listens for.\n- Commands now run through a chain of middleware, and selfhosters can add their own steps to it.\n- Added modules.usercooldowns and modules.rolecooldowns, so a command can be limited per user or per role instead of only per channel with modules.commandlimits. Cooldown errors now say how long is left, and cooldowns survive restarts.\n- basic.commandprefix can now be any length and include emoji. Add more prefixes with basic.extraprefixes, or replace the prefix in a single channel with basic.channelprefixes. Mentioning the bot right before a command always works as a prefix.\n- Added the CustomCommands module. Moderators can add their own commands with !addcommand, which respond with a template that can use arguments, the author, random choices, counters, tags and the time.\n- The webserver now serves Prometheus metrics at /metrics, covering messages, commands, database statements, rate limits and spam silences.\n- Log entries now have levels and record the server, channel and command they came from. Use log.level and log.channellevel to choose which ones are saved to the debug log and posted in log.channel. Selfhosters can set loglevel and logjson in selfhost.json to filter the console or write it as JSON.\n- The webserver now answers health checks at /healthz and /readyz, reporting the discord connection, heartbeat, database and background loops of every shard.\n- Shutting down now finishes queued events and pending messages first, and the spam module remembers spam pressure, the last raid and any lockdown across a restart, so the verification level still gets restored afterwards.\n- !help, !listguilds, !getaudit, !searchtags, !schedule and !search now show long results one page at a time in the channel instead of cutting them off or sending them in a PM. Whoever ran the command can flip through the pages by reacting with ◀ and ▶ for a few minutes.",
 			AssembleVersion(0, 9, 9, 25): "- Changed !autosilence command to !raidsilence and migrated any existing aliases.\n- The bot now tells the user if a PM failed to be sent.\n- The bot now yells at you if you haven't set it up on the server yet.\n- Added a silence timeout even though this is a bad idea becuase you all wanted it so damn bad.\n- Added a counter module for all your counting needs.\n- Setting a config string value to \"\" will now actually delete the string value.",
 			AssembleVersion(0, 9, 9, 24): "- Fix updater issue on linux\n- provide zip files instead of raw files for downloads\n- Fix timezones on windows without go installations\n- more idiotproofing",
 			AssembleVersion(0, 9, 9, 23): "- Fixed crash in RolesModule",
@@ -1189,67 +1194,38 @@ func New(token string, loader func(*GuildInfo) []Module) *SweetieBot {
 	}
 
 	json.Unmarshal(hostfile, sb)
-	sb.Token = strings.TrimSpace(sb.Token)
-	sb.EmptyGuild = NewGuildInfo(sb, &discordgo.Guild{})
-
-	sb.EmptyGuild.Config.FillConfig()
-	sb.EmptyGuild.Config.SetupDone = true
-	sb.EmptyGuild.Modules = sb.loader(sb.EmptyGuild)
-	sort.Sort(moduleArray(sb.EmptyGuild.Modules))
-
-	for _, v := range sb.EmptyGuild.Modules {
-		sb.EmptyGuild.RegisterModule(v)
-		for _, command := range v.Commands() {
-			if command.Info().ServerIndependent {
-				sb.EmptyGuild.AddCommand(command, v)
-			}
-		}
-	}
+	sb.Log = NewLogger(os.Stdout, sb.LogLevel, sb.LogJSON)
 
-	db, err := dbLoad(&emptyLog{}, "mysql", strings.TrimSpace(sb.DBAuth))
+	if len(sb.DBDriver) == 0 {
+		sb.DBDriver = DriverMySQL
+	}
+	db, err := dbLoad(sb.Log, sb.DBDriver, strings.TrimSpace(sb.DBAuth))
 	sb.DB = db
 	if !db.Status.Get() {
-		fmt.Println("Database connection failure - running in No Database mode: ", err.Error())
+		sb.Log.LogError("Database connection failure - running in No Database mode: ", err)
 	} else {
 		err = sb.DB.LoadStatements()
 		if err == nil {
-			fmt.Println("Finished loading database statements")
+			sb.Log.Info("Finished loading database statements")
 		} else {
-			fmt.Println("Loading database statements failed: ", err)
-			fmt.Println("DATABASE IS BADLY FORMATTED OR CORRUPT - TERMINATING SWEETIE BOT!")
+			sb.Log.LogError("Loading database statements failed: ", err)
+			sb.Log.Error("DATABASE IS BADLY FORMATTED OR CORRUPT - TERMINATING SWEETIE BOT!")
 			return nil
 		}
 	}
 
-	var dg *discordgo.Session
-	if sb.IsUserMode {
-		dg, err = discordgo.New(sb.Token)
-		fmt.Println("Started SweetieBot on a user account.")
-	} else {
-		dg, err = discordgo.New("Bot " + sb.Token)
-	}
-	sb.DG = &DiscordGoSession{*dg}
-
-	if err != nil {
-		fmt.Println("Error creating discord session", err.Error())
+	if err = sb.initShards(); err != nil {
+		sb.Log.LogError("Error creating discord session: ", err)
 		return nil
 	}
-	sb.DG.LogLevel = discordgo.LogWarning
-
-	sb.DG.AddHandler(sb.OnReady)
-	sb.DG.AddHandler(sb.MessageCreate)
-	sb.DG.AddHandler(sb.MessageUpdate)
-	sb.DG.AddHandler(sb.MessageDelete)
-	sb.DG.AddHandler(sb.UserUpdate)
-	sb.DG.AddHandler(sb.GuildUpdate)
-	sb.DG.AddHandler(sb.GuildMemberAdd)
-	sb.DG.AddHandler(sb.GuildMemberRemove)
-	sb.DG.AddHandler(sb.GuildMemberUpdate)
-	sb.DG.AddHandler(sb.GuildBanAdd)
-	sb.DG.AddHandler(sb.GuildBanRemove)
-	sb.DG.AddHandler(sb.GuildRoleDelete)
-	sb.DG.AddHandler(sb.GuildCreate)
-	sb.DG.AddHandler(sb.ChannelCreate)
+	for i, config := range sb.InstanceConfigs {
+		instance := sb.newInstance(config)
+		if err = instance.initShards(); err != nil {
+			sb.Log.With("instance", i+1).LogError("Error creating discord session for instance: ", err)
+			return nil
+		}
+		sb.Instances = append(sb.Instances, instance)
+	}
 	return sb
 }
 
@@ -1259,39 +1235,37 @@ func (sb *SweetieBot) Connect() int {
 		go func() {
 			var input string
 			fmt.Scanln(&input)
-			atomic.StoreUint32(&sb.quit, QuitNow)
+			atomic.StoreUint32(sb.quit, QuitNow)
 		}()
 	}
 
-	go sb.deferProcessing()
-	go sb.idleCheckLoop()
-	go sb.deadlockDetector()
-	go sb.memberIngestionLoop()
 	go sb.ServeWeb()
 
-	err := sb.DG.Open()
+	err := sb.Start()
 	if err == nil {
-		fmt.Println("Connection established")
-		for atomic.LoadUint32(&sb.quit) == QuitNone {
+		sb.Log.Info("Connection established")
+		for atomic.LoadUint32(sb.quit) == QuitNone {
 			time.Sleep(800 * time.Millisecond)
 		}
 		begin := time.Now().UTC().Unix()
-		for cur := time.Now().UTC().Unix(); (cur-begin) < MaxUpdateGrace && atomic.LoadUint32(&sb.quit) == QuitRaid; cur = time.Now().UTC().Unix() {
+		for cur := time.Now().UTC().Unix(); (cur-begin) < MaxUpdateGrace && atomic.LoadUint32(sb.quit) == QuitRaid; cur = time.Now().UTC().Unix() {
 			quit := true
-			for _, g := range sb.Guilds {
-				if cur-g.LastRaid < UpdateGrace {
-					quit = false
-					break
+			for _, instance := range sb.AllInstances() {
+				for _, g := range instance.Guilds {
+					if cur-g.LastRaid() < UpdateGrace {
+						quit = false
+						break
+					}
 				}
 			}
 			if quit {
-				atomic.StoreUint32(&sb.quit, QuitNow)
+				atomic.StoreUint32(sb.quit, QuitNow)
 			} else {
 				time.Sleep(2400 * time.Millisecond)
 			}
 		}
 	} else {
-		fmt.Println("Error opening websocket connection: ", err.Error())
+		sb.Log.LogError("Error opening websocket connection: ", err)
 	}
 
 	/*if q, err := sb.DB.db.Query("SELECT DISTINCT Guild FROM members"); err == nil {
@@ -1307,11 +1281,60 @@ func (sb *SweetieBot) Connect() int {
 		}
 	}*/
 
-	fmt.Println("Sweetiebot quitting")
-	sb.DG.Close()
-	sb.DB.Close()
-	sb.GuildsLock.Lock() // Prevents a race condition from sending a value to a closed channel
-	close(sb.memberChan)
-	sb.GuildsLock.Unlock()
+	sb.shutdown()
 	return BotVersion.Integer()
 }
+
+// Start launches the background processing loops and opens the websocket connection of every instance without
+// blocking. Unlike Connect, it doesn't serve the website, which lets tests run a bot against a fake discord server.
+func (sb *SweetieBot) Start() error {
+	for i, instance := range append([]*SweetieBot{sb}, sb.Instances...) {
+		for j, shard := range instance.Shards {
+			if j > 0 {
+				time.Sleep(shardIdentifyDelay)
+			}
+			if err := shard.startInstance(); err != nil {
+				if shard.ShardCount > 1 {
+					err = fmt.Errorf("shard %v: %s", shard.ShardID, err.Error())
+				}
+				if i > 0 {
+					err = fmt.Errorf("instance %v: %s", i, err.Error())
+				}
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Stop immediately shuts down a bot that was launched with Start
+func (sb *SweetieBot) Stop() {
+	atomic.StoreUint32(sb.quit, QuitNow)
+	sb.shutdown()
+}
+
+// shutdown disconnects every instance so no new events come in, then gives the guild queues and any messages held
+// back by rate limits until shutdownTimeout to finish. Module state is saved as each guild finishes, so it can be
+// restored on the next start.
+func (sb *SweetieBot) shutdown() {
+	sb.Log.Info("Sweetiebot quitting")
+	instances := sb.AllInstances()
+	for _, instance := range instances {
+		instance.DG.Close()
+	}
+	deadline := time.Now().Add(shutdownTimeout)
+	var wg sync.WaitGroup
+	for _, instance := range instances {
+		wg.Add(1)
+		go func(instance *SweetieBot) {
+			defer wg.Done()
+			instance.drain(deadline)
+			instance.waitForMessages(deadline)
+		}(instance)
+	}
+	wg.Wait()
+	for _, instance := range instances {
+		instance.stopWorkers()
+	}
+	sb.DB.Close()
+}
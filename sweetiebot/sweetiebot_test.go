@@ -1,9 +1,12 @@
 package sweetiebot
 
 import (
+	"database/sql"
 	"database/sql/driver"
 	"fmt"
+	"io/ioutil"
 	"path/filepath"
+	"reflect"
 	"runtime"
 	"strconv"
 	"sync"
@@ -266,6 +269,33 @@ func mockDiscordGo() *DiscordGoSession {
 	return s
 }
 
+// preparedStatements is how many statements LoadStatements prepares. It's counted by loading them once and looking at
+// which statement fields were filled in, so mockBotDB never expects too few when new statements are added.
+var preparedStatements = func() int {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		panic(err)
+	}
+	defer db.Close()
+	for i := 0; i < 1000; i++ {
+		mock.ExpectPrepare(".*")
+	}
+	botdb := &BotDB{db: db, log: NewLogger(ioutil.Discard, LogInfo, false), driver: "mysql"}
+	if err = botdb.LoadStatements(); err != nil {
+		panic(err)
+	}
+	n := 0
+	for _, v := range []reflect.Value{reflect.ValueOf(botdb).Elem(), reflect.ValueOf(botdb.store).Elem()} {
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Field(i)
+			if (f.Type() == reflect.TypeOf((*statement)(nil)) || f.Type() == reflect.TypeOf((*sql.Stmt)(nil))) && !f.IsNil() {
+				n++
+			}
+		}
+	}
+	return n
+}()
+
 func mockBotDB() (*BotDB, sqlmock.Sqlmock) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -275,11 +305,11 @@ func mockBotDB() (*BotDB, sqlmock.Sqlmock) {
 	botdb := &BotDB{
 		db:          db,
 		lastattempt: time.Now().UTC(),
-		log:         &emptyLog{},
+		log:         NewLogger(ioutil.Discard, LogInfo, false),
 		driver:      "mysql",
 		conn:        "",
 	}
-	for i := 0; i < 76; i++ {
+	for i := 0; i < preparedStatements; i++ {
 		mock.ExpectPrepare(".*")
 	}
 	botdb.Status.Set(botdb.LoadStatements() == nil)
@@ -333,22 +363,25 @@ func mockModule(name string) Module {
 func MockSweetieBot(t *testing.T) (*SweetieBot, sqlmock.Sqlmock, *Mock) {
 	db, dbmock := mockBotDB()
 	sb := &SweetieBot{
-		Owner:          NewDiscordUser(TestOwnerBot),
-		DG:             mockDiscordGo(),
+		BotInstance: BotInstance{
+			Owner:         NewDiscordUser(TestOwnerBot),
+			DG:            mockDiscordGo(),
+			SelfName:      "Sweetie Bot",
+			SelfID:        NewDiscordUser(TestSelfID),
+			AppName:       "Sweetie Bot",
+			MainGuildID:   NewDiscordGuild(TestServer | 0),
+			DebugChannels: make(map[DiscordGuild]DiscordChannel),
+		},
 		DB:             db,
-		SelfName:       "Sweetie Bot",
-		SelfID:         NewDiscordUser(TestSelfID),
-		AppName:        "Sweetie Bot",
-		MainGuildID:    NewDiscordGuild(TestServer | 0),
-		DebugChannels:  make(map[DiscordGuild]DiscordChannel),
+		quit:           new(uint32),
 		Guilds:         make(map[DiscordGuild]*GuildInfo),
 		MaxConfigSize:  1000000,
 		MaxUniqueItems: 25000,
 		StartTime:      time.Now().UTC().Unix(),
 		heartbeat:      4294967290,
-		memberChan:     make(chan *GuildInfo, 1500),
 		Selfhoster:     &Selfhost{SelfhostBase{BotVersion.Integer()}, AtomicBool{0}, sync.Map{}},
 	}
+	sb.Shards = []*SweetieBot{sb}
 	sb.EmptyGuild = NewGuildInfo(sb, &discordgo.Guild{})
 	sb.EmptyGuild.Config.FillConfig()
 	sb.EmptyGuild.Config.SetupDone = true
@@ -360,7 +393,7 @@ func MockSweetieBot(t *testing.T) (*SweetieBot, sqlmock.Sqlmock, *Mock) {
 			ID:           guild.ID,
 			Name:         guild.Name,
 			OwnerID:      DiscordUser(guild.OwnerID),
-			commandLast:  make(map[string]int64),
+			cooldowns:    make(map[cooldownKey]int64),
 			commandlimit: &SaturationLimit{[]int64{}, 0, AtomicFlag{0}},
 			commands:     make(map[CommandID]Command),
 			commandmap:   make(map[CommandID]ModuleID),
@@ -492,10 +525,10 @@ func TestProcessCommand(t *testing.T) {
 		v.Config.Basic.CommandPrefix = "asdf"
 		dbmock.ExpectExec("INSERT INTO debuglog .*").WillReturnResult(sqlmock.NewResult(1, 1))
 		mock.Expect(sb.DG.ChannelMessageSendEmbed, strconv.Itoa(TestChannel|i), MockAny{})
-		sb.ProcessCommand(MockMessage("!about", TestChannel, 1000000, TestUserBoring, i), v, 1000000, false, false)
+		sb.ProcessCommand(MockMessage("asdfabout", TestChannel, 1000000, TestUserBoring, i), v, 1000000, false, false)
 		dbmock.ExpectExec("INSERT INTO debuglog .*").WillReturnResult(sqlmock.NewResult(1, 1))
 		mock.Expect(sb.DG.RequestWithLockedBucket, "POST", MockAny{}, "application/json", MockAny{}, MockAny{}, 0)
-		sb.ProcessCommand(MockMessage("!about", TestChannel, 1000000, TestUserBoring, i), v, 1000000, false, false)
+		sb.ProcessCommand(MockMessage("asdfabout", TestChannel, 1000000, TestUserBoring, i), v, 1000000, false, false)
 		Check(mock.Check(), true, t) // Check that the command saturation works
 		v.Config.Basic.CommandPrefix = "~"
 		dbmock.ExpectExec("INSERT INTO debuglog .*").WillReturnResult(sqlmock.NewResult(1, 1))
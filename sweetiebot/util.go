@@ -481,7 +481,7 @@ func splitSpeaker(speaker string) []string {
 func (sb *SweetieBot) buildMarkov(seasonStart int, seasonEnd int) {
 	regex := regexp.MustCompile("[^~!@#$%^&*()_+`=[\\];,./<>?\" \n\r\f\t\v]+[?!.]?")
 
-	sb.DB.sqlResetMarkov.Exec()
+	sb.DB.ResetMarkov()
 
 	var cur uint64
 	var prev uint64
@@ -122,13 +122,13 @@ func (sb *SweetieBot) generateCache(webdir string) *template.Template {
 			Name:        v,
 			Description: "",
 			URL:         strings.ToLower(v),
-			Config:      ConfigHelp[strings.ToLower(v)],
+			Config:      configHelp(v),
 		})
 	}
 
 	modules := sb.loader(sb.EmptyGuild)
 	for _, m := range modules {
-		config, _ := ConfigHelp[strings.ToLower(m.Name())]
+		config := configHelp(m.Name())
 		module := webModule{
 			Name:        m.Name(),
 			Description: m.Description(),
@@ -150,7 +150,7 @@ func (sb *SweetieBot) generateCache(webdir string) *template.Template {
 	if home, err := os.Create("home.tcache"); err == nil {
 		defer home.Close()
 		if err = t.ExecuteTemplate(home, "home", data); err != nil {
-			fmt.Println(err)
+			sb.Log.LogError("Failed to cache the home page: ", err)
 		}
 	}
 
@@ -160,22 +160,35 @@ func (sb *SweetieBot) generateCache(webdir string) *template.Template {
 			data.Title = m.Name
 			data.Index = k
 			if err = t.ExecuteTemplate(cache, "module", data); err != nil {
-				fmt.Println(err)
+				sb.Log.With("module", m.Name).LogError("Failed to cache module page: ", err)
 			}
 		}
 	}
 	return t
 }
 
-// ServeWeb starts a webserver on :80 and optionally on :443. If you're doing a reverse-proxy via nginx, SSL terminates at nginx, so use insecure mode.
-func (sb *SweetieBot) ServeWeb() error {
-	sb.generateCache(sb.Selfhoster.GetWebDir())
-
+// WebHandler serves the help pages, the config dashboard, the metrics, the health checks and anything the selfhoster
+// adds
+func (sb *SweetieBot) WebHandler() http.Handler {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", sb.Selfhoster.helpHandler)
 	mux.HandleFunc("/help", sb.Selfhoster.helpHandler)
 	mux.HandleFunc("/help/", sb.Selfhoster.helpHandler)
+	d := &dashboard{bot: sb}
+	mux.Handle("/dashboard", d)
+	mux.Handle("/dashboard/", d)
+	mux.HandleFunc("/metrics", sb.serveMetrics)
+	mux.HandleFunc("/healthz", sb.serveHealth)
+	mux.HandleFunc("/readyz", sb.serveReady)
 	sb.Selfhoster.ConfigureMux(mux)
+	return mux
+}
+
+// ServeWeb starts a webserver on :80 and optionally on :443. If you're doing a reverse-proxy via nginx, SSL terminates at nginx, so use insecure mode.
+func (sb *SweetieBot) ServeWeb() error {
+	sb.generateCache(sb.Selfhoster.GetWebDir())
+
+	mux := sb.WebHandler()
 	if sb.WebSecure {
 		go http.ListenAndServe(":80", http.HandlerFunc(fwdhttps))
 		m := autocert.Manager{
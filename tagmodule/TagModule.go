@@ -619,14 +619,14 @@ func (c *searchTagsCommand) Process(args []string, msg *discordgo.Message, indic
 		return fmt.Sprintf("```\nNo items match %s that contain that string!```", arg), false, nil
 	}
 
-	count := len(r)
-	if len(r) > maxTagResults {
-		r = r[:maxTagResults]
+	for i := range r {
+		r[i] = info.Sanitize(r[i], bot.CleanAll)
 	}
-	s := strings.Join(r, "\n")
-	s = strings.Replace(s, "```", "\\`\\`\\`", -1)
-	s = strings.Replace(s, "[](/", "[\u200B](/", -1)
-	return fmt.Sprintf("```\nAll %v items satisfying %s that contain that string:\n%s```", count, arg, s), len(r) > bot.MaxPublicLines, nil
+	embed := &discordgo.MessageEmbed{
+		Title: fmt.Sprintf("All %v items satisfying %s that contain that string:", len(r), arg),
+		Color: 0x3e92e5,
+	}
+	return "", false, info.Paginate(msg, bot.PageLines(embed, r, 15))
 }
 func (c *searchTagsCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
 	return &bot.CommandUsage{
@@ -659,10 +659,9 @@ func (c *importCommand) Process(args []string, msg *discordgo.Message, indices [
 	other := []*bot.GuildInfo{}
 	str := args[0]
 	exact := false
-	func() {
-		info.Bot.GuildsLock.RLock()
-		defer info.Bot.GuildsLock.RUnlock()
-		for _, v := range info.Bot.Guilds {
+	for _, shard := range info.Bot.Shards {
+		shard.GuildsLock.RLock()
+		for _, v := range shard.Guilds {
 			if strings.Compare(strings.ToLower(v.Name), strings.ToLower(str)) == 0 {
 				if !exact {
 					other = []*bot.GuildInfo{}
@@ -675,7 +674,8 @@ func (c *importCommand) Process(args []string, msg *discordgo.Message, indices [
 				}
 			}
 		}
-	}()
+		shard.GuildsLock.RUnlock()
+	}
 
 	if len(other) > 1 {
 		names := make([]string, len(other), len(other))
@@ -688,7 +688,7 @@ func (c *importCommand) Process(args []string, msg *discordgo.Message, indices [
 		return fmt.Sprintf("```Could not find any server matching %s!```", args[0]), false, nil
 	}
 	if !other[0].Config.Basic.Importable {
-		return "```\nThat server has not made their tags importable by other servers. If this is a public server, you can ask a moderator on that server to run \"" + info.Config.Basic.CommandPrefix + "setconfig importable true\" if they wish to make their tags public.```", false, nil
+		return "```\nThat server has not made their tags importable by other servers. If this is a public server, you can ask a moderator on that server to run \"" + info.Prefix(bot.DiscordChannel(msg.ChannelID)) + "setconfig importable true\" if they wish to make their tags public.```", false, nil
 	}
 
 	if len(args) < 2 {
@@ -724,7 +724,7 @@ func (c *importCommand) Process(args []string, msg *discordgo.Message, indices [
 }
 func (c *importCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
 	return &bot.CommandUsage{
-		Desc: "Adds all elements from the source tag on the source server to the target tag on this server. If no target is specified, attempts to copy all items into a tag of the same name as the source. Example: ```" + info.Config.Basic.CommandPrefix + "import Manechat cool notcool```",
+		Desc: "Adds all elements from the source tag on the source server to the target tag on this server. If no target is specified, attempts to copy all items into a tag of the same name as the source. Example: ```" + info.Prefix(bot.ChannelEmpty) + "import Manechat cool notcool```",
 		Params: []bot.CommandUsageParam{
 			{Name: "source server", Desc: "The exact name of the source server to copy from.", Optional: false},
 			{Name: "source tag", Desc: "Name of the tag to copy from on the source server.", Optional: false},
@@ -3,7 +3,6 @@ package usersmodule
 import (
 	"errors"
 	"fmt"
-	"strconv"
 	"strings"
 	"time"
 
@@ -40,6 +39,11 @@ func (w *UsersModule) Commands() []bot.Command {
 		&silenceCommand{},
 		&unsilenceCommand{},
 		&assignRoleCommand{},
+		&caseCommand{},
+		&casesCommand{},
+		&noteCommand{},
+		&reasonCommand{},
+		&warnCommand{},
 	}
 }
 
@@ -52,7 +56,7 @@ func (w *UsersModule) Description() string {
 func (w *UsersModule) OnGuildMemberAdd(info *bot.GuildInfo, m *discordgo.Member, t time.Time) {
 	if info.Config.Users.NotifyChannel != bot.ChannelEmpty {
 		created := "(Created " + bot.TimeDiff(t.Sub(bot.SnowflakeTime(bot.SBatoi(m.User.ID)))) + " ago) joined"
-		if info.Config.Spam.RaidSilence >= 2 || (info.Config.Spam.RaidSilence >= 1 && ((info.LastRaid + info.Config.Spam.RaidTime*2) > t.Unix())) {
+		if info.Config.Spam.RaidSilence >= 2 || (info.Config.Spam.RaidSilence >= 1 && ((info.LastRaid() + info.Config.Spam.RaidTime*2) > t.Unix())) {
 			created += " and was silenced"
 		}
 		info.SendMessage(info.Config.Users.NotifyChannel, "<@"+m.User.ID+"> "+created+".")
@@ -93,13 +97,13 @@ func (c *newUsersCommand) Info() *bot.CommandInfo {
 }
 
 func (c *newUsersCommand) Process(args []string, msg *discordgo.Message, indices []int, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+	return info.ProcessTyped(c, args, msg, indices)
+}
+func (c *newUsersCommand) ProcessArgs(args bot.CommandArgs, msg *discordgo.Message, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
 	if !info.Bot.DB.CheckStatus() {
 		return "```\nA temporary database outage is preventing this command from being executed.```", false, nil
 	}
-	maxresults := 5
-	if len(args) > 0 {
-		maxresults, _ = strconv.Atoi(args[0])
-	}
+	maxresults := int(args.Int("maxresults", 5))
 	if maxresults < 1 {
 		return "```\nHow I return no results???```", false, nil
 	}
@@ -118,7 +122,7 @@ func (c *newUsersCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
 	return &bot.CommandUsage{
 		Desc: "Lists up to maxresults users, starting with the newest user to join the server.",
 		Params: []bot.CommandUsageParam{
-			{Name: "maxresults", Desc: "Defaults to 5 results, returns a maximum of 40.", Optional: true},
+			{Name: "maxresults", Desc: "Defaults to 5 results, returns a maximum of 40.", Optional: true, Type: bot.ParamInt},
 		},
 	}
 }
@@ -137,17 +141,13 @@ func (c *akaCommand) Name() string {
 	return "aka"
 }
 func (c *akaCommand) Process(args []string, msg *discordgo.Message, indices []int, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+	return info.ProcessTyped(c, args, msg, indices)
+}
+func (c *akaCommand) ProcessArgs(args bot.CommandArgs, msg *discordgo.Message, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
 	if !info.Bot.DB.CheckStatus() {
 		return "```\nA temporary database outage is preventing this command from being executed.```", false, nil
 	}
-	if len(args) < 1 {
-		return "```\nYou must provide a user to search for.```", false, nil
-	}
-	user, err := bot.ParseUser(msg.Content[indices[0]:], info)
-	if err != nil {
-		return bot.ReturnError(err)
-	}
-
+	user := args.User("user")
 	r := info.Bot.DB.GetAliases(user.Convert())
 	u, err := info.Bot.DG.GetMember(user, info.ID)
 	if err != nil {
@@ -166,63 +166,35 @@ func (c *akaCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
 	return &bot.CommandUsage{
 		Desc: "Lists all known aliases of the user in question, up to a maximum of 10, with the names used the longest first.",
 		Params: []bot.CommandUsageParam{
-			{Name: "user", Desc: "A ping of the user, or simply their name.", Optional: true},
+			{Name: "user", Desc: "A ping of the user, or simply their name.", Variadic: true, Type: bot.ParamUser},
 		},
 	}
 }
 
-func processDurationAndReason(args []string, msg *discordgo.Message, indices []int, ty uint8, data string, gID uint64, db *bot.BotDB) (string, error) {
-	if ty == 6 {
-		return "", errors.New("Illegal event type.")
-	}
-	reason := ""
-	if len(args) > 0 {
-		if strings.ToLower(args[0]) == "for:" {
-			if len(args) < 3 {
-				return "", errors.New("Duration should be specified as 'for: 5 DAYS' or 'for: 72 HOURS'")
-			}
-			duration, err := strconv.Atoi(args[1])
-			if err != nil {
-				return "", errors.New("Duration number was not an integer.")
-			}
-
-			t := bot.GetTimestamp(msg)
-			switch bot.ParseRepeatInterval(args[2]) {
-			case 1:
-				t = t.Add(time.Duration(duration) * time.Second)
-			case 2:
-				t = t.Add(time.Duration(duration) * time.Minute)
-			case 3:
-				t = t.Add(time.Duration(duration) * time.Hour)
-			case 4:
-				t = t.AddDate(0, 0, duration)
-			case 5:
-				t = t.AddDate(0, 0, duration*7)
-			case 6:
-				t = t.AddDate(0, duration, 0)
-			case 8:
-				t = t.AddDate(duration, 0, 0)
-			case 7, 255:
-				return "", errors.New("unrecognized interval.")
-			}
+// durationParam is the name of the optional duration parameter used by every command that can undo itself later
+const durationParam = "for: duration"
 
-			if err := db.AddSchedule(gID, t, ty, data); err != nil {
-				return "", err
-			}
-
-			scheduleID := db.FindEvent(data, gID, ty)
-			if scheduleID == nil {
-				return "", errors.New("Could not find inserted event!")
-			}
+// scheduleUndo adds an event to the schedule that fires once the duration given to the command has passed, if there was one
+func scheduleUndo(args bot.CommandArgs, msg *discordgo.Message, info *bot.GuildInfo, ty uint8, data string) error {
+	if !args.Has(durationParam) {
+		return nil
+	}
+	gID := bot.SBatoi(info.ID)
+	if err := info.Bot.DB.AddSchedule(gID, args.Interval(durationParam).Add(bot.GetTimestamp(msg)), ty, data); err != nil {
+		return err
+	}
+	if info.Bot.DB.FindEvent(data, gID, ty) == nil {
+		return errors.New("Could not find inserted event!")
+	}
+	return nil
+}
 
-			if len(args) > 3 {
-				reason = msg.Content[indices[3]:]
-			}
-		} else {
-			reason = msg.Content[indices[0]:]
-		}
+// durationString describes the duration given to the command, or returns nothing if it's permanent
+func durationString(args bot.CommandArgs) string {
+	if !args.Has(durationParam) {
+		return ""
 	}
-	return reason, nil
+	return args.Interval(durationParam).String()
 }
 
 // Ban command that tracks who banned someone, why, and optionally make the ban temporary
@@ -242,36 +214,33 @@ func (c *banCommand) Name() string {
 }
 
 func (c *banCommand) Process(args []string, msg *discordgo.Message, indices []int, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+	return info.ProcessTyped(c, args, msg, indices)
+}
+func (c *banCommand) ProcessArgs(args bot.CommandArgs, msg *discordgo.Message, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
 	if !info.Bot.DB.CheckStatus() {
 		return "```\nA temporary database outage is preventing this command from being executed.```", false, nil
 	}
-	if len(args) < 1 {
-		return "```\nYou must specify a username (in quotes, if it has spaces).```", false, nil
-	}
-	name, err := bot.ParseUser(args[0], info)
-	if err != nil {
-		return bot.ReturnError(err)
-	}
-	reason, err := processDurationAndReason(args[1:], msg, indices[1:], 0, name.String(), bot.SBatoi(info.ID), info.Bot.DB)
-	if err != nil {
+	name := args.User("user")
+	if err := scheduleUndo(args, msg, info, 0, name.String()); err != nil {
 		return bot.ReturnError(err)
 	}
-	reason = fmt.Sprintf("Banned by %s#%s for %s", msg.Author.Username, msg.Author.Discriminator, reason)
+	reason := fmt.Sprintf("Banned by %s#%s for %s", msg.Author.Username, msg.Author.Discriminator, args.String("reason"))
 	username := info.GetUserName(name)
 
-	err = info.Bot.DG.GuildBanCreateWithReason(info.ID, name.String(), reason, 1) // Note that this will probably generate a SawBan event
+	err := info.Bot.DG.GuildBanCreateWithReason(info.ID, name.String(), reason, 1) // Note that this will probably generate a SawBan event
 	if err != nil {
 		return bot.ReturnError(err)
 	}
-	return "```\nBanned " + username + " from the server.```", false, nil
+	id := info.AddCase(bot.CaseBan, name.String(), bot.DiscordUser(msg.Author.ID), args.String("reason"), durationString(args), msg)
+	return "```\nBanned " + username + " from the server" + bot.CaseSuffix(id) + ".```", false, nil
 }
 func (c *banCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
 	return &bot.CommandUsage{
-		Desc: "Bans the given user. Examples: `'" + info.Config.Basic.CommandPrefix + "ban @CrystalFlash for: 5 MINUTES because he's a dunce` or `" + info.Config.Basic.CommandPrefix + "ban \"Name With Spaces\" caught stealing cookies`",
+		Desc: "Bans the given user. Examples: `'" + info.Prefix(bot.ChannelEmpty) + "ban @CrystalFlash for: 5 MINUTES because he's a dunce` or `" + info.Prefix(bot.ChannelEmpty) + "ban \"Name With Spaces\" caught stealing cookies`",
 		Params: []bot.CommandUsageParam{
-			{Name: "user", Desc: "A ping of the user, or simply their name. If the name has spaces, this argument must be put in quotes.", Optional: false},
-			{Name: "for: duration", Desc: "If the keyword `for:` is used after the username, looks for a duration of the form `for: 50 MINUTES` and creates an unban event that will be fired after that much time has passed from now.", Optional: true},
-			{Name: "reason", Desc: "The rest of the message is treated as a reason for the ban.", Optional: true},
+			{Name: "user", Desc: "A ping of the user, or simply their name. If the name has spaces, this argument must be put in quotes.", Optional: false, Type: bot.ParamUser},
+			{Name: durationParam, Desc: "If the keyword `for:` is used after the username, looks for a duration of the form `for: 50 MINUTES` (or just `50 MINUTES` at the end of the message) and creates an unban event that will be fired after that much time has passed from now.", Optional: true, Type: bot.ParamDuration},
+			{Name: "reason", Desc: "The rest of the message is treated as a reason for the ban.", Optional: true, Type: bot.ParamRest},
 		},
 	}
 }
@@ -289,17 +258,13 @@ func (c *banNewcomersCommand) Info() *bot.CommandInfo {
 }
 
 func (c *banNewcomersCommand) Process(args []string, msg *discordgo.Message, indices []int, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+	return info.ProcessTyped(c, args, msg, indices)
+}
+func (c *banNewcomersCommand) ProcessArgs(args bot.CommandArgs, msg *discordgo.Message, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
 	if !info.Bot.DB.CheckStatus() {
 		return "```\nA temporary database outage is preventing this command from being executed.```", false, nil
 	}
-	duration := 120
-	if len(args) > 0 {
-		var err error
-		duration, err = strconv.Atoi(args[0])
-		if err != nil {
-			return "```\nThat's not a valid number of seconds!```", false, nil
-		}
-	}
+	duration := int(args.Int("duration", 120))
 
 	IDs := info.Bot.DB.GetNewcomers(duration, bot.SBatoi(info.ID))
 	if len(IDs) == 0 {
@@ -309,6 +274,9 @@ func (c *banNewcomersCommand) Process(args []string, msg *discordgo.Message, ind
 	for _, id := range IDs {
 		err := info.Bot.DG.GuildBanCreateWithReason(info.ID, bot.SBitoa(id), reason, 1)
 		info.LogError("Error banning user: ", err)
+		if err == nil {
+			info.AddCase(bot.CaseBan, bot.SBitoa(id), bot.DiscordUser(msg.Author.ID), fmt.Sprintf("Sent their first message in the past %v seconds", duration), "", msg)
+		}
 	}
 
 	return fmt.Sprintf("```Banned %v people from the server. Use discord's audit log if you need to reverse a ban.```", len(IDs)), false, nil
@@ -317,7 +285,7 @@ func (c *banNewcomersCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
 	return &bot.CommandUsage{
 		Desc: "Bans all users who have sent their first message in the past `duration` seconds.",
 		Params: []bot.CommandUsageParam{
-			{Name: "duration", Desc: "The number of seconds to look back, defaults to 120 seconds (so anyone who sent their first message in the past 2 minutes would be banned).", Optional: true},
+			{Name: "duration", Desc: "The number of seconds to look back, defaults to 120 seconds (so anyone who sent their first message in the past 2 minutes would be banned).", Optional: true, Type: bot.ParamInt, Min: 1, Max: 86400},
 		},
 	}
 }
@@ -333,18 +301,17 @@ func (c *timeCommand) Info() *bot.CommandInfo {
 }
 
 func (c *timeCommand) Process(args []string, msg *discordgo.Message, indices []int, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+	return info.ProcessTyped(c, args, msg, indices)
+}
+func (c *timeCommand) ProcessArgs(args bot.CommandArgs, msg *discordgo.Message, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
 	if !info.Bot.DB.CheckStatus() {
 		return "```\nA temporary database outage is preventing this command from being executed.```", false, nil
 	}
-	if len(args) < 1 {
+	if !args.Has("user") {
 		return "```\nThis server's local time is: " + info.ApplyTimezone(bot.GetTimestamp(msg), bot.UserEmpty).Format("Jan 2, 3:04pm```"), false, nil
 	}
 
-	user, err := bot.ParseUser(msg.Content[indices[0]:], info)
-	if err != nil {
-		return bot.ReturnError(err)
-	}
-	tz := info.Bot.DB.GetTimeZone(user.Convert())
+	tz := info.Bot.DB.GetTimeZone(args.User("user").Convert())
 	if tz == nil {
 		return "```\nThat user has not specified what their timezone is.```", false, nil
 	}
@@ -354,7 +321,7 @@ func (c *timeCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
 	return &bot.CommandUsage{
 		Desc: "Gets the local time for the specified user, or simply gets the local time for this server.",
 		Params: []bot.CommandUsageParam{
-			{Name: "user", Desc: "A ping of the user, or simply their name.", Optional: true},
+			{Name: "user", Desc: "A ping of the user, or simply their name.", Optional: true, Variadic: true, Type: bot.ParamUser},
 		},
 	}
 }
@@ -369,28 +336,25 @@ func (c *setTimeZoneCommand) Info() *bot.CommandInfo {
 	}
 }
 func (c *setTimeZoneCommand) Process(args []string, msg *discordgo.Message, indices []int, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+	return info.ProcessTyped(c, args, msg, indices)
+}
+func (c *setTimeZoneCommand) ProcessArgs(args bot.CommandArgs, msg *discordgo.Message, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
 	if !info.Bot.DB.CheckStatus() {
 		return "```\nA temporary database outage is preventing this command from being executed.```", false, nil
 	}
-	if len(args) < 1 {
-		return "```\nYou have to specify what your timezone is!```", false, nil
-	}
+	search := args.String("timezone")
 	zone := []string{}
-	if len(args) < 2 {
-		zone = info.Bot.DB.FindTimeZone("%" + args[0] + "%")
+	if !args.Has("offset") {
+		zone = info.Bot.DB.FindTimeZone("%" + search + "%")
 	} else {
-		offset, err := strconv.Atoi(args[1])
-		if err != nil {
-			return "```\nCould not parse offset. Note that timezones do not have spaces - use underscores (_) instead. The second argument should be your time difference from GMT in hours. For example, PDT is GMT-7, so you could search for \"America -7\".```", false, nil
-		}
-		zone = info.Bot.DB.FindTimeZoneOffset("%"+args[0]+"%", offset*60)
+		zone = info.Bot.DB.FindTimeZoneOffset("%"+search+"%", int(args.Int("offset", 0))*60)
 	}
-	if strings.Contains(strings.ToLower(args[0]), "gmt") || (len(zone) == 1 && strings.Contains(strings.ToLower(zone[0]), "gmt")) {
+	if strings.Contains(strings.ToLower(search), "gmt") || (len(zone) == 1 && strings.Contains(strings.ToLower(zone[0]), "gmt")) {
 		return "```\nStop. Just stop. That's not going to work for daylight savings. You have to provide a timezone LOCATION, like 'America/Los_Angeles'. If you aren't sure what timezone location to use, check what your operating system is set to.```", false, nil
 	}
 
 	if len(zone) < 1 {
-		if len(args) < 2 {
+		if !args.Has("offset") {
 			return "```\nCould not find any timezone locations that match that string. Try broadening your search (for example, search for 'America' or 'Pacific').```", false, nil
 		}
 		return "```\nCould not find any timezone locations that match that string and offset combination. Try broadening your search, or leaving out the timezone offset parameter.```", false, nil
@@ -413,8 +377,8 @@ func (c *setTimeZoneCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
 	return &bot.CommandUsage{
 		Desc: "Sets your timezone to the given location. Providing a partial timezone name, like \"America\", will return a list of all possible timezones that contain that string.",
 		Params: []bot.CommandUsageParam{
-			{Name: "timezone", Desc: "A timezone location, such as `America/Los_Angeles`. Note that timezones do not have spaces.", Optional: true},
-			{Name: "offset", Desc: "Your expected timezone offset in hours, used to narrow the search. For example, if you know you're in the PDT timezone, which is GMT-7, you could search for `America -7` to list all timezones in america with a standard or DST timezone offset of -7.", Optional: true},
+			{Name: "timezone", Desc: "A timezone location, such as `America/Los_Angeles`. Note that timezones do not have spaces.", Optional: false},
+			{Name: "offset", Desc: "Your expected timezone offset in hours, used to narrow the search. For example, if you know you're in the PDT timezone, which is GMT-7, you could search for `America -7` to list all timezones in america with a standard or DST timezone offset of -7.", Optional: true, Type: bot.ParamInt, Min: -12, Max: 14},
 		},
 	}
 }
@@ -433,17 +397,13 @@ func (c *userInfoCommand) Name() string {
 	return "UserInfo"
 }
 func (c *userInfoCommand) Process(args []string, msg *discordgo.Message, indices []int, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+	return info.ProcessTyped(c, args, msg, indices)
+}
+func (c *userInfoCommand) ProcessArgs(args bot.CommandArgs, msg *discordgo.Message, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
 	if !info.Bot.DB.CheckStatus() {
 		return "```\nA temporary database outage is preventing this command from being executed.```", false, nil
 	}
-	if len(args) < 1 {
-		return "```\nYou must provide a user to search for.```", false, nil
-	}
-
-	user, err := bot.ParseUser(msg.Content[indices[0]:], info)
-	if err != nil {
-		return bot.ReturnError(err)
-	}
+	user := args.User("user")
 	id := user.Convert()
 	aliases := info.Bot.DB.GetAliases(id)
 	dbuser, lastseen, tz, _ := info.Bot.DB.GetUser(id)
@@ -518,7 +478,7 @@ func (c *userInfoCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
 	return &bot.CommandUsage{
 		Desc: "Lists the ID, username, nickname, timezone, roles, avatar, join date, and other information about a given user.",
 		Params: []bot.CommandUsageParam{
-			{Name: "user", Desc: "A ping of the user, or simply their name.", Optional: false},
+			{Name: "user", Desc: "A ping of the user, or simply their name.", Optional: false, Variadic: true, Type: bot.ParamUser},
 		},
 	}
 }
@@ -599,27 +559,15 @@ func (c *silenceCommand) Info() *bot.CommandInfo {
 }
 
 func (c *silenceCommand) Process(args []string, msg *discordgo.Message, indices []int, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
-	if len(args) < 1 {
-		return "```\nYou must provide a user to silence.```", false, nil
-	}
-	index := len(args)
-	for i := 1; i < len(args); i++ {
-		if strings.ToLower(args[i]) == "for:" {
-			index = i
-			break
-		}
-	}
-
-	user, err := bot.ParseUser(strings.Join(args[0:index], " "), info)
-	if err != nil {
-		return bot.ReturnError(err)
-	}
-
+	return info.ProcessTyped(c, args, msg, indices)
+}
+func (c *silenceCommand) ProcessArgs(args bot.CommandArgs, msg *discordgo.Message, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+	user := args.User("user")
 	gID := bot.SBatoi(info.ID)
-	reason, err := processDurationAndReason(args[index:], msg, indices[index:], 8, user.String(), gID, info.Bot.DB)
-	if err != nil {
+	if err := scheduleUndo(args, msg, info, 8, user.String()); err != nil {
 		return bot.ReturnError(err)
 	}
+	reason := args.String("reason")
 
 	code, err := assignRoleMember(info, user, info.Config.Basic.SilenceRole)
 	if code < 0 || err != nil {
@@ -637,17 +585,20 @@ func (c *silenceCommand) Process(args []string, msg *discordgo.Message, indices
 	if len(info.Config.Users.SilenceMessage) > 0 {
 		info.SendMessage(info.Config.Users.WelcomeChannel, user.Display()+info.Config.Users.SilenceMessage)
 	}
+	id := info.AddCase(bot.CaseSilence, user.String(), bot.DiscordUser(msg.Author.ID), reason, durationString(args), msg)
+	info.Publish(&bot.MemberSilenced{User: user, Moderator: bot.DiscordUser(msg.Author.ID), Reason: reason, Case: id})
 	if len(reason) > 0 {
 		reason = " because " + reason
 	}
-	return fmt.Sprintf("```\nSilenced %s%s.```", info.GetUserName(user), reason), false, nil
+	return fmt.Sprintf("```\nSilenced %s%s%s.```", info.GetUserName(user), reason, bot.CaseSuffix(id)), false, nil
 }
 func (c *silenceCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
 	return &bot.CommandUsage{
-		Desc: "Silences the given user.",
+		Desc: "Silences the given user. Examples: `" + info.Prefix(bot.ChannelEmpty) + "silence @CrystalFlash 2 hours`, `" + info.Prefix(bot.ChannelEmpty) + "silence @CrystalFlash for: 2 hours spamming` or `" + info.Prefix(bot.ChannelEmpty) + "silence Name With Spaces for: 30 minutes`",
 		Params: []bot.CommandUsageParam{
-			{Name: "user", Desc: "A ping of the user, or simply their name.", Optional: false},
-			{Name: "for: duration", Desc: "If the keyword `for:` is used after the username, looks for a duration of the form `for: 50 MINUTES` and creates an unsilence event that will be fired after that much time has passed from now.", Optional: true},
+			{Name: "user", Desc: "A ping of the user, or simply their name.", Optional: false, Variadic: true, Type: bot.ParamUser},
+			{Name: durationParam, Desc: "If the keyword `for:` is used after the username, looks for a duration of the form `for: 50 MINUTES` (or just `50 MINUTES` at the end of the message) and creates an unsilence event that will be fired after that much time has passed from now. These show up in `" + info.Prefix(bot.ChannelEmpty) + "schedule silences`.", Optional: true, Type: bot.ParamDuration},
+			{Name: "reason", Desc: "The rest of the message is treated as the reason they were silenced.", Optional: true, Type: bot.ParamRest},
 		},
 	}
 }
@@ -664,25 +615,22 @@ func (c *unsilenceCommand) Info() *bot.CommandInfo {
 }
 
 func (c *unsilenceCommand) Process(args []string, msg *discordgo.Message, indices []int, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
-	if len(args) < 1 {
-		return "```\nYou must provide a user to unsilence.```", false, nil
-	}
-	user, err := bot.ParseUser(msg.Content[indices[0]:], info)
-	if err != nil {
-		return bot.ReturnError(info.ResolveRoleAddError(err))
-	}
-
-	err = info.Bot.DG.RemoveRole(info.ID, user, info.Config.Basic.SilenceRole)
+	return info.ProcessTyped(c, args, msg, indices)
+}
+func (c *unsilenceCommand) ProcessArgs(args bot.CommandArgs, msg *discordgo.Message, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+	user := args.User("user")
+	err := info.Bot.DG.RemoveRole(info.ID, user, info.Config.Basic.SilenceRole)
 	if err != nil {
 		return "```\nError unsilencing member: " + err.Error() + "```", false, nil
 	}
-	return "```\nUnsilenced " + info.GetUserName(user) + ".```", false, nil
+	id := info.AddCase(bot.CaseUnsilence, user.String(), bot.DiscordUser(msg.Author.ID), "", "", msg)
+	return "```\nUnsilenced " + info.GetUserName(user) + bot.CaseSuffix(id) + ".```", false, nil
 }
 func (c *unsilenceCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
 	return &bot.CommandUsage{
 		Desc: "Unsilences the given user.",
 		Params: []bot.CommandUsageParam{
-			{Name: "user", Desc: "A ping of the user, or simply their name.", Optional: false},
+			{Name: "user", Desc: "A ping of the user, or simply their name.", Optional: false, Variadic: true, Type: bot.ParamUser},
 		},
 	}
 }
@@ -699,33 +647,16 @@ func (c *assignRoleCommand) Info() *bot.CommandInfo {
 }
 
 func (c *assignRoleCommand) Process(args []string, msg *discordgo.Message, indices []int, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
-	if len(args) < 2 {
-		return "```\nYou must provide a role to assign and a user to assign it to.```", false, nil
-	}
-	index := len(args)
-	for i := 1; i < len(args); i++ {
-		if strings.ToLower(args[i]) == "for:" {
-			index = i
-			break
-		}
-	}
-
-	g, err := info.GetGuild()
-	role, err := bot.ParseRole(args[0], g)
-	if err != nil {
-		return bot.ReturnError(err)
-	}
-
-	user, err := bot.ParseUser(strings.Join(args[1:index], " "), info)
-	if err != nil {
-		return bot.ReturnError(err)
-	}
-
+	return info.ProcessTyped(c, args, msg, indices)
+}
+func (c *assignRoleCommand) ProcessArgs(args bot.CommandArgs, msg *discordgo.Message, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+	role := args.Role("role")
+	user := args.User("user")
 	gID := bot.SBatoi(info.ID)
-	reason, err := processDurationAndReason(args[index:], msg, indices[index:], 9, user.String()+"|"+role.String(), gID, info.Bot.DB)
-	if err != nil {
+	if err := scheduleUndo(args, msg, info, 9, user.String()+"|"+role.String()); err != nil {
 		return bot.ReturnError(err)
 	}
+	reason := args.String("reason")
 
 	code, err := assignRoleMember(info, user, role)
 	if code < 0 || err != nil {
@@ -749,9 +680,267 @@ func (c *assignRoleCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
 	return &bot.CommandUsage{
 		Desc: "Assigns the role to the given user, and optionally adds an event to remove it in the future.",
 		Params: []bot.CommandUsageParam{
-			{Name: "role", Desc: "The role to add, either as a ping or as the name, but must be in quotes if it has spaces.", Optional: false},
-			{Name: "user", Desc: "A ping of the user, or simply their name.", Optional: false},
-			{Name: "for: duration", Desc: "If the keyword `for:` is used after the username, looks for a duration of the form `for: 50 MINUTES` and creates an event that will remove the role after that much time has passed from now.", Optional: true},
+			{Name: "role", Desc: "The role to add, either as a ping or as the name, but must be in quotes if it has spaces.", Optional: false, Type: bot.ParamRole},
+			{Name: "user", Desc: "A ping of the user, or simply their name.", Optional: false, Variadic: true, Type: bot.ParamUser},
+			{Name: durationParam, Desc: "If the keyword `for:` is used after the username, looks for a duration of the form `for: 50 MINUTES` (or just `50 MINUTES` at the end of the message) and creates an event that will remove the role after that much time has passed from now.", Optional: true, Type: bot.ParamDuration},
+			{Name: "reason", Desc: "The rest of the message is treated as the reason the role was assigned.", Optional: true, Type: bot.ParamRest},
+		},
+	}
+}
+
+type caseCommand struct {
+}
+
+func (c *caseCommand) Info() *bot.CommandInfo {
+	return &bot.CommandInfo{
+		Name:      "Case",
+		Usage:     "Shows a moderation case.",
+		Sensitive: true,
+	}
+}
+func (c *caseCommand) Process(args []string, msg *discordgo.Message, indices []int, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+	return info.ProcessTyped(c, args, msg, indices)
+}
+func (c *caseCommand) ProcessArgs(args bot.CommandArgs, msg *discordgo.Message, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+	if !info.Bot.DB.CheckStatus() {
+		return "```\nA temporary database outage is preventing this command from being executed.```", false, nil
+	}
+	mc := info.Bot.DB.GetCase(bot.SBatoi(info.ID), uint64(args.Int("case", 0)))
+	if mc == nil {
+		return "```\nThat case doesn't exist!```", false, nil
+	}
+	return info.CaseDetails(mc, info.GetTimezone(bot.DiscordUser(msg.Author.ID))), false, nil
+}
+func (c *caseCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
+	return &bot.CommandUsage{
+		Desc: "Shows everything recorded about a moderation case, including who took the action, why, and links to any relevant messages.",
+		Params: []bot.CommandUsageParam{
+			{Name: "case", Desc: "The case number.", Optional: false, Type: bot.ParamInt},
 		},
 	}
 }
+
+type casesCommand struct {
+}
+
+func (c *casesCommand) Info() *bot.CommandInfo {
+	return &bot.CommandInfo{
+		Name:      "Cases",
+		Usage:     "Lists moderation cases.",
+		Sensitive: true,
+	}
+}
+func (c *casesCommand) Process(args []string, msg *discordgo.Message, indices []int, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+	return info.ProcessTyped(c, args, msg, indices)
+}
+func (c *casesCommand) ProcessArgs(args bot.CommandArgs, msg *discordgo.Message, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+	if !info.Bot.DB.CheckStatus() {
+		return "```\nA temporary database outage is preventing this command from being executed.```", false, nil
+	}
+	user := args.User("user")
+	cases := info.Bot.DB.GetCases(bot.SBatoi(info.ID), user.Convert(), 20, 0)
+	if len(cases) == 0 {
+		if args.Has("user") {
+			return "```\n" + info.GetUserName(user) + " has no moderation history.```", false, nil
+		}
+		return "```\nNo moderation cases have been recorded on this server.```", false, nil
+	}
+	tz := info.GetTimezone(bot.DiscordUser(msg.Author.ID))
+	lines := make([]string, 0, len(cases)+1)
+	if args.Has("user") {
+		lines = append(lines, "Moderation history of "+info.GetUserName(user)+":")
+	} else {
+		lines = append(lines, "Most recent moderation cases:")
+	}
+	for i := range cases {
+		lines = append(lines, info.CaseLine(&cases[i], tz))
+	}
+	return "```\n" + info.Sanitize(strings.Join(lines, "\n"), bot.CleanCodeBlock) + "```", len(lines) > bot.MaxPublicLines, nil
+}
+func (c *casesCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
+	return &bot.CommandUsage{
+		Desc: "Lists the 20 most recent moderation cases against a user, or on the whole server if no user is given. Use `" + info.Prefix(bot.ChannelEmpty) + "case` to see the details of a single case.",
+		Params: []bot.CommandUsageParam{
+			{Name: "user", Desc: "A ping of the user, or simply their name.", Optional: true, Variadic: true, Type: bot.ParamUser},
+		},
+	}
+}
+
+type noteCommand struct {
+}
+
+func (c *noteCommand) Info() *bot.CommandInfo {
+	return &bot.CommandInfo{
+		Name:      "Note",
+		Usage:     "Adds a note to a user's moderation history.",
+		Sensitive: true,
+	}
+}
+func (c *noteCommand) Process(args []string, msg *discordgo.Message, indices []int, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+	return info.ProcessTyped(c, args, msg, indices)
+}
+func (c *noteCommand) ProcessArgs(args bot.CommandArgs, msg *discordgo.Message, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+	if !info.Bot.DB.CheckStatus() {
+		return "```\nA temporary database outage is preventing this command from being executed.```", false, nil
+	}
+	user := args.User("user")
+	id := info.AddCase(bot.CaseNote, user.String(), bot.DiscordUser(msg.Author.ID), args.String("note"), "", msg)
+	if id == 0 {
+		return "```\nFailed to add the note, check the error log for details.```", false, nil
+	}
+	return "```\nAdded a note to " + info.GetUserName(user) + bot.CaseSuffix(id) + ".```", false, nil
+}
+func (c *noteCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
+	return &bot.CommandUsage{
+		Desc: "Records a note about a user as a moderation case, without taking any action against them. Notes show up in `" + info.Prefix(bot.ChannelEmpty) + "cases` like any other case.",
+		Params: []bot.CommandUsageParam{
+			{Name: "user", Desc: "A ping of the user, or simply their name. If the name has spaces, this argument must be put in quotes.", Optional: false, Type: bot.ParamUser},
+			{Name: "note", Desc: "The rest of the message is the note.", Optional: false, Type: bot.ParamRest},
+		},
+	}
+}
+
+type reasonCommand struct {
+}
+
+func (c *reasonCommand) Info() *bot.CommandInfo {
+	return &bot.CommandInfo{
+		Name:      "Reason",
+		Usage:     "Sets the reason for a moderation case.",
+		Sensitive: true,
+	}
+}
+func (c *reasonCommand) Process(args []string, msg *discordgo.Message, indices []int, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+	return info.ProcessTyped(c, args, msg, indices)
+}
+func (c *reasonCommand) ProcessArgs(args bot.CommandArgs, msg *discordgo.Message, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+	if !info.Bot.DB.CheckStatus() {
+		return "```\nA temporary database outage is preventing this command from being executed.```", false, nil
+	}
+	gID := bot.SBatoi(info.ID)
+	mc := info.Bot.DB.GetCase(gID, uint64(args.Int("case", 0)))
+	if mc == nil {
+		return "```\nThat case doesn't exist!```", false, nil
+	}
+	reason := args.String("reason")
+	if r := []rune(reason); len(r) > bot.MaxCaseReason {
+		return fmt.Sprintf("```\nReasons can't be longer than %v characters!```", bot.MaxCaseReason), false, nil
+	}
+	if err := info.Bot.DB.SetCaseReason(gID, mc.ID, reason); err != nil {
+		return bot.ReturnError(err)
+	}
+	return fmt.Sprintf("```\nUpdated the reason for case #%v.```", mc.ID), false, nil
+}
+func (c *reasonCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
+	return &bot.CommandUsage{
+		Desc: "Replaces the reason given for a moderation case. Useful for cases created automatically by the spam filter, or when someone forgot to give a reason.",
+		Params: []bot.CommandUsageParam{
+			{Name: "case", Desc: "The case number.", Optional: false, Type: bot.ParamInt},
+			{Name: "reason", Desc: "The rest of the message is the new reason.", Optional: false, Type: bot.ParamRest},
+		},
+	}
+}
+
+type warnCommand struct {
+}
+
+func (c *warnCommand) Info() *bot.CommandInfo {
+	return &bot.CommandInfo{
+		Name:      "Warn",
+		Usage:     "Warns a user, silencing or banning them if they get too many warnings.",
+		Sensitive: true,
+	}
+}
+func (c *warnCommand) Process(args []string, msg *discordgo.Message, indices []int, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+	return info.ProcessTyped(c, args, msg, indices)
+}
+func (c *warnCommand) ProcessArgs(args bot.CommandArgs, msg *discordgo.Message, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+	if !info.Bot.DB.CheckStatus() {
+		return "```\nA temporary database outage is preventing this command from being executed.```", false, nil
+	}
+	user := args.User("user")
+	gID := bot.SBatoi(info.ID)
+	mc := &bot.ModCase{
+		Action:    bot.CaseWarn,
+		Target:    user.Convert(),
+		Moderator: bot.SBatoi(msg.Author.ID),
+		Reason:    args.String("reason"),
+		Points:    int(args.Int("points", int64(info.Config.Warnings.Points))),
+		Messages:  []string{msg.ChannelID + "/" + msg.ID},
+	}
+	if r := []rune(mc.Reason); len(r) > bot.MaxCaseReason {
+		return fmt.Sprintf("```\nReasons can't be longer than %v characters!```", bot.MaxCaseReason), false, nil
+	}
+	id, err := info.Bot.DB.AddCase(gID, mc)
+	if err != nil {
+		return bot.ReturnError(err)
+	}
+
+	now := bot.GetTimestamp(msg)
+	points := bot.WarningPoints(info.Bot.DB.GetWarnings(gID, mc.Target), info.Config.Warnings.DecayTime, now)
+	s := fmt.Sprintf("Warned %s%s. They now have %s.", info.GetUserName(user), bot.CaseSuffix(id), bot.Pluralize(int64(points), " warning point"))
+	reason := "Reached " + bot.Pluralize(int64(points), " warning point")
+	if info.Config.Warnings.BanThreshold > 0 && points >= info.Config.Warnings.BanThreshold {
+		s += "\n" + warnBan(info, user, reason, now)
+	} else if info.Config.Warnings.SilenceThreshold > 0 && points >= info.Config.Warnings.SilenceThreshold {
+		s += "\n" + warnSilence(info, user, reason, now)
+	}
+	return "```\n" + s + "```", false, nil
+}
+func (c *warnCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
+	return &bot.CommandUsage{
+		Desc: fmt.Sprintf("Gives a user warning points, which decay over time. Anyone who reaches %v points is silenced, and anyone who reaches %v points is banned. These thresholds can be changed in the `warnings` config group.", info.Config.Warnings.SilenceThreshold, info.Config.Warnings.BanThreshold),
+		Params: []bot.CommandUsageParam{
+			{Name: "user", Desc: "A ping of the user, or simply their name. If the name has spaces, this argument must be put in quotes.", Optional: false, Type: bot.ParamUser},
+			{Name: "points", Desc: fmt.Sprintf("How many points the warning is worth. Defaults to %v.", info.Config.Warnings.Points), Optional: true, Type: bot.ParamInt, Min: 1, Max: 100},
+			{Name: "reason", Desc: "The rest of the message is treated as the reason for the warning.", Optional: true, Type: bot.ParamRest},
+		},
+	}
+}
+
+// warnSilence silences someone who has reached the silence threshold, and schedules their unsilence
+func warnSilence(info *bot.GuildInfo, user bot.DiscordUser, reason string, now time.Time) string {
+	code, err := assignRoleMember(info, user, info.Config.Basic.SilenceRole)
+	if code < 0 || err != nil {
+		return "Error occurred trying to silence them: " + info.ResolveRoleAddError(err).Error()
+	} else if code == 1 {
+		return "They were already silenced."
+	}
+	if len(info.Config.Users.SilenceMessage) > 0 {
+		info.SendMessage(info.Config.Users.WelcomeChannel, user.Display()+info.Config.Users.SilenceMessage)
+	}
+	duration := ""
+	if info.Config.Warnings.SilenceDuration > 0 {
+		d := time.Duration(info.Config.Warnings.SilenceDuration) * time.Second
+		if err := info.Bot.DB.AddSchedule(bot.SBatoi(info.ID), now.Add(d), 8, user.String()); err != nil {
+			info.LogError("Failed to schedule unsilence after a warning: ", err)
+		}
+		duration = bot.TimeDiff(d)
+	}
+	id := info.AddCase(bot.CaseSilence, user.String(), info.Bot.SelfID, reason, duration)
+	info.Publish(&bot.MemberSilenced{User: user, Moderator: info.Bot.SelfID, Reason: reason, Case: id})
+	if len(duration) > 0 {
+		return "They have been silenced for " + duration + bot.CaseSuffix(id) + "."
+	}
+	return "They have been silenced" + bot.CaseSuffix(id) + "."
+}
+
+// warnBan bans someone who has reached the ban threshold, and schedules their unban if the ban is temporary
+func warnBan(info *bot.GuildInfo, user bot.DiscordUser, reason string, now time.Time) string {
+	if err := info.Bot.DG.GuildBanCreateWithReason(info.ID, user.String(), reason, 0); err != nil {
+		return "Error occurred trying to ban them: " + err.Error()
+	}
+	duration := ""
+	if info.Config.Warnings.BanDuration > 0 {
+		d := time.Duration(info.Config.Warnings.BanDuration) * time.Second
+		if err := info.Bot.DB.AddSchedule(bot.SBatoi(info.ID), now.Add(d), 0, user.String()); err != nil {
+			info.LogError("Failed to schedule unban after a warning: ", err)
+		}
+		duration = bot.TimeDiff(d)
+	}
+	id := info.AddCase(bot.CaseBan, user.String(), info.Bot.SelfID, reason, duration)
+	if len(duration) > 0 {
+		return "They have been banned for " + duration + bot.CaseSuffix(id) + "."
+	}
+	return "They have been banned" + bot.CaseSuffix(id) + "."
+}
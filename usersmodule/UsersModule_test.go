@@ -0,0 +1,66 @@
+package usersmodule
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	bot "../sweetiebot"
+	"github.com/blackhole12/discordgo"
+)
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+// testGuild returns a guild backed by an in-memory database, whose discord session accepts every request without
+// sending it anywhere
+func testGuild(t *testing.T) *bot.GuildInfo {
+	hostfile := []byte(`{"token": "test", "dbdriver": "sqlite3", "dbauth": ":memory:", "mainguildid": "1"}`)
+	sb := bot.NewFromSelfhost("", hostfile, func(guild *bot.GuildInfo) []bot.Module { return []bot.Module{New()} })
+	if sb == nil {
+		t.Fatal("Failed to create bot")
+	}
+	sb.DG.Client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader("{}")), Header: make(http.Header), Request: r}, nil
+	})}
+	info := bot.NewGuildInfo(sb, &discordgo.Guild{ID: "1", Name: "Test Server"})
+	info.Config.FillConfig()
+	info.Config.Basic.SilenceRole = bot.DiscordRole("4")
+	return info
+}
+
+func runCommand(info *bot.GuildInfo, c bot.Command, content string, now time.Time) string {
+	args, indices := bot.ParseArguments(content[1:])
+	msg := &discordgo.Message{ID: "5", ChannelID: "6", Content: content, Author: &discordgo.User{ID: "3"}, Timestamp: discordgo.Timestamp(now.Format(time.RFC3339))}
+	s, _, _ := c.Process(args[1:], msg, indices[1:], info)
+	return s
+}
+
+func TestTimedSilence(t *testing.T) {
+	info := testGuild(t)
+	defer info.Bot.DB.Close()
+	now := time.Now().UTC().Truncate(time.Second)
+
+	s := runCommand(info, &silenceCommand{}, "!silence <@2> 2 hours", now)
+	if !strings.Contains(s, "Silenced") {
+		t.Fatal(s)
+	}
+	date := info.Bot.DB.GetScheduleDate(1, 8, "2")
+	if date == nil {
+		t.Fatal("No unsilence event was scheduled")
+	}
+	if !date.Equal(now.Add(2 * time.Hour)) {
+		t.Errorf("Unsilence was scheduled for %v instead of %v", date, now.Add(2*time.Hour))
+	}
+
+	s = runCommand(info, &silenceCommand{}, "!silence <@7> posting memes", now)
+	if !strings.Contains(s, "because posting memes") {
+		t.Error(s)
+	}
+	if info.Bot.DB.GetScheduleDate(1, 8, "7") != nil {
+		t.Error("A silence without a duration was scheduled to end")
+	}
+}
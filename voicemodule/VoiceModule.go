@@ -0,0 +1,365 @@
+package voicemodule
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	bot "../sweetiebot"
+	"github.com/blackhole12/discordgo"
+)
+
+// An empty temporary channel is only deleted by OnTick after this long, so we don't delete a channel we just created
+// before its owner has been moved into it
+const tempChannelGrace = time.Minute
+
+// Colors used for each kind of log entry
+const (
+	colorJoin  = 0x56d34f
+	colorLeave = 0xe5a03e
+	colorMove  = 0x3e92e5
+)
+
+// VoiceConfig holds the options in the voice category of the config
+type VoiceConfig struct {
+	TempChannels    map[bot.DiscordChannel]bool `json:"tempchannels"`
+	TempChannelName string                      `json:"tempchannelname"`
+	MaxTempChannels int                         `json:"maxtempchannels"`
+}
+
+var voiceSection = &bot.ConfigSection{
+	Name:    "Voice",
+	Version: 1,
+	Default: func() interface{} {
+		return &VoiceConfig{
+			TempChannels:    make(map[bot.DiscordChannel]bool),
+			TempChannelName: "{user}'s channel",
+			MaxTempChannels: 10,
+		}
+	},
+	Help: map[string]string{
+		"tempchannels":    "A list of voice channels that act as lobbies for temporary channels. Anyone joining one of these gets a new voice channel of their own in the same category and is moved into it. The channel is deleted once everyone has left it.",
+		"tempchannelname": "The name given to new temporary voice channels. `{user}` is replaced with the name of the member who created it. Default: `{user}'s channel`",
+		"maxtempchannels": "The maximum number of temporary voice channels that can exist at once. Once this many exist, joining a lobby does nothing. Default: 10",
+	},
+	Migrate: func(info *bot.GuildInfo, section interface{}, from int) {
+		if from < 1 {
+			if info.Config.Modules.Disabled == nil {
+				info.Config.Modules.Disabled = make(map[bot.ModuleID]bool)
+			}
+			info.Config.Modules.Disabled["voice"] = true // Existing log channels shouldn't suddenly start getting voice activity
+		}
+	},
+}
+
+func voiceConfig(info *bot.GuildInfo) *VoiceConfig {
+	return info.Config.Section("voice").(*VoiceConfig)
+}
+
+type voiceSession struct {
+	channel string
+	since   time.Time     // When they joined the channel they are in now
+	counted time.Duration // Time spent in earlier channels during this session, not counting the AFK channel
+}
+
+type tempChannel struct {
+	owner   string
+	created time.Time
+}
+
+// VoiceModule logs people joining, leaving and moving between voice channels, keeps track of how long each member
+// spends in voice, and creates temporary voice channels for anyone who joins one of the voice.tempchannels.
+type VoiceModule struct {
+	lock     sync.Mutex
+	sessions map[string]*voiceSession // Everyone currently in a voice channel, by user ID
+	temp     map[string]*tempChannel  // Temporary channels by channel ID
+	loaded   bool                     // True once the temporary channels left over from before a restart were loaded
+}
+
+// New VoiceModule
+func New(guild *bot.GuildInfo) *VoiceModule {
+	w := &VoiceModule{
+		sessions: make(map[string]*voiceSession),
+		temp:     make(map[string]*tempChannel),
+	}
+	if g, err := guild.GetGuild(); err == nil {
+		now := time.Now().UTC()
+		guild.Bot.DG.State.RLock()
+		for _, v := range g.VoiceStates {
+			if len(v.ChannelID) > 0 {
+				w.sessions[v.UserID] = &voiceSession{channel: v.ChannelID, since: now}
+			}
+		}
+		guild.Bot.DG.State.RUnlock()
+	}
+	return w
+}
+
+// Name of the module
+func (w *VoiceModule) Name() string {
+	return "Voice"
+}
+
+// Commands in the module
+func (w *VoiceModule) Commands() []bot.Command {
+	return []bot.Command{
+		&voiceTimeCommand{},
+	}
+}
+
+// Config section of the module
+func (w *VoiceModule) Config() *bot.ConfigSection {
+	return voiceSection
+}
+
+// Description of the module
+func (w *VoiceModule) Description() string {
+	return "Posts members joining, leaving and moving between voice channels to `log.eventchannel`, or `log.channel` if that isn't set, and keeps track of how much time everyone spends in voice. Time spent in the AFK channel doesn't count. If `voice.tempchannels` is set, anyone joining one of those channels gets their own temporary voice channel, which is deleted once everyone leaves it."
+}
+
+func channelName(info *bot.GuildInfo, channelID string) string {
+	if ch, err := info.Bot.DG.State.Channel(channelID); err == nil {
+		return ch.Name
+	}
+	return channelID
+}
+
+func (w *VoiceModule) log(info *bot.GuildInfo, userID string, color int, description string) {
+	ch := info.EventChannel()
+	if ch == bot.ChannelEmpty {
+		return
+	}
+	embed := &discordgo.MessageEmbed{
+		Type:        "rich",
+		Color:       color,
+		Description: description,
+		Footer:      &discordgo.MessageEmbedFooter{Text: "User ID: " + userID},
+		Timestamp:   time.Now().UTC().Format(time.RFC3339),
+	}
+	if err := info.SendEmbed(ch, embed); err != nil {
+		info.LogError("Failed to send voice log entry: ", err)
+	}
+}
+
+// occupied returns true if anyone is in the channel. The caller must hold the lock.
+func (w *VoiceModule) occupied(channelID string) bool {
+	for _, s := range w.sessions {
+		if s.channel == channelID {
+			return true
+		}
+	}
+	return false
+}
+
+// OnVoiceStateUpdate discord hook
+func (w *VoiceModule) OnVoiceStateUpdate(info *bot.GuildInfo, v *discordgo.VoiceState) {
+	if info.Bot.SelfID.Equals(v.UserID) {
+		return
+	}
+	now := time.Now().UTC()
+	afk := ""
+	if g, err := info.GetGuild(); err == nil {
+		afk = g.AfkChannelID
+	}
+
+	w.lock.Lock()
+	s, ok := w.sessions[v.UserID]
+	if ok && s.channel == v.ChannelID {
+		w.lock.Unlock()
+		return // They only muted or deafened themselves
+	}
+	var ended *voiceSession
+	before := ""
+	if ok {
+		before = s.channel
+		if s.channel != afk {
+			s.counted += now.Sub(s.since)
+		}
+		if len(v.ChannelID) == 0 {
+			ended = s
+			delete(w.sessions, v.UserID)
+		} else {
+			s.channel, s.since = v.ChannelID, now
+		}
+	} else if len(v.ChannelID) > 0 {
+		w.sessions[v.UserID] = &voiceSession{channel: v.ChannelID, since: now}
+	}
+	abandoned := ""
+	if _, temp := w.temp[before]; temp && !w.occupied(before) {
+		abandoned = before
+		delete(w.temp, before)
+	}
+	w.lock.Unlock()
+
+	// Record the time before logging, so anyone watching the log never sees a stale total
+	if ended != nil && ended.counted > 0 && info.Bot.DB.CheckStatus() {
+		info.Bot.DB.AddVoiceTime(bot.SBatoi(info.ID), bot.SBatoi(v.UserID), uint64(ended.counted/time.Second))
+	}
+
+	switch {
+	case len(before) == 0:
+		w.log(info, v.UserID, colorJoin, fmt.Sprintf("<@%s> joined voice channel **%s**", v.UserID, channelName(info, v.ChannelID)))
+	case len(v.ChannelID) == 0:
+		w.log(info, v.UserID, colorLeave, fmt.Sprintf("<@%s> left voice channel **%s**", v.UserID, channelName(info, before)))
+	default:
+		w.log(info, v.UserID, colorMove, fmt.Sprintf("<@%s> moved from voice channel **%s** to **%s**", v.UserID, channelName(info, before), channelName(info, v.ChannelID)))
+	}
+
+	if len(abandoned) > 0 {
+		w.deleteTemp(info, abandoned)
+	}
+	if _, lobby := voiceConfig(info).TempChannels[bot.DiscordChannel(v.ChannelID)]; lobby {
+		w.createTemp(info, v.UserID, v.ChannelID)
+	}
+}
+
+func (w *VoiceModule) deleteTemp(info *bot.GuildInfo, channelID string) {
+	if _, err := info.Bot.DG.ChannelDelete(channelID); err != nil {
+		info.LogError("Failed to delete temporary voice channel: ", err)
+	}
+	if info.Bot.DB.CheckStatus() {
+		info.Bot.DB.RemoveTempChannel(bot.SBatoi(channelID))
+	}
+}
+
+// createTemp makes a new voice channel next to the one the user joined and moves them into it. Each member only gets
+// one temporary channel, so if they already have one, they are moved back into it instead.
+func (w *VoiceModule) createTemp(info *bot.GuildInfo, userID string, lobby string) {
+	w.lock.Lock()
+	existing := ""
+	for id, t := range w.temp {
+		if t.owner == userID {
+			existing = id
+		}
+	}
+	full := len(w.temp) >= voiceConfig(info).MaxTempChannels
+	w.lock.Unlock()
+	if len(existing) > 0 {
+		info.Bot.DG.GuildMemberMove(info.ID, userID, existing)
+		return
+	}
+	if full {
+		return
+	}
+
+	name := voiceConfig(info).TempChannelName
+	if len(name) == 0 {
+		name = "{user}'s channel"
+	}
+	name = strings.Replace(name, "{user}", info.GetUserName(bot.DiscordUser(userID)), -1)
+	if r := []rune(name); len(r) > 100 {
+		name = string(r[:100])
+	}
+	parent := ""
+	if ch, err := info.Bot.DG.State.Channel(lobby); err == nil {
+		parent = ch.ParentID
+	}
+
+	// discordgo can't create a channel inside a category, so we make the request ourselves
+	endpoint := discordgo.EndpointGuildChannels(info.ID)
+	body := map[string]interface{}{"name": name, "type": discordgo.ChannelTypeGuildVoice}
+	if len(parent) > 0 {
+		body["parent_id"] = parent
+	}
+	response, err := info.Bot.DG.RequestWithBucketID("POST", endpoint, body, endpoint)
+	ch := &discordgo.Channel{}
+	if err == nil {
+		err = json.Unmarshal(response, ch)
+	}
+	if err != nil {
+		info.LogError("Failed to create temporary voice channel: ", err)
+		return
+	}
+
+	w.lock.Lock()
+	w.temp[ch.ID] = &tempChannel{owner: userID, created: time.Now().UTC()}
+	w.lock.Unlock()
+	if info.Bot.DB.CheckStatus() {
+		info.Bot.DB.AddTempChannel(bot.SBatoi(info.ID), bot.SBatoi(ch.ID), bot.SBatoi(userID))
+	}
+	if err = info.Bot.DG.GuildMemberMove(info.ID, userID, ch.ID); err != nil {
+		info.LogError("Failed to move member into their temporary voice channel: ", err)
+	}
+}
+
+// OnTick discord hook. Deletes temporary channels that were left empty while the bot wasn't watching, like when
+// their owner never made it into the channel or the bot was restarted.
+func (w *VoiceModule) OnTick(info *bot.GuildInfo, t time.Time) {
+	if !w.loaded && info.Bot.DB.Status.Get() {
+		channels := info.Bot.DB.GetTempChannels(bot.SBatoi(info.ID))
+		w.lock.Lock()
+		for _, c := range channels {
+			w.temp[bot.SBitoa(c.Channel)] = &tempChannel{owner: bot.SBitoa(c.Owner), created: c.Created}
+		}
+		w.loaded = true
+		w.lock.Unlock()
+	}
+
+	abandoned := []string{}
+	w.lock.Lock()
+	for id, c := range w.temp {
+		if t.Sub(c.created) > tempChannelGrace && !w.occupied(id) {
+			abandoned = append(abandoned, id)
+			delete(w.temp, id)
+		}
+	}
+	w.lock.Unlock()
+	for _, id := range abandoned {
+		w.deleteTemp(info, id)
+	}
+}
+
+func sessions(n uint64) string {
+	if n == 1 {
+		return "1 session"
+	}
+	return fmt.Sprintf("%v sessions", n)
+}
+
+type voiceTimeCommand struct {
+}
+
+func (c *voiceTimeCommand) Info() *bot.CommandInfo {
+	return &bot.CommandInfo{
+		Name:  "VoiceTime",
+		Usage: "Shows how long someone has spent in voice channels.",
+	}
+}
+
+func (c *voiceTimeCommand) Process(args []string, msg *discordgo.Message, indices []int, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+	return info.ProcessTyped(c, args, msg, indices)
+}
+func (c *voiceTimeCommand) ProcessArgs(args bot.CommandArgs, msg *discordgo.Message, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+	if !info.Bot.DB.Status.Get() {
+		return "```\nA temporary database outage is preventing this command from being executed.```", false, nil
+	}
+	now := time.Now().UTC()
+	if args.Has("user") {
+		user := args.User("user")
+		v := info.Bot.DB.GetVoiceTime(bot.SBatoi(info.ID), user.Convert())
+		if v == nil {
+			return "```\n" + info.GetUserName(user) + " has never been in a voice channel.```", false, nil
+		}
+		return fmt.Sprintf("```\n%s has spent %s in voice channels over %s, and was last in one %s ago.```", info.GetUserName(user), bot.TimeDiff(time.Duration(v.Seconds)*time.Second), sessions(v.Sessions), bot.TimeDiff(now.Sub(v.LastSeen))), false, nil
+	}
+
+	top := info.Bot.DB.GetTopVoiceTime(bot.SBatoi(info.ID), 10)
+	if len(top) == 0 {
+		return "```\nNobody has spent any time in voice channels yet.```", false, nil
+	}
+	s := []string{"Most time spent in voice channels:"}
+	for i, v := range top {
+		s = append(s, fmt.Sprintf("%v. %s: %s (%s)", i+1, info.GetUserName(bot.DiscordUser(bot.SBitoa(v.User))), bot.TimeDiff(time.Duration(v.Seconds)*time.Second), sessions(v.Sessions)))
+	}
+	return "```\n" + strings.Join(s, "\n") + "```", false, nil
+}
+func (c *voiceTimeCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
+	return &bot.CommandUsage{
+		Desc: "Shows how much time a member has spent in voice channels, how many times they've joined one, and when they were last in one. Time is only recorded once they leave voice, and time spent in the AFK channel doesn't count. Without a user, lists the 10 members who have spent the most time in voice.",
+		Params: []bot.CommandUsageParam{
+			{Name: "user", Desc: "The member to look up.", Optional: true, Variadic: true, Type: bot.ParamUser},
+		},
+	}
+}
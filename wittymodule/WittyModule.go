@@ -70,7 +70,7 @@ func (w *WittyModule) UpdateRegex(info *bot.GuildInfo) bool {
 	}
 
 	if len(w.triggerregex) != len(w.remarks) { // This should never happen but we check just in case
-		info.Log("ERROR! triggers do not equal remarks!!")
+		info.Logger().Error("Triggers do not equal remarks!")
 		return false
 	}
 	return err == nil
@@ -129,7 +129,7 @@ func (c *addWitCommand) Process(args []string, msg *discordgo.Message, indices [
 
 	bot.CheckMapNilString(&info.Config.Witty.Responses)
 	info.Config.Witty.Responses[trigger] = remark
-	info.SaveConfig()
+	info.SaveConfigBy(bot.DiscordUser(msg.Author.ID))
 	if !c.wit.UpdateRegex(info) {
 		witRemove(trigger, info)
 		c.wit.UpdateRegex(info)
@@ -168,7 +168,7 @@ func (c *removeWitCommand) Process(args []string, msg *discordgo.Message, indice
 	if !witRemove(arg, info) {
 		return "```\nCould not find " + arg + "!```", false, nil
 	}
-	info.SaveConfig()
+	info.SaveConfigBy(bot.DiscordUser(msg.Author.ID))
 	c.wit.UpdateRegex(info)
 	return "```\nRemoved " + arg + " and recompiled the wittyremarks regex.```", false, nil
 }